@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/acars"
+	"github.com/burnettdev/adsb2otel/pkg/ais"
+	"github.com/burnettdev/adsb2otel/pkg/config"
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/flightdata"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+	"github.com/burnettdev/adsb2otel/pkg/sinks"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// runConfigCommand handles "config export" and "config import" and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: adsb2otel config <export|import> [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("config export", flag.ContinueOnError)
+		output := fs.String("o", "-", "file to write the bundle to, - for stdout")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		var w io.Writer = os.Stdout
+		if *output != "-" {
+			f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *output, err)
+				return 1
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := config.Export(w); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export configuration: %v\n", err)
+			return 1
+		}
+		return 0
+
+	case "import":
+		fs := flag.NewFlagSet("config import", flag.ContinueOnError)
+		envPath := fs.String("env", ".env", "env file to merge the configuration into")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: adsb2otel config import [-env .env] <bundle.json|->")
+			return 2
+		}
+
+		var r io.Reader = os.Stdin
+		if path := fs.Arg(0); path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", path, err)
+				return 1
+			}
+			defer f.Close()
+			r = f
+		}
+
+		missing, err := config.Import(r, *envPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to import configuration: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Configuration written to %s\n", *envPath)
+		for _, key := range missing {
+			fmt.Printf("  %s was redacted in the bundle, set it before starting\n", key)
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command %q\n", args[0])
+		return 2
+	}
+}
+
+// usage prints the available commands
+func usage(w io.Writer) {
+	fmt.Fprint(w, `usage: adsb2otel [command] [flags]
+
+commands:
+  run [--once] [--dry-run]   start the service (default when no command is given)
+  check-config               validate the configuration and exit
+  doctor [-timeout 5s]       validate the configuration and check the receiver,
+                             the OTLP endpoint and DNS
+  probe [url]                fetch a flight data URL once and report what it serves
+                             (defaults to FLIGHT_DATA_URL)
+  simulate-rules --input f   replay recorded aircraft.json snapshots through the
+                             emergency and rapid descent alerts, route conditions,
+                             sink filters and mutes and report what fires (receiver
+                             health alerts are not simulated)
+  replay [-speed 1] path...  replay recorded aircraft.json captures or tar1090
+                             history chunks through the pipeline and export them
+  version                    print version information
+  config export|import       export or import the configuration
+  help                       show this help
+`)
+}
+
+// runRunCommand parses the run flags and starts the service, or a dry run
+func runRunCommand(envErr error, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	once := fs.Bool("once", false, "run a single fetch cycle, export it and exit")
+	dryRun := fs.Bool("dry-run", false, "fetch once and print what would be emitted per aircraft without exporting")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *dryRun {
+		return runDryRun(envErr)
+	}
+	if code, ok := runService(envErr); ok {
+		return code
+	}
+	return run(context.Background(), envErr, *once)
+}
+
+// applyLowResource applies the LOW_RESOURCE settings and logs what it changed
+func applyLowResource() {
+	if !config.LowResource() {
+		return
+	}
+	cleared := config.ApplyLowResource()
+	logging.Info("Low resource mode enabled", "export_mode", os.Getenv("EXPORT_MODE"), "memory_limit_mib", os.Getenv("EXPORT_MEMORY_LIMIT_MIB"))
+	for _, key := range cleared {
+		logging.Warn("Enrichment disabled in low resource mode", "key", key)
+	}
+}
+
+// runCheckConfigCommand validates the configuration without starting the service
+func runCheckConfigCommand() int {
+	config.ApplyLowResource()
+	problems := checkConfig()
+	if len(problems) == 0 {
+		fmt.Println("Configuration is valid")
+		return 0
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "config: %s\n", problem)
+	}
+	printRequired(os.Stderr, missingConfig())
+	return 2
+}
+
+// runDoctorCommand validates the configuration and runs the startup checks,
+// printing the outcome of each, to find out why a feeder isn't exporting
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 5*time.Second, "time allowed for the checks")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config.ApplyLowResource()
+	report := runChecks(context.Background(), *timeout)
+
+	names := make([]string, 0, len(report.checks))
+	for name := range report.checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Printf("%-45s %s\n", name+":", report.checks[name])
+	}
+	for _, problem := range report.problems {
+		fmt.Fprintf(os.Stderr, "problem: %s\n", problem)
+	}
+
+	missing := missingConfig()
+	printRequired(os.Stderr, missing)
+	switch {
+	case len(missing) > 0:
+		return 2
+	case len(report.problems) > 0:
+		return 1
+	}
+	fmt.Println("No problems found")
+	return 0
+}
+
+// requiredConfig lists the variables the service can't run without, with
+// what they hold and an example
+var requiredConfig = []struct {
+	key         string
+	description string
+	example     string
+}{
+	{"FLIGHT_DATA_URL", "URL of the aircraft.json served by dump1090-fa, readsb or tar1090", "http://192.168.1.10:8080/data/aircraft.json"},
+}
+
+// missingConfig returns the keys of the required variables that are not set
+func missingConfig() []string {
+	var missing []string
+	for _, required := range requiredConfig {
+		if strings.TrimSpace(os.Getenv(required.key)) == "" {
+			missing = append(missing, required.key)
+		}
+	}
+	return missing
+}
+
+// printRequired explains how to set the required variables that are missing
+func printRequired(w io.Writer, missing []string) {
+	if len(missing) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nRequired configuration is missing. Set it in the environment, or in a .env file in the working directory (see .env.example):")
+	for _, required := range requiredConfig {
+		if slices.Contains(missing, required.key) {
+			fmt.Fprintf(w, "  %s: %s, e.g.\n    %s=%s\n", required.key, required.description, required.key, required.example)
+		}
+	}
+}
+
+// checkConfig returns problems with the configuration that would prevent the
+// service from fetching or exporting
+func checkConfig() []string {
+	var problems []string
+	for _, err := range flightdata.CheckConfig() {
+		problems = append(problems, err.Error())
+	}
+	for _, err := range enrich.CheckConfig() {
+		problems = append(problems, err.Error())
+	}
+	for _, err := range acars.CheckConfig() {
+		problems = append(problems, err.Error())
+	}
+	for _, err := range ais.CheckConfig() {
+		problems = append(problems, err.Error())
+	}
+
+	for _, name := range strings.Split(os.Getenv("SINKS"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" && !slices.Contains(sinks.Names(), name) {
+			problems = append(problems, fmt.Sprintf("SINKS: unknown sink %q, expected one of %s", name, strings.Join(sinks.Names(), ", ")))
+		}
+	}
+	if _, err := headers.Parse(os.Getenv("LOKI_HEADERS")); err != nil {
+		problems = append(problems, fmt.Sprintf("LOKI_HEADERS: %v", err))
+	}
+	if value := strings.ToLower(os.Getenv("LOKI_TIMESTAMP")); value != "" && value != "source" && value != "ingestion" {
+		problems = append(problems, fmt.Sprintf("LOKI_TIMESTAMP must be source or ingestion, got %q", value))
+	}
+	if value := strings.ToLower(os.Getenv("LOKI_COMPRESSION")); value != "" && value != "gzip" && value != "none" {
+		problems = append(problems, fmt.Sprintf("LOKI_COMPRESSION must be gzip or none, got %q", value))
+	}
+	if value := strings.ToLower(os.Getenv("LOKI_REJECT_POLICY")); value != "" && value != "drop" && value != "requeue" {
+		problems = append(problems, fmt.Sprintf("LOKI_REJECT_POLICY must be drop or requeue, got %q", value))
+	}
+
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"} {
+		if value := strings.ToLower(os.Getenv(key)); value != "" && value != "http" && value != "grpc" {
+			problems = append(problems, fmt.Sprintf("%s must be http or grpc, got %q", key, value))
+		}
+	}
+	for _, signal := range []string{"LOGS", "TRACES", "METRICS"} {
+		// Shared headers are checked for every signal, so report their problems once
+		if _, err := headers.FromEnv(signal); err != nil && !slices.Contains(problems, err.Error()) {
+			problems = append(problems, err.Error())
+		}
+	}
+	problems = append(problems, checkRules()...)
+	if value := strings.ToLower(os.Getenv("OTEL_LOGS_EXPORTER")); value != "" && value != "otlp" && value != "console" && value != "file" {
+		problems = append(problems, fmt.Sprintf("OTEL_LOGS_EXPORTER must be otlp, console or file, got %q", value))
+	}
+	if value := strings.ToLower(os.Getenv("LOG_LEVEL")); value != "" && !slices.Contains([]string{"debug", "info", "warn", "warning", "error"}, value) {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error, got %q", value))
+	}
+	return problems
+}
+
+// runProbeCommand fetches a flight data URL once and reports what it serves
+func runProbeCommand(args []string) int {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for the fetch")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	target := fs.Arg(0)
+	if target == "" {
+		target = os.Getenv("FLIGHT_DATA_URL")
+	}
+	if target == "" {
+		fmt.Fprintln(os.Stderr, "usage: adsb2otel probe [-timeout 30s] <url>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	result, err := flightdata.Probe(ctx, target)
+	fmt.Printf("URL:           %s\n", result.URL)
+	if result.Status != "" {
+		fmt.Printf("Status:        %s\n", result.Status)
+		fmt.Printf("Content-Type:  %s\n", result.ContentType)
+		fmt.Printf("Latency:       %s\n", result.Latency.Round(time.Millisecond))
+		fmt.Printf("Size:          %d bytes\n", result.Bytes)
+	}
+	if len(result.Keys) > 0 {
+		fmt.Printf("Keys:          %s\n", strings.Join(result.Keys, ", "))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "probe failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Schema:        %s\n", result.Schema)
+	fmt.Printf("Aircraft:      %d (%d with position)\n", result.Aircraft, result.WithPosition)
+	if result.Messages > 0 {
+		fmt.Printf("Messages:      %d\n", result.Messages)
+	}
+	if !result.Timestamp.IsZero() {
+		fmt.Printf("Timestamp:     %s (%s behind)\n", result.Timestamp.UTC().Format(time.RFC3339), time.Since(result.Timestamp).Round(time.Second))
+	}
+	return 0
+}
+
+// runVersionCommand prints version information
+func runVersionCommand() int {
+	fmt.Printf("adsb2otel %s (%s, %s/%s)\n", version.Get(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if revision := version.Revision(); revision != "" {
+		fmt.Printf("commit %s\n", revision)
+	}
+	if date := version.Date(); date != "" {
+		fmt.Printf("built %s\n", date)
+	}
+	return 0
+}
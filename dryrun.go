@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/burnettdev/adsb2otel/pkg/blackbox"
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+)
+
+// Exit codes of the dry run
+const (
+	exitOK          = 0
+	exitNoAircraft  = 1
+	exitConfigError = 2
+)
+
+// runDryRun fetches once and prints the log record that would be emitted for
+// each aircraft, without exporting anything or writing to sinks
+// It exits non-zero when the configuration is invalid, the fetch fails or no
+// aircraft are reported, for install-time verification and smoke tests
+func runDryRun(envErr error) int {
+	logging.Init()
+	if envErr != nil {
+		logging.Debug("Environment file not found (this is normal in production)", "error", envErr)
+	}
+	applyLowResource()
+
+	if problems := checkConfig(); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "config: %s\n", problem)
+		}
+		return exitConfigError
+	}
+
+	shutdownLogs, err := logs.InitDryRun(os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up dry run: %v\n", err)
+		return exitConfigError
+	}
+	defer shutdownLogs()
+
+	shutdownRoutes, err := enrich.InitRoutes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up dry run: %v\n", err)
+		return exitConfigError
+	}
+	defer shutdownRoutes()
+
+	err = fetchAndPush(context.Background())
+	cycle, _ := blackbox.LastCycle()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dry run failed after %s: %v\n", cycle.Duration, err)
+		return exitNoAircraft
+	}
+
+	fmt.Fprintf(os.Stderr, "dry run: %d aircraft, %d log records would be emitted (fetch took %s)\n", cycle.Aircraft, cycle.LogsEmitted, cycle.Duration)
+	if cycle.Aircraft == 0 {
+		fmt.Fprintln(os.Stderr, "dry run: no aircraft reported, check FLIGHT_DATA_URL and the receiver")
+		return exitNoAircraft
+	}
+	return exitOK
+}
@@ -2,15 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/burnettdev/adsb2otel/pkg/acars"
+	"github.com/burnettdev/adsb2otel/pkg/ais"
+	"github.com/burnettdev/adsb2otel/pkg/api"
+	"github.com/burnettdev/adsb2otel/pkg/crash"
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
 	"github.com/burnettdev/adsb2otel/pkg/flightdata"
+	"github.com/burnettdev/adsb2otel/pkg/health"
+	"github.com/burnettdev/adsb2otel/pkg/instance"
 	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/collector"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
 	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+	"github.com/burnettdev/adsb2otel/pkg/sinks"
 	"github.com/burnettdev/adsb2otel/pkg/tracing"
+	"github.com/burnettdev/adsb2otel/pkg/version"
 	"github.com/joho/godotenv"
 )
 
@@ -18,6 +34,42 @@ func main() {
 	// Load .env file before initializing logger so LOG_LEVEL is available
 	envErr := godotenv.Load()
 
+	// Without a command the service runs, so existing deployments keep working
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "run":
+		os.Exit(runRunCommand(envErr, args))
+	case "check-config":
+		os.Exit(runCheckConfigCommand())
+	case "doctor":
+		os.Exit(runDoctorCommand(args))
+	case "probe":
+		os.Exit(runProbeCommand(args))
+	case "simulate-rules":
+		os.Exit(runSimulateRulesCommand(args))
+	case "replay":
+		os.Exit(runReplayCommand(envErr, args))
+	case "version":
+		os.Exit(runVersionCommand())
+	case "config":
+		os.Exit(runConfigCommand(args))
+	case "help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+}
+
+// run starts the service and returns the exit code once it stops, on a
+// shutdown signal or when parent is cancelled
+// With once set, a single fetch cycle is run and exported
+func run(parent context.Context, envErr error, once bool) int {
 	logging.Init()
 	logger := logging.Get()
 
@@ -29,9 +81,53 @@ func main() {
 		logger.Debug("Environment file loaded successfully")
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// Fill in the low resource settings before anything reads the configuration
+	applyLowResource()
+
+	// Without a receiver there is nothing to do, so don't start polling an empty URL
+	if missing := missingConfig(); len(missing) > 0 {
+		for _, required := range requiredConfig {
+			if slices.Contains(missing, required.key) {
+				logger.Error("Required configuration is missing, not starting", "key", required.key, "description", required.description, "example", required.key+"="+required.example)
+			}
+		}
+		logger.Error("Set the missing variables in the environment or a .env file, see .env.example or run adsb2otel doctor")
+		return exitConfigError
+	}
+
+	// Exporting with broken headers would send no credentials, so don't start
+	for _, signal := range []string{"LOGS", "TRACES", "METRICS"} {
+		if _, err := headers.FromEnv(signal); err != nil {
+			logger.Error("Invalid OTLP headers, not starting", "error", err)
+			return exitConfigError
+		}
+	}
+
+	// Refuse to start when another copy already exports this receiver
+	releaseLock, err := instance.Acquire()
+	if err != nil {
+		logger.Error("Failed to acquire instance lock", "error", err)
+		return 1
+	}
+	defer releaseLock()
+
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
+	// Start the health server so liveness is reported while the rest initializes
+	shutdownHealth, err := health.InitHealth()
+	if err != nil {
+		logger.Error("Failed to start health server", "error", err)
+	}
+	defer shutdownHealth()
+
+	// Attribute OpenTelemetry export failures, polling the collector's health if configured
+	shutdownCollector, err := collector.Init()
+	if err != nil {
+		logger.Error("Failed to start collector health checks", "error", err)
+	}
+	defer shutdownCollector()
+
 	// Initialize OpenTelemetry tracing
 	shutdownTracing, err := tracing.InitTracing()
 	if err != nil {
@@ -48,37 +144,174 @@ func main() {
 	}
 	defer shutdownLogs()
 
-	ticker := time.NewTicker(5 * time.Second)
+	// Initialize OpenTelemetry metrics
+	shutdownMetrics, err := metrics.InitMetrics()
+	if err != nil {
+		logger.Error("Failed to initialize OpenTelemetry metrics", "error", err)
+		// Continue without metrics rather than failing
+	}
+	defer shutdownMetrics()
+
+	// Initialize additional sinks (ClickHouse, InfluxDB, ...)
+	shutdownSinks, err := sinks.InitSinks()
+	if err != nil {
+		logger.Error("Failed to initialize sinks", "error", err)
+		// Continue with the sinks that could be initialized
+	}
+	defer shutdownSinks()
+
+	// Load and resolve flight routes, if configured
+	shutdownRoutes, err := enrich.InitRoutes()
+	if err != nil {
+		logger.Error("Failed to initialize route lookups", "error", err)
+	}
+	defer shutdownRoutes()
+
+	// Fetch weather at nearby airports, if configured
+	shutdownWeather, err := enrich.InitWeather()
+	if err != nil {
+		logger.Error("Failed to initialize METAR fetching", "error", err)
+	}
+	defer shutdownWeather()
+
+	// Receive ACARS messages from acarsdec or dumpvdl2, if configured
+	shutdownACARS, err := acars.InitACARS()
+	if err != nil {
+		logger.Error("Failed to start ACARS ingestion", "error", err)
+	}
+	defer shutdownACARS()
+
+	// Receive AIS vessel reports, if configured
+	shutdownAIS, err := ais.InitAIS()
+	if err != nil {
+		logger.Error("Failed to start AIS ingestion", "error", err)
+	}
+	defer shutdownAIS()
+
+	// Merge gliders and other FLARM traffic from the OGN, if configured
+	shutdownOGN, err := flightdata.InitOGN()
+	if err != nil {
+		logger.Error("Failed to start the OGN source", "error", err)
+	}
+	defer shutdownOGN()
+
+	// Decode aircraft from a raw Mode S output, if configured
+	shutdownRaw, err := flightdata.InitRaw()
+	if err != nil {
+		logger.Error("Failed to start the raw Mode S input", "error", err)
+	}
+	defer shutdownRaw()
+
+	// Scrape the receiver's statistics, if configured
+	shutdownStats, err := flightdata.InitStats()
+	if err != nil {
+		logger.Error("Failed to initialize receiver stats", "error", err)
+	}
+	defer shutdownStats()
+
+	// Compare the receiver's coverage with an aggregator's, if configured
+	shutdownCoverage, err := flightdata.InitCoverage()
+	if err != nil {
+		logger.Error("Failed to start the coverage comparison", "error", err)
+	}
+	defer shutdownCoverage()
+
+	// Archive the raw aircraft.json of each poll, if configured
+	shutdownRecorder, err := flightdata.InitRecorder()
+	if err != nil {
+		logger.Error("Failed to start recording flight data", "error", err)
+	}
+	defer shutdownRecorder()
+
+	// Start the API for live consumers (replay and streaming)
+	shutdownAPI, err := api.InitAPI()
+	if err != nil {
+		logger.Error("Failed to start API server", "error", err)
+	}
+	defer shutdownAPI()
+
+	emitStartup(ctx)
+
+	if once {
+		// The deferred shutdowns flush the exporters before exiting
+		if err := fetchAndPush(ctx); err != nil {
+			logging.ErrorCtx(ctx, "Fetch cycle failed", "error", err)
+			return 1
+		}
+		logger.Info("Single fetch cycle completed", "version", version.Get())
+		return 0
+	}
+
+	ticker := time.NewTicker(flightdata.PollInterval)
 	defer ticker.Stop()
+	tick := ticker.C
 
-	logger.Info("Starting data fetch loop", "interval", "5s")
+	// Read a local aircraft.json when it is rewritten rather than on the ticker
+	changes, err := flightdata.Watch(ctx)
+	if err != nil {
+		logger.Warn("Failed to watch the flight data file, polling it instead", "error", err)
+	}
+	if changes != nil {
+		tick = nil
+		logger.Info("Watching the flight data file for changes", "url", os.Getenv("FLIGHT_DATA_URL"))
+	}
+
+	logger.Info("Starting data fetch loop", "interval", flightdata.PollInterval.String())
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// On Windows, SIGTERM is delivered when the console is closed or the user logs off
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	logger.Info("Application started successfully")
+	logger.Info("Application started successfully", "version", version.Get())
+	var overrun flightdata.Overrun
+	cycle := func() {
+		defer overrun.Observe(ctx, time.Now())
+		if err := fetchAndPush(ctx); errors.Is(err, flightdata.ErrBackingOff) {
+			logging.DebugCtx(ctx, "Skipping fetch while the flight data source is down")
+		} else if err != nil {
+			logging.ErrorCtx(ctx, "Error fetching and pushing data", "error", err)
+		} else {
+			logging.DebugCtx(ctx, "Data fetch and push completed successfully")
+		}
+	}
 	for {
 		select {
-		case <-ticker.C:
+		case t := <-tick:
+			if overrun.Stale(t) {
+				logging.DebugCtx(ctx, "Skipping a tick that fired during the previous cycle")
+				continue
+			}
 			logging.DebugCtx(ctx, "Ticker fired - fetching data")
+			cycle()
 
-			if err := flightdata.FetchAndPushLogs(ctx); err != nil {
-				logging.ErrorCtx(ctx, "Error fetching and pushing data", "error", err)
-			} else {
-				logging.DebugCtx(ctx, "Data fetch and push completed successfully")
+		case _, ok := <-changes:
+			if !ok {
+				if ctx.Err() == nil {
+					logger.Warn("Stopped watching the flight data file, polling it instead")
+				}
+				changes, tick = nil, ticker.C
+				continue
 			}
+			logging.DebugCtx(ctx, "Flight data file changed - fetching data")
+			cycle()
 
 		case sig := <-sigChan:
 			logger.Info("Received shutdown signal", "signal", sig)
 			logger.Debug("Graceful shutdown initiated")
-			return
+			return 0
 		case <-ctx.Done():
 			logger.Debug("Context cancelled")
-			return
+			return 0
 		}
 	}
 }
 
+// fetchAndPush runs a single fetch cycle, reporting a crash if it panics
+func fetchAndPush(ctx context.Context) error {
+	defer crash.Recover("fetch_loop")
+	return flightdata.FetchAndPushLogs(ctx)
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	logging.DebugCall("getEnvOrDefault", "key", key, "default", defaultValue)
 
@@ -0,0 +1,343 @@
+// Package acars ingests ACARS and VDL2 messages decoded by acarsdec and
+// dumpvdl2 and exports them as OpenTelemetry log records
+package acars
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+// messageEvent is the event name of the record emitted for each message
+const messageEvent = "acars.message"
+
+// Decoders a message can come from
+const (
+	decoderAcarsdec = "acarsdec"
+	decoderDumpvdl2 = "dumpvdl2"
+)
+
+// maxDatagram is the largest datagram read; decoders send a JSON document
+// per datagram, well below this
+const maxDatagram = 64 * 1024
+
+var (
+	meter = metrics.Meter("acars")
+
+	messageCounter, _ = meter.Int64Counter("adsb2otel.acars.messages",
+		metric.WithDescription("ACARS messages received, by decoder"),
+		metric.WithUnit("{message}"),
+	)
+	invalidCounter, _ = meter.Int64Counter("adsb2otel.acars.invalid",
+		metric.WithDescription("Datagrams received that could not be decoded as acarsdec or dumpvdl2 JSON"),
+		metric.WithUnit("{datagram}"),
+	)
+)
+
+// message is an ACARS message, from either decoder
+type message struct {
+	decoder   string
+	timestamp time.Time
+	station   string
+	frequency float64
+	level     *float64
+	errors    *int
+	mode      string
+	label     string
+	blockID   string
+	ack       string
+	tail      string
+	flight    string
+	msgno     string
+	text      string
+	hex       string
+}
+
+// acarsdecMessage is the JSON acarsdec sends with --output json:udp
+type acarsdecMessage struct {
+	Timestamp float64  `json:"timestamp"`
+	StationID string   `json:"station_id"`
+	Freq      float64  `json:"freq"`
+	Level     *float64 `json:"level"`
+	Error     *int     `json:"error"`
+	Mode      string   `json:"mode"`
+	Label     string   `json:"label"`
+	BlockID   string   `json:"block_id"`
+	Ack       any      `json:"ack"`
+	Tail      string   `json:"tail"`
+	Flight    string   `json:"flight"`
+	Msgno     string   `json:"msgno"`
+	Text      string   `json:"text"`
+	Icao      any      `json:"icao"`
+}
+
+// dumpvdl2Message is the JSON dumpvdl2 sends with --output decoded:json:udp
+type dumpvdl2Message struct {
+	VDL2 *struct {
+		Station string `json:"station"`
+		T       struct {
+			Sec  int64 `json:"sec"`
+			Usec int64 `json:"usec"`
+		} `json:"t"`
+		Freq     float64  `json:"freq"`
+		SigLevel *float64 `json:"sig_level"`
+		AVLC     struct {
+			Src struct {
+				Addr string `json:"addr"`
+				Type string `json:"type"`
+			} `json:"src"`
+			ACARS *struct {
+				Mode    string `json:"mode"`
+				Label   string `json:"label"`
+				BlkID   string `json:"blk_id"`
+				Ack     string `json:"ack"`
+				Reg     string `json:"reg"`
+				Flight  string `json:"flight"`
+				MsgNum  string `json:"msg_num"`
+				MsgSeq  string `json:"msg_num_seq"`
+				MsgText string `json:"msg_text"`
+			} `json:"acars"`
+		} `json:"avlc"`
+	} `json:"vdl2"`
+}
+
+// errNotACARS is returned for dumpvdl2 frames that carry no ACARS message,
+// such as link management frames, which are ignored
+var errNotACARS = errors.New("not an ACARS message")
+
+// decode decodes a datagram from acarsdec or dumpvdl2, telling them apart by
+// dumpvdl2's top level vdl2 object
+func decode(data []byte) (*message, error) {
+	var vdl2 dumpvdl2Message
+	if err := json.Unmarshal(data, &vdl2); err != nil {
+		return nil, err
+	}
+	if v := vdl2.VDL2; v != nil {
+		acars := v.AVLC.ACARS
+		if acars == nil {
+			return nil, errNotACARS
+		}
+		m := &message{
+			decoder:   decoderDumpvdl2,
+			timestamp: time.Unix(v.T.Sec, v.T.Usec*1000),
+			station:   v.Station,
+			frequency: v.Freq / 1e6,
+			level:     v.SigLevel,
+			mode:      acars.Mode,
+			label:     acars.Label,
+			blockID:   acars.BlkID,
+			ack:       acars.Ack,
+			tail:      acars.Reg,
+			flight:    acars.Flight,
+			msgno:     acars.MsgNum + acars.MsgSeq,
+			text:      acars.MsgText,
+		}
+		if v.AVLC.Src.Type == "Aircraft" {
+			m.hex = strings.ToLower(v.AVLC.Src.Addr)
+		}
+		return m, nil
+	}
+
+	var a acarsdecMessage
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	if a.Timestamp == 0 && a.Label == "" {
+		return nil, errors.New("neither acarsdec nor dumpvdl2 JSON")
+	}
+	sec, frac := math.Modf(a.Timestamp)
+	m := &message{
+		decoder:   decoderAcarsdec,
+		timestamp: time.Unix(int64(sec), int64(frac*1e9)),
+		station:   a.StationID,
+		frequency: a.Freq,
+		level:     a.Level,
+		errors:    a.Error,
+		mode:      a.Mode,
+		label:     a.Label,
+		blockID:   a.BlockID,
+		tail:      a.Tail,
+		flight:    a.Flight,
+		msgno:     a.Msgno,
+		text:      a.Text,
+	}
+	// acarsdec sends the ack as a string, or false when there is none
+	if ack, ok := a.Ack.(string); ok {
+		m.ack = ack
+	}
+	// and the ICAO address as a number or a hex string, if known
+	switch icao := a.Icao.(type) {
+	case float64:
+		m.hex = fmt.Sprintf("%06x", int64(icao))
+	case string:
+		m.hex = strings.ToLower(icao)
+	}
+	return m, nil
+}
+
+// attributes returns the log attributes for a message
+func (m *message) attributes() []otellog.KeyValue {
+	attrs := []otellog.KeyValue{
+		otellog.String("service", "acars"),
+		otellog.String("acars.decoder", m.decoder),
+		otellog.String("acars.label", m.label),
+	}
+	// Registrations are sent padded with dots, e.g. .N12345
+	if tail := strings.TrimLeft(strings.TrimSpace(m.tail), "."); tail != "" {
+		attrs = append(attrs, otellog.String("acars.tail", tail))
+	}
+	if flight := strings.TrimSpace(m.flight); flight != "" {
+		attrs = append(attrs, otellog.String("acars.flight", flight))
+	}
+	if m.hex != "" {
+		attrs = append(attrs, otellog.String("aircraft.hex", m.hex))
+	}
+	if m.mode != "" {
+		attrs = append(attrs, otellog.String("acars.mode", m.mode))
+	}
+	if m.blockID != "" {
+		attrs = append(attrs, otellog.String("acars.block_id", m.blockID))
+	}
+	if m.ack != "" {
+		attrs = append(attrs, otellog.String("acars.ack", m.ack))
+	}
+	if m.msgno != "" {
+		attrs = append(attrs, otellog.String("acars.message_number", m.msgno))
+	}
+	if m.station != "" {
+		attrs = append(attrs, otellog.String("acars.station_id", m.station))
+	}
+	if m.frequency > 0 {
+		attrs = append(attrs, otellog.Float64("acars.frequency_mhz", m.frequency))
+	}
+	if m.level != nil {
+		attrs = append(attrs, otellog.Float64("acars.signal_db", *m.level))
+	}
+	if m.errors != nil {
+		attrs = append(attrs, otellog.Int("acars.errors", *m.errors))
+	}
+	return attrs
+}
+
+// listener receives datagrams from the decoders on a UDP address
+type listener struct {
+	conn net.PacketConn
+	done chan struct{}
+}
+
+// InitACARS listens on the UDP addresses in ACARS_LISTEN for the JSON output
+// of acarsdec and dumpvdl2, exporting each ACARS message as an acars.message
+// log record, so the messages of a feeder box end up next to its aircraft
+// The returned function stops listening
+func InitACARS() (func(), error) {
+	addrs := listenAddrs(os.Getenv("ACARS_LISTEN"))
+	if len(addrs) == 0 {
+		return func() {}, nil
+	}
+
+	var listeners []*listener
+	stop := func() {
+		for _, l := range listeners {
+			l.conn.Close()
+			<-l.done
+		}
+	}
+	for _, addr := range addrs {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			stop()
+			return func() {}, fmt.Errorf("failed to listen for ACARS messages on %s: %w", addr, err)
+		}
+		l := &listener{conn: conn, done: make(chan struct{})}
+		listeners = append(listeners, l)
+		go l.run()
+		logging.Info("Listening for ACARS messages", "addr", conn.LocalAddr().String())
+	}
+	return stop, nil
+}
+
+// listenAddrs parses a comma separated list of UDP addresses
+func listenAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (l *listener) run() {
+	defer close(l.done)
+
+	buf := make([]byte, maxDatagram)
+	var warnOnce sync.Once
+	for {
+		n, from, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logging.Error("Stopped listening for ACARS messages", "addr", l.conn.LocalAddr().String(), "error", err)
+			}
+			return
+		}
+
+		m, err := decode(buf[:n])
+		if errors.Is(err, errNotACARS) {
+			continue
+		}
+		if err != nil {
+			invalidCounter.Add(context.Background(), 1)
+			warnOnce.Do(func() {
+				logging.Warn("Received a datagram that is not acarsdec or dumpvdl2 JSON", "from", from.String(), "error", err)
+			})
+			continue
+		}
+		l.emit(m)
+	}
+}
+
+// emit exports a message as a log record
+func (l *listener) emit(m *message) {
+	ctx := context.Background()
+	messageCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("decoder", m.decoder)))
+	logging.Debug("ACARS message", "decoder", m.decoder, "label", m.label, "tail", m.tail, "flight", m.flight)
+
+	logger := logs.GetLogger("acars")
+	if logger == nil {
+		return
+	}
+	record := otellog.Record{}
+	record.SetEventName(messageEvent)
+	record.SetTimestamp(m.timestamp)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetBody(otellog.StringValue(m.text))
+	record.AddAttributes(m.attributes()...)
+	logger.Emit(ctx, record)
+}
+
+// CheckConfig returns problems with the ACARS configuration
+func CheckConfig() []error {
+	var errs []error
+	for _, addr := range listenAddrs(os.Getenv("ACARS_LISTEN")) {
+		if _, err := net.ResolveUDPAddr("udp", addr); err != nil {
+			errs = append(errs, fmt.Errorf("ACARS_LISTEN: %w", err))
+		}
+	}
+	return errs
+}
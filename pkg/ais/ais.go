@@ -0,0 +1,400 @@
+// Package ais ingests AIS vessel reports from AIS-catcher, rtl-ais and other
+// receivers and exports them as OpenTelemetry log records
+package ais
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+// observationEvent is the event name of the record emitted for each position
+const observationEvent = "vessel.observation"
+
+// maxDatagram is the largest datagram read
+const maxDatagram = 64 * 1024
+
+// vesselTTL is how long the static data of a vessel is kept after its last
+// report, to be attached to its positions
+const vesselTTL = 24 * time.Hour
+
+// navigationStatuses are the navigational statuses of class A positions,
+// indexed by their value
+var navigationStatuses = []string{
+	"under_way_engine", "at_anchor", "not_under_command", "restricted_manoeuvrability",
+	"constrained_by_draught", "moored", "aground", "fishing", "under_way_sailing",
+}
+
+var (
+	meter = metrics.Meter("ais")
+
+	messageCounter, _ = meter.Int64Counter("adsb2otel.ais.messages",
+		metric.WithDescription("AIS messages decoded, by format and message type"),
+		metric.WithUnit("{message}"),
+	)
+	invalidCounter, _ = meter.Int64Counter("adsb2otel.ais.invalid",
+		metric.WithDescription("AIS sentences or JSON documents that could not be decoded"),
+		metric.WithUnit("{message}"),
+	)
+)
+
+// report is a decoded AIS message, a position or static vessel data
+type report struct {
+	msgType int
+	mmsi    uint32
+	class   string
+	channel string
+	signal  *float64
+
+	lat, lon *float64
+	speed    *float64
+	course   *float64
+	heading  *int
+	status   *int
+
+	name        string
+	callsign    string
+	destination string
+	imo         uint32
+	shipType    *int
+}
+
+// hasPosition reports whether the report carries a position
+func (r *report) hasPosition() bool {
+	return r.lat != nil && r.lon != nil
+}
+
+// catcherMessage is a message as AIS-catcher sends it with JSON output, in
+// the scaled gpsd format
+type catcherMessage struct {
+	Class       string   `json:"class"`
+	Type        int      `json:"type"`
+	MMSI        uint32   `json:"mmsi"`
+	Channel     string   `json:"channel"`
+	SignalPower *float64 `json:"signalpower"`
+	Status      *int     `json:"status"`
+	Speed       *float64 `json:"speed"`
+	Lat         *float64 `json:"lat"`
+	Lon         *float64 `json:"lon"`
+	Course      *float64 `json:"course"`
+	Heading     *int     `json:"heading"`
+	PartNo      *int     `json:"partno"`
+	Shipname    string   `json:"shipname"`
+	Callsign    string   `json:"callsign"`
+	Destination string   `json:"destination"`
+	IMO         uint32   `json:"imo"`
+	Shiptype    *int     `json:"shiptype"`
+}
+
+// decodeJSON decodes an AIS-catcher JSON message
+func decodeJSON(data []byte) (*report, error) {
+	var m catcherMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.MMSI == 0 || (m.Class != "" && m.Class != "AIS") {
+		return nil, errors.New("not an AIS-catcher message")
+	}
+	r := &report{
+		msgType:     m.Type,
+		mmsi:        m.MMSI,
+		channel:     m.Channel,
+		signal:      m.SignalPower,
+		name:        strings.TrimSpace(m.Shipname),
+		callsign:    strings.TrimSpace(m.Callsign),
+		destination: strings.TrimSpace(m.Destination),
+		imo:         m.IMO,
+		shipType:    m.Shiptype,
+	}
+	switch m.Type {
+	case 1, 2, 3, 5:
+		r.class = "A"
+	case 18, 19, 24:
+		r.class = "B"
+	default:
+		return nil, errUnsupported
+	}
+	if m.Type == 1 || m.Type == 2 || m.Type == 3 {
+		r.status = m.Status
+	}
+	// Values that are not available are sent as their out of range markers
+	if m.Lat != nil && m.Lon != nil && *m.Lat <= 90 && *m.Lon <= 180 {
+		r.lat, r.lon = m.Lat, m.Lon
+	}
+	if m.Speed != nil && *m.Speed < 102.3 {
+		r.speed = m.Speed
+	}
+	if m.Course != nil && *m.Course < 360 {
+		r.course = m.Course
+	}
+	if m.Heading != nil && *m.Heading < 360 {
+		r.heading = m.Heading
+	}
+	return r, nil
+}
+
+// vessel is the static data last reported for a vessel
+type vessel struct {
+	name        string
+	callsign    string
+	destination string
+	imo         uint32
+	shipType    *int
+	seen        time.Time
+}
+
+// update keeps the static data of a report
+func (v *vessel) update(r *report, now time.Time) {
+	v.seen = now
+	if r.name != "" {
+		v.name = r.name
+	}
+	if r.callsign != "" {
+		v.callsign = r.callsign
+	}
+	if r.destination != "" {
+		v.destination = r.destination
+	}
+	if r.imo != 0 {
+		v.imo = r.imo
+	}
+	if r.shipType != nil {
+		v.shipType = r.shipType
+	}
+}
+
+// listener receives AIS messages on a UDP address, as NMEA sentences or
+// AIS-catcher JSON, one or more lines per datagram
+type listener struct {
+	conn      net.PacketConn
+	fragments fragments
+	vessels   map[uint32]*vessel
+	pruned    time.Time
+	done      chan struct{}
+}
+
+// InitAIS listens on the UDP addresses in AIS_LISTEN for AIS messages from
+// AIS-catcher, rtl-ais or any receiver sending NMEA, exporting each vessel
+// position as a vessel.observation log record, with the static data last
+// received for the vessel
+// The returned function stops listening
+func InitAIS() (func(), error) {
+	addrs := listenAddrs(os.Getenv("AIS_LISTEN"))
+	if len(addrs) == 0 {
+		return func() {}, nil
+	}
+
+	var listeners []*listener
+	stop := func() {
+		for _, l := range listeners {
+			l.conn.Close()
+			<-l.done
+		}
+	}
+	for _, addr := range addrs {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			stop()
+			return func() {}, fmt.Errorf("failed to listen for AIS messages on %s: %w", addr, err)
+		}
+		l := &listener{conn: conn, vessels: make(map[uint32]*vessel), done: make(chan struct{})}
+		listeners = append(listeners, l)
+		go l.run()
+		logging.Info("Listening for AIS messages", "addr", conn.LocalAddr().String())
+	}
+	return stop, nil
+}
+
+// listenAddrs parses a comma separated list of UDP addresses
+func listenAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (l *listener) run() {
+	defer close(l.done)
+
+	buf := make([]byte, maxDatagram)
+	var warnOnce sync.Once
+	for {
+		n, from, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logging.Error("Stopped listening for AIS messages", "addr", l.conn.LocalAddr().String(), "error", err)
+			}
+			return
+		}
+
+		now := time.Now()
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+			format := "nmea"
+			var r *report
+			if line[0] == '{' {
+				format = "json"
+				r, err = decodeJSON(line)
+			} else {
+				r, err = l.fragments.sentence(string(line), now)
+			}
+			if errors.Is(err, errIncomplete) || errors.Is(err, errUnsupported) {
+				continue
+			}
+			if err != nil {
+				invalidCounter.Add(context.Background(), 1)
+				warnOnce.Do(func() {
+					logging.Warn("Received an AIS message that could not be decoded", "from", from.String(), "error", err)
+				})
+				continue
+			}
+			l.handle(r, format, now)
+		}
+	}
+}
+
+// handle keeps the static data of a report and exports its position, if any
+func (l *listener) handle(r *report, format string, now time.Time) {
+	ctx := context.Background()
+	messageCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("format", format),
+		attribute.Int("type", r.msgType),
+	))
+
+	if now.Sub(l.pruned) > time.Hour {
+		for mmsi, v := range l.vessels {
+			if now.Sub(v.seen) > vesselTTL {
+				delete(l.vessels, mmsi)
+			}
+		}
+		l.pruned = now
+	}
+	v, ok := l.vessels[r.mmsi]
+	if !ok {
+		v = &vessel{}
+		l.vessels[r.mmsi] = v
+	}
+	v.update(r, now)
+
+	if !r.hasPosition() {
+		return
+	}
+	logging.Debug("Vessel position", "mmsi", r.mmsi, "name", v.name, "lat", *r.lat, "lon", *r.lon)
+
+	logger := logs.GetLogger("ais")
+	if logger == nil {
+		return
+	}
+	attrs := r.attributes(v)
+	body, _ := json.Marshal(vesselBody(attrs))
+	record := otellog.Record{}
+	record.SetEventName(observationEvent)
+	record.SetTimestamp(now)
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetBody(otellog.StringValue(string(body)))
+	record.AddAttributes(attrs...)
+	logger.Emit(ctx, record)
+}
+
+// attributes returns the log attributes for a position and the static data
+// of its vessel
+func (r *report) attributes(v *vessel) []otellog.KeyValue {
+	attrs := []otellog.KeyValue{
+		otellog.String("service", "ais"),
+		otellog.String("vessel.mmsi", strconv.FormatUint(uint64(r.mmsi), 10)),
+		otellog.String("vessel.class", r.class),
+		otellog.Int("ais.message_type", r.msgType),
+		otellog.Float64("vessel.lat", *r.lat),
+		otellog.Float64("vessel.lon", *r.lon),
+		otellog.Float64(string(semconv.GeoLocationLatKey), *r.lat),
+		otellog.Float64(string(semconv.GeoLocationLonKey), *r.lon),
+	}
+	if r.speed != nil {
+		attrs = append(attrs, otellog.Float64("vessel.speed_kt", *r.speed))
+	}
+	if r.course != nil {
+		attrs = append(attrs, otellog.Float64("vessel.course", *r.course))
+	}
+	if r.heading != nil {
+		attrs = append(attrs, otellog.Int("vessel.heading", *r.heading))
+	}
+	if r.status != nil && *r.status < len(navigationStatuses) {
+		attrs = append(attrs, otellog.String("vessel.status", navigationStatuses[*r.status]))
+	}
+	if r.channel != "" {
+		attrs = append(attrs, otellog.String("ais.channel", r.channel))
+	}
+	if r.signal != nil {
+		attrs = append(attrs, otellog.Float64("ais.signal_db", *r.signal))
+	}
+	if v.name != "" {
+		attrs = append(attrs, otellog.String("vessel.name", v.name))
+	}
+	if v.callsign != "" {
+		attrs = append(attrs, otellog.String("vessel.callsign", v.callsign))
+	}
+	if v.destination != "" {
+		attrs = append(attrs, otellog.String("vessel.destination", v.destination))
+	}
+	if v.imo != 0 {
+		attrs = append(attrs, otellog.String("vessel.imo", strconv.FormatUint(uint64(v.imo), 10)))
+	}
+	if v.shipType != nil && *v.shipType != 0 {
+		attrs = append(attrs, otellog.Int("vessel.ship_type", *v.shipType))
+	}
+	return attrs
+}
+
+// vesselBody returns the vessel attributes as a JSON object for the record
+// body, the way aircraft records carry their aircraft.json entry
+func vesselBody(attrs []otellog.KeyValue) map[string]any {
+	body := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		key, found := strings.CutPrefix(kv.Key, "vessel.")
+		if !found {
+			continue
+		}
+		switch kv.Value.Kind() {
+		case otellog.KindString:
+			body[key] = kv.Value.AsString()
+		case otellog.KindInt64:
+			body[key] = kv.Value.AsInt64()
+		case otellog.KindFloat64:
+			body[key] = kv.Value.AsFloat64()
+		}
+	}
+	return body
+}
+
+// CheckConfig returns problems with the AIS configuration
+func CheckConfig() []error {
+	var errs []error
+	for _, addr := range listenAddrs(os.Getenv("AIS_LISTEN")) {
+		if _, err := net.ResolveUDPAddr("udp", addr); err != nil {
+			errs = append(errs, fmt.Errorf("AIS_LISTEN: %w", err))
+		}
+	}
+	return errs
+}
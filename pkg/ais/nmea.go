@@ -0,0 +1,250 @@
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fragmentTTL is how long the first fragments of a multi-sentence message
+// are kept waiting for the rest
+const fragmentTTL = 10 * time.Second
+
+// errIncomplete is returned for a fragment of a message that is not complete yet
+var errIncomplete = errors.New("incomplete message")
+
+// errUnsupported is returned for message types that carry neither a position
+// nor static vessel data, which are ignored
+var errUnsupported = errors.New("unsupported message type")
+
+// fragments collects the sentences of multi-sentence messages, such as the
+// static data of message type 5, by sequence id and channel
+type fragments struct {
+	pending map[string]*fragment
+}
+
+type fragment struct {
+	parts    []string
+	received int
+	fill     int
+	started  time.Time
+}
+
+// sentence decodes an !AIVDM or !AIVDO sentence, returning errIncomplete
+// until every fragment of a message has been received
+func (f *fragments) sentence(line string, now time.Time) (*report, error) {
+	// AIS-catcher and some receivers prefix sentences with an NMEA 4.0 tag block
+	if strings.HasPrefix(line, `\`) {
+		if end := strings.Index(line[1:], `\`); end >= 0 {
+			line = line[end+2:]
+		}
+	}
+	if !strings.HasPrefix(line, "!AIVDM") && !strings.HasPrefix(line, "!AIVDO") {
+		return nil, fmt.Errorf("not an AIVDM sentence: %q", line)
+	}
+	body, checksum, found := strings.Cut(line[1:], "*")
+	if !found || !validChecksum(body, checksum) {
+		return nil, errors.New("invalid NMEA checksum")
+	}
+	field := strings.Split(body, ",")
+	if len(field) < 7 {
+		return nil, errors.New("truncated AIVDM sentence")
+	}
+	count, err1 := strconv.Atoi(field[1])
+	number, err2 := strconv.Atoi(field[2])
+	fill, err3 := strconv.Atoi(field[6])
+	if err1 != nil || err2 != nil || err3 != nil || count < 1 || number < 1 || number > count {
+		return nil, errors.New("invalid AIVDM fragment fields")
+	}
+	channel, payload := field[4], field[5]
+
+	if count == 1 {
+		return decodePayload(payload, fill, channel)
+	}
+
+	if f.pending == nil {
+		f.pending = make(map[string]*fragment)
+	}
+	for key, pending := range f.pending {
+		if now.Sub(pending.started) > fragmentTTL {
+			delete(f.pending, key)
+		}
+	}
+	key := field[3] + "/" + channel
+	pending, ok := f.pending[key]
+	if !ok || len(pending.parts) != count {
+		pending = &fragment{parts: make([]string, count), started: now}
+		f.pending[key] = pending
+	}
+	if pending.parts[number-1] == "" {
+		pending.received++
+	}
+	pending.parts[number-1] = payload
+	if number == count {
+		pending.fill = fill
+	}
+	if pending.received < count {
+		return nil, errIncomplete
+	}
+	delete(f.pending, key)
+	return decodePayload(strings.Join(pending.parts, ""), pending.fill, channel)
+}
+
+// validChecksum checks the XOR checksum of an NMEA sentence
+func validChecksum(body, checksum string) bool {
+	want, err := strconv.ParseUint(strings.TrimSpace(checksum), 16, 8)
+	if err != nil {
+		return false
+	}
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum == byte(want)
+}
+
+// bits is an unpacked AIS payload, one bit per byte
+type bits []byte
+
+// unarmor unpacks the 6-bit ASCII armoring of an AIS payload
+func unarmor(payload string, fill int) (bits, error) {
+	b := make(bits, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		v := int(payload[i]) - 48
+		if v > 40 {
+			v -= 8
+		}
+		if v < 0 || v > 63 {
+			return nil, fmt.Errorf("invalid AIS payload character %q", payload[i])
+		}
+		for bit := 5; bit >= 0; bit-- {
+			b = append(b, byte(v>>bit&1))
+		}
+	}
+	if fill > 0 && fill <= len(b) {
+		b = b[:len(b)-fill]
+	}
+	return b, nil
+}
+
+// uint returns the unsigned integer of n bits at start, 0 past the end
+func (b bits) uint(start, n int) uint64 {
+	var v uint64
+	for i := start; i < start+n; i++ {
+		v <<= 1
+		if i < len(b) {
+			v |= uint64(b[i])
+		}
+	}
+	return v
+}
+
+// int returns the two's complement integer of n bits at start
+func (b bits) int(start, n int) int64 {
+	v := int64(b.uint(start, n))
+	if v&(1<<(n-1)) != 0 {
+		v -= 1 << n
+	}
+	return v
+}
+
+// text returns the 6-bit ASCII text of n bits at start, without padding
+func (b bits) text(start, n int) string {
+	var s strings.Builder
+	for i := start; i+6 <= start+n && i+6 <= len(b); i += 6 {
+		c := byte(b.uint(i, 6))
+		if c < 32 {
+			c += 64
+		}
+		s.WriteByte(c)
+	}
+	return strings.TrimRight(strings.TrimRight(s.String(), "@"), " ")
+}
+
+// decodePayload decodes the message types carrying positions (1, 2, 3, 18
+// and 19) or static vessel data (5, 19 and 24)
+func decodePayload(payload string, fill int, channel string) (*report, error) {
+	b, err := unarmor(payload, fill)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 38 {
+		return nil, errors.New("AIS message too short")
+	}
+
+	r := &report{
+		msgType: int(b.uint(0, 6)),
+		mmsi:    uint32(b.uint(8, 30)),
+		channel: channel,
+	}
+	switch r.msgType {
+	case 1, 2, 3:
+		if len(b) < 149 {
+			return nil, fmt.Errorf("AIS message type %d too short", r.msgType)
+		}
+		r.class = "A"
+		status := int(b.uint(38, 4))
+		r.status = &status
+		r.setPosition(b.int(61, 28), b.int(89, 27), b.uint(50, 10), b.uint(116, 12), b.uint(128, 9))
+	case 18, 19:
+		if len(b) < 168 {
+			return nil, fmt.Errorf("AIS message type %d too short", r.msgType)
+		}
+		r.class = "B"
+		r.setPosition(b.int(57, 28), b.int(85, 27), b.uint(46, 10), b.uint(112, 12), b.uint(124, 9))
+		if r.msgType == 19 && len(b) >= 271 {
+			r.name = b.text(143, 120)
+			shipType := int(b.uint(263, 8))
+			r.shipType = &shipType
+		}
+	case 5:
+		if len(b) < 422 {
+			return nil, errors.New("AIS message type 5 too short")
+		}
+		r.class = "A"
+		r.imo = uint32(b.uint(40, 30))
+		r.callsign = b.text(70, 42)
+		r.name = b.text(112, 120)
+		shipType := int(b.uint(232, 8))
+		r.shipType = &shipType
+		r.destination = b.text(302, 120)
+	case 24:
+		if len(b) < 160 {
+			return nil, errors.New("AIS message type 24 too short")
+		}
+		r.class = "B"
+		if b.uint(38, 2) == 0 {
+			r.name = b.text(40, 120)
+		} else {
+			shipType := int(b.uint(40, 8))
+			r.shipType = &shipType
+			r.callsign = b.text(90, 42)
+		}
+	default:
+		return nil, errUnsupported
+	}
+	return r, nil
+}
+
+// setPosition sets the position, speed, course and heading from their raw
+// values, leaving out those marked as not available
+func (r *report) setPosition(lon, lat int64, speed, course, heading uint64) {
+	if lon != 181*600000 && lat != 91*600000 {
+		lonDeg, latDeg := float64(lon)/600000, float64(lat)/600000
+		r.lon, r.lat = &lonDeg, &latDeg
+	}
+	if speed != 1023 {
+		knots := float64(speed) / 10
+		r.speed = &knots
+	}
+	if course != 3600 {
+		degrees := float64(course) / 10
+		r.course = &degrees
+	}
+	if heading != 511 {
+		degrees := int(heading)
+		r.heading = &degrees
+	}
+}
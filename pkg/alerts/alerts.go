@@ -0,0 +1,140 @@
+package alerts
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+// Rule is an alert rule whose state is exported as metrics, so meta-alerts
+// can be built on the rules themselves, e.g. on a rule that fires too often
+// A rule may fire for several instances at once, such as one per aircraft
+type Rule struct {
+	name string
+
+	mu        sync.Mutex
+	active    map[string]bool
+	fired     int64
+	lastFired time.Time
+}
+
+// State is a snapshot of a rule's state
+type State struct {
+	Name      string
+	Active    int
+	Fired     int64
+	LastFired time.Time
+}
+
+var (
+	meter = metrics.Meter("alerts")
+
+	mu    sync.Mutex
+	rules = make(map[string]*Rule)
+
+	registerOnce sync.Once
+)
+
+// Get returns the rule with the given name, creating it on first use
+func Get(name string) *Rule {
+	registerOnce.Do(register)
+
+	mu.Lock()
+	defer mu.Unlock()
+	r, ok := rules[name]
+	if !ok {
+		r = &Rule{name: name, active: make(map[string]bool)}
+		rules[name] = r
+	}
+	return r
+}
+
+// Set records whether the rule is firing for an instance, counting it as
+// fired when it starts
+func (r *Rule) Set(instance string, firing bool) {
+	if firing {
+		r.Fire(instance)
+	} else {
+		r.Resolve(instance)
+	}
+}
+
+// Fire records the rule as firing for an instance, "" for rules without
+// instances, and counts it unless it was firing already
+func (r *Rule) Fire(instance string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active[instance] {
+		return
+	}
+	r.active[instance] = true
+	r.fired++
+	r.lastFired = time.Now()
+}
+
+// Resolve records that the rule stopped firing for an instance
+func (r *Rule) Resolve(instance string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.active, instance)
+}
+
+// State returns a snapshot of the rule's state
+func (r *Rule) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return State{Name: r.name, Active: len(r.active), Fired: r.fired, LastFired: r.lastFired}
+}
+
+// States returns the state of every rule used so far, by name
+func States() []State {
+	mu.Lock()
+	list := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		list = append(list, r)
+	}
+	mu.Unlock()
+
+	states := make([]State, len(list))
+	for i, r := range list {
+		states[i] = r.State()
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// register creates the instruments reporting the state of the rules
+func register() {
+	firing, err1 := meter.Int64ObservableGauge("adsb2otel.alert.rule.firing",
+		metric.WithDescription("Instances an alert rule is firing for, 0 when resolved, by rule"),
+		metric.WithUnit("{alert}"),
+	)
+	fired, err2 := meter.Int64ObservableCounter("adsb2otel.alert.rule.fired",
+		metric.WithDescription("Times an alert rule started firing, by rule"),
+		metric.WithUnit("{alert}"),
+	)
+	lastFired, err3 := meter.Int64ObservableGauge("adsb2otel.alert.rule.last_fired",
+		metric.WithDescription("Unix time an alert rule last started firing, by rule"),
+		metric.WithUnit("s"),
+	)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+	meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, state := range States() {
+			attrs := metric.WithAttributes(attribute.String("rule", state.Name))
+			o.ObserveInt64(firing, int64(state.Active), attrs)
+			o.ObserveInt64(fired, state.Fired, attrs)
+			if !state.LastFired.IsZero() {
+				o.ObserveInt64(lastFired, state.LastFired.Unix(), attrs)
+			}
+		}
+		return nil
+	}, firing, fired, lastFired)
+}
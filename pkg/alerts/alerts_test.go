@@ -0,0 +1,39 @@
+package alerts
+
+import "testing"
+
+func TestRuleState(t *testing.T) {
+	r := Get("test.rule")
+	if Get("test.rule") != r {
+		t.Fatal("Get returned a new rule for the same name")
+	}
+
+	r.Fire("4ca7b4")
+	r.Fire("4ca7b4")
+	r.Set("3c6444", true)
+	state := r.State()
+	if state.Active != 2 || state.Fired != 2 || state.LastFired.IsZero() {
+		t.Errorf("after firing twice for one aircraft and once for another: %+v", state)
+	}
+
+	r.Resolve("4ca7b4")
+	r.Set("3c6444", false)
+	r.Resolve("unknown")
+	if state := r.State(); state.Active != 0 || state.Fired != 2 {
+		t.Errorf("after resolving: %+v", state)
+	}
+
+	// Firing again after resolving counts again
+	r.Fire("4ca7b4")
+	if state := r.State(); state.Active != 1 || state.Fired != 3 {
+		t.Errorf("after firing again: %+v", state)
+	}
+
+	found := false
+	for _, s := range States() {
+		found = found || s.Name == "test.rule"
+	}
+	if !found {
+		t.Errorf("test.rule missing from %+v", States())
+	}
+}
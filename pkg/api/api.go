@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+	"github.com/burnettdev/adsb2otel/pkg/tracks"
+)
+
+// InitAPI starts the HTTP API for live consumers if API_ADDR is set
+func InitAPI() (func(), error) {
+	addr := os.Getenv("API_ADDR")
+	hub := stream.Get()
+	if addr == "" || hub == nil {
+		return func() {}, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/replay", replayHandler(hub))
+	mux.HandleFunc("GET /api/v1/stream", sseHandler(hub))
+	mux.HandleFunc("GET /api/v1/ws", wsHandler(hub))
+	mux.HandleFunc("GET /api/v1/aircraft.geojson", geoJSONHandler(hub))
+	mux.HandleFunc("GET /api/v1/aircraft.kml", kmlHandler(hub))
+	if history := tracks.Get(); history != nil {
+		mux.HandleFunc("GET /api/v1/tracks/{hex}", trackHandler(history))
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return func() {}, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	// Streaming responses are long lived, so only the header read is bounded
+	// and their context is cancelled on shutdown
+	baseCtx, cancelStreams := context.WithCancel(context.Background())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Error("API server stopped", "error", err)
+		}
+	}()
+
+	log.Printf("API server listening on %s (buffer window: %s)", listener.Addr(), hub.Window())
+
+	return func() {
+		cancelStreams()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down API server: %v", err)
+		}
+	}, nil
+}
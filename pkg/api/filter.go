@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+)
+
+// filter selects the records a consumer receives
+// Every criterion that is set must match, an empty filter matches everything
+type filter struct {
+	Hex    []string  `json:"hex,omitempty"`
+	Flight string    `json:"flight,omitempty"`
+	BBox   []float64 `json:"bbox,omitempty"`
+	MinAlt *int      `json:"min_alt,omitempty"`
+	MaxAlt *int      `json:"max_alt,omitempty"`
+	hexSet map[string]bool
+}
+
+// parseFilter reads a filter from query parameters:
+// hex (comma separated addresses), flight (callsign glob, e.g. BAW*),
+// bbox (min_lat,min_lon,max_lat,max_lon), min_alt and max_alt (feet)
+func parseFilter(q url.Values) (filter, error) {
+	var f filter
+	if list := q.Get("hex"); list != "" {
+		f.Hex = strings.Split(list, ",")
+	}
+	f.Flight = q.Get("flight")
+	if bbox := q.Get("bbox"); bbox != "" {
+		for _, part := range strings.Split(bbox, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return filter{}, fmt.Errorf("invalid bbox %q", bbox)
+			}
+			f.BBox = append(f.BBox, v)
+		}
+	}
+	for name, dst := range map[string]**int{"min_alt": &f.MinAlt, "max_alt": &f.MaxAlt} {
+		if value := q.Get(name); value != "" {
+			alt, err := strconv.Atoi(value)
+			if err != nil {
+				return filter{}, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*dst = &alt
+		}
+	}
+	return f, f.compile()
+}
+
+// compile validates the filter and prepares it for matching
+func (f *filter) compile() error {
+	if len(f.BBox) != 0 && len(f.BBox) != 4 {
+		return fmt.Errorf("bbox must be min_lat,min_lon,max_lat,max_lon")
+	}
+	if f.Flight != "" {
+		if _, err := path.Match(strings.ToUpper(f.Flight), ""); err != nil {
+			return fmt.Errorf("invalid flight pattern %q", f.Flight)
+		}
+	}
+
+	f.hexSet = nil
+	for _, hex := range f.Hex {
+		if hex = strings.ToLower(strings.TrimSpace(hex)); hex != "" {
+			if f.hexSet == nil {
+				f.hexSet = make(map[string]bool)
+			}
+			f.hexSet[hex] = true
+		}
+	}
+	return nil
+}
+
+func (f *filter) match(r *stream.Record) bool {
+	a := &r.Aircraft
+
+	if f.hexSet != nil && !f.hexSet[strings.ToLower(a.Hex)] {
+		return false
+	}
+	if f.Flight != "" {
+		if matched, _ := path.Match(strings.ToUpper(f.Flight), strings.ToUpper(strings.TrimSpace(a.Flight))); !matched {
+			return false
+		}
+	}
+	if len(f.BBox) == 4 {
+		pos, ok := a.Position()
+		if !ok || pos.Lat < f.BBox[0] || pos.Lon < f.BBox[1] || pos.Lat > f.BBox[2] || pos.Lon > f.BBox[3] {
+			return false
+		}
+	}
+	if f.MinAlt != nil || f.MaxAlt != nil {
+		alt, ok := a.AltitudeFeet()
+		if a.OnGround() {
+			alt, ok = 0, true
+		}
+		if !ok || (f.MinAlt != nil && alt < *f.MinAlt) || (f.MaxAlt != nil && alt > *f.MaxAlt) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *filter) empty() bool {
+	return f.hexSet == nil && f.Flight == "" && len(f.BBox) == 0 && f.MinAlt == nil && f.MaxAlt == nil
+}
+
+// apply returns the records of a batch that match the filter
+func (f *filter) apply(batch stream.Batch) []stream.Record {
+	if f.empty() {
+		return batch.Records
+	}
+	var matched []stream.Record
+	for i := range batch.Records {
+		if f.match(&batch.Records[i]) {
+			matched = append(matched, batch.Records[i])
+		}
+	}
+	return matched
+}
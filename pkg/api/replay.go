@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+)
+
+// parseReplayWindow reads the named duration parameter, capped to the hub's window
+func parseReplayWindow(q url.Values, name string, hub *stream.Hub, defaultValue time.Duration) (time.Duration, error) {
+	window := defaultValue
+	if value := q.Get(name); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid %s %q", name, value)
+		}
+		window = d
+	}
+	return min(window, hub.Window()), nil
+}
+
+// replayHandler returns the buffered records of the last window as a JSON array
+// GET /api/v1/replay?window=5m&hex=abc123,def456
+func replayHandler(hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		window, err := parseReplayWindow(q, "window", hub, hub.Window())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := parseFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		records := []stream.Record{}
+		for _, batch := range hub.Since(time.Now().Add(-window)) {
+			records = append(records, f.apply(batch)...)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			logging.Debug("Failed to write replay response", "error", err)
+		}
+	}
+}
+
+// sseHandler streams records as server-sent events, starting with a replay
+// of the last replay window (none by default) before following live polls
+// GET /api/v1/stream?replay=5m&hex=abc123
+func sseHandler(hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query()
+		window, err := parseReplayWindow(q, "replay", hub, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := parseFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		since := time.Now().Add(-window)
+		if window == 0 {
+			since = time.Now()
+		}
+		replay, sub := hub.Subscribe(since)
+		defer sub.Cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(batch stream.Batch) error {
+			for _, record := range f.apply(batch) {
+				data, err := json.Marshal(record)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "event: aircraft\ndata: %s\n\n", data); err != nil {
+					return err
+				}
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		for _, batch := range replay {
+			if err := send(batch); err != nil {
+				return
+			}
+		}
+
+		for {
+			select {
+			case batch, ok := <-sub.C:
+				if !ok {
+					logging.Debug("Stream consumer fell behind, disconnecting", "remote", r.RemoteAddr)
+					return
+				}
+				if err := send(batch); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
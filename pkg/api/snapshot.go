@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+)
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// geoJSONGeometry is a Point with []float64 or a LineString with [][]float64 coordinates
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// snapshot returns the positioned aircraft of the latest poll that match the request's filter
+func snapshot(hub *stream.Hub, w http.ResponseWriter, r *http.Request) ([]stream.Record, bool) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	batch, _ := hub.Latest()
+	var records []stream.Record
+	for _, record := range f.apply(batch) {
+		if record.Aircraft.HasPosition() {
+			records = append(records, record)
+		}
+	}
+	return records, true
+}
+
+// properties returns the feature properties describing an aircraft
+func properties(record *stream.Record) map[string]any {
+	a := &record.Aircraft
+	props := map[string]any{
+		"hex":  a.Hex,
+		"time": record.Time,
+		"seen": a.Seen,
+	}
+	if flight := strings.TrimSpace(a.Flight); flight != "" {
+		props["flight"] = flight
+	}
+	if a.R != "" {
+		props["registration"] = a.R
+	}
+	if a.T != "" {
+		props["aircraft_type"] = a.T
+	}
+	if a.AltBaro != nil {
+		props["on_ground"] = a.OnGround()
+	}
+	if alt, ok := a.AltitudeFeet(); ok && !a.OnGround() {
+		props["altitude"] = alt
+	}
+	if a.Gs != nil {
+		props["gs"] = *a.Gs
+	}
+	if a.Track != nil {
+		props["track"] = *a.Track
+	}
+	if a.Squawk != "" {
+		props["squawk"] = a.Squawk
+	}
+	if a.Source != "" {
+		props["source"] = a.Source
+	}
+	return props
+}
+
+// geoJSONHandler serves the current aircraft positions as a GeoJSON FeatureCollection
+// GET /api/v1/aircraft.geojson
+func geoJSONHandler(hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, ok := snapshot(hub, w, r)
+		if !ok {
+			return
+		}
+
+		fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+		for i := range records {
+			pos, _ := records[i].Aircraft.Position()
+			coordinates := []float64{pos.Lon, pos.Lat}
+			if alt, ok := records[i].Aircraft.AltitudeFeet(); ok && !records[i].Aircraft.OnGround() {
+				// GeoJSON altitudes are in meters
+				coordinates = append(coordinates, float64(alt)*0.3048)
+			}
+			fc.Features = append(fc.Features, geoJSONFeature{
+				Type:       "Feature",
+				ID:         records[i].Aircraft.Hex,
+				Geometry:   geoJSONGeometry{Type: "Point", Coordinates: coordinates},
+				Properties: properties(&records[i]),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		if err := json.NewEncoder(w).Encode(fc); err != nil {
+			logging.Debug("Failed to write GeoJSON response", "error", err)
+		}
+	}
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	ID          string `xml:"id,attr"`
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Point       struct {
+		AltitudeMode string `xml:"altitudeMode"`
+		Coordinates  string `xml:"coordinates"`
+	} `xml:"Point"`
+}
+
+// kmlHandler serves the current aircraft positions as a KML document
+// GET /api/v1/aircraft.kml
+func kmlHandler(hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, ok := snapshot(hub, w, r)
+		if !ok {
+			return
+		}
+
+		doc := kmlDocument{Name: "adsb2otel aircraft"}
+		for i := range records {
+			a := &records[i].Aircraft
+			pos, _ := a.Position()
+
+			pm := kmlPlacemark{ID: a.Hex, Name: strings.TrimSpace(a.Flight)}
+			if pm.Name == "" {
+				pm.Name = a.Hex
+			}
+
+			props := properties(&records[i])
+			var desc []string
+			for _, key := range []string{"hex", "registration", "aircraft_type", "altitude", "gs", "track", "squawk"} {
+				if v, ok := props[key]; ok {
+					desc = append(desc, fmt.Sprintf("%s: %v", key, v))
+				}
+			}
+			pm.Description = strings.Join(desc, "\n")
+
+			pm.Point.AltitudeMode = "clampToGround"
+			pm.Point.Coordinates = fmt.Sprintf("%f,%f", pos.Lon, pos.Lat)
+			if alt, ok := a.AltitudeFeet(); ok && !a.OnGround() {
+				pm.Point.AltitudeMode = "absolute"
+				pm.Point.Coordinates = fmt.Sprintf("%f,%f,%.0f", pos.Lon, pos.Lat, float64(alt)*0.3048)
+			}
+			doc.Placemarks = append(doc.Placemarks, pm)
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+		w.Write([]byte(xml.Header))
+		if err := xml.NewEncoder(w).Encode(doc); err != nil {
+			logging.Debug("Failed to write KML response", "error", err)
+		}
+	}
+}
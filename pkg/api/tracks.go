@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/tracks"
+)
+
+// trackHandler serves the recorded positions of an aircraft, as JSON or,
+// with format=geojson, as a GeoJSON LineString feature
+// GET /api/v1/tracks/{hex}?window=30m&format=geojson
+func trackHandler(history *tracks.History) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		window := history.Window()
+		if value := q.Get("window"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				http.Error(w, fmt.Sprintf("invalid window %q", value), http.StatusBadRequest)
+				return
+			}
+			window = d
+		}
+		format := q.Get("format")
+		if format != "" && format != "json" && format != "geojson" {
+			http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		track, ok := history.Track(r.PathValue("hex"), window)
+		if !ok {
+			http.Error(w, "no track recorded for "+r.PathValue("hex"), http.StatusNotFound)
+			return
+		}
+
+		if format != "geojson" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(track); err != nil {
+				logging.Debug("Failed to write track response", "error", err)
+			}
+			return
+		}
+
+		coordinates := make([][]float64, len(track.Points))
+		for i, p := range track.Points {
+			coordinates[i] = []float64{p.Lon, p.Lat}
+			if p.Altitude != nil {
+				// GeoJSON altitudes are in meters
+				coordinates[i] = append(coordinates[i], float64(*p.Altitude)*0.3048)
+			}
+		}
+		props := map[string]any{
+			"hex":   track.Hex,
+			"start": track.Points[0].Time,
+			"end":   track.Points[len(track.Points)-1].Time,
+		}
+		if track.Flight != "" {
+			props["flight"] = track.Flight
+		}
+		feature := geoJSONFeature{
+			Type:       "Feature",
+			ID:         track.Hex,
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+			Properties: props,
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		if err := json.NewEncoder(w).Encode(feature); err != nil {
+			logging.Debug("Failed to write track response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+)
+
+const wsWriteTimeout = 10 * time.Second
+
+// wsMessage is sent to WebSocket clients, either a record or an error
+type wsMessage struct {
+	Type string `json:"type"`
+	*stream.Record
+	Error string `json:"error,omitempty"`
+}
+
+// wsHandler rebroadcasts the aircraft stream to WebSocket clients
+// The initial filter and replay window come from the same query parameters
+// as the SSE stream; clients can replace their filter at any time by sending
+// it as a JSON message, e.g. {"flight":"BAW*","bbox":[50,-2,53,1]}
+// GET /api/v1/ws?replay=5m&hex=abc123
+func wsHandler(hub *stream.Hub) http.HandlerFunc {
+	var originPatterns []string
+	if origins := os.Getenv("API_WS_ORIGINS"); origins != "" {
+		originPatterns = strings.Split(origins, ",")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		window, err := parseReplayWindow(q, "replay", hub, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		initial, err := parseFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: originPatterns})
+		if err != nil {
+			logging.Debug("WebSocket handshake failed", "remote", r.RemoteAddr, "error", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		var current atomic.Pointer[filter]
+		current.Store(&initial)
+
+		since := time.Now().Add(-window)
+		if window == 0 {
+			since = time.Now()
+		}
+		replay, sub := hub.Subscribe(since)
+		defer sub.Cancel()
+
+		logging.Debug("WebSocket consumer connected", "remote", r.RemoteAddr)
+
+		// Read filter updates until the client goes away
+		go func() {
+			defer cancel()
+			for {
+				_, data, err := conn.Read(ctx)
+				if err != nil {
+					return
+				}
+				var update filter
+				if err := json.Unmarshal(data, &update); err != nil {
+					write(ctx, conn, wsMessage{Type: "error", Error: "invalid filter: " + err.Error()})
+					continue
+				}
+				if err := update.compile(); err != nil {
+					write(ctx, conn, wsMessage{Type: "error", Error: err.Error()})
+					continue
+				}
+				current.Store(&update)
+			}
+		}()
+
+		send := func(batch stream.Batch) error {
+			f := current.Load()
+			for _, record := range f.apply(batch) {
+				if err := write(ctx, conn, wsMessage{Type: "aircraft", Record: &record}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, batch := range replay {
+			if err := send(batch); err != nil {
+				return
+			}
+		}
+
+		for {
+			select {
+			case batch, ok := <-sub.C:
+				if !ok {
+					conn.Close(websocket.StatusPolicyViolation, "consumer fell behind")
+					return
+				}
+				if err := send(batch); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				conn.Close(websocket.StatusGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func write(ctx context.Context, conn *websocket.Conn, msg wsMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, conn, msg)
+}
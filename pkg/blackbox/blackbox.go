@@ -0,0 +1,141 @@
+package blackbox
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cycle summarizes a single fetch cycle
+type Cycle struct {
+	Start       time.Time     `json:"start"`
+	Duration    time.Duration `json:"duration_ns"`
+	Aircraft    int           `json:"aircraft"`
+	LogsEmitted int           `json:"logs_emitted"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// ring is a fixed size buffer that overwrites its oldest entries
+type ring[T any] struct {
+	mu      sync.Mutex
+	entries []T
+	next    int
+	full    bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{entries: make([]T, size)}
+}
+
+func (r *ring[T]) add(entry T) {
+	if len(r.entries) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// snapshot returns the entries oldest first
+func (r *ring[T]) snapshot() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]T(nil), r.entries[:r.next]...)
+	}
+	return append(append([]T(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+var (
+	logs    *ring[string]
+	cycles  *ring[Cycle]
+	started = time.Now()
+	once    sync.Once
+)
+
+func initBuffers() {
+	once.Do(func() {
+		logs = newRing[string](getEnvInt("BLACKBOX_LOG_LINES", 1000))
+		cycles = newRing[Cycle](getEnvInt("BLACKBOX_CYCLES", 100))
+	})
+}
+
+// logWriter captures log output line by line
+type logWriter struct {
+	mu      sync.Mutex
+	partial []byte
+}
+
+// LogWriter returns a writer that keeps the most recent log lines
+// Attach it alongside the regular log output
+func LogWriter() io.Writer {
+	initBuffers()
+	return &logWriter{}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		logs.add(string(data[:i]))
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial[:0], data...)
+	return len(p), nil
+}
+
+// RecordCycle keeps the summary of a completed fetch cycle
+func RecordCycle(c Cycle) {
+	initBuffers()
+	cycles.add(c)
+}
+
+// Logs returns the buffered log lines, oldest first
+func Logs() []string {
+	initBuffers()
+	return logs.snapshot()
+}
+
+// Cycles returns the buffered cycle summaries, oldest first
+func Cycles() []Cycle {
+	initBuffers()
+	return cycles.snapshot()
+}
+
+// LastCycle returns the summary of the most recent fetch cycle
+func LastCycle() (Cycle, bool) {
+	all := Cycles()
+	if len(all) == 0 {
+		return Cycle{}, false
+	}
+	return all[len(all)-1], true
+}
+
+// Started returns the time the process started
+func Started() time.Time {
+	return started
+}
+
+// getEnvInt returns a non-negative integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// BundleVersion is the format version written to exported bundles
+const BundleVersion = 1
+
+// Redacted replaces the value of sensitive variables in exported bundles
+const Redacted = "[REDACTED]"
+
+// Prefixes selects the environment variables that make up the configuration
+var Prefixes = []string{
+	"FLIGHT_DATA_", "SATELLITE_", "OTEL_", "LOG_LEVEL", "HEALTH_", "DNS_CACHE_", "EXPORT_", "GHOST_",
+	"CLICKHOUSE_", "INFLUXDB_", "POSTGRES_", "ARCHIVE_", "NATS_", "ALERTMANAGER_", "SINK_ROUTES", "SINK_FILTER_", "MUTED_SECTORS", "API_", "STREAM_",
+	"K8S_", "BLACKBOX_", "LOGBOOK_", "INSTANCE_", "LOGS_", "AIRCRAFT_", "MIN_RSSI", "MIN_MESSAGES", "ROUTES_", "ANOMALY_", "AIRPORT", "METAR_", "MLAT_", "STATS_", "TRACKS_", "LOW_RESOURCE", "LOKI_", "SINKS", "STARTUP_", "RECORD_", "RECEIVERS", "ACARS_", "AIS_", "OGN_", "REMOTEID_", "RAW_", "COMPARE_", "HTTP_",
+}
+
+// Bundle is a portable snapshot of a deployment's configuration. It holds no
+// tracker or statistics state: that is kept in memory only and rebuilt from
+// the receiver after a move, so there is nothing on disk to carry over
+type Bundle struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Hostname   string            `json:"hostname,omitempty"`
+	Config     map[string]string `json:"config"`
+}
+
+// Environ returns the configuration environment variables currently set
+func Environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if isConfigKey(key) {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// Export writes the effective configuration as a JSON bundle with secrets redacted
+func Export(w io.Writer) error {
+	bundle := Bundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Config:     EnvironRedacted(),
+	}
+	bundle.Hostname, _ = os.Hostname()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+// EnvironRedacted returns the configuration environment variables currently set,
+// with secrets redacted
+func EnvironRedacted() map[string]string {
+	env := Environ()
+	for key, value := range env {
+		env[key] = redact(key, value)
+	}
+	return env
+}
+
+// Import reads a bundle and merges its configuration into the .env file at
+// envPath, creating it if necessary. Redacted values are not written, and
+// their keys are returned so the caller can ask for them to be filled in
+// unless the file already has a value for them
+func Import(r io.Reader, envPath string) ([]string, error) {
+	var bundle Bundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+
+	env := make(map[string]string)
+	if _, err := os.Stat(envPath); err == nil {
+		existing, err := godotenv.Read(envPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", envPath, err)
+		}
+		env = existing
+	}
+
+	var missing []string
+	for key, value := range bundle.Config {
+		if !isConfigKey(key) {
+			continue
+		}
+		if value == Redacted {
+			if _, ok := env[key]; !ok {
+				missing = append(missing, key)
+			}
+			continue
+		}
+		env[key] = value
+	}
+	sort.Strings(missing)
+
+	if err := godotenv.Write(env, envPath); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", envPath, err)
+	}
+	return missing, nil
+}
+
+// urlPassword matches a URL with a password, also inside lists of URLs such
+// as RECEIVERS
+var urlPassword = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^/?#@\s,]*:[^/?#@\s,]*@`)
+
+// redact hides secrets in a configuration value, including headers and
+// passwords embedded in connection URLs
+func redact(key, value string) string {
+	if logging.IsSensitiveEnvVar(key) || strings.HasSuffix(key, "_HEADERS") {
+		return Redacted
+	}
+	if urlPassword.MatchString(value) {
+		return Redacted
+	}
+	return value
+}
+
+func isConfigKey(key string) bool {
+	for _, prefix := range Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// features maps the optional features to the variables enabling them; a
+// feature is enabled if any of its variables is set, or true for flags
+var features = []struct {
+	name string
+	keys []string
+	flag bool
+}{
+	{"tracing", []string{"OTEL_TRACING_ENABLED"}, true},
+	{"metrics", []string{"OTEL_METRICS_ENABLED"}, true},
+	{"aircraft_metrics", []string{"AIRCRAFT_METRICS"}, true},
+	{"low_resource", []string{"LOW_RESOURCE"}, true},
+	{"satellite", []string{"SATELLITE_DATA_URL"}, false},
+	{"receivers", []string{"RECEIVERS"}, false},
+	{"ogn", []string{"OGN_APRS_ADDR"}, false},
+	{"remote_id", []string{"REMOTEID_URL"}, false},
+	{"raw_input", []string{"RAW_INPUT_ADDR"}, false},
+	{"mlat", []string{"MLAT_DATA_URL"}, false},
+	{"stats", []string{"STATS_URL"}, false},
+	{"coverage", []string{"COMPARE_URL"}, false},
+	{"routes", []string{"ROUTES_FILE", "ROUTES_API_URL"}, false},
+	{"airports", []string{"AIRPORTS_FILE"}, false},
+	{"weather", []string{"METAR_AIRPORTS"}, false},
+	{"acars", []string{"ACARS_LISTEN"}, false},
+	{"ais", []string{"AIS_LISTEN"}, false},
+	{"watchlist", []string{"AIRCRAFT_WATCHLIST"}, false},
+	{"muted_sectors", []string{"MUTED_SECTORS"}, false},
+	{"api", []string{"API_ADDR"}, false},
+	{"recording", []string{"RECORD_DIR"}, false},
+	{"clickhouse", []string{"CLICKHOUSE_DSN"}, false},
+	{"influxdb", []string{"INFLUXDB_URL"}, false},
+	{"postgres", []string{"POSTGRES_DSN"}, false},
+	{"parquet", []string{"ARCHIVE_BUCKET"}, false},
+	{"nats", []string{"NATS_URL"}, false},
+	{"alertmanager", []string{"ALERTMANAGER_URL"}, false},
+	{"logbook", []string{"LOGBOOK_DIR"}, false},
+	{"loki", []string{"LOKI_URL"}, false},
+}
+
+// Features returns the names of the optional features that are enabled,
+// sorted, so the telemetry of a feeder tells how it is configured
+func Features() []string {
+	var enabled []string
+	if strings.EqualFold(os.Getenv("EXPORT_MODE"), "delta") {
+		enabled = append(enabled, "delta")
+	}
+	for _, f := range features {
+		for _, key := range f.keys {
+			value := strings.TrimSpace(os.Getenv(key))
+			if f.flag && isTrue(value) || !f.flag && value != "" {
+				enabled = append(enabled, f.name)
+				break
+			}
+		}
+	}
+	slices.Sort(enabled)
+	return enabled
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "1", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// lowResourceDefaults are the settings LOW_RESOURCE applies to variables that
+// are not set, trading throughput and history for memory on small devices
+var lowResourceDefaults = map[string]string{
+	// Emit only what changed, which allocates and sends far less per poll
+	"EXPORT_MODE": "delta",
+	// Small export queues and batches, shedding records rather than buffering
+	"EXPORT_QUEUE_SIZE":               "256",
+	"EXPORT_MEMORY_LIMIT_MIB":         "100",
+	"OTEL_BLRP_MAX_EXPORT_BATCH_SIZE": "64",
+	"OTEL_BSP_MAX_QUEUE_SIZE":         "256",
+	"OTEL_BSP_MAX_EXPORT_BATCH_SIZE":  "64",
+	"CLICKHOUSE_BATCH_SIZE":           "100",
+	"INFLUXDB_BATCH_SIZE":             "500",
+	"POSTGRES_BATCH_SIZE":             "100",
+	"ARCHIVE_BATCH_SIZE":              "10000",
+	"LOKI_BATCH_SIZE":                 "100",
+	// Short in-memory histories
+	"STREAM_BUFFER_WINDOW":      "2m",
+	"STREAM_BUFFER_MAX_RECORDS": "10000",
+	"TRACKS_MAX_POINTS":         "200",
+}
+
+// lowResourceCaches are the variables enabling enrichment that keeps large
+// tables or caches in memory, which LOW_RESOURCE turns off
+var lowResourceCaches = []string{"ROUTES_FILE", "ROUTES_AIRLINES_FILE", "ROUTES_API_URL"}
+
+// lowResourceGCPercent is the garbage collection target used unless GOGC is set
+const lowResourceGCPercent = 50
+
+// LowResource reports whether LOW_RESOURCE is enabled
+func LowResource() bool {
+	return isTrue(strings.TrimSpace(os.Getenv("LOW_RESOURCE")))
+}
+
+// ApplyLowResource prepares the service for devices such as a Raspberry Pi
+// Zero if LOW_RESOURCE is enabled: it fills in the low resource settings for
+// variables that are not set, turns off enrichment caches and lowers the
+// garbage collection target
+// It returns the enrichment variables that were set and have been cleared
+func ApplyLowResource() []string {
+	if !LowResource() {
+		return nil
+	}
+	for key, value := range lowResourceDefaults {
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
+		}
+	}
+	var cleared []string
+	for _, key := range lowResourceCaches {
+		if os.Getenv(key) != "" {
+			cleared = append(cleared, key)
+		}
+		os.Unsetenv(key)
+	}
+	if os.Getenv("GOGC") == "" {
+		debug.SetGCPercent(lowResourceGCPercent)
+	}
+	return cleared
+}
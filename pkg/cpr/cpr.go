@@ -0,0 +1,360 @@
+package cpr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+)
+
+const (
+	// cprMax is 2^17, the resolution of the encoded latitude/longitude fields
+	cprMax = 131072.0
+	nz     = 15.0
+
+	// Maximum age difference between an even/odd pair for a global decode
+	airborneGlobalWindow = 10 * time.Second
+	surfaceGlobalWindow  = 25 * time.Second
+
+	// How long a decoded position stays usable as a local decode reference
+	referenceMaxAge = 2 * time.Minute
+
+	// Local decoding is only unambiguous within half a zone of the reference
+	airborneLocalRangeNM = 180
+	surfaceLocalRangeNM  = 45
+)
+
+var (
+	// ErrInsufficientData means there is not yet enough information to decode a position
+	ErrInsufficientData = errors.New("insufficient CPR data to decode position")
+	// ErrZoneMismatch means the even and odd frames straddle a longitude zone boundary
+	ErrZoneMismatch = errors.New("even and odd frames are in different longitude zones")
+	// ErrImpossibleDecode means the decoded position fails a plausibility check
+	ErrImpossibleDecode = errors.New("impossible CPR decode")
+)
+
+// Frame is a single CPR-encoded position from an airborne or surface position message
+type Frame struct {
+	LatCPR  uint32
+	LonCPR  uint32
+	Odd     bool
+	Surface bool
+	Time    time.Time
+}
+
+// NL returns the number of longitude zones for the given latitude
+func NL(lat float64) int {
+	lat = math.Abs(lat)
+	if lat == 0 {
+		return 59
+	}
+	if lat == 87 {
+		return 2
+	}
+	if lat > 87 {
+		return 1
+	}
+
+	a := 1 - math.Cos(math.Pi/(2*nz))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/b)))
+}
+
+// DecodeGlobal resolves an even/odd frame pair into a position
+// Surface frames are ambiguous by 90 degrees and require a reference position
+// to choose the correct quadrant; airborne frames ignore the reference
+func DecodeGlobal(even, odd Frame, ref *geo.Position) (geo.Position, error) {
+	if even.Odd || !odd.Odd {
+		return geo.Position{}, fmt.Errorf("global decode requires one even and one odd frame")
+	}
+	if even.Surface != odd.Surface {
+		return geo.Position{}, fmt.Errorf("cannot mix surface and airborne frames")
+	}
+
+	surface := even.Surface
+	if surface && ref == nil {
+		return geo.Position{}, fmt.Errorf("surface global decode requires a reference position: %w", ErrInsufficientData)
+	}
+
+	span := 360.0
+	if surface {
+		span = 90.0
+	}
+	dLatEven := span / 60
+	dLatOdd := span / 59
+
+	latE := float64(even.LatCPR) / cprMax
+	lonE := float64(even.LonCPR) / cprMax
+	latO := float64(odd.LatCPR) / cprMax
+	lonO := float64(odd.LonCPR) / cprMax
+
+	j := math.Floor(59*latE - 60*latO + 0.5)
+	latEven := dLatEven * (mod(j, 60) + latE)
+	latOdd := dLatOdd * (mod(j, 59) + latO)
+
+	if surface {
+		// Surface latitudes are ambiguous between the northern and southern hemisphere
+		latEven = closestSurfaceLat(latEven, ref.Lat)
+		latOdd = closestSurfaceLat(latOdd, ref.Lat)
+	} else {
+		if latEven >= 270 {
+			latEven -= 360
+		}
+		if latOdd >= 270 {
+			latOdd -= 360
+		}
+	}
+
+	if latEven < -90 || latEven > 90 || latOdd < -90 || latOdd > 90 {
+		return geo.Position{}, fmt.Errorf("latitude out of range: %w", ErrImpossibleDecode)
+	}
+
+	nlEven := NL(latEven)
+	if nlEven != NL(latOdd) {
+		return geo.Position{}, ErrZoneMismatch
+	}
+
+	// Use the most recent frame to determine the final position
+	lat := latEven
+	lonCPR := lonE
+	ni := nlEven
+	if odd.Time.After(even.Time) {
+		lat = latOdd
+		lonCPR = lonO
+		ni = nlEven - 1
+	}
+	if ni < 1 {
+		ni = 1
+	}
+
+	m := math.Floor(lonE*float64(nlEven-1) - lonO*float64(nlEven) + 0.5)
+	lon := (span / float64(ni)) * (mod(m, float64(ni)) + lonCPR)
+
+	if surface {
+		lon = closestSurfaceLon(lon, ref.Lon)
+	} else if lon >= 180 {
+		lon -= 360
+	}
+
+	return geo.Position{Lat: lat, Lon: normalizeLon(lon)}, nil
+}
+
+// DecodeLocal resolves a single frame into a position using a nearby reference,
+// either a recent position of the same aircraft or the receiver location
+func DecodeLocal(f Frame, ref geo.Position) (geo.Position, error) {
+	span := 360.0
+	if f.Surface {
+		span = 90.0
+	}
+
+	dLat := span / 60
+	if f.Odd {
+		dLat = span / 59
+	}
+
+	latCPR := float64(f.LatCPR) / cprMax
+	lonCPR := float64(f.LonCPR) / cprMax
+
+	j := math.Floor(ref.Lat/dLat) + math.Floor(0.5+mod(ref.Lat, dLat)/dLat-latCPR)
+	lat := dLat * (j + latCPR)
+	if lat < -90 || lat > 90 {
+		return geo.Position{}, fmt.Errorf("latitude out of range: %w", ErrImpossibleDecode)
+	}
+
+	ni := NL(lat)
+	if f.Odd {
+		ni--
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	dLon := span / float64(ni)
+
+	m := math.Floor(ref.Lon/dLon) + math.Floor(0.5+mod(ref.Lon, dLon)/dLon-lonCPR)
+	lon := dLon * (m + lonCPR)
+
+	return geo.Position{Lat: lat, Lon: normalizeLon(lon)}, nil
+}
+
+// Decoder tracks recent frames per aircraft and turns them into validated positions
+// Global decoding is used until a position is known, after which frames are
+// decoded locally relative to the last good position
+type Decoder struct {
+	mu         sync.Mutex
+	receiver   *geo.Position
+	maxRangeNM float64
+	aircraft   map[string]*aircraftState
+}
+
+type aircraftState struct {
+	even    *Frame
+	odd     *Frame
+	last    geo.Position
+	lastAt  time.Time
+	hasLast bool
+}
+
+// NewDecoder creates a decoder that rejects positions further than maxRangeNM
+// from the receiver. A nil receiver disables the range check and surface
+// decoding until a reference position is known.
+func NewDecoder(receiver *geo.Position, maxRangeNM float64) *Decoder {
+	return &Decoder{
+		receiver:   receiver,
+		maxRangeNM: maxRangeNM,
+		aircraft:   make(map[string]*aircraftState),
+	}
+}
+
+// NewDecoderFromEnv creates a decoder using the receiver location and range
+// configured via RECEIVER_LAT, RECEIVER_LON and RECEIVER_MAX_RANGE_NM
+func NewDecoderFromEnv() *Decoder {
+	var receiver *geo.Position
+	if pos, ok := geo.Receiver(); ok {
+		receiver = &pos
+	}
+	return NewDecoder(receiver, geo.MaxRangeNM())
+}
+
+// Decode adds a frame for the given aircraft and returns the decoded position
+// Returns ErrInsufficientData while waiting for a matching frame, and an error
+// wrapping ErrImpossibleDecode if the result fails validation; such positions
+// must not be exported
+func (d *Decoder) Decode(hex string, f Frame) (geo.Position, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.aircraft[hex]
+	if !ok {
+		state = &aircraftState{}
+		d.aircraft[hex] = state
+	}
+
+	frame := f
+	if f.Odd {
+		state.odd = &frame
+	} else {
+		state.even = &frame
+	}
+
+	pos, ref, err := d.decode(state, f)
+	if err != nil {
+		return geo.Position{}, err
+	}
+
+	if err := d.validate(pos, ref, f.Surface); err != nil {
+		// Drop the reference so the next decode starts from a fresh global pair
+		state.hasLast = false
+		return geo.Position{}, err
+	}
+
+	state.last = pos
+	state.lastAt = f.Time
+	state.hasLast = true
+	return pos, nil
+}
+
+// Forget discards all state for an aircraft that is no longer being tracked
+func (d *Decoder) Forget(hex string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.aircraft, hex)
+}
+
+func (d *Decoder) decode(state *aircraftState, f Frame) (geo.Position, *geo.Position, error) {
+	// Prefer a local decode against the aircraft's own recent position
+	if state.hasLast && f.Time.Sub(state.lastAt) <= referenceMaxAge {
+		ref := state.last
+		pos, err := DecodeLocal(f, ref)
+		return pos, &ref, err
+	}
+
+	window := airborneGlobalWindow
+	if f.Surface {
+		window = surfaceGlobalWindow
+	}
+
+	if state.even != nil && state.odd != nil && state.even.Surface == state.odd.Surface {
+		age := state.even.Time.Sub(state.odd.Time)
+		if age < 0 {
+			age = -age
+		}
+		if age <= window {
+			pos, err := DecodeGlobal(*state.even, *state.odd, d.receiver)
+			return pos, nil, err
+		}
+	}
+
+	// Surface aircraft are always close to the receiver, so it is a safe reference
+	if f.Surface && d.receiver != nil {
+		ref := *d.receiver
+		pos, err := DecodeLocal(f, ref)
+		return pos, &ref, err
+	}
+
+	return geo.Position{}, nil, ErrInsufficientData
+}
+
+func (d *Decoder) validate(pos geo.Position, ref *geo.Position, surface bool) error {
+	if !pos.Valid() {
+		return fmt.Errorf("position %.5f,%.5f out of range: %w", pos.Lat, pos.Lon, ErrImpossibleDecode)
+	}
+
+	if ref != nil {
+		limit := float64(airborneLocalRangeNM)
+		if surface {
+			limit = surfaceLocalRangeNM
+		}
+		if dist := geo.DistanceNM(*ref, pos); dist > limit {
+			return fmt.Errorf("position %.1fnm from reference exceeds local decode limit of %.0fnm: %w", dist, limit, ErrImpossibleDecode)
+		}
+	}
+
+	if d.receiver != nil && d.maxRangeNM > 0 {
+		if dist := geo.DistanceNM(*d.receiver, pos); dist > d.maxRangeNM {
+			return fmt.Errorf("position %.1fnm from receiver exceeds maximum range of %.0fnm: %w", dist, d.maxRangeNM, ErrImpossibleDecode)
+		}
+	}
+
+	return nil
+}
+
+// closestSurfaceLat picks the hemisphere solution closest to the reference latitude
+func closestSurfaceLat(lat, refLat float64) float64 {
+	south := lat - 90
+	if math.Abs(south-refLat) < math.Abs(lat-refLat) {
+		return south
+	}
+	return lat
+}
+
+// closestSurfaceLon picks the one of four 90 degree solutions closest to the reference longitude
+func closestSurfaceLon(lon, refLon float64) float64 {
+	best := lon
+	bestDiff := math.Inf(1)
+	for k := 0; k < 4; k++ {
+		candidate := normalizeLon(lon + float64(k)*90)
+		diff := math.Abs(normalizeLon(candidate - refLon))
+		if diff < bestDiff {
+			best = candidate
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+func normalizeLon(lon float64) float64 {
+	lon = mod(lon+180, 360) - 180
+	return lon
+}
+
+// mod is a floored modulo that always returns a non-negative result
+func mod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if r < 0 {
+		r += b
+	}
+	return r
+}
@@ -0,0 +1,214 @@
+package cpr
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+)
+
+// The airborne position pair of aircraft 40621D from "The 1090MHz Riddle":
+// 8D40621D58C382D690C8AC2863A7 (even) and 8D40621D58C386435CC412692AD6 (odd)
+var (
+	riddleEven = Frame{LatCPR: 93000, LonCPR: 51372}
+	riddleOdd  = Frame{LatCPR: 74158, LonCPR: 50194, Odd: true}
+)
+
+// at returns a copy of the frame received at the given second
+func at(f Frame, sec int) Frame {
+	f.Time = time.Unix(1700000000+int64(sec), 0)
+	return f
+}
+
+// encode CPR-encodes a position the way transponders do, for round trips
+func encode(lat, lon float64, odd, surface bool) Frame {
+	span := 360.0
+	if surface {
+		span = 90
+	}
+	i := 0.0
+	if odd {
+		i = 1
+	}
+	dLat := span / (60 - i)
+	yz := math.Floor(cprMax*mod(lat, dLat)/dLat + 0.5)
+	rLat := dLat * (yz/cprMax + math.Floor(lat/dLat))
+	dLon := span / math.Max(float64(NL(rLat))-i, 1)
+	xz := math.Floor(cprMax*mod(lon, dLon)/dLon + 0.5)
+	return Frame{
+		LatCPR:  uint32(mod(yz, cprMax)),
+		LonCPR:  uint32(mod(xz, cprMax)),
+		Odd:     odd,
+		Surface: surface,
+	}
+}
+
+func near(t *testing.T, got geo.Position, lat, lon, tolerance float64) {
+	t.Helper()
+	if math.Abs(got.Lat-lat) > tolerance || math.Abs(got.Lon-lon) > tolerance {
+		t.Errorf("position = %.5f,%.5f, want %.5f,%.5f", got.Lat, got.Lon, lat, lon)
+	}
+}
+
+func TestNL(t *testing.T) {
+	tests := []struct {
+		lat  float64
+		want int
+	}{
+		{0, 59},
+		{10.4, 59},
+		{10.5, 58},
+		{-10.5, 58},
+		{52.2572, 36},
+		{86.5, 3},
+		{86.6, 2},
+		{87, 2},
+		{88, 1},
+	}
+	for _, tt := range tests {
+		if got := NL(tt.lat); got != tt.want {
+			t.Errorf("NL(%v) = %d, want %d", tt.lat, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeGlobal(t *testing.T) {
+	tests := []struct {
+		name     string
+		even     Frame
+		odd      Frame
+		lat, lon float64
+	}{
+		{"even newest", at(riddleEven, 1), at(riddleOdd, 0), 52.25720, 3.91937},
+		{"odd newest", at(riddleEven, 0), at(riddleOdd, 1), 52.26578, 3.93891},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := DecodeGlobal(tt.even, tt.odd, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			near(t, pos, tt.lat, tt.lon, 1e-5)
+		})
+	}
+}
+
+func TestDecodeGlobalSurface(t *testing.T) {
+	// A surface position pair is ambiguous by 90 degrees, resolved by the
+	// reference, here in the southern and western hemispheres
+	lat, lon := -33.9461, -70.7858
+	even := at(encode(lat, lon, false, true), 0)
+	odd := at(encode(lat, lon, true, true), 1)
+
+	if _, err := DecodeGlobal(even, odd, nil); !errors.Is(err, ErrInsufficientData) {
+		t.Fatalf("err = %v, want ErrInsufficientData without a reference", err)
+	}
+	pos, err := DecodeGlobal(even, odd, &geo.Position{Lat: -33.4, Lon: -70.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, lat, lon, 1e-4)
+}
+
+func TestDecodeGlobalErrors(t *testing.T) {
+	if _, err := DecodeGlobal(riddleOdd, riddleOdd, nil); err == nil {
+		t.Error("two odd frames decoded")
+	}
+	surface := riddleEven
+	surface.Surface = true
+	if _, err := DecodeGlobal(surface, riddleOdd, nil); err == nil {
+		t.Error("mixed surface and airborne frames decoded")
+	}
+
+	// Frames either side of a zone boundary, at 10.4704713 degrees
+	even := at(encode(10.46, 20, false, false), 0)
+	odd := at(encode(10.48, 20, true, false), 1)
+	if _, err := DecodeGlobal(even, odd, nil); !errors.Is(err, ErrZoneMismatch) {
+		t.Errorf("err = %v, want ErrZoneMismatch", err)
+	}
+}
+
+func TestDecodeLocal(t *testing.T) {
+	pos, err := DecodeLocal(riddleEven, geo.Position{Lat: 52.258, Lon: 3.918})
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 52.25720, 3.91937, 1e-5)
+
+	pos, err = DecodeLocal(encode(40.6413, -73.7781, true, true), geo.Position{Lat: 40.7, Lon: -73.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 40.6413, -73.7781, 1e-4)
+}
+
+func TestDecoder(t *testing.T) {
+	receiver := geo.Position{Lat: 52.3, Lon: 4.76}
+	d := NewDecoder(&receiver, 300)
+
+	if _, err := d.Decode("40621d", at(riddleOdd, 0)); !errors.Is(err, ErrInsufficientData) {
+		t.Fatalf("err = %v, want ErrInsufficientData for a single airborne frame", err)
+	}
+	pos, err := d.Decode("40621d", at(riddleEven, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 52.25720, 3.91937, 1e-5)
+
+	// Once a position is known, single frames are decoded locally
+	pos, err = d.Decode("40621d", at(riddleOdd, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 52.26578, 3.93891, 1e-5)
+
+	// Frames too old to pair are not decoded globally
+	d.Forget("40621d")
+	d.Decode("40621d", at(riddleOdd, 0))
+	if _, err := d.Decode("40621d", at(riddleEven, 30)); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("err = %v, want ErrInsufficientData for frames 30s apart", err)
+	}
+}
+
+func TestDecoderTooFarFromReceiver(t *testing.T) {
+	// The Riddle pair decodes over the Netherlands, about 500nm from this
+	// receiver in Scotland
+	receiver := geo.Position{Lat: 55.95, Lon: -3.37}
+	d := NewDecoder(&receiver, 250)
+
+	d.Decode("40621d", at(riddleOdd, 0))
+	_, err := d.Decode("40621d", at(riddleEven, 1))
+	if !errors.Is(err, ErrImpossibleDecode) {
+		t.Fatalf("err = %v, want ErrImpossibleDecode", err)
+	}
+
+	// The rejected position is not used as a reference for the next frame
+	if _, err := d.Decode("40621d", at(riddleOdd, 2)); !errors.Is(err, ErrImpossibleDecode) {
+		t.Errorf("err = %v, want ErrImpossibleDecode from a fresh global decode", err)
+	}
+
+	// Without a receiver there is no range check
+	pos, err := func() (geo.Position, error) {
+		d := NewDecoder(nil, 250)
+		d.Decode("40621d", at(riddleOdd, 0))
+		return d.Decode("40621d", at(riddleEven, 1))
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 52.25720, 3.91937, 1e-5)
+}
+
+func TestDecoderSurface(t *testing.T) {
+	receiver := geo.Position{Lat: 51.47, Lon: -0.45}
+	d := NewDecoder(&receiver, 250)
+
+	// A single surface frame is decoded locally against the receiver
+	pos, err := d.Decode("4ca7b4", at(encode(51.4775, -0.4614, false, true), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, pos, 51.4775, -0.4614, 1e-4)
+}
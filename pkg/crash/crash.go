@@ -0,0 +1,109 @@
+package crash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/config"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+)
+
+// exitCode is used when the process exits after a panic, matching the Go runtime
+const exitCode = 2
+
+// LastRecord describes the record that was being processed most recently
+type LastRecord struct {
+	Hex  string
+	Time time.Time
+}
+
+var lastRecord atomic.Pointer[LastRecord]
+
+// SetLastRecord remembers the record currently being processed so a crash
+// report can point at the input that triggered it
+func SetLastRecord(hex string, t time.Time) {
+	lastRecord.Store(&LastRecord{Hex: hex, Time: t})
+}
+
+// Recover reports a panic in the calling goroutine and exits the process
+// It must be deferred directly, e.g. defer crash.Recover("fetch_loop")
+func Recover(component string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	report(component, r, debug.Stack())
+	os.Exit(exitCode)
+}
+
+// report logs the crash locally and emits it as an OpenTelemetry log record,
+// flushing the exporter since deferred shutdowns won't run after exiting
+func report(component string, value any, stack []byte) {
+	configHash := ConfigHash()
+	last := lastRecord.Load()
+
+	args := []interface{}{"component", component, "panic", fmt.Sprint(value), "config_hash", configHash, "stack", string(stack)}
+	if last != nil {
+		args = append(args, "last_record_hex", last.Hex, "last_record_time", last.Time)
+	}
+	logging.Error("Panic recovered, exiting", args...)
+
+	logger := logs.GetLogger("crash")
+	if logger == nil {
+		return
+	}
+
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityFatal)
+	record.SetSeverityText("FATAL")
+	record.SetBody(otellog.StringValue(fmt.Sprintf("panic in %s: %v", component, value)))
+	record.AddAttributes(
+		otellog.String("event.name", "adsb2otel.crash"),
+		otellog.String("crash.component", component),
+		otellog.String("exception.type", fmt.Sprintf("%T", value)),
+		otellog.String("exception.message", fmt.Sprint(value)),
+		otellog.String("exception.stacktrace", string(stack)),
+		otellog.String("config.hash", configHash),
+	)
+	if last != nil {
+		record.AddAttributes(
+			otellog.String("crash.last_record.hex", last.Hex),
+			otellog.Int64("crash.last_record.time", last.Time.Unix()),
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	logger.Emit(ctx, record)
+	if lp := logs.GetLoggerProvider(); lp != nil {
+		if err := lp.ForceFlush(ctx); err != nil {
+			logging.Error("Failed to flush crash report", "error", err)
+		}
+	}
+}
+
+// ConfigHash returns a short hash of the configuration environment variables
+// It identifies which configuration a crash happened with without revealing secrets
+func ConfigHash() string {
+	var entries []string
+	for key, value := range config.Environ() {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,247 @@
+package dedupe
+
+import (
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Mode controls what happens to aircraft detected as ghosts of another aircraft
+type Mode string
+
+const (
+	// ModeMerge drops ghosts after filling in the fields the primary aircraft
+	// lacks from them, and records their addresses as aliases on it
+	ModeMerge Mode = "merge"
+	// ModeFlag keeps ghosts but marks which aircraft they duplicate
+	ModeFlag Mode = "flag"
+	// ModeOff disables ghost detection
+	ModeOff Mode = "off"
+)
+
+const (
+	defaultMaxDistanceNM = 1.0
+	defaultMaxAltDiffFt  = 500
+)
+
+// Result describes the ghosts found in a single poll
+type Result struct {
+	// Aircraft is the list to export, with ghosts merged into their primary
+	// aircraft in merge mode
+	Aircraft []models.Aircraft
+	// GhostOf is parallel to Aircraft and holds the primary hex an entry
+	// duplicates, or "" if it is not a ghost. Only populated in flag mode.
+	GhostOf []string
+	// Aliases maps a primary hex to the non-ICAO addresses found for it
+	Aliases map[string][]string
+	// Ghosts is the number of ghost entries detected
+	Ghosts int
+}
+
+// GetMode returns the ghost handling mode configured via GHOST_MERGE_MODE (default: off)
+func GetMode() Mode {
+	switch Mode(strings.ToLower(strings.TrimSpace(os.Getenv("GHOST_MERGE_MODE")))) {
+	case ModeMerge:
+		return ModeMerge
+	case ModeFlag:
+		return ModeFlag
+	default:
+		return ModeOff
+	}
+}
+
+// Primary returns the aircraft that are not ghosts of another, which are
+// the ones counted in metrics
+func (r Result) Primary() []models.Aircraft {
+	if r.Ghosts == 0 || len(r.GhostOf) == 0 {
+		return r.Aircraft
+	}
+	primary := make([]models.Aircraft, 0, len(r.Aircraft))
+	for i := range r.Aircraft {
+		if r.GhostOf[i] == "" {
+			primary = append(primary, r.Aircraft[i])
+		}
+	}
+	return primary
+}
+
+// MergeGhosts detects aircraft reported more than once in the same poll, either
+// under the same ICAO address or under a non-ICAO TIS-B/ADS-R address alongside
+// the aircraft's own ADS-B address, and merges or flags them according to mode
+func MergeGhosts(aircraft []models.Aircraft, mode Mode) Result {
+	result := Result{
+		Aircraft: aircraft,
+		GhostOf:  make([]string, len(aircraft)),
+		Aliases:  make(map[string][]string),
+	}
+	if mode == ModeOff || len(aircraft) < 2 {
+		return result
+	}
+
+	maxDistance := getEnvFloat("GHOST_MAX_DISTANCE_NM", defaultMaxDistanceNM)
+	maxAltDiff := getEnvFloat("GHOST_MAX_ALTITUDE_DIFF_FT", defaultMaxAltDiffFt)
+
+	// Index of the freshest entry for every ICAO address seen in this poll
+	primaries := make(map[string]int)
+	ghost := make([]bool, len(aircraft))
+	ghostOf := make([]string, len(aircraft))
+	// Index of the entry each ghost is merged into
+	into := make([]int, len(aircraft))
+
+	for i := range aircraft {
+		a := &aircraft[i]
+		if !isICAO(a) {
+			continue
+		}
+		hex := strings.ToLower(a.Hex)
+		if j, ok := primaries[hex]; ok {
+			// Duplicate ICAO address, keep whichever entry is fresher
+			if a.Seen < aircraft[j].Seen {
+				ghost[j] = true
+				ghostOf[j] = a.Hex
+				primaries[hex] = i
+				for k := range aircraft {
+					if ghost[k] && into[k] == j {
+						into[k] = i
+					}
+				}
+				into[j] = i
+			} else {
+				ghost[i] = true
+				ghostOf[i] = a.Hex
+				into[i] = j
+			}
+			result.Ghosts++
+			continue
+		}
+		primaries[hex] = i
+	}
+
+	for i := range aircraft {
+		a := &aircraft[i]
+		if isICAO(a) {
+			continue
+		}
+		for j := range aircraft {
+			if ghost[j] || !isICAO(&aircraft[j]) {
+				continue
+			}
+			if sameFlight(a, &aircraft[j], maxDistance, maxAltDiff) {
+				primaryHex := aircraft[j].Hex
+				ghost[i] = true
+				ghostOf[i] = primaryHex
+				into[i] = j
+				result.Aliases[primaryHex] = append(result.Aliases[primaryHex], a.Hex)
+				result.Ghosts++
+				break
+			}
+		}
+	}
+
+	if result.Ghosts == 0 {
+		return result
+	}
+
+	if mode == ModeFlag {
+		result.GhostOf = ghostOf
+		return result
+	}
+
+	// Merge into a copy, as the entries are in the caller's slice
+	kept := make([]models.Aircraft, len(aircraft))
+	copy(kept, aircraft)
+	for i := range aircraft {
+		if ghost[i] {
+			fill(&kept[into[i]], &aircraft[i])
+		}
+	}
+	merged := make([]models.Aircraft, 0, len(aircraft)-result.Ghosts)
+	for i := range kept {
+		if !ghost[i] {
+			merged = append(merged, kept[i])
+		}
+	}
+	result.Aircraft = merged
+	result.GhostOf = make([]string, len(merged))
+	return result
+}
+
+// fill fills in the fields the primary aircraft lacks from its ghost, such
+// as the callsign of a TIS-B track, taking the ghost's position only when
+// the primary has none
+func fill(primary, ghost *models.Aircraft) {
+	if !primary.HasPosition() && ghost.HasPosition() {
+		primary.Lat, primary.Lon, primary.SeenPos = ghost.Lat, ghost.Lon, ghost.SeenPos
+		primary.Nic, primary.Rc = ghost.Nic, ghost.Rc
+		// Keep the position attributed to the source the ghost had it from
+		switch ghost.PositionSource() {
+		case models.PositionMLAT:
+			primary.Mlat = append(slices.Clip(primary.Mlat), "lat", "lon")
+		case models.PositionTISB:
+			primary.Tisb = append(slices.Clip(primary.Tisb), "lat", "lon")
+		}
+	}
+	primary.FillFrom(ghost)
+}
+
+// isICAO reports whether the aircraft is identified by a real 24-bit ICAO address
+// readsb prefixes non-ICAO (TIS-B track file, anonymous) addresses with '~'
+func isICAO(a *models.Aircraft) bool {
+	if strings.HasPrefix(a.Hex, "~") {
+		return false
+	}
+	switch a.Type {
+	case "tisb_trackfile", "tisb_other", "adsb_other", "adsr_other":
+		return false
+	}
+	return true
+}
+
+// sameFlight decides whether a non-ICAO entry describes the same physical
+// aircraft as an ICAO entry, using the callsign where both have one and
+// otherwise position, altitude and squawk proximity
+func sameFlight(ghost, primary *models.Aircraft, maxDistanceNM, maxAltDiffFt float64) bool {
+	ghostFlight := strings.TrimSpace(ghost.Flight)
+	primaryFlight := strings.TrimSpace(primary.Flight)
+	if ghostFlight != "" && primaryFlight != "" {
+		return strings.EqualFold(ghostFlight, primaryFlight)
+	}
+
+	if ghost.Squawk != "" && primary.Squawk != "" && ghost.Squawk != primary.Squawk {
+		return false
+	}
+
+	ghostPos, ok := ghost.Position()
+	if !ok {
+		return false
+	}
+	primaryPos, ok := primary.Position()
+	if !ok {
+		return false
+	}
+	if geo.DistanceNM(ghostPos, primaryPos) > maxDistanceNM {
+		return false
+	}
+
+	ghostAlt, ghostHasAlt := ghost.AltitudeFeet()
+	primaryAlt, primaryHasAlt := primary.AltitudeFeet()
+	if ghostHasAlt && primaryHasAlt && math.Abs(float64(ghostAlt-primaryAlt)) > maxAltDiffFt {
+		return false
+	}
+
+	return true
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,126 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// sameAddress is an aircraft reported twice under its ICAO address, the
+// stale entry carrying the callsign and the fresh one the position
+func sameAddress() []models.Aircraft {
+	return []models.Aircraft{
+		{Hex: "4ca7b4", Type: "adsb_icao", Flight: "EIN12B", Seen: 8},
+		{Hex: "4CA7B4", Type: "adsb_icao", Seen: 0.5, Lat: ptr(53.42), Lon: ptr(-6.27), AltBaro: &models.Altitude{Feet: 12000}},
+		{Hex: "a1b2c3", Type: "adsb_icao", Seen: 1},
+	}
+}
+
+// tisbPair is an aircraft reported under its own address without a
+// position, and as a TIS-B track with the same callsign and a position
+func tisbPair() []models.Aircraft {
+	return []models.Aircraft{
+		{Hex: "a1b2c3", Type: "adsb_icao", Flight: "N123AB ", Seen: 1, Squawk: "1200"},
+		{Hex: "~2b1a0c", Type: "tisb_other", Flight: "N123AB", Seen: 2, Lat: ptr(40.1), Lon: ptr(-74.2), Category: "A1"},
+	}
+}
+
+func TestMergeGhosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		aircraft  func() []models.Aircraft
+		mode      Mode
+		ghosts    int
+		exported  []string
+		ghostOf   []string
+		primary   int
+		aliases   map[string][]string
+		checkKept func(t *testing.T, a models.Aircraft)
+	}{
+		{
+			name: "same address off", aircraft: sameAddress, mode: ModeOff,
+			ghosts: 0, exported: []string{"4ca7b4", "4CA7B4", "a1b2c3"}, ghostOf: []string{"", "", ""}, primary: 3,
+		},
+		{
+			name: "same address flag", aircraft: sameAddress, mode: ModeFlag,
+			ghosts: 1, exported: []string{"4ca7b4", "4CA7B4", "a1b2c3"}, ghostOf: []string{"4CA7B4", "", ""}, primary: 2,
+		},
+		{
+			name: "same address merge", aircraft: sameAddress, mode: ModeMerge,
+			ghosts: 1, exported: []string{"4CA7B4", "a1b2c3"}, ghostOf: []string{"", ""}, primary: 2,
+			checkKept: func(t *testing.T, a models.Aircraft) {
+				if a.Seen != 0.5 || a.Lat == nil || a.Flight != "EIN12B" {
+					t.Errorf("merged entry: seen %v, lat %v, flight %q", a.Seen, a.Lat, a.Flight)
+				}
+			},
+		},
+		{
+			name: "tisb pair off", aircraft: tisbPair, mode: ModeOff,
+			ghosts: 0, exported: []string{"a1b2c3", "~2b1a0c"}, ghostOf: []string{"", ""}, primary: 2,
+		},
+		{
+			name: "tisb pair flag", aircraft: tisbPair, mode: ModeFlag,
+			ghosts: 1, exported: []string{"a1b2c3", "~2b1a0c"}, ghostOf: []string{"", "a1b2c3"}, primary: 1,
+			aliases: map[string][]string{"a1b2c3": {"~2b1a0c"}},
+		},
+		{
+			name: "tisb pair merge", aircraft: tisbPair, mode: ModeMerge,
+			ghosts: 1, exported: []string{"a1b2c3"}, ghostOf: []string{""}, primary: 1,
+			aliases: map[string][]string{"a1b2c3": {"~2b1a0c"}},
+			checkKept: func(t *testing.T, a models.Aircraft) {
+				if a.Type != "adsb_icao" || a.Flight != "N123AB " || a.Squawk != "1200" || a.Category != "A1" {
+					t.Errorf("merged entry: type %q, flight %q, squawk %q, category %q", a.Type, a.Flight, a.Squawk, a.Category)
+				}
+				if a.Lat == nil || *a.Lat != 40.1 || a.PositionSource() != models.PositionTISB {
+					t.Errorf("merged position %v from %q", a.Lat, a.PositionSource())
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.aircraft()
+			result := MergeGhosts(input, tt.mode)
+
+			if result.Ghosts != tt.ghosts {
+				t.Errorf("ghosts = %d, want %d", result.Ghosts, tt.ghosts)
+			}
+			if len(result.Aircraft) != len(tt.exported) || len(result.GhostOf) != len(tt.exported) {
+				t.Fatalf("%d aircraft, %d ghost_of, want %d", len(result.Aircraft), len(result.GhostOf), len(tt.exported))
+			}
+			for i, hex := range tt.exported {
+				if result.Aircraft[i].Hex != hex || result.GhostOf[i] != tt.ghostOf[i] {
+					t.Errorf("aircraft %d = %q ghost of %q, want %q ghost of %q", i, result.Aircraft[i].Hex, result.GhostOf[i], hex, tt.ghostOf[i])
+				}
+			}
+			if got := len(result.Primary()); got != tt.primary {
+				t.Errorf("%d primary aircraft, want %d", got, tt.primary)
+			}
+			for hex, aliases := range tt.aliases {
+				if got := result.Aliases[hex]; len(got) != len(aliases) || got[0] != aliases[0] {
+					t.Errorf("aliases of %s = %v, want %v", hex, got, aliases)
+				}
+			}
+			if tt.checkKept != nil {
+				tt.checkKept(t, result.Aircraft[0])
+			}
+
+			// The caller's entries are left as they were
+			if fresh := tt.aircraft(); input[0].Flight != fresh[0].Flight || (input[0].Lat == nil) != (fresh[0].Lat == nil) {
+				t.Errorf("input modified: %+v", input[0])
+			}
+		})
+	}
+}
+
+func TestGetMode(t *testing.T) {
+	tests := map[string]Mode{"": ModeOff, "merge": ModeMerge, " Flag ": ModeFlag, "off": ModeOff, "drop": ModeOff}
+	for value, want := range tests {
+		t.Setenv("GHOST_MERGE_MODE", value)
+		if got := GetMode(); got != want {
+			t.Errorf("GHOST_MERGE_MODE=%q: %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL      = 5 * time.Minute
+	defaultStaleTTL = 1 * time.Hour
+)
+
+var (
+	globalResolver *Resolver
+	once           sync.Once
+)
+
+// Resolver caches host lookups in-process so that a momentary DNS outage on
+// the local network doesn't break every fetch and export cycle. Entries are
+// refreshed after TTL; if a refresh fails the previous addresses keep being
+// served for up to StaleTTL.
+type Resolver struct {
+	TTL      time.Duration
+	StaleTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	lookup  func(ctx context.Context, host string) ([]string, error)
+	dialer  *net.Dialer
+}
+
+type entry struct {
+	addrs     []string
+	resolved  time.Time
+	lastError error
+}
+
+// New creates a caching resolver using the system resolver for lookups
+func New(ttl, staleTTL time.Duration) *Resolver {
+	return &Resolver{
+		TTL:      ttl,
+		StaleTTL: staleTTL,
+		entries:  make(map[string]entry),
+		lookup:   net.DefaultResolver.LookupHost,
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+}
+
+// Get returns the resolver configured via DNS_CACHE_ENABLED, DNS_CACHE_TTL and
+// DNS_CACHE_STALE_TTL, or nil if DNS caching is disabled
+func Get() *Resolver {
+	once.Do(func() {
+		if !isTrue(os.Getenv("DNS_CACHE_ENABLED")) {
+			return
+		}
+		ttl := getEnvDuration("DNS_CACHE_TTL", defaultTTL)
+		staleTTL := getEnvDuration("DNS_CACHE_STALE_TTL", defaultStaleTTL)
+		globalResolver = New(ttl, staleTTL)
+		log.Printf("DNS cache enabled (ttl: %s, stale ttl: %s)", ttl, staleTTL)
+	})
+	return globalResolver
+}
+
+// LookupHost returns the addresses for host, from the cache when fresh
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+
+	key := strings.ToLower(host)
+	now := time.Now()
+
+	r.mu.Lock()
+	cached, ok := r.entries[key]
+	r.mu.Unlock()
+
+	if ok && now.Sub(cached.resolved) < r.TTL {
+		return cached.addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		// Serve stale addresses rather than failing while DNS is unavailable
+		if ok && now.Sub(cached.resolved) < r.StaleTTL {
+			r.mu.Lock()
+			if cached.lastError == nil {
+				log.Printf("DNS lookup for %s failed, serving cached addresses: %v", host, err)
+			}
+			cached.lastError = err
+			r.entries[key] = cached
+			r.mu.Unlock()
+			return cached.addrs, nil
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.entries[key] = entry{addrs: addrs, resolved: now}
+	r.mu.Unlock()
+
+	return addrs, nil
+}
+
+// DialContext dials addr using cached addresses, trying each in turn
+// It can be used as http.Transport.DialContext or a gRPC context dialer
+func (r *Resolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, ip := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// getEnvDuration parses a duration from an environment variable, falling back
+// to the default if it is unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %q for %s, using default %s", value, key, defaultValue)
+	}
+	return defaultValue
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}
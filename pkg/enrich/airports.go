@@ -0,0 +1,172 @@
+package enrich
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+)
+
+// bundledAirports is a subset of the OurAirports database covering major
+// international airports, used when no AIRPORTS_FILE is configured
+//
+//go:embed data/airports.csv
+var bundledAirports []byte
+
+// Airport is an airport from the OurAirports database
+type Airport struct {
+	// Ident is the ICAO code, or the OurAirports identifier for airports without one
+	Ident       string
+	IATA        string
+	Name        string
+	Country     string
+	Position    geo.Position
+	ElevationFt int
+}
+
+// Attributes returns the log attributes for an aircraft near the airport
+func (a Airport) Attributes(distanceNM float64) []otellog.KeyValue {
+	attrs := []otellog.KeyValue{
+		otellog.String("airport.ident", a.Ident),
+		otellog.String("airport.name", a.Name),
+		otellog.Float64("airport.distance_nm", math.Round(distanceNM*10)/10),
+	}
+	if a.IATA != "" {
+		attrs = append(attrs, otellog.String("airport.iata", a.IATA))
+	}
+	return attrs
+}
+
+// airportCell is a 1x1 degree cell of the airport index
+type airportCell struct {
+	lat, lon int
+}
+
+// AirportIndex finds airports near a position
+type AirportIndex struct {
+	cells map[airportCell][]Airport
+	count int
+}
+
+var (
+	airports     *AirportIndex
+	airportsOnce sync.Once
+)
+
+// Airports returns the airports loaded from AIRPORTS_FILE, or the bundled
+// airports if it is not set, limited to the types in AIRPORTS_TYPES
+// Returns nil if the airports could not be loaded
+func Airports() *AirportIndex {
+	airportsOnce.Do(func() {
+		index, err := loadAirports()
+		if err != nil {
+			log.Printf("Airport lookups disabled: %v", err)
+			return
+		}
+		airports = index
+	})
+	return airports
+}
+
+func loadAirports() (*AirportIndex, error) {
+	types := strings.Split(getEnvOrDefault("AIRPORTS_TYPES", "large_airport,medium_airport"), ",")
+	for i := range types {
+		types[i] = strings.TrimSpace(types[i])
+	}
+
+	columns := []string{"ident", "type", "name", "latitude_deg", "longitude_deg", "elevation_ft", "iso_country", "iata_code"}
+	var rows [][]string
+	var err error
+	path := os.Getenv("AIRPORTS_FILE")
+	if path != "" {
+		rows, err = readCSVFile(path, columns...)
+	} else {
+		rows, err = readCSV(bytes.NewReader(bundledAirports), columns...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load airports: %w", err)
+	}
+
+	index := &AirportIndex{cells: make(map[airportCell][]Airport)}
+	for _, row := range rows {
+		if !slices.Contains(types, row[1]) {
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(row[3], 64)
+		lon, errLon := strconv.ParseFloat(row[4], 64)
+		if errLat != nil || errLon != nil {
+			continue
+		}
+		elevation, _ := strconv.Atoi(row[5])
+		airport := Airport{
+			Ident:       row[0],
+			Name:        row[2],
+			Position:    geo.Position{Lat: lat, Lon: lon},
+			ElevationFt: elevation,
+			Country:     row[6],
+			IATA:        row[7],
+		}
+		cell := cellOf(airport.Position)
+		index.cells[cell] = append(index.cells[cell], airport)
+		index.count++
+	}
+
+	source := path
+	if source == "" {
+		source = "bundled airports"
+	}
+	log.Printf("Loaded %d airports from %s", index.count, source)
+	return index, nil
+}
+
+// Nearest returns the airport nearest to a position within maxNM
+func (x *AirportIndex) Nearest(pos geo.Position, maxNM float64) (Airport, float64, bool) {
+	// A degree of latitude is 60NM, a degree of longitude shrinks towards the poles
+	latCells := int(math.Ceil(maxNM / 60))
+	lonCells := 180
+	if cos := math.Cos(pos.Lat * math.Pi / 180); cos > 0.01 {
+		lonCells = min(int(math.Ceil(maxNM/(60*cos))), 180)
+	}
+
+	center := cellOf(pos)
+	var nearest Airport
+	best := math.Inf(1)
+	for dLat := -latCells; dLat <= latCells; dLat++ {
+		for dLon := -lonCells; dLon <= lonCells; dLon++ {
+			cell := airportCell{lat: center.lat + dLat, lon: wrapLon(center.lon + dLon)}
+			for _, airport := range x.cells[cell] {
+				if d := geo.DistanceNM(pos, airport.Position); d < best {
+					nearest, best = airport, d
+				}
+			}
+		}
+	}
+	return nearest, best, best <= maxNM
+}
+
+func cellOf(pos geo.Position) airportCell {
+	return airportCell{lat: int(math.Floor(pos.Lat)), lon: wrapLon(int(math.Floor(pos.Lon)))}
+}
+
+// wrapLon wraps a cell longitude into -180..179
+func wrapLon(lon int) int {
+	return ((lon+180)%360+360)%360 - 180
+}
+
+// getEnvOrDefault returns the value of an environment variable or the default if not set
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,225 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// metarEvent is the event emitted for each new weather observation
+const metarEvent = "weather.metar"
+
+// metar is an observation as returned by the aviationweather.gov data API
+type metar struct {
+	Station     string   `json:"icaoId"`
+	ObsTime     int64    `json:"obsTime"`
+	Raw         string   `json:"rawOb"`
+	Temperature *float64 `json:"temp"`
+	Dewpoint    *float64 `json:"dewp"`
+	// WindDirection is in degrees, or "VRB" for variable winds
+	WindDirection any      `json:"wdir"`
+	WindSpeed     *float64 `json:"wspd"`
+	WindGust      *float64 `json:"wgst"`
+	// Visibility is in statute miles, or a string such as "10+"
+	Visibility any `json:"visib"`
+	// Altimeter is the QNH in hPa
+	Altimeter *float64 `json:"altim"`
+}
+
+// attributes returns the log attributes for an observation
+func (m metar) attributes() []otellog.KeyValue {
+	attrs := []otellog.KeyValue{
+		otellog.String("weather.station", m.Station),
+		otellog.String("weather.metar", m.Raw),
+	}
+	switch dir := m.WindDirection.(type) {
+	case float64:
+		attrs = append(attrs, otellog.Int("weather.wind_direction", int(dir)))
+	case string:
+		if strings.EqualFold(dir, "VRB") {
+			attrs = append(attrs, otellog.Bool("weather.wind_variable", true))
+		}
+	}
+	if m.WindSpeed != nil {
+		attrs = append(attrs, otellog.Float64("weather.wind_speed_kt", *m.WindSpeed))
+	}
+	if m.WindGust != nil {
+		attrs = append(attrs, otellog.Float64("weather.wind_gust_kt", *m.WindGust))
+	}
+	if visibility, ok := parseVisibility(m.Visibility); ok {
+		attrs = append(attrs, otellog.Float64("weather.visibility_sm", visibility))
+	}
+	if m.Altimeter != nil {
+		attrs = append(attrs, otellog.Float64("weather.qnh_hpa", *m.Altimeter))
+	}
+	if m.Temperature != nil {
+		attrs = append(attrs, otellog.Float64("weather.temperature_c", *m.Temperature))
+	}
+	if m.Dewpoint != nil {
+		attrs = append(attrs, otellog.Float64("weather.dewpoint_c", *m.Dewpoint))
+	}
+	return attrs
+}
+
+// parseVisibility returns the visibility in statute miles, reading values
+// such as "10+" as their lower bound
+func parseVisibility(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimRight(strings.TrimSpace(v), "+"), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// metarPoller fetches METARs for the configured airports and emits each
+// new observation as a log event
+type metarPoller struct {
+	url      string
+	airports []string
+	interval time.Duration
+	client   *http.Client
+	// observed is the time of the last observation emitted per station
+	observed map[string]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// InitWeather starts fetching METARs for the airports in METAR_AIRPORTS,
+// emitting each new observation as a weather.metar log event so receiver
+// range can be correlated with the weather in the same backend
+// The returned function stops fetching
+func InitWeather() (func(), error) {
+	var airports []string
+	for _, airport := range strings.Split(os.Getenv("METAR_AIRPORTS"), ",") {
+		if airport = strings.ToUpper(strings.TrimSpace(airport)); airport != "" {
+			airports = append(airports, airport)
+		}
+	}
+	if len(airports) == 0 {
+		return func() {}, nil
+	}
+
+	p := &metarPoller{
+		url:      getEnvOrDefault("METAR_URL", "https://aviationweather.gov/api/data/metar"),
+		airports: airports,
+		interval: getEnvDuration("METAR_INTERVAL", 10*time.Minute),
+		client:   newHTTPClient(),
+		observed: make(map[string]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := url.Parse(p.url); err != nil {
+		return func() {}, fmt.Errorf("invalid METAR_URL: %w", err)
+	}
+
+	go p.run()
+
+	log.Printf("METAR fetching enabled (airports: %s, interval: %s)", strings.Join(airports, ","), p.interval)
+	return func() {
+		close(p.stop)
+		<-p.done
+	}, nil
+}
+
+func (p *metarPoller) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll()
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// poll fetches the latest METARs and emits those not emitted before
+func (p *metarPoller) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.client.Timeout)
+	defer cancel()
+
+	metars, err := p.fetch(ctx)
+	if err != nil {
+		logging.Warn("Failed to fetch METARs", "error", err)
+		return
+	}
+
+	logger := logs.GetLogger("weather")
+	for _, m := range metars {
+		if m.Station == "" || m.ObsTime <= p.observed[m.Station] {
+			continue
+		}
+		p.observed[m.Station] = m.ObsTime
+		logging.Debug("New METAR", "station", m.Station, "metar", m.Raw)
+		if logger == nil {
+			continue
+		}
+
+		record := otellog.Record{}
+		record.SetEventName(metarEvent)
+		record.SetTimestamp(time.Unix(m.ObsTime, 0))
+		record.SetObservedTimestamp(time.Now())
+		record.SetSeverity(otellog.SeverityInfo)
+		record.SetBody(otellog.StringValue(m.Raw))
+		record.AddAttributes(m.attributes()...)
+		logger.Emit(ctx, record)
+	}
+}
+
+func (p *metarPoller) fetch(ctx context.Context) ([]metar, error) {
+	u, err := url.Parse(p.url)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("ids", strings.Join(p.airports, ","))
+	query.Set("format", "json")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	useragent.Set(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// The API answers 204 when there are no observations for the airports
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("METAR API returned %s", resp.Status)
+	}
+
+	var metars []metar
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&metars); err != nil {
+		return nil, fmt.Errorf("failed to decode METARs: %w", err)
+	}
+	return metars, nil
+}
@@ -0,0 +1,497 @@
+// Package enrich adds information about aircraft and flights that the
+// receiver does not decode, such as the route a callsign is flying
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// Route is the scheduled route of a callsign
+type Route struct {
+	// AirlineCode is the ICAO code of the operating airline
+	AirlineCode string
+	// Airline is the name of the airline, if known
+	Airline string
+	// Origin and Destination are ICAO airport codes
+	Origin      string
+	Destination string
+}
+
+// Attributes returns the log attributes for a route
+func (r Route) Attributes() []otellog.KeyValue {
+	var attrs []otellog.KeyValue
+	if r.AirlineCode != "" {
+		attrs = append(attrs, otellog.String("flight.airline_code", r.AirlineCode))
+	}
+	if airline := r.Airline; airline != "" || r.AirlineCode != "" {
+		if airline == "" {
+			airline = r.AirlineCode
+		}
+		attrs = append(attrs, otellog.String("flight.airline", airline))
+	}
+	if r.Origin != "" {
+		attrs = append(attrs, otellog.String("flight.origin", r.Origin))
+	}
+	if r.Destination != "" {
+		attrs = append(attrs, otellog.String("flight.destination", r.Destination))
+	}
+	return attrs
+}
+
+// Results of route lookups against the API
+const (
+	lookupFound   = "found"
+	lookupUnknown = "unknown"
+	lookupError   = "error"
+)
+
+var (
+	meter = metrics.Meter("enrich")
+
+	routeLookups, _ = meter.Int64Counter("adsb2otel.routes.lookups",
+		metric.WithDescription("Callsigns looked up in the routes API, by result"),
+		metric.WithUnit("{callsign}"),
+	)
+
+	routesMu sync.RWMutex
+	routes   *routeResolver
+)
+
+// cachedRoute is a route, or the lack of one, returned by the API
+type cachedRoute struct {
+	route   Route
+	found   bool
+	expires time.Time
+}
+
+// position is where an aircraft was when its callsign was queued, so the
+// API can reject routes that are implausible for it
+type position struct {
+	lat, lon *float64
+}
+
+// routeResolver resolves callsigns from a local routes file and, for
+// callsigns not in it, a routes API queried in the background
+type routeResolver struct {
+	file     map[string]Route
+	airlines map[string]string
+
+	apiURL    string
+	client    *http.Client
+	ttl       time.Duration
+	interval  time.Duration
+	batchSize int
+
+	mu      sync.Mutex
+	cache   map[string]cachedRoute
+	pending map[string]position
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// InitRoutes loads the routes configured via ROUTES_FILE and ROUTES_AIRLINES_FILE
+// and, if ROUTES_API_URL is set, starts resolving unknown callsigns through
+// the API in the background
+// The returned function stops the lookups
+func InitRoutes() (func(), error) {
+	r := &routeResolver{
+		apiURL:    os.Getenv("ROUTES_API_URL"),
+		ttl:       getEnvDuration("ROUTES_CACHE_TTL", 24*time.Hour),
+		interval:  getEnvDuration("ROUTES_LOOKUP_INTERVAL", 10*time.Second),
+		batchSize: getEnvInt("ROUTES_BATCH_SIZE", 100),
+		cache:     make(map[string]cachedRoute),
+		pending:   make(map[string]position),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	var err error
+	if path := os.Getenv("ROUTES_FILE"); path != "" {
+		if r.file, err = loadRoutesFile(path); err != nil {
+			return func() {}, fmt.Errorf("failed to load ROUTES_FILE: %w", err)
+		}
+		log.Printf("Loaded %d routes from %s", len(r.file), path)
+	}
+	if path := os.Getenv("ROUTES_AIRLINES_FILE"); path != "" {
+		if r.airlines, err = loadAirlinesFile(path); err != nil {
+			return func() {}, fmt.Errorf("failed to load ROUTES_AIRLINES_FILE: %w", err)
+		}
+		log.Printf("Loaded %d airlines from %s", len(r.airlines), path)
+	}
+	if r.file == nil && r.apiURL == "" {
+		return func() {}, nil
+	}
+
+	if r.apiURL != "" {
+		r.client = newHTTPClient()
+		go r.run()
+		log.Printf("Route lookups enabled (url: %s, cache ttl: %s)", r.apiURL, r.ttl)
+	} else {
+		close(r.done)
+	}
+
+	routesMu.Lock()
+	routes = r
+	routesMu.Unlock()
+
+	return func() {
+		routesMu.Lock()
+		routes = nil
+		routesMu.Unlock()
+		if r.apiURL != "" {
+			close(r.stop)
+		}
+		<-r.done
+	}, nil
+}
+
+// LookupRoute returns the route of an aircraft's callsign
+// Callsigns not known yet are queued for the API, so their route is
+// available from a later poll on
+func LookupRoute(a *models.Aircraft) (Route, bool) {
+	routesMu.RLock()
+	r := routes
+	routesMu.RUnlock()
+	if r == nil {
+		return Route{}, false
+	}
+	return r.lookup(a, time.Now())
+}
+
+func (r *routeResolver) lookup(a *models.Aircraft, now time.Time) (Route, bool) {
+	callsign := normalizeCallsign(a.Flight)
+	if callsign == "" {
+		return Route{}, false
+	}
+	if route, ok := r.file[callsign]; ok {
+		return r.withAirline(route), true
+	}
+	if r.apiURL == "" {
+		return Route{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.cache[callsign]; ok && now.Before(cached.expires) {
+		return r.withAirline(cached.route), cached.found
+	}
+	r.pending[callsign] = position{lat: a.Lat, lon: a.Lon}
+	return Route{}, false
+}
+
+// withAirline fills in the airline name from the airlines file
+func (r *routeResolver) withAirline(route Route) Route {
+	if route.Airline == "" {
+		route.Airline = r.airlines[route.AirlineCode]
+	}
+	return route
+}
+
+func (r *routeResolver) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.resolvePending()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// resolvePending looks up a batch of queued callsigns and drops expired cache entries
+func (r *routeResolver) resolvePending() {
+	now := time.Now()
+	r.mu.Lock()
+	for callsign, cached := range r.cache {
+		if !now.Before(cached.expires) {
+			delete(r.cache, callsign)
+		}
+	}
+	batch := make(map[string]position, min(len(r.pending), r.batchSize))
+	for callsign, pos := range r.pending {
+		if len(batch) == r.batchSize {
+			break
+		}
+		batch[callsign] = pos
+		delete(r.pending, callsign)
+	}
+	r.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.client.Timeout)
+	defer cancel()
+	found, err := r.fetch(ctx, batch)
+	if err != nil {
+		// The callsigns are queued again the next time they are seen
+		routeLookups.Add(ctx, int64(len(batch)), metric.WithAttributes(attribute.String("result", lookupError)))
+		logging.Warn("Route lookup failed", "callsigns", len(batch), "error", err)
+		return
+	}
+
+	expires := time.Now().Add(r.ttl)
+	r.mu.Lock()
+	for callsign := range batch {
+		route, ok := found[callsign]
+		r.cache[callsign] = cachedRoute{route: route, found: ok, expires: expires}
+	}
+	r.mu.Unlock()
+
+	routeLookups.Add(ctx, int64(len(found)), metric.WithAttributes(attribute.String("result", lookupFound)))
+	routeLookups.Add(ctx, int64(len(batch)-len(found)), metric.WithAttributes(attribute.String("result", lookupUnknown)))
+	logging.Debug("Resolved routes", "callsigns", len(batch), "found", len(found))
+}
+
+// routesetPlane is a callsign in a routeset request
+type routesetPlane struct {
+	Callsign string   `json:"callsign"`
+	Lat      *float64 `json:"lat,omitempty"`
+	Lng      *float64 `json:"lng,omitempty"`
+}
+
+// routesetRoute is a route in a routeset response
+type routesetRoute struct {
+	Callsign     string `json:"callsign"`
+	AirlineCode  string `json:"airline_code"`
+	AirportCodes string `json:"airport_codes"`
+	// Plausible is false when the route does not fit the aircraft's position
+	Plausible any `json:"plausible"`
+}
+
+// fetch looks up callsigns through the routeset API as offered by adsb.lol
+// and returns the plausible routes found
+func (r *routeResolver) fetch(ctx context.Context, batch map[string]position) (map[string]Route, error) {
+	planes := make([]routesetPlane, 0, len(batch))
+	for callsign, pos := range batch {
+		planes = append(planes, routesetPlane{Callsign: callsign, Lat: pos.lat, Lng: pos.lon})
+	}
+	body, err := json.Marshal(map[string]any{"planes": planes})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	useragent.Set(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("routes API returned %s", resp.Status)
+	}
+
+	var results []routesetRoute
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to decode routes: %w", err)
+	}
+
+	found := make(map[string]Route, len(results))
+	for _, result := range results {
+		if result.Plausible == false || result.Plausible == float64(0) {
+			continue
+		}
+		route, ok := parseRoute(result.AirlineCode, result.AirportCodes)
+		if ok {
+			found[normalizeCallsign(result.Callsign)] = route
+		}
+	}
+	return found, nil
+}
+
+// parseRoute builds a route from an airline code and airport codes like
+// "EGLL-KJFK", taking the first and last airport of multi-leg routes
+func parseRoute(airlineCode, airportCodes string) (Route, bool) {
+	airports := strings.Split(strings.TrimSpace(airportCodes), "-")
+	if len(airports) < 2 || strings.EqualFold(airports[0], "unknown") {
+		return Route{}, false
+	}
+	return Route{
+		AirlineCode: strings.TrimSpace(airlineCode),
+		Origin:      strings.TrimSpace(airports[0]),
+		Destination: strings.TrimSpace(airports[len(airports)-1]),
+	}, true
+}
+
+// loadRoutesFile reads routes in the format of the Virtual Radar Server
+// standing data routes.csv, with Callsign, AirlineCode and AirportCodes columns
+func loadRoutesFile(path string) (map[string]Route, error) {
+	rows, err := readCSVFile(path, "Callsign", "AirlineCode", "AirportCodes")
+	if err != nil {
+		return nil, err
+	}
+	routes := make(map[string]Route, len(rows))
+	for _, row := range rows {
+		if route, ok := parseRoute(row[1], row[2]); ok {
+			routes[normalizeCallsign(row[0])] = route
+		}
+	}
+	return routes, nil
+}
+
+// loadAirlinesFile reads airline names by ICAO code from a file in the format
+// of the Virtual Radar Server standing data airlines.csv
+func loadAirlinesFile(path string) (map[string]string, error) {
+	rows, err := readCSVFile(path, "ICAO", "Name")
+	if err != nil {
+		return nil, err
+	}
+	airlines := make(map[string]string, len(rows))
+	for _, row := range rows {
+		if code := strings.ToUpper(strings.TrimSpace(row[0])); code != "" {
+			airlines[code] = strings.TrimSpace(row[1])
+		}
+	}
+	return airlines, nil
+}
+
+// readCSVFile reads a CSV file with a header row and returns the given columns of each row
+func readCSVFile(path string, columns ...string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readCSV(f, columns...)
+}
+
+// readCSV reads CSV with a header row and returns the given columns of each row
+func readCSV(r io.Reader, columns ...string) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := make([]int, len(columns))
+	for i, column := range columns {
+		index[i] = -1
+		for j, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				index[i] = j
+			}
+		}
+		if index[i] < 0 {
+			return nil, fmt.Errorf("missing %s column", column)
+		}
+	}
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]string, len(columns))
+		for i, j := range index {
+			if j < len(record) {
+				row[i] = record[j]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// CheckConfig returns problems with the enrichment configuration
+func CheckConfig() []error {
+	var errs []error
+	if raw := os.Getenv("ROUTES_API_URL"); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("ROUTES_API_URL: %q is not an http(s) URL", raw))
+		}
+	}
+	if path := os.Getenv("ROUTES_FILE"); path != "" {
+		if _, err := loadRoutesFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("ROUTES_FILE: %w", err))
+		}
+	}
+	if path := os.Getenv("ROUTES_AIRLINES_FILE"); path != "" {
+		if _, err := loadAirlinesFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("ROUTES_AIRLINES_FILE: %w", err))
+		}
+	}
+	if raw := os.Getenv("METAR_URL"); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("METAR_URL: %q is not an http(s) URL", raw))
+		}
+	}
+	if path := os.Getenv("AIRPORTS_FILE"); path != "" {
+		if _, err := readCSVFile(path, "ident", "type", "latitude_deg", "longitude_deg"); err != nil {
+			errs = append(errs, fmt.Errorf("AIRPORTS_FILE: %w", err))
+		}
+	}
+	return errs
+}
+
+func normalizeCallsign(callsign string) string {
+	return strings.ToUpper(strings.TrimSpace(callsign))
+}
+
+// newHTTPClient returns a client for the enrichment APIs
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if resolver := dnscache.Get(); resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %s=%s, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvInt returns a positive integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		log.Printf("Invalid integer %s=%s, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
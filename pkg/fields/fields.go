@@ -0,0 +1,213 @@
+package fields
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Scope prefixes restrict a pattern to either the log body or the attributes
+const (
+	scopeBody = "body:"
+	scopeAttr = "attr:"
+)
+
+// defaultAttributeFields are the fields exported as attributes when no
+// attribute include patterns are configured
+var defaultAttributeFields = []string{"hex", "type", "flight", "lat", "lon", "alt_baro", "squawk", "recentReceiverIds", "receivers"}
+
+var (
+	aircraftFields = jsonFieldNames(reflect.TypeOf(models.Aircraft{}))
+
+	defaultFilter *Filter
+	once          sync.Once
+)
+
+// Filter decides which aircraft fields are exported in the log body and as attributes
+// Fields are matched by their aircraft.json name using glob patterns (e.g. nav_*)
+type Filter struct {
+	bodyInclude []string
+	bodyExclude []string
+	attrInclude []string
+	attrExclude []string
+
+	// extraAttributes are fields exported as generic aircraft.<field>
+	// attributes on top of the curated attribute set
+	extraAttributes []string
+}
+
+// Get returns the filter configured via EXPORT_FIELDS and EXPORT_FIELDS_EXCLUDE
+func Get() *Filter {
+	once.Do(func() {
+		defaultFilter = New(os.Getenv("EXPORT_FIELDS"), os.Getenv("EXPORT_FIELDS_EXCLUDE"))
+	})
+	return defaultFilter
+}
+
+// New creates a filter from comma separated include and exclude pattern lists
+// Patterns apply to both the body and the attributes unless prefixed with
+// "body:" or "attr:". An empty include list keeps every body field and the
+// default attribute set.
+func New(include, exclude string) *Filter {
+	f := &Filter{}
+	f.bodyInclude, f.attrInclude = splitPatterns(include)
+	f.bodyExclude, f.attrExclude = splitPatterns(exclude)
+
+	if len(f.attrInclude) > 0 {
+		curated := make(map[string]bool, len(defaultAttributeFields))
+		for _, field := range defaultAttributeFields {
+			curated[field] = true
+		}
+		for _, field := range aircraftFields {
+			if !curated[field] && f.Attribute(field) {
+				f.extraAttributes = append(f.extraAttributes, field)
+			}
+		}
+	}
+
+	return f
+}
+
+// Active reports whether any include or exclude patterns are configured
+func (f *Filter) Active() bool {
+	return len(f.bodyInclude) > 0 || len(f.bodyExclude) > 0 || len(f.attrInclude) > 0 || len(f.attrExclude) > 0
+}
+
+// Body reports whether a field should appear in the log body
+func (f *Filter) Body(field string) bool {
+	return allowed(field, f.bodyInclude, f.bodyExclude, true)
+}
+
+// Attribute reports whether a field should be exported as an attribute
+func (f *Filter) Attribute(field string) bool {
+	isDefault := false
+	for _, d := range defaultAttributeFields {
+		if d == field {
+			isDefault = true
+			break
+		}
+	}
+	return allowed(field, f.attrInclude, f.attrExclude, isDefault)
+}
+
+// Apply filters an encoded aircraft body and returns any extra attributes
+// requested through attribute include patterns
+func (f *Filter) Apply(body string) (string, []otellog.KeyValue, error) {
+	filterBody := len(f.bodyInclude) > 0 || len(f.bodyExclude) > 0
+	if !filterBody && len(f.extraAttributes) == 0 {
+		return body, nil, nil
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &values); err != nil {
+		return "", nil, err
+	}
+
+	var attrs []otellog.KeyValue
+	for _, field := range f.extraAttributes {
+		if raw, ok := values[field]; ok {
+			attrs = append(attrs, otellog.KeyValue{Key: "aircraft." + field, Value: Value(raw)})
+		}
+	}
+
+	if !filterBody {
+		return body, attrs, nil
+	}
+
+	for field := range values {
+		if !f.Body(field) {
+			delete(values, field)
+		}
+	}
+
+	filtered, err := json.Marshal(values)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(filtered), attrs, nil
+}
+
+// allowed applies include then exclude patterns; defaultAllowed is used
+// when there are no include patterns
+func allowed(field string, include, exclude []string, defaultAllowed bool) bool {
+	ok := defaultAllowed
+	if len(include) > 0 {
+		ok = matchAny(field, include)
+	}
+	return ok && !matchAny(field, exclude)
+}
+
+func matchAny(field string, patterns []string) bool {
+	field = strings.ToLower(field)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, field); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns parses a comma separated pattern list into body and attribute patterns
+func splitPatterns(s string) (body, attr []string) {
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "":
+		case strings.HasPrefix(p, scopeBody):
+			body = append(body, strings.TrimPrefix(p, scopeBody))
+		case strings.HasPrefix(p, scopeAttr):
+			attr = append(attr, strings.TrimPrefix(p, scopeAttr))
+		default:
+			body = append(body, p)
+			attr = append(attr, p)
+		}
+	}
+	return body, attr
+}
+
+// Value converts a raw JSON value into an OTel log value
+func Value(raw json.RawMessage) otellog.Value {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return otellog.StringValue(string(raw))
+	}
+
+	switch val := v.(type) {
+	case string:
+		return otellog.StringValue(val)
+	case bool:
+		return otellog.BoolValue(val)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return otellog.Int64Value(i)
+		}
+		if f, err := val.Float64(); err == nil {
+			return otellog.Float64Value(f)
+		}
+	}
+	return otellog.StringValue(string(raw))
+}
+
+// jsonFieldNames returns the JSON names of a struct's fields in declaration order
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,72 @@
+package flightdata
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// ageFilter drops aircraft the receiver has not heard from recently, which
+// decoders keep listing for a while after they have gone
+type ageFilter struct {
+	maxSeen    time.Duration
+	maxSeenPos time.Duration
+}
+
+var (
+	age     ageFilter
+	ageOnce sync.Once
+)
+
+// getAgeFilter returns the filter configured via AIRCRAFT_MAX_SEEN and AIRCRAFT_MAX_SEEN_POS
+func getAgeFilter() ageFilter {
+	ageOnce.Do(func() {
+		age = ageFilter{
+			maxSeen:    getEnvDurationOrDefault("AIRCRAFT_MAX_SEEN", 0),
+			maxSeenPos: getEnvDurationOrDefault("AIRCRAFT_MAX_SEEN_POS", 0),
+		}
+		if age.active() {
+			logging.Info("Aircraft age filter enabled", "max_seen", age.maxSeen, "max_seen_pos", age.maxSeenPos)
+		}
+	})
+	return age
+}
+
+func (f ageFilter) active() bool {
+	return f.maxSeen > 0 || f.maxSeenPos > 0
+}
+
+// apply removes stale aircraft in place and returns the remaining aircraft
+// and how many were removed
+// Aircraft without a position are only checked against the seen threshold
+func (f ageFilter) apply(aircraft []models.Aircraft) ([]models.Aircraft, int) {
+	if !f.active() {
+		return aircraft, 0
+	}
+	n := len(aircraft)
+	aircraft = slices.DeleteFunc(aircraft, func(a models.Aircraft) bool {
+		if f.maxSeen > 0 && a.Seen > f.maxSeen.Seconds() {
+			return true
+		}
+		return f.maxSeenPos > 0 && a.SeenPos != nil && *a.SeenPos > f.maxSeenPos.Seconds()
+	})
+	return aircraft, n - len(aircraft)
+}
+
+// attributes returns the thresholds the exported aircraft passed, so
+// dashboards can tell how fresh the data is guaranteed to be
+func (f ageFilter) attributes() []otellog.KeyValue {
+	var attrs []otellog.KeyValue
+	if f.maxSeen > 0 {
+		attrs = append(attrs, otellog.Float64("filter.max_seen_s", f.maxSeen.Seconds()))
+	}
+	if f.maxSeenPos > 0 {
+		attrs = append(attrs, otellog.Float64("filter.max_seen_pos_s", f.maxSeenPos.Seconds()))
+	}
+	return attrs
+}
@@ -0,0 +1,211 @@
+package flightdata
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// aircraftMetrics reports the altitude, ground speed and signal strength of
+// individual aircraft as gauges labeled by address, for dashboards that
+// graph a single aircraft without querying logs
+// Cardinality is bounded by tracking at most max aircraft, least recently
+// seen first out, and dropping aircraft not seen for ttl; series of dropped
+// aircraft are no longer reported, which Prometheus turns into staleness
+// markers
+type aircraftMetrics struct {
+	max int
+	ttl time.Duration
+
+	mu sync.Mutex
+	// lru holds *trackedAircraft, most recently seen first
+	lru     *list.List
+	byHex   map[string]*list.Element
+	capped  bool
+	current time.Time
+
+	overflow metric.Int64Counter
+}
+
+// trackedAircraft is the latest reading of an aircraft
+type trackedAircraft struct {
+	hex      string
+	attrs    metric.MeasurementOption
+	lastSeen time.Time
+
+	altitude    float64
+	hasAltitude bool
+	groundSpeed float64
+	hasSpeed    bool
+	rssi        float64
+}
+
+var (
+	aircraftMetricsInstance *aircraftMetrics
+	aircraftMetricsOnce     sync.Once
+)
+
+// getAircraftMetrics returns the per-aircraft metrics if AIRCRAFT_METRICS is
+// enabled, tracking at most AIRCRAFT_METRICS_MAX aircraft each for up to
+// AIRCRAFT_METRICS_TTL after they were last seen, or nil otherwise
+func getAircraftMetrics() *aircraftMetrics {
+	aircraftMetricsOnce.Do(func() {
+		if !isTrue(os.Getenv("AIRCRAFT_METRICS")) {
+			return
+		}
+		m, err := newAircraftMetrics(getEnvIntOrDefault("AIRCRAFT_METRICS_MAX", 500), getEnvDurationOrDefault("AIRCRAFT_METRICS_TTL", 2*time.Minute))
+		if err != nil {
+			logging.Warn("Failed to create per-aircraft metrics", "error", err)
+			return
+		}
+		aircraftMetricsInstance = m
+		logging.Info("Per-aircraft metrics enabled", "max", m.max, "ttl", m.ttl)
+	})
+	return aircraftMetricsInstance
+}
+
+func newAircraftMetrics(max int, ttl time.Duration) (*aircraftMetrics, error) {
+	m := &aircraftMetrics{
+		max:   max,
+		ttl:   ttl,
+		lru:   list.New(),
+		byHex: make(map[string]*list.Element),
+	}
+
+	altitude, err1 := meter.Float64ObservableGauge("adsb2otel.aircraft.altitude",
+		metric.WithDescription("Altitude of an aircraft, barometric or else geometric"),
+		metric.WithUnit("[ft_i]"),
+	)
+	groundSpeed, err2 := meter.Float64ObservableGauge("adsb2otel.aircraft.ground_speed",
+		metric.WithDescription("Ground speed of an aircraft"),
+		metric.WithUnit("[kn_i]"),
+	)
+	rssi, err3 := meter.Float64ObservableGauge("adsb2otel.aircraft.rssi",
+		metric.WithDescription("Signal strength of an aircraft over its recent messages"),
+		metric.WithUnit("dBFS"),
+	)
+	tracked, err4 := meter.Int64ObservableGauge("adsb2otel.aircraft.metrics.tracked",
+		metric.WithDescription("Aircraft per-aircraft metrics are reported for"),
+		metric.WithUnit("{aircraft}"),
+	)
+	overflow, err5 := meter.Int64Counter("adsb2otel.aircraft.metrics.overflow",
+		metric.WithDescription("Aircraft evicted from or left out of per-aircraft metrics because AIRCRAFT_METRICS_MAX was reached"),
+		metric.WithUnit("{aircraft}"),
+	)
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, err
+	}
+	m.overflow = overflow
+
+	_, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		o.ObserveInt64(tracked, int64(m.lru.Len()))
+		for e := m.lru.Front(); e != nil; e = e.Next() {
+			t := e.Value.(*trackedAircraft)
+			if t.hasAltitude {
+				o.ObserveFloat64(altitude, t.altitude, t.attrs)
+			}
+			if t.hasSpeed {
+				o.ObserveFloat64(groundSpeed, t.groundSpeed, t.attrs)
+			}
+			if t.rssi != 0 {
+				o.ObserveFloat64(rssi, t.rssi, t.attrs)
+			}
+		}
+		return nil
+	}, altitude, groundSpeed, rssi, tracked)
+	return m, err
+}
+
+// observe updates the readings from a poll taken at timestamp and drops
+// aircraft that have not been seen for the TTL
+// Aircraft in muted sectors are left out, as they are from the log records
+func (m *aircraftMetrics) observe(ctx context.Context, timestamp time.Time, aircraft []models.Aircraft) {
+	routes := routing.Get()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current = timestamp
+	overflow := 0
+	for i := range aircraft {
+		a := &aircraft[i]
+		if a.Hex == "" || routes.Muted(a) {
+			continue
+		}
+		t, overflowed := m.track(a.Hex)
+		if overflowed {
+			overflow++
+		}
+		if t == nil {
+			continue
+		}
+		t.lastSeen = timestamp
+		altitude, hasAltitude := a.AltitudeFeet()
+		t.altitude, t.hasAltitude = float64(altitude), hasAltitude
+		t.hasSpeed = a.Gs != nil
+		if t.hasSpeed {
+			t.groundSpeed = *a.Gs
+		}
+		t.rssi = a.Rssi
+	}
+
+	for e := m.lru.Back(); e != nil; e = m.lru.Back() {
+		t := e.Value.(*trackedAircraft)
+		if timestamp.Sub(t.lastSeen) < m.ttl {
+			break
+		}
+		m.lru.Remove(e)
+		delete(m.byHex, t.hex)
+	}
+
+	if overflow > 0 {
+		m.overflow.Add(ctx, int64(overflow))
+		if !m.capped {
+			logging.WarnCtx(ctx, "Per-aircraft metrics reached AIRCRAFT_METRICS_MAX, evicting least recently seen aircraft", "max", m.max)
+		}
+	} else if m.capped {
+		logging.InfoCtx(ctx, "Per-aircraft metrics are back under AIRCRAFT_METRICS_MAX", "tracked", m.lru.Len())
+	}
+	m.capped = overflow > 0
+}
+
+// track returns the entry for an aircraft, moved to the front, adding it if
+// there is room or the least recently seen aircraft can be evicted, and
+// whether the cap was hit
+// Aircraft seen in the current poll are never evicted, so it returns nil
+// if they fill the cap
+func (m *aircraftMetrics) track(hex string) (*trackedAircraft, bool) {
+	if e, ok := m.byHex[hex]; ok {
+		m.lru.MoveToFront(e)
+		return e.Value.(*trackedAircraft), false
+	}
+
+	t := &trackedAircraft{
+		hex:   hex,
+		attrs: metric.WithAttributeSet(attribute.NewSet(attribute.String("aircraft.hex", hex))),
+	}
+	evicted := false
+	if m.lru.Len() >= m.max {
+		back := m.lru.Back()
+		if back.Value.(*trackedAircraft).lastSeen.Equal(m.current) {
+			return nil, true
+		}
+		delete(m.byHex, back.Value.(*trackedAircraft).hex)
+		m.lru.Remove(back)
+		evicted = true
+	}
+	m.byHex[hex] = m.lru.PushFront(t)
+	return t, evicted
+}
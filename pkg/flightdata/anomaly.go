@@ -0,0 +1,165 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/alerts"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// anomalyRapidDescent is the event emitted when an aircraft descends faster
+// than the configured rate at low altitude
+const anomalyRapidDescent = "aircraft.rapid_descent"
+
+var anomalyCounter, _ = meter.Int64Counter("adsb2otel.anomalies",
+	metric.WithDescription("Anomaly events emitted, by type"),
+	metric.WithUnit("{event}"),
+)
+
+// descentDetector emits an event when an aircraft starts descending faster
+// than a threshold below an altitude, to catch unusual approaches and
+// emergencies. An aircraft triggers again only after it has recovered
+type descentDetector struct {
+	// rate is the descent rate in ft/min above which an event is emitted, 0 when disabled
+	rate        int
+	maxAltitude int
+
+	mu     sync.Mutex
+	active map[string]bool
+	next   map[string]bool
+}
+
+var (
+	descent     *descentDetector
+	descentOnce sync.Once
+)
+
+// getDescentDetector returns the detector configured via ANOMALY_DESCENT_RATE
+// and ANOMALY_DESCENT_MAX_ALTITUDE
+func getDescentDetector() *descentDetector {
+	descentOnce.Do(func() {
+		descent = &descentDetector{
+			rate:        getEnvIntOrDefault("ANOMALY_DESCENT_RATE", 0),
+			maxAltitude: getEnvIntOrDefault("ANOMALY_DESCENT_MAX_ALTITUDE", 10000),
+			active:      make(map[string]bool),
+			next:        make(map[string]bool),
+		}
+		if descent.rate > 0 {
+			logging.Info("Rapid descent detection enabled", "rate_fpm", descent.rate, "max_altitude_ft", descent.maxAltitude)
+		}
+	})
+	return descent
+}
+
+// check emits an event if the aircraft just started descending too fast
+// Aircraft checked are remembered until end is called for the poll
+func (d *descentDetector) check(ctx context.Context, logger otellog.Logger, a *models.Aircraft, timestamp time.Time) {
+	rate, source, altitude, ok := d.descending(a)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	d.next[a.Hex] = true
+	triggered := !d.active[a.Hex]
+	d.mu.Unlock()
+	if !triggered {
+		return
+	}
+
+	callsign := strings.TrimSpace(a.Flight)
+	logging.WarnCtx(ctx, "Rapid descent detected", "hex", a.Hex, "flight", callsign, "vertical_rate_fpm", rate, "altitude_ft", altitude)
+	anomalyCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("type", anomalyRapidDescent)))
+	alerts.Get(anomalyRapidDescent).Fire(a.Hex)
+
+	attrs := []otellog.KeyValue{
+		otellog.String("service", "adsb"),
+		otellog.String("aircraft.hex", a.Hex),
+		otellog.Int("aircraft.vertical_rate", rate),
+		otellog.String("aircraft.vertical_rate_source", source),
+		otellog.Int("aircraft.altitude", altitude),
+		otellog.Int("anomaly.threshold_fpm", d.rate),
+	}
+	if callsign != "" {
+		attrs = append(attrs, otellog.String("aircraft.flight", callsign))
+	}
+	if pos, ok := a.Position(); ok {
+		attrs = append(attrs, otellog.Float64("aircraft.lat", pos.Lat), otellog.Float64("aircraft.lon", pos.Lon))
+	}
+
+	// Without OTLP logs the descent is only logged and counted
+	if logger == nil {
+		return
+	}
+	record := otellog.Record{}
+	record.SetEventName(anomalyRapidDescent)
+	record.SetTimestamp(timestamp)
+	record.SetSeverity(otellog.SeverityWarn)
+	record.SetBody(otellog.StringValue(fmt.Sprintf("Rapid descent of %d ft/min at %d ft", -rate, altitude)))
+	record.AddAttributes(attrs...)
+	logger.Emit(ctx, record)
+}
+
+// descending reports whether an aircraft descends faster than the threshold
+// below the maximum altitude, with its vertical rate, the rate's source and
+// its altitude
+func (d *descentDetector) descending(a *models.Aircraft) (int, string, int, bool) {
+	if d.rate <= 0 || a.OnGround() {
+		return 0, "", 0, false
+	}
+	altitude, ok := a.AltitudeFeet()
+	if !ok || altitude > d.maxAltitude {
+		return 0, "", 0, false
+	}
+	rate, source, ok := verticalRate(a)
+	if !ok || -rate <= d.rate {
+		return 0, "", 0, false
+	}
+	return rate, source, altitude, true
+}
+
+// RapidDescent reports whether an aircraft descends fast enough for a rapid
+// descent event, as checked on every poll, with its vertical rate in ft/min
+// and altitude in feet
+func RapidDescent(a *models.Aircraft) (int, int, bool) {
+	rate, _, altitude, ok := getDescentDetector().descending(a)
+	return rate, altitude, ok
+}
+
+// end finishes a poll, re-arming aircraft that no longer descend too fast
+func (d *descentDetector) end() {
+	if d.rate <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rule := alerts.Get(anomalyRapidDescent)
+	for hex := range d.active {
+		if !d.next[hex] {
+			rule.Resolve(hex)
+		}
+	}
+	d.active, d.next = d.next, d.active
+	clear(d.next)
+}
+
+// verticalRate returns the barometric vertical rate in ft/min, falling back
+// to the geometric rate, and which of the two it is
+func verticalRate(a *models.Aircraft) (int, string, bool) {
+	if a.BaroRate != nil {
+		return *a.BaroRate, "baro", true
+	}
+	if a.GeomRate != nil {
+		return *a.GeomRate, "geom", true
+	}
+	return 0, "", false
+}
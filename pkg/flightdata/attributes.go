@@ -0,0 +1,121 @@
+package flightdata
+
+import (
+	"maps"
+	"slices"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/fields"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// aircraftAttributes builds the curated set of log attributes for an aircraft,
+// leaving out any fields excluded by the export filter
+func aircraftAttributes(aircraft *models.Aircraft, filter *fields.Filter) []otellog.KeyValue {
+	source := aircraft.Source
+	if source == "" {
+		source = models.SourceReceiver
+	}
+
+	attrs := []otellog.KeyValue{
+		otellog.String("service", "adsb"),
+		otellog.String("aircraft.hex", aircraft.Hex),
+		otellog.String("aircraft.source", source),
+		otellog.String("aircraft.source_type", aircraft.SourceType()),
+	}
+	if aircraft.Protocol != "" {
+		attrs = append(attrs, otellog.String("aircraft.protocol", aircraft.Protocol))
+	}
+	if aircraft.Class != "" {
+		attrs = append(attrs, otellog.String("aircraft.class", aircraft.Class))
+	}
+	if uas := aircraft.UAS; uas != nil {
+		attrs = append(attrs, otellog.String("uas.id", uas.ID))
+		if uas.IDType != "" {
+			attrs = append(attrs, otellog.String("uas.id_type", uas.IDType))
+		}
+		if uas.UAType != "" {
+			attrs = append(attrs, otellog.String("uas.type", uas.UAType))
+		}
+		if uas.OperatorID != "" {
+			attrs = append(attrs, otellog.String("uas.operator_id", uas.OperatorID))
+		}
+		if uas.OperatorLat != nil && uas.OperatorLon != nil {
+			attrs = append(attrs,
+				otellog.Float64("uas.operator.lat", *uas.OperatorLat),
+				otellog.Float64("uas.operator.lon", *uas.OperatorLon),
+			)
+		}
+		if uas.Height != nil {
+			attrs = append(attrs, otellog.Float64("uas.height_m", *uas.Height))
+		}
+	}
+
+	if country, ok := enrich.RegistrationCountry(aircraft.Hex); ok {
+		attrs = append(attrs, otellog.String("aircraft.registration_country", country))
+	}
+
+	if filter.Attribute("type") {
+		attrs = append(attrs, otellog.String("aircraft.type", aircraft.Type))
+	}
+
+	if aircraft.Category != "" {
+		attrs = append(attrs, otellog.String("aircraft.category_class", aircraft.CategoryClass()))
+	}
+
+	// Add optional fields as attributes
+	if aircraft.Flight != "" && filter.Attribute("flight") {
+		attrs = append(attrs, otellog.String("aircraft.flight", aircraft.Flight))
+	}
+	if pos, ok := aircraft.Position(); ok {
+		attrs = append(attrs, otellog.String("aircraft.position_source", aircraft.PositionSource()))
+		// The position is also given as semantic convention geo attributes
+		if filter.Attribute("lat") {
+			attrs = append(attrs,
+				otellog.Float64("aircraft.lat", pos.Lat),
+				otellog.Float64(string(semconv.GeoLocationLatKey), pos.Lat),
+			)
+		}
+		if filter.Attribute("lon") {
+			attrs = append(attrs,
+				otellog.Float64("aircraft.lon", pos.Lon),
+				otellog.Float64(string(semconv.GeoLocationLonKey), pos.Lon),
+			)
+		}
+	}
+	if aircraft.AltBaro != nil && filter.Attribute("alt_baro") {
+		attrs = append(attrs, otellog.Bool("aircraft.on_ground", aircraft.OnGround()))
+		if !aircraft.AltBaro.Ground {
+			attrs = append(attrs, otellog.Int("aircraft.alt_baro", aircraft.AltBaro.Feet))
+		}
+	}
+	if aircraft.Squawk != "" && filter.Attribute("squawk") {
+		attrs = append(attrs, otellog.String("aircraft.squawk", aircraft.Squawk))
+	}
+	if len(aircraft.Receivers) > 0 && filter.Attribute("receivers") {
+		names := make([]otellog.Value, len(aircraft.Receivers))
+		for i, name := range aircraft.Receivers {
+			names[i] = otellog.StringValue(name)
+		}
+		attrs = append(attrs, otellog.Slice("aircraft.receivers", names...))
+	}
+	if len(aircraft.RecentReceiverIDs) > 0 && filter.Attribute("recentReceiverIds") {
+		receivers := make([]otellog.Value, len(aircraft.RecentReceiverIDs))
+		for i, id := range aircraft.RecentReceiverIDs {
+			receivers[i] = otellog.StringValue(id)
+		}
+		attrs = append(attrs,
+			otellog.Slice("aircraft.receiver_ids", receivers...),
+			otellog.Int("aircraft.receiver_count", len(receivers)),
+		)
+	}
+	// Fields captured in the tolerant schema mode, sorted for stable output
+	for _, name := range slices.Sorted(maps.Keys(aircraft.Extra)) {
+		attrs = append(attrs, otellog.KeyValue{Key: "aircraft.extra." + name, Value: fields.Value(aircraft.Extra[name])})
+	}
+
+	return attrs
+}
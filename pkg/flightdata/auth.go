@@ -0,0 +1,71 @@
+package flightdata
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+)
+
+// headersFromEnv returns the headers sent with every request to a source, or
+// nil if none are set: basic auth from <prefix>USERNAME and <prefix>PASSWORD
+// or a bearer token from <prefix>TOKEN, then custom headers from
+// <prefix>HEADERS_FILE and <prefix>HEADERS, which can override the others
+func headersFromEnv(prefix string) (http.Header, error) {
+	hdrs := http.Header{}
+
+	username, password, token := os.Getenv(prefix+"USERNAME"), os.Getenv(prefix+"PASSWORD"), os.Getenv(prefix+"TOKEN")
+	switch {
+	case token != "" && username != "":
+		return nil, fmt.Errorf("%sTOKEN and %sUSERNAME are mutually exclusive", prefix, prefix)
+	case token != "":
+		hdrs.Set("Authorization", "Bearer "+token)
+	case username != "":
+		req := http.Request{Header: hdrs}
+		req.SetBasicAuth(username, password)
+	case password != "":
+		return nil, fmt.Errorf("%sPASSWORD is set without %sUSERNAME", prefix, prefix)
+	}
+
+	if path := os.Getenv(prefix + "HEADERS_FILE"); path != "" {
+		custom, err := headers.ParseFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%sHEADERS_FILE: %w", prefix, err)
+		}
+		for k, v := range custom {
+			hdrs.Set(k, v)
+		}
+	}
+	custom, err := headers.Parse(os.Getenv(prefix + "HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("%sHEADERS: %w", prefix, err)
+	}
+	for k, v := range custom {
+		hdrs.Set(k, v)
+	}
+
+	if len(hdrs) == 0 {
+		return nil, nil
+	}
+	return hdrs, nil
+}
+
+// headerTransport adds a source's headers to its requests
+// Like the client does for the Authorization header, they are not sent on
+// when a redirect leads to another host, so credentials don't leak
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Response != nil && req.Response.Request.URL.Host != req.URL.Host {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,286 @@
+package flightdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Source formats selected by FLIGHT_DATA_FORMAT
+const (
+	formatJSON     = "json"
+	formatBinCraft = "bincraft"
+)
+
+// binCraftMinStride is the size of the header and of each aircraft entry in
+// the oldest binCraft version read; newer readsb versions append fields
+const binCraftMinStride = 112
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// binCraft enumerations, indexed by their value in readsb
+var (
+	binCraftTypes = []string{
+		"adsb_icao", "adsb_icao_nt", "adsr_icao", "tisb_icao", "adsc", "mlat", "other",
+		"mode_s", "adsb_other", "adsr_other", "tisb_trackfile", "tisb_other", "mode_ac",
+	}
+	binCraftEmergencies = []string{"none", "general", "lifeguard", "minfuel", "nordo", "unlawful", "downed", "reserved"}
+	binCraftSilTypes    = []string{"", "unknown", "persample", "perhour"}
+	binCraftNavModes    = []string{"autopilot", "vnav", "althold", "approach", "lnav", "tcas"}
+)
+
+var (
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+// getZstdDecoder returns the decoder for zstd compressed binCraft, which can
+// be shared as DecodeAll is safe for concurrent use
+func getZstdDecoder() (*zstd.Decoder, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	})
+	return zstdDecoder, zstdDecoderErr
+}
+
+// sourceFormat returns the format a source URL serves: FLIGHT_DATA_FORMAT if
+// set, otherwise binCraft for readsb's aircraft.binCraft(.zst) files and
+// ?binCraft API requests, and JSON for anything else
+func sourceFormat(target string) string {
+	if format := strings.ToLower(strings.TrimSpace(os.Getenv("FLIGHT_DATA_FORMAT"))); format != "" && format != "auto" {
+		return format
+	}
+	return urlFormat(target)
+}
+
+// urlFormat returns the format a source URL serves judging by the URL alone
+func urlFormat(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return formatJSON
+	}
+	path := strings.ToLower(u.Path)
+	if strings.HasSuffix(path, ".bincraft") || strings.HasSuffix(path, ".bincraft.zst") || u.Query().Has("binCraft") {
+		return formatBinCraft
+	}
+	return formatJSON
+}
+
+// decodeSource decodes a poll in the given format into data
+func decodeSource(r io.Reader, format string, data *models.Dump1090fa) error {
+	if format == formatBinCraft {
+		return decodeBinCraft(r, data)
+	}
+	return decodeFlightData(r, data)
+}
+
+// decodeBinCraft decodes readsb's binCraft format, zstd compressed or not,
+// into the same structure aircraft.json is decoded into
+// The first stride bytes are a header, followed by an entry per aircraft;
+// fields are little endian and scaled integers, with validity bits telling
+// which of them are set
+func decodeBinCraft(r io.Reader, data *models.Dump1090fa) error {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(buf, zstdMagic) {
+		dec, err := getZstdDecoder()
+		if err != nil {
+			return err
+		}
+		if buf, err = dec.DecodeAll(buf, nil); err != nil {
+			return fmt.Errorf("failed to decompress binCraft: %w", err)
+		}
+	}
+	if len(buf) < binCraftMinStride {
+		return errors.New("binCraft data is shorter than its header")
+	}
+
+	le := binary.LittleEndian
+	stride := int(le.Uint32(buf[8:]))
+	if stride < binCraftMinStride || stride > len(buf) {
+		return fmt.Errorf("invalid binCraft stride %d for %d bytes", stride, len(buf))
+	}
+	nowMillis := uint64(le.Uint32(buf[0:])) | uint64(le.Uint32(buf[4:]))<<32
+	data.Now = float64(nowMillis) / 1000
+	data.Messages = int(le.Uint32(buf[28:]))
+
+	for offset := stride; offset+stride <= len(buf); offset += stride {
+		data.Aircraft = append(data.Aircraft, decodeBinCraftAircraft(buf[offset:offset+stride]))
+	}
+	return nil
+}
+
+// decodeBinCraftAircraft decodes an aircraft entry of binCraft
+func decodeBinCraftAircraft(b []byte) models.Aircraft {
+	le := binary.LittleEndian
+	u16 := func(i int) uint16 { return le.Uint16(b[i*2:]) }
+	s16 := func(i int) int16 { return int16(u16(i)) }
+	s32 := func(i int) int32 { return int32(le.Uint32(b[i*4:])) }
+	valid := func(i int, bit uint) bool { return b[i]&(1<<bit) != 0 }
+	text := func(from, to int) string {
+		s := b[from:to]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			s = s[:end]
+		}
+		return string(s)
+	}
+	intp := func(v int) *int { return &v }
+	floatp := func(v float64) *float64 { return &v }
+
+	var a models.Aircraft
+	addr := uint32(s32(0))
+	a.Hex = fmt.Sprintf("%06x", addr&0xffffff)
+	if addr&(1<<24) != 0 {
+		// Non-ICAO addresses, as readsb writes them
+		a.Hex = "~" + a.Hex
+	}
+	a.Seen = float64(u16(3)) / 10
+	a.Messages = int(u16(31))
+	if t := int(b[67] >> 4); t < len(binCraftTypes) {
+		a.Type = binCraftTypes[t]
+	}
+	if b[64] != 0 {
+		a.Category = strings.ToUpper(strconv.FormatUint(uint64(b[64]), 16))
+	}
+	a.Nic = intp(int(b[65]))
+	a.Rc = intp(int(u16(30)))
+	a.Version = intp(int(b[69] >> 4))
+	if t := int(b[69] & 15); t < len(binCraftSilTypes) {
+		a.SilType = binCraftSilTypes[t]
+	}
+	a.T = text(88, 92)
+	a.R = text(92, 104)
+	a.DbFlags = int(u16(43))
+	level := float64(b[105])
+	a.Rssi = math.Round(10*10*math.Log10(level*level/65025+1.125e-5)) / 10
+
+	if valid(73, 3) {
+		a.Flight = text(78, 86)
+	}
+	if b[68]&15 == 1 {
+		a.AltBaro = &models.Altitude{Ground: true}
+	} else if valid(73, 4) {
+		a.AltBaro = &models.Altitude{Feet: int(s16(10)) * 25}
+	}
+	if valid(73, 5) {
+		a.AltGeom = intp(int(s16(11)) * 25)
+	}
+	if valid(73, 6) {
+		a.Lon = floatp(float64(s32(2)) / 1e6)
+		a.Lat = floatp(float64(s32(3)) / 1e6)
+		a.SeenPos = floatp(float64(u16(2)) / 10)
+	}
+	if valid(73, 7) {
+		a.Gs = floatp(float64(s16(17)) / 10)
+	}
+
+	if valid(74, 0) {
+		a.Ias = intp(int(u16(29)))
+	}
+	if valid(74, 1) {
+		a.Tas = intp(int(u16(28)))
+	}
+	if valid(74, 2) {
+		a.Mach = floatp(float64(s16(18)) / 1000)
+	}
+	if valid(74, 3) {
+		a.Track = floatp(float64(s16(20)) / 90)
+	}
+	if valid(74, 4) {
+		a.TrackRate = floatp(float64(s16(21)) / 100)
+	}
+	if valid(74, 5) {
+		a.Roll = floatp(float64(s16(19)) / 100)
+	}
+	if valid(74, 6) {
+		a.MagHeading = floatp(float64(s16(22)) / 90)
+	}
+	if valid(74, 7) {
+		a.TrueHeading = floatp(float64(s16(23)) / 90)
+	}
+
+	if valid(75, 0) {
+		a.BaroRate = intp(int(s16(8)) * 8)
+	}
+	if valid(75, 1) {
+		a.GeomRate = intp(int(s16(9)) * 8)
+	}
+	if valid(75, 4) {
+		a.NicBaro = intp(int(b[73] & 1))
+	}
+	if valid(75, 5) {
+		a.NacP = intp(int(b[71] & 15))
+	}
+	if valid(75, 6) {
+		a.NacV = intp(int(b[71] >> 4))
+	}
+	if valid(75, 7) {
+		a.Sil = intp(int(b[72] & 3))
+	}
+
+	if valid(76, 0) {
+		a.Gva = intp(int(b[72] >> 2 & 3))
+	}
+	if valid(76, 1) {
+		a.Sda = intp(int(b[72] >> 4 & 3))
+	}
+	if valid(76, 2) {
+		a.Squawk = fmt.Sprintf("%04x", u16(16))
+	}
+	if valid(76, 3) {
+		if e := int(b[67] & 15); e < len(binCraftEmergencies) {
+			a.Emergency = binCraftEmergencies[e]
+		}
+	}
+	if valid(76, 4) {
+		a.Spi = intp(int(b[73] >> 2 & 1))
+	}
+	if valid(76, 5) {
+		a.NavQnh = floatp(float64(s16(14)) / 10)
+	}
+	if valid(76, 6) {
+		a.NavAltitudeMcp = intp(int(u16(12)) * 4)
+	}
+	if valid(76, 7) {
+		a.NavAltitudeFms = intp(int(u16(13)) * 4)
+	}
+
+	if valid(77, 1) {
+		a.NavHeading = floatp(float64(s16(15)) / 90)
+	}
+	if valid(77, 2) {
+		for bit, mode := range binCraftNavModes {
+			if b[66]&(1<<bit) != 0 {
+				a.NavModes = append(a.NavModes, mode)
+			}
+		}
+	}
+	if valid(77, 3) {
+		a.Alert = intp(int(b[73] >> 1 & 1))
+	}
+	if valid(77, 4) {
+		a.Wd = intp(int(s16(24)))
+		a.Ws = intp(int(s16(25)))
+	}
+	if valid(77, 5) {
+		a.Oat = intp(int(s16(26)))
+		a.Tat = intp(int(s16(27)))
+	}
+	return a
+}
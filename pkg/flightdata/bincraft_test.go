@@ -0,0 +1,153 @@
+package flightdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// binCraftRecord is a poll with the header and the aircraft entries laid out
+// at the byte offsets of readsb's binCraft, in its original 112 byte stride
+func binCraftRecord(entries ...func(b []byte)) []byte {
+	le := binary.LittleEndian
+	buf := make([]byte, binCraftMinStride*(1+len(entries)))
+	le.PutUint64(buf[0:], 1718000000100)
+	le.PutUint32(buf[8:], binCraftMinStride)
+	le.PutUint32(buf[28:], 123456789)
+	for i, fill := range entries {
+		fill(buf[binCraftMinStride*(i+1) : binCraftMinStride*(i+2)])
+	}
+	return buf
+}
+
+// putSigned writes the signed 16 or 32 bit fields of binCraft
+func putSigned(b []byte, v int32, size int) {
+	if size == 2 {
+		binary.LittleEndian.PutUint16(b, uint16(v))
+		return
+	}
+	binary.LittleEndian.PutUint32(b, uint32(v))
+}
+
+// airborneEntry is a fully valid airborne aircraft
+func airborneEntry(b []byte) {
+	le := binary.LittleEndian
+	le.PutUint32(b[0:], 0x4ca7b4)
+	le.PutUint16(b[4:], 12)                  // seen_pos 1.2s
+	le.PutUint16(b[6:], 5)                   // seen 0.5s
+	putSigned(b[8:], -461400, 4)             // lon
+	putSigned(b[12:], 51477500, 4)           // lat
+	putSigned(b[16:], -80, 2)                // baro_rate / 8
+	le.PutUint16(b[20:], 1400)               // alt_baro / 25
+	le.PutUint16(b[22:], 1420)               // alt_geom / 25
+	le.PutUint16(b[28:], 10132)              // nav_qnh * 10
+	le.PutUint16(b[32:], 0x7700)             // squawk as BCD
+	le.PutUint16(b[34:], 4312)               // gs * 10
+	le.PutUint16(b[40:], 270*90)             // track * 90
+	le.PutUint16(b[60:], 186)                // rc
+	le.PutUint16(b[62:], 1834)               // messages
+	b[64] = 0xa3                             // category
+	b[65] = 8                                // nic
+	b[67] = 0<<4 | 5                         // adsb_icao, unlawful interference
+	b[69] = 2<<4 | 3                         // version 2, sil per hour
+	b[71] = 1<<4 | 9                         // nac_v 1, nac_p 9
+	b[73] = 1<<3 | 1<<4 | 1<<5 | 1<<6 | 1<<7 // flight, alt_baro, alt_geom, position, gs
+	b[74] = 1 << 3                           // track
+	b[75] = 1<<0 | 1<<5 | 1<<6               // baro_rate, nac_p, nac_v
+	b[76] = 1<<2 | 1<<3 | 1<<5               // squawk, emergency, nav_qnh
+	copy(b[78:], "BAW123  ")
+	copy(b[88:], "A320")
+	copy(b[92:], "G-EUYA")
+	b[105] = 255 // full scale signal
+}
+
+// groundEntry is an aircraft on the ground with a non-ICAO address and no
+// validity bits set, whose fields must all be left out
+func groundEntry(b []byte) {
+	le := binary.LittleEndian
+	le.PutUint32(b[0:], 1<<24|0x123456)
+	le.PutUint32(b[8:], 1)
+	le.PutUint32(b[12:], 1)
+	le.PutUint16(b[20:], 1400)
+	le.PutUint16(b[32:], 0x1200)
+	b[67] = 11 << 4 // tisb_other
+	b[68] = 1       // on the ground
+}
+
+func checkBinCraft(t *testing.T, data *models.Dump1090fa) {
+	t.Helper()
+	if data.Now != 1718000000.1 || data.Messages != 123456789 || len(data.Aircraft) != 2 {
+		t.Fatalf("now %v, messages %d, %d aircraft", data.Now, data.Messages, len(data.Aircraft))
+	}
+
+	a := data.Aircraft[0]
+	if a.Hex != "4ca7b4" || a.Type != "adsb_icao" || a.Flight != "BAW123  " || a.T != "A320" || a.R != "G-EUYA" {
+		t.Errorf("hex %q, type %q, flight %q, t %q, r %q", a.Hex, a.Type, a.Flight, a.T, a.R)
+	}
+	if a.Lat == nil || *a.Lat != 51.4775 || *a.Lon != -0.4614 || a.SeenPos == nil || *a.SeenPos != 1.2 || a.Seen != 0.5 {
+		t.Errorf("position %v,%v seen_pos %v seen %v", a.Lat, a.Lon, a.SeenPos, a.Seen)
+	}
+	if a.AltBaro == nil || a.AltBaro.Ground || a.AltBaro.Feet != 35000 || a.AltGeom == nil || *a.AltGeom != 35500 {
+		t.Errorf("alt_baro %+v, alt_geom %v", a.AltBaro, a.AltGeom)
+	}
+	if a.BaroRate == nil || *a.BaroRate != -640 || a.GeomRate != nil {
+		t.Errorf("baro_rate %v, geom_rate %v", a.BaroRate, a.GeomRate)
+	}
+	if a.Gs == nil || *a.Gs != 431.2 || a.Track == nil || *a.Track != 270 || a.Ias != nil || a.Mach != nil {
+		t.Errorf("gs %v, track %v, ias %v, mach %v", a.Gs, a.Track, a.Ias, a.Mach)
+	}
+	if a.Squawk != "7700" || a.Emergency != "unlawful" || a.Category != "A3" || a.NavQnh == nil || *a.NavQnh != 1013.2 {
+		t.Errorf("squawk %q, emergency %q, category %q, nav_qnh %v", a.Squawk, a.Emergency, a.Category, a.NavQnh)
+	}
+	if *a.Nic != 8 || *a.Rc != 186 || *a.Version != 2 || a.SilType != "perhour" || a.NacP == nil || *a.NacP != 9 || a.Sil != nil {
+		t.Errorf("nic %v, rc %v, version %v, sil_type %q, nac_p %v, sil %v", *a.Nic, *a.Rc, *a.Version, a.SilType, a.NacP, a.Sil)
+	}
+	if a.Messages != 1834 || math.Abs(a.Rssi) > 0.1 {
+		t.Errorf("messages %d, rssi %v", a.Messages, a.Rssi)
+	}
+
+	g := data.Aircraft[1]
+	if g.Hex != "~123456" || g.Type != "tisb_other" || !g.OnGround() {
+		t.Errorf("hex %q, type %q, alt_baro %+v", g.Hex, g.Type, g.AltBaro)
+	}
+	if g.Lat != nil || g.SeenPos != nil || g.AltGeom != nil || g.Gs != nil || g.Squawk != "" || g.Flight != "" || g.Emergency != "" {
+		t.Errorf("fields without their validity bit decoded: %+v", g)
+	}
+}
+
+func TestDecodeBinCraft(t *testing.T) {
+	record := binCraftRecord(airborneEntry, groundEntry)
+
+	var data models.Dump1090fa
+	if err := decodeBinCraft(bytes.NewReader(record), &data); err != nil {
+		t.Fatal(err)
+	}
+	checkBinCraft(t, &data)
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed models.Dump1090fa
+	if err := decodeBinCraft(bytes.NewReader(enc.EncodeAll(record, nil)), &compressed); err != nil {
+		t.Fatal(err)
+	}
+	checkBinCraft(t, &compressed)
+}
+
+func TestDecodeBinCraftInvalid(t *testing.T) {
+	var data models.Dump1090fa
+	if err := decodeBinCraft(bytes.NewReader(make([]byte, 50)), &data); err == nil {
+		t.Error("truncated header decoded")
+	}
+	record := binCraftRecord(airborneEntry)
+	binary.LittleEndian.PutUint32(record[8:], 64)
+	if err := decodeBinCraft(bytes.NewReader(record), &data); err == nil {
+		t.Error("stride shorter than the header decoded")
+	}
+}
@@ -0,0 +1,196 @@
+package flightdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// ErrBackingOff is returned instead of fetching while the flight data source
+// is considered down and the next attempt is not due yet
+var ErrBackingOff = errors.New("flight data source unavailable, backing off")
+
+// breakerBaseDelay is the time between attempts once the breaker opens,
+// doubling with every further failure up to the configured maximum
+const breakerBaseDelay = 10 * time.Second
+
+var (
+	sourceAvailable, _ = meter.Int64Gauge("adsb2otel.source.available",
+		metric.WithDescription("Whether the flight data source is reachable (1) or considered down (0)"),
+		metric.WithUnit("1"),
+	)
+	sourceRecoveries, _ = meter.Int64Counter("adsb2otel.source.recoveries",
+		metric.WithDescription("Times the flight data source came back after being considered down"),
+		metric.WithUnit("{recovery}"),
+	)
+)
+
+// sourceBreaker slows polling while the flight data source is down
+// After threshold consecutive failed fetches it opens, and fetches are only
+// attempted again after a delay that grows with each failure
+type sourceBreaker struct {
+	threshold int
+	maxDelay  time.Duration
+
+	mu          sync.Mutex
+	failures    int
+	reason      string
+	open        bool
+	downSince   time.Time
+	delay       time.Duration
+	nextAttempt time.Time
+}
+
+var (
+	breaker     *sourceBreaker
+	breakerOnce sync.Once
+)
+
+// getBreaker returns the breaker configured via FLIGHT_DATA_BREAKER_THRESHOLD
+// and FLIGHT_DATA_BREAKER_MAX_INTERVAL
+func getBreaker() *sourceBreaker {
+	breakerOnce.Do(func() {
+		breaker = &sourceBreaker{
+			threshold: getEnvIntOrDefault("FLIGHT_DATA_BREAKER_THRESHOLD", 3),
+			maxDelay:  getEnvDurationOrDefault("FLIGHT_DATA_BREAKER_MAX_INTERVAL", 2*time.Minute),
+		}
+	})
+	return breaker
+}
+
+// allow reports whether a fetch should be attempted now
+func (b *sourceBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open || !now.Before(b.nextAttempt)
+}
+
+// record updates the breaker with the outcome of a fetch
+func (b *sourceBreaker) record(ctx context.Context, now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.open {
+			downtime := now.Sub(b.downSince)
+			logging.InfoCtx(ctx, "Flight data source recovered", "downtime", downtime.Round(time.Second), "failed_attempts", b.failures)
+			sourceRecoveries.Add(ctx, 1)
+			sourceDowntime.Record(ctx, downtime.Seconds(), failureAttr(b.reason))
+			emitReceiverEvent(ctx, "receiver.online", otellog.SeverityInfo, "Receiver back online",
+				otellog.Float64("receiver.downtime_s", downtime.Seconds()),
+				otellog.Int("receiver.failed_attempts", b.failures),
+				otellog.String("error.type", b.reason),
+			)
+		}
+		b.failures = 0
+		b.open = false
+		b.delay = 0
+		sourceAvailable.Record(ctx, 1)
+		return
+	}
+
+	b.failures++
+	b.reason = classifyFailure(err)
+	sourceFailures.Add(ctx, 1, failureAttr(b.reason))
+	if b.failures == 1 {
+		b.downSince = now
+	}
+	if b.failures < b.threshold {
+		return
+	}
+
+	if !b.open {
+		b.open = true
+		b.delay = breakerBaseDelay
+		logging.WarnCtx(ctx, "Flight data source unavailable, slowing polling", "failures", b.failures, "reason", b.reason, "retry_in", b.delay, "error", err)
+		sourceAvailable.Record(ctx, 0)
+		emitReceiverEvent(ctx, "receiver.offline", otellog.SeverityWarn, "Receiver offline",
+			otellog.String("error.type", b.reason),
+			otellog.String("error.message", err.Error()),
+			otellog.Int("receiver.failed_attempts", b.failures),
+			otellog.Int64("receiver.down_since", b.downSince.Unix()),
+		)
+	} else {
+		b.delay = min(b.delay*2, b.maxDelay)
+		logging.DebugCtx(ctx, "Flight data source still unavailable", "failures", b.failures, "retry_in", b.delay)
+	}
+	b.nextAttempt = now.Add(b.delay)
+}
+
+// retryPolicy retries fetches that failed because the receiver was briefly
+// unreachable, e.g. while dump1090 restarts
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+var (
+	retry     retryPolicy
+	retryOnce sync.Once
+)
+
+// getRetryPolicy returns the policy configured via FLIGHT_DATA_RETRIES and FLIGHT_DATA_RETRY_BACKOFF
+func getRetryPolicy() retryPolicy {
+	retryOnce.Do(func() {
+		retry = retryPolicy{
+			retries: 2,
+			backoff: getEnvDurationOrDefault("FLIGHT_DATA_RETRY_BACKOFF", 500*time.Millisecond),
+		}
+		if value := strings.TrimSpace(os.Getenv("FLIGHT_DATA_RETRIES")); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				retry.retries = n
+			} else {
+				logging.Warn("Invalid FLIGHT_DATA_RETRIES, using default", "value", value, "default", retry.retries)
+			}
+		}
+	})
+	return retry
+}
+
+// do sends a request, retrying with exponential backoff on network errors and
+// server errors as long as the context allows
+func (p retryPolicy) do(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	delay := p.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req.Clone(ctx))
+		retryable := err != nil && ctx.Err() == nil || err == nil && resp.StatusCode >= 500
+		if !retryable || attempt >= p.retries {
+			return resp, err
+		}
+
+		if err == nil {
+			resp.Body.Close()
+			logging.DebugCtx(ctx, "Flight data fetch failed, retrying", "status", resp.Status, "attempt", attempt+1, "backoff", delay)
+		} else {
+			logging.DebugCtx(ctx, "Flight data fetch failed, retrying", "error", err, "attempt", attempt+1, "backoff", delay)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// getEnvIntOrDefault returns a positive integer environment variable or the default
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		logging.Warn("Invalid integer, using default", "key", key, "value", value, "default", defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,169 @@
+package flightdata
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+var (
+	httpClient     *http.Client
+	httpClientErr  error
+	httpClientOnce sync.Once
+)
+
+// getHTTPClient returns the instrumented HTTP client used to fetch flight data
+// It is built on first use so that configuration loaded from .env applies
+func getHTTPClient() (*http.Client, error) {
+	httpClientOnce.Do(func() {
+		httpClient, httpClientErr = newHTTPClient("FLIGHT_DATA_")
+	})
+	return httpClient, httpClientErr
+}
+
+// newHTTPClient builds an instrumented HTTP client for a source, with TLS
+// settings read from the source's <prefix>TLS_* environment variables and
+// the credentials and headers from headersFromEnv
+func newHTTPClient(prefix string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if resolver := dnscache.Get(); resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+	registerLocalProtocols(transport)
+
+	tlsConfig, err := tlsConfigFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	var base http.RoundTripper = transport
+	hdrs, err := headersFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if hdrs != nil {
+		base = &headerTransport{base: transport, headers: hdrs}
+		logging.Info("Headers configured for source", "source", prefix, "authorization", hdrs.Get("Authorization") != "", "headers", len(hdrs))
+	}
+
+	return &http.Client{
+		Transport: otelhttp.NewTransport(base),
+		Timeout:   30 * time.Second,
+	}, nil
+}
+
+// tlsConfigFromEnv returns the TLS configuration for a source, or nil if none is set:
+// <prefix>TLS_CERT and <prefix>TLS_KEY are the client certificate and key for
+// mutual TLS, <prefix>TLS_CA a CA bundle to verify the server with,
+// <prefix>TLS_SERVER_NAME overrides the name verified and
+// <prefix>TLS_INSECURE_SKIP_VERIFY disables verification
+func tlsConfigFromEnv(prefix string) (*tls.Config, error) {
+	certFile := os.Getenv(prefix + "TLS_CERT")
+	keyFile := os.Getenv(prefix + "TLS_KEY")
+	caFile := os.Getenv(prefix + "TLS_CA")
+	serverName := os.Getenv(prefix + "TLS_SERVER_NAME")
+	skipVerify := isTrue(os.Getenv(prefix + "TLS_INSECURE_SKIP_VERIFY"))
+
+	if certFile == "" && keyFile == "" && caFile == "" && serverName == "" && !skipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("%sTLS_CERT and %sTLS_KEY must be set together", prefix, prefix)
+	}
+	if certFile != "" {
+		cert := &clientCertificate{certFile: certFile, keyFile: keyFile}
+		if _, err := cert.get(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %sTLS_CA: %w", prefix, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %sTLS_CA %s", prefix, caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if skipVerify {
+		logging.Warn("TLS certificate verification disabled", "source", prefix)
+	}
+	logging.Info("TLS configured for source", "source", prefix, "client_cert", certFile != "", "ca", caFile != "")
+	return cfg, nil
+}
+
+// clientCertificate loads a client certificate and reloads it when the files
+// change, so certificates rotated on disk are picked up without a restart
+type clientCertificate struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (c *clientCertificate) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTime := latestModTime(c.certFile, c.keyFile)
+	if c.cert != nil && !modTime.After(c.modTime) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			// Keep using the previous certificate while files are being replaced
+			logging.Warn("Failed to reload client certificate, using previous", "cert", c.certFile, "error", err)
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return c.cert, nil
+}
+
+func latestModTime(files ...string) time.Time {
+	var latest time.Time
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}
@@ -0,0 +1,79 @@
+package flightdata
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+var clockSkewGauge, _ = meter.Float64Gauge("adsb2otel.source.clock_skew",
+	metric.WithDescription("Difference between the receiver's clock and the local clock at the latest poll, positive when the receiver is ahead"),
+	metric.WithUnit("s"),
+)
+
+// skewDetector compares the receiver's timestamp of each poll with the time
+// it was received, as a receiver with a broken clock otherwise silently
+// timestamps its records hours off
+type skewDetector struct {
+	threshold time.Duration
+	// correct replaces skewed receiver timestamps with the local time
+	correct bool
+
+	mu     sync.Mutex
+	skewed bool
+}
+
+var (
+	skew     *skewDetector
+	skewOnce sync.Once
+)
+
+// getSkewDetector returns the detector configured via
+// FLIGHT_DATA_CLOCK_SKEW_THRESHOLD and FLIGHT_DATA_CLOCK_SKEW_CORRECT
+func getSkewDetector() *skewDetector {
+	skewOnce.Do(func() {
+		skew = &skewDetector{
+			threshold: getEnvDurationOrDefault("FLIGHT_DATA_CLOCK_SKEW_THRESHOLD", 30*time.Second),
+			correct:   isTrue(os.Getenv("FLIGHT_DATA_CLOCK_SKEW_CORRECT")),
+		}
+	})
+	return skew
+}
+
+// timestamp returns the timestamp to export for a poll the receiver
+// stamped at now, in seconds, and that was received at the local time
+// received, recording the skew between the two and logging when it goes
+// over or back under the threshold
+// Skewed timestamps are replaced with the local time if correction is enabled,
+// and polls without a receiver timestamp take the local time
+func (d *skewDetector) timestamp(ctx context.Context, now float64, received time.Time) time.Time {
+	if now <= 0 {
+		return time.Unix(received.Unix(), 0)
+	}
+	timestamp := time.Unix(int64(now), 0)
+
+	offset := time.Duration(now*float64(time.Second)) - time.Duration(received.UnixNano())
+	clockSkewGauge.Record(ctx, offset.Seconds())
+	skewed := offset > d.threshold || offset < -d.threshold
+
+	d.mu.Lock()
+	if skewed != d.skewed {
+		if skewed {
+			logging.WarnCtx(ctx, "Receiver clock differs from the local clock by more than FLIGHT_DATA_CLOCK_SKEW_THRESHOLD", "skew", offset.Round(time.Second), "threshold", d.threshold, "corrected", d.correct)
+		} else {
+			logging.InfoCtx(ctx, "Receiver clock is back in line with the local clock", "skew", offset.Round(time.Millisecond))
+		}
+	}
+	d.skewed = skewed
+	d.mu.Unlock()
+
+	if skewed && d.correct {
+		return time.Unix(received.Unix(), 0)
+	}
+	return timestamp
+}
@@ -0,0 +1,279 @@
+package flightdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// coverageWindow is how long an aircraft counts as seen locally after the
+// receiver last reported it, so aircraft the aggregator saw a few seconds
+// before or after a poll are not counted as missed
+const coverageWindow = time.Minute
+
+// coverageMaxAge is the oldest position the aggregator may report for an
+// aircraft to be expected locally
+const coverageMaxAge = 30.0
+
+// coverageAggregators are the URL templates of the aggregators COMPARE_URL
+// can name, each with the header their API key is sent in
+var coverageAggregators = map[string]struct{ url, keyHeader string }{
+	"adsb.fi":        {"https://opendata.adsb.fi/api/v2/lat/{lat}/lon/{lon}/dist/{dist}", "api-auth"},
+	"airplanes.live": {"https://api.airplanes.live/v2/point/{lat}/{lon}/{dist}", "api-auth"},
+	"adsbexchange":   {"https://adsbexchange-com1.p.rapidapi.com/v2/lat/{lat}/lon/{lon}/dist/{dist}/", "X-RapidAPI-Key"},
+}
+
+var (
+	coverageExpectedGauge, _ = meter.Int64Gauge("adsb2otel.coverage.expected",
+		metric.WithDescription("Airborne ADS-B aircraft the aggregator reported within range of the receiver in the latest comparison"),
+		metric.WithUnit("{aircraft}"),
+	)
+	coverageMissedGauge, _ = meter.Int64Gauge("adsb2otel.coverage.missed",
+		metric.WithDescription("Aircraft the aggregator reported within range that the receiver did not see, in the latest comparison"),
+		metric.WithUnit("{aircraft}"),
+	)
+	coverageRatioGauge, _ = meter.Float64Gauge("adsb2otel.coverage.ratio",
+		metric.WithDescription("Share of the aircraft the aggregator reported within range that the receiver saw, in the latest comparison"),
+		metric.WithUnit("1"),
+	)
+	coverageMissedDistance, _ = meter.Float64Histogram("adsb2otel.coverage.missed.distance",
+		metric.WithDescription("Distance from the receiver to the aircraft it missed, recorded once per missed aircraft per comparison"),
+		metric.WithUnit("[nmi_i]"),
+		metric.WithExplicitBucketBoundaries(10, 25, 50, 75, 100, 125, 150, 175, 200, 250),
+	)
+)
+
+// coverageComparison periodically compares the aircraft the receiver sees
+// with those an aggregator such as adsb.fi reports for the same area, as an
+// objective measure of the receiver's performance
+type coverageComparison struct {
+	url       string
+	apiKey    string
+	keyHeader string
+	receiver  geo.Position
+	rangeNM   float64
+	interval  time.Duration
+	client    *http.Client
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// coverage is the running comparison, nil unless configured
+var coverage *coverageComparison
+
+// InitCoverage starts comparing the receiver's aircraft with the aggregator
+// configured via COMPARE_URL every COMPARE_INTERVAL
+// The returned function stops the comparison
+func InitCoverage() (func(), error) {
+	raw := os.Getenv("COMPARE_URL")
+	if raw == "" {
+		return func() {}, nil
+	}
+	c, err := newCoverageComparison(raw)
+	if err != nil {
+		return func() {}, err
+	}
+	go c.run()
+	coverage = c
+
+	logging.Info("Coverage comparison enabled", "url", c.url, "range_nm", c.rangeNM, "interval", c.interval)
+	return func() {
+		coverage = nil
+		close(c.stop)
+		<-c.done
+	}, nil
+}
+
+// newCoverageComparison resolves the aggregator named or templated by raw
+// for the area around the receiver
+func newCoverageComparison(raw string) (*coverageComparison, error) {
+	receiver, ok := geo.Receiver()
+	if !ok {
+		return nil, errors.New("RECEIVER_LAT and RECEIVER_LON must be set to compare coverage")
+	}
+	rangeNM, err := strconv.ParseFloat(getEnvOrDefault("COMPARE_RANGE_NM", "100"), 64)
+	if err != nil || rangeNM <= 0 {
+		return nil, fmt.Errorf("COMPARE_RANGE_NM must be a positive number of nautical miles")
+	}
+
+	keyHeader := "api-auth"
+	if aggregator, ok := coverageAggregators[strings.ToLower(raw)]; ok {
+		raw, keyHeader = aggregator.url, aggregator.keyHeader
+	}
+	url := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(receiver.Lat, 'f', 4, 64),
+		"{lon}", strconv.FormatFloat(receiver.Lon, 'f', 4, 64),
+		"{dist}", strconv.FormatFloat(rangeNM, 'f', -1, 64),
+	).Replace(raw)
+	if err := checkURL(url); err != nil {
+		return nil, fmt.Errorf("COMPARE_URL: %w", err)
+	}
+
+	client, err := newHTTPClient("COMPARE_")
+	if err != nil {
+		return nil, err
+	}
+	return &coverageComparison{
+		url:       url,
+		apiKey:    os.Getenv("COMPARE_API_KEY"),
+		keyHeader: getEnvOrDefault("COMPARE_API_KEY_HEADER", keyHeader),
+		receiver:  receiver,
+		rangeNM:   rangeNM,
+		interval:  getEnvDurationOrDefault("COMPARE_INTERVAL", time.Minute),
+		client:    client,
+		seen:      make(map[string]time.Time),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// observeCoverage notes the aircraft the receiver reported in a poll
+func observeCoverage(aircraft []models.Aircraft) {
+	c := coverage
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range aircraft {
+		c.seen[strings.ToLower(aircraft[i].Hex)] = now
+	}
+}
+
+// run compares coverage every interval, starting one interval in so the
+// receiver has been polled for a while
+func (c *coverageComparison) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.compare(context.Background()); err != nil {
+				logging.Warn("Failed to compare coverage", "url", c.url, "error", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// compare fetches the aggregator's aircraft and records how many of those
+// the receiver should have seen it missed
+func (c *coverageComparison) compare(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "flightdata.compare_coverage")
+	defer span.End()
+
+	reported, err := c.fetch(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	for hex, at := range c.seen {
+		if now.Sub(at) > coverageWindow {
+			delete(c.seen, hex)
+		}
+	}
+	var expected int64
+	var missed []string
+	for i := range reported {
+		a := &reported[i]
+		d, ok := c.expected(a)
+		if !ok {
+			continue
+		}
+		expected++
+		if _, seen := c.seen[strings.ToLower(a.Hex)]; !seen {
+			missed = append(missed, a.Hex)
+			coverageMissedDistance.Record(ctx, d)
+		}
+	}
+	c.mu.Unlock()
+
+	coverageExpectedGauge.Record(ctx, expected)
+	coverageMissedGauge.Record(ctx, int64(len(missed)))
+	if expected > 0 {
+		coverageRatioGauge.Record(ctx, float64(expected-int64(len(missed)))/float64(expected))
+	}
+	span.SetAttributes(
+		attribute.Int("coverage.reported", len(reported)),
+		attribute.Int64("coverage.expected", expected),
+		attribute.Int("coverage.missed", len(missed)),
+	)
+	slices.Sort(missed)
+	logging.DebugCtx(ctx, "Compared coverage with the aggregator", "expected", expected, "missed", len(missed), "missed_aircraft", strings.Join(missed[:min(len(missed), 10)], ","))
+	return nil
+}
+
+// expected reports whether the receiver should have seen an aircraft the
+// aggregator reported: an airborne aircraft transmitting its own ADS-B
+// position recently, within range; the distance is returned too
+// MLAT, TIS-B and other positions are left out, as the receiver may hear
+// such aircraft without ever getting a position
+func (c *coverageComparison) expected(a *models.Aircraft) (float64, bool) {
+	pos, ok := a.Position()
+	if !ok || a.PositionSource() != models.PositionADSB || a.OnGround() {
+		return 0, false
+	}
+	if positionAge(a) > coverageMaxAge {
+		return 0, false
+	}
+	d := geo.DistanceNM(c.receiver, pos)
+	return d, d <= c.rangeNM
+}
+
+// fetch retrieves the aircraft the aggregator reports around the receiver
+func (c *coverageComparison) fetch(ctx context.Context) ([]models.Aircraft, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+	if c.apiKey != "" {
+		req.Header.Set(c.keyHeader, c.apiKey)
+		if c.keyHeader == "X-RapidAPI-Key" {
+			req.Header.Set("X-RapidAPI-Host", req.URL.Host)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch aggregator data: %w", err)
+	}
+	defer resp.Body.Close()
+	logging.DebugHTTPCtx(ctx, "GET", c.url, resp.StatusCode, time.Since(start))
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aggregator request failed with status: %s", resp.Status)
+	}
+	var data models.Dump1090fa
+	if err := decodeFlightData(resp.Body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode aggregator data: %w", err)
+	}
+	return data.Aircraft, nil
+}
@@ -0,0 +1,241 @@
+package flightdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+var (
+	// aircraftPool reuses aircraft slices between polls so busy receivers
+	// don't regrow a several-hundred element slice every cycle
+	aircraftPool = sync.Pool{
+		New: func() any {
+			s := make([]models.Aircraft, 0, 256)
+			return &s
+		},
+	}
+
+	// encoderPool reuses the buffers and encoders used to encode each
+	// aircraft's log body
+	encoderPool = sync.Pool{
+		New: func() any {
+			e := &aircraftEncoder{}
+			e.enc = json.NewEncoder(&e.buf)
+			e.enc.SetEscapeHTML(false)
+			return e
+		},
+	}
+
+	// numericFields are the indexes of the optional *int and *float64 fields
+	// of models.Aircraft, see presetNumeric
+	intFields, floatFields []int
+	numericFieldsOnce      sync.Once
+)
+
+// aircraftEncoder is an encoder writing into its own buffer
+type aircraftEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// unsetInt marks a preset int field the document did not set; a float field
+// is marked with NaN, which JSON can't hold
+const unsetInt = math.MinInt
+
+// acquireFlightData returns an empty document backed by a pooled aircraft slice
+// The caller must hand it back with releaseFlightData once it is no longer used
+func acquireFlightData() *models.Dump1090fa {
+	s := aircraftPool.Get().(*[]models.Aircraft)
+	return &models.Dump1090fa{Aircraft: (*s)[:0]}
+}
+
+// releaseFlightData returns the document's aircraft slice to the pool
+func releaseFlightData(data *models.Dump1090fa) {
+	s := data.Aircraft
+	clear(s)
+	s = s[:0]
+	data.Aircraft = nil
+	aircraftPool.Put(&s)
+}
+
+// decodeFlightData streams an aircraft.json document into data, decoding the
+// aircraft array one element at a time instead of buffering the whole document
+func decodeFlightData(r io.Reader, data *models.Dump1090fa) error {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected token %v, expected object key", tok)
+		}
+
+		switch key {
+		case "now":
+			err = dec.Decode(&data.Now)
+		case "messages":
+			err = dec.Decode(&data.Messages)
+		case "aircraft", "ac":
+			// Aggregator APIs in the ADSBExchange v2 format call the array "ac"
+			err = decodeAircraftArray(dec, data)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode %q: %w", key, err)
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+// DecodeDocument decodes an aircraft.json document the way polls are decoded,
+// accepting the "ac" array of aggregator APIs and the FLIGHT_DATA_SCHEMA mode
+func DecodeDocument(r io.Reader) (*models.Dump1090fa, error) {
+	data := &models.Dump1090fa{}
+	if err := decodeFlightData(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeAircraftArray decodes each element of the aircraft array in place
+func decodeAircraftArray(dec *json.Decoder, data *models.Dump1090fa) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		// "aircraft": null
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("unexpected token %v, expected '['", tok)
+	}
+
+	decode := func(a *models.Aircraft) error { return dec.Decode(a) }
+	if isTolerant() {
+		decode = func(a *models.Aircraft) error { return decodeAircraftTolerant(dec, a) }
+	}
+	for dec.More() {
+		data.Aircraft = append(data.Aircraft, models.Aircraft{})
+		a := &data.Aircraft[len(data.Aircraft)-1]
+		presetNumeric(a)
+		err := decode(a)
+		clearUnset(a)
+		if err != nil {
+			return fmt.Errorf("aircraft %d: %w", len(data.Aircraft)-1, err)
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
+
+// getNumericFields returns the indexes of the optional int and float fields
+func getNumericFields() ([]int, []int) {
+	numericFieldsOnce.Do(func() {
+		t := reflect.TypeOf(models.Aircraft{})
+		for i := 0; i < t.NumField(); i++ {
+			switch t.Field(i).Type {
+			case reflect.TypeOf((*int)(nil)):
+				intFields = append(intFields, i)
+			case reflect.TypeOf((*float64)(nil)):
+				floatFields = append(floatFields, i)
+			}
+		}
+	})
+	return intFields, floatFields
+}
+
+// presetNumeric points the optional numeric fields of an aircraft at values
+// allocated together and marked unset, so the decoder fills them in place
+// instead of allocating each field it finds
+func presetNumeric(a *models.Aircraft) {
+	ints, floats := getNumericFields()
+	v := reflect.ValueOf(a).Elem()
+	intValues := make([]int, len(ints))
+	for k, i := range ints {
+		intValues[k] = unsetInt
+		v.Field(i).Set(reflect.ValueOf(&intValues[k]))
+	}
+	floatValues := make([]float64, len(floats))
+	for k, i := range floats {
+		floatValues[k] = math.NaN()
+		v.Field(i).Set(reflect.ValueOf(&floatValues[k]))
+	}
+}
+
+// clearUnset drops the preset fields the document left unset
+func clearUnset(a *models.Aircraft) {
+	ints, floats := getNumericFields()
+	v := reflect.ValueOf(a).Elem()
+	for _, i := range ints {
+		if p := v.Field(i).Interface().(*int); p != nil && *p == unsetInt {
+			v.Field(i).SetZero()
+		}
+	}
+	for _, i := range floats {
+		if p := v.Field(i).Interface().(*float64); p != nil && math.IsNaN(*p) {
+			v.Field(i).SetZero()
+		}
+	}
+}
+
+// encodeAircraft encodes an aircraft as JSON using a pooled buffer
+func encodeAircraft(aircraft *models.Aircraft) (string, error) {
+	e := encoderPool.Get().(*aircraftEncoder)
+	defer encoderPool.Put(e)
+	e.buf.Reset()
+
+	if err := e.enc.Encode(aircraft); err != nil {
+		return "", err
+	}
+
+	// Drop the trailing newline added by Encode
+	return string(bytes.TrimRight(e.buf.Bytes(), "\n")), nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected token %v, expected %q", tok, want)
+	}
+	return nil
+}
+
+// skipValue discards the next JSON value, whatever its type
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package flightdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+const benchmarkAircraftCount = 400
+
+// benchmarkDocument builds an aircraft.json document shaped like a busy receiver's output
+func benchmarkDocument(tb testing.TB) []byte {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"now":1718000000.1,"messages":123456789,"aircraft":[`)
+	for i := 0; i < benchmarkAircraftCount; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"hex":"%06x","type":"adsb_icao","flight":"TEST%03d ","r":"G-ABCD","t":"A320","desc":"AIRBUS A-320",`+
+			`"alt_baro":%d,"alt_geom":%d,"gs":431.2,"ias":280,"tas":452,"mach":0.776,"track":271.3,"baro_rate":-64,"geom_rate":-32,`+
+			`"squawk":"2345","category":"A3","nav_qnh":1013.2,"nav_altitude_mcp":36000,"nav_heading":270.0,"lat":51.%04d,"lon":-0.%04d,`+
+			`"nic":8,"rc":186,"seen_pos":0.4,"version":2,"nic_baro":1,"nac_p":9,"nac_v":1,"sil":3,"sil_type":"perhour","gva":2,"sda":2,`+
+			`"alert":0,"spi":0,"mlat":[],"tisb":[],"messages":1834,"seen":0.2,"rssi":-21.4}`,
+			0x400000+i, i, 35000+i, 35100+i, i, i)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+// BenchmarkDecodeFullDocument measures the previous approach of decoding the
+// whole document into a fresh struct and marshalling each aircraft separately
+func BenchmarkDecodeFullDocument(b *testing.B) {
+	doc := benchmarkDocument(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var data models.Dump1090fa
+		if err := json.NewDecoder(bytes.NewReader(doc)).Decode(&data); err != nil {
+			b.Fatal(err)
+		}
+		for j := range data.Aircraft {
+			if _, err := json.Marshal(data.Aircraft[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkDecodeStreaming measures the streaming decoder with pooled slices,
+// buffers and encoders and the numeric fields of each aircraft allocated together
+func BenchmarkDecodeStreaming(b *testing.B) {
+	doc := benchmarkDocument(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data := acquireFlightData()
+		if err := decodeFlightData(bytes.NewReader(doc), data); err != nil {
+			b.Fatal(err)
+		}
+		for j := range data.Aircraft {
+			if _, err := encodeAircraft(&data.Aircraft[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		releaseFlightData(data)
+	}
+}
+
+// setTolerant selects the schema mode for the duration of a test
+func setTolerant(t *testing.T, on bool) {
+	tolerantOnce.Do(func() {})
+	previous := tolerant
+	tolerant = on
+	t.Cleanup(func() { tolerant = previous })
+}
+
+func TestDecodeStreamingMatchesFullDocument(t *testing.T) {
+	setTolerant(t, false)
+	doc := benchmarkDocument(t)
+
+	var full models.Dump1090fa
+	if err := json.Unmarshal(doc, &full); err != nil {
+		t.Fatal(err)
+	}
+	streamed := acquireFlightData()
+	defer releaseFlightData(streamed)
+	if err := decodeFlightData(bytes.NewReader(doc), streamed); err != nil {
+		t.Fatal(err)
+	}
+
+	if streamed.Now != full.Now || streamed.Messages != full.Messages || len(streamed.Aircraft) != benchmarkAircraftCount {
+		t.Fatalf("now %v, messages %d, %d aircraft", streamed.Now, streamed.Messages, len(streamed.Aircraft))
+	}
+	for i := range full.Aircraft {
+		if !reflect.DeepEqual(streamed.Aircraft[i], full.Aircraft[i]) {
+			t.Fatalf("aircraft %d differs:\n%+v\n%+v", i, streamed.Aircraft[i], full.Aircraft[i])
+		}
+		body, err := encodeAircraft(&streamed.Aircraft[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := json.Marshal(full.Aircraft[i])
+		if body != string(want) {
+			t.Fatalf("aircraft %d encoded as %s, want %s", i, body, want)
+		}
+	}
+}
+
+func TestDecodeFlightData(t *testing.T) {
+	setTolerant(t, false)
+	tests := []struct {
+		name string
+		doc  string
+		hex  []string
+	}{
+		{"aircraft", `{"now":1718000000.5,"messages":10,"aircraft":[{"hex":"4ca7b4","alt_baro":"ground"},{"hex":"a1b2c3"}]}`, []string{"4ca7b4", "a1b2c3"}},
+		{"ac", `{"ac":[{"hex":"4ca7b4","alt_baro":12000}],"now":1718000000.5,"total":1,"ctime":1718000000512}`, []string{"4ca7b4"}},
+		{"unknown keys", `{"now":1718000000.5,"stats":{"a":[1,{"b":2}]},"aircraft":[{"hex":"4ca7b4"}],"version":"3.14"}`, []string{"4ca7b4"}},
+		{"null", `{"now":1718000000.5,"aircraft":null}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data models.Dump1090fa
+			if err := decodeFlightData(strings.NewReader(tt.doc), &data); err != nil {
+				t.Fatal(err)
+			}
+			if data.Now != 1718000000.5 || len(data.Aircraft) != len(tt.hex) {
+				t.Fatalf("now %v, %d aircraft", data.Now, len(data.Aircraft))
+			}
+			for i, hex := range tt.hex {
+				if data.Aircraft[i].Hex != hex {
+					t.Errorf("aircraft %d = %q, want %q", i, data.Aircraft[i].Hex, hex)
+				}
+			}
+		})
+	}
+}
+
+// mismatchedDocument has an unknown field and a field of the wrong type
+const mismatchedDocument = `{"now":1718000000.5,"aircraft":[{"hex":"4ca7b4","alt_baro":"","gs":"fast","track":90.5,"acas_ra":{"ara":"1000"}}]}`
+
+func TestDecodeStrictRejectsBadField(t *testing.T) {
+	setTolerant(t, false)
+	var data models.Dump1090fa
+	err := decodeFlightData(strings.NewReader(mismatchedDocument), &data)
+	if err == nil || !strings.Contains(err.Error(), "aircraft 0") {
+		t.Errorf("err = %v, want an error for aircraft 0", err)
+	}
+}
+
+func TestDecodeTolerantKeepsExtra(t *testing.T) {
+	setTolerant(t, true)
+	var data models.Dump1090fa
+	if err := decodeFlightData(strings.NewReader(mismatchedDocument), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Aircraft) != 1 {
+		t.Fatalf("%d aircraft", len(data.Aircraft))
+	}
+	a := data.Aircraft[0]
+	if a.Hex != "4ca7b4" || a.Track == nil || *a.Track != 90.5 || a.Gs != nil || a.AltBaro != nil {
+		t.Errorf("hex %q, track %v, gs %v, alt_baro %v", a.Hex, a.Track, a.Gs, a.AltBaro)
+	}
+	if len(a.Extra) != 2 || string(a.Extra["gs"]) != `"fast"` || string(a.Extra["acas_ra"]) != `{"ara":"1000"}` {
+		t.Errorf("extra %v", a.Extra)
+	}
+}
+
+func TestDecodePresetNumericFields(t *testing.T) {
+	setTolerant(t, false)
+	doc := `{"aircraft":[{"hex":"4ca7b4","gs":0,"baro_rate":0,"lat":null,"nic":8},{"hex":"a1b2c3","track":-0.5}]}`
+	var data models.Dump1090fa
+	if err := decodeFlightData(strings.NewReader(doc), &data); err != nil {
+		t.Fatal(err)
+	}
+	a, b := data.Aircraft[0], data.Aircraft[1]
+	if a.Gs == nil || *a.Gs != 0 || a.BaroRate == nil || *a.BaroRate != 0 || a.Nic == nil || *a.Nic != 8 {
+		t.Errorf("gs %v, baro_rate %v, nic %v", a.Gs, a.BaroRate, a.Nic)
+	}
+	if a.Lat != nil || a.Track != nil || a.Rc != nil || a.AltGeom != nil {
+		t.Errorf("absent fields set: lat %v, track %v, rc %v, alt_geom %v", a.Lat, a.Track, a.Rc, a.AltGeom)
+	}
+	if b.Track == nil || *b.Track != -0.5 || b.Gs != nil || b.Nic != nil {
+		t.Errorf("track %v, gs %v, nic %v", b.Track, b.Gs, b.Nic)
+	}
+}
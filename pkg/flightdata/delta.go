@@ -0,0 +1,148 @@
+package flightdata
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// Modes for emitting aircraft records
+const (
+	// exportFull emits every field of every aircraft each poll
+	exportFull = "full"
+	// exportDelta emits only the fields that changed since the aircraft's previous record
+	exportDelta = "delta"
+)
+
+// deltaExpiry is how long the last record of an aircraft is kept after it
+// was last emitted; an aircraft seen again after that gets a full record
+const deltaExpiry = 10 * time.Minute
+
+// deltaState is what was last emitted for an aircraft, with every field
+type deltaState struct {
+	body  map[string]json.RawMessage
+	attrs map[string]otellog.Value
+	// full is when the last full record was emitted
+	full time.Time
+	seen time.Time
+}
+
+// deltaEncoder reduces records to the fields that changed since the
+// aircraft's previous record, sending a full record when an aircraft is
+// first seen and every keyframe interval so consumers can resynchronize
+type deltaEncoder struct {
+	keyframe time.Duration
+
+	mu    sync.Mutex
+	state map[string]*deltaState
+}
+
+var (
+	delta     *deltaEncoder
+	deltaOnce sync.Once
+)
+
+// getDeltaEncoder returns the encoder if EXPORT_MODE is delta, otherwise nil
+// EXPORT_DELTA_KEYFRAME sets how often a full record is sent
+func getDeltaEncoder() *deltaEncoder {
+	deltaOnce.Do(func() {
+		mode := strings.ToLower(strings.TrimSpace(getEnvOrDefault("EXPORT_MODE", exportFull)))
+		switch mode {
+		case exportFull:
+			return
+		case exportDelta:
+		default:
+			logging.Warn("Invalid export mode, using full", "key", "EXPORT_MODE", "value", mode)
+			return
+		}
+		delta = &deltaEncoder{
+			keyframe: getEnvDurationOrDefault("EXPORT_DELTA_KEYFRAME", 5*time.Minute),
+			state:    make(map[string]*deltaState),
+		}
+		logging.Info("Delta export enabled", "keyframe", delta.keyframe)
+	})
+	return delta
+}
+
+// alwaysAttributes are kept in delta records so they can be attributed
+var alwaysAttributes = map[string]bool{"service": true, "aircraft.hex": true}
+
+// apply returns the body and attributes to emit for an aircraft, reduced to
+// what changed unless a full record is due, and whether anything changed
+// Fields that are no longer reported are sent as null in the body
+func (d *deltaEncoder) apply(hex, body string, attrs []otellog.KeyValue, now time.Time) (string, []otellog.KeyValue, bool) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(body), &fields); err != nil {
+		// Not an object, which the encoder never produces; send it as it is
+		return body, attrs, true
+	}
+	values := make(map[string]otellog.Value, len(attrs))
+	for _, kv := range attrs {
+		values[kv.Key] = kv.Value
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev := d.state[hex]
+	next := &deltaState{body: fields, attrs: values, full: now, seen: now}
+	d.state[hex] = next
+	if prev == nil || now.Sub(prev.full) >= d.keyframe {
+		return body, append(attrs, otellog.Bool("aircraft.delta", false)), true
+	}
+	next.full = prev.full
+
+	changed := make(map[string]json.RawMessage)
+	bodyChanged := false
+	for key, value := range fields {
+		if old, ok := prev.body[key]; !ok || string(old) != string(value) {
+			changed[key] = value
+			bodyChanged = true
+		}
+	}
+	for key := range prev.body {
+		if _, ok := fields[key]; !ok {
+			changed[key] = json.RawMessage("null")
+			bodyChanged = true
+		}
+	}
+	if hex, ok := fields["hex"]; ok {
+		changed["hex"] = hex
+	}
+
+	var changedAttrs []otellog.KeyValue
+	attrsChanged := false
+	for _, kv := range attrs {
+		if alwaysAttributes[kv.Key] {
+			changedAttrs = append(changedAttrs, kv)
+		} else if old, ok := prev.attrs[kv.Key]; !ok || !old.Equal(kv.Value) {
+			changedAttrs = append(changedAttrs, kv)
+			attrsChanged = true
+		}
+	}
+
+	if !bodyChanged && !attrsChanged {
+		return "", nil, false
+	}
+	encoded, err := json.Marshal(changed)
+	if err != nil {
+		return body, append(attrs, otellog.Bool("aircraft.delta", false)), true
+	}
+	return string(encoded), append(changedAttrs, otellog.Bool("aircraft.delta", true)), true
+}
+
+// prune forgets aircraft that have not been emitted for a while
+func (d *deltaEncoder) prune(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for hex, state := range d.state {
+		if now.Sub(state.seen) > deltaExpiry {
+			delete(d.state, hex)
+		}
+	}
+}
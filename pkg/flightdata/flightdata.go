@@ -1,33 +1,69 @@
 package flightdata
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/burnettdev/adsb2otel/pkg/blackbox"
+	"github.com/burnettdev/adsb2otel/pkg/crash"
+	"github.com/burnettdev/adsb2otel/pkg/dedupe"
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/fields"
 	"github.com/burnettdev/adsb2otel/pkg/logging"
 	"github.com/burnettdev/adsb2otel/pkg/models"
 	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+	"github.com/burnettdev/adsb2otel/pkg/sinks"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
 )
 
-var (
-	httpClient = &http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport),
-		Timeout:   30 * time.Second,
+var tracer = otel.Tracer("flightdata-client")
+
+// observationEvent is the event name of the record emitted for each aircraft
+const observationEvent = "aircraft.observation"
+
+func FetchAndPushLogs(ctx context.Context) (err error) {
+	// Poll less often while the source is down rather than failing every cycle
+	sourceBreaker := getBreaker()
+	if !sourceBreaker.allow(time.Now()) {
+		// The additional receivers are still exported while it is down
+		pushReceivers(ctx, &blackbox.Cycle{Start: time.Now()})
+		return ErrBackingOff
 	}
-	tracer = otel.Tracer("flightdata-client")
-)
+	fetched := false
+	defer func() {
+		switch {
+		case fetched:
+			sourceBreaker.record(ctx, time.Now(), nil)
+		case err != nil && ctx.Err() == nil:
+			// A fetch timing out counts as a failure, shutting down does not
+			sourceBreaker.record(ctx, time.Now(), err)
+		}
+	}()
+
+	// Keep a summary of the cycle for diagnostic bundles
+	cycle := blackbox.Cycle{Start: time.Now()}
+	defer func() {
+		cycle.Duration = time.Since(cycle.Start)
+		if err != nil {
+			cycle.Error = err.Error()
+		}
+		blackbox.RecordCycle(cycle)
+	}()
 
-func FetchAndPushLogs(ctx context.Context) error {
 	ctx, span := tracer.Start(ctx, "flightdata.fetch_and_push",
 		trace.WithAttributes(
 			attribute.String("service", "adsb"),
@@ -35,6 +71,13 @@ func FetchAndPushLogs(ctx context.Context) error {
 	)
 	defer span.End()
 
+	// Export the additional receivers on their own if the main receiver fails
+	defer func() {
+		if !fetched && err != nil && ctx.Err() == nil {
+			pushReceivers(ctx, &cycle)
+		}
+	}()
+
 	logging.DebugCallCtx(ctx, "FetchAndPushLogs")
 
 	flightDataURL := os.Getenv("FLIGHT_DATA_URL")
@@ -45,18 +88,31 @@ func FetchAndPushLogs(ctx context.Context) error {
 		attribute.String("http.method", "GET"),
 	)
 
+	// Bound the fetch, including retries and reading the body, so a stalled
+	// receiver doesn't overlap the next cycle
+	fetchCtx, cancelFetch := context.WithTimeout(ctx, getFetchTimeout())
+	defer cancelFetch()
+
 	// Create HTTP request with context for automatic tracing via otelhttp
-	req, err := http.NewRequestWithContext(ctx, "GET", flightDataURL, nil)
-		if err != nil {
+	req, err := http.NewRequestWithContext(fetchCtx, "GET", flightDataURL, nil)
+	if err != nil {
 		span.RecordError(err)
 		logging.ErrorCtx(ctx, "Failed to create HTTP request", "error", err, "url", flightDataURL)
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("User-Agent", "adsb2otel/1.0.0")
+	useragent.Set(req)
+
+	client, err := getHTTPClient()
+	if err != nil {
+		span.RecordError(err)
+		logging.ErrorCtx(ctx, "Failed to configure HTTP client", "error", err)
+		return fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
 
 	start := time.Now()
-	resp, err := httpClient.Do(req)
+	resp, err := getRetryPolicy().do(fetchCtx, client, req)
 	duration := time.Since(start)
+	recordFetch(ctx, duration, err)
 
 	if err != nil {
 		span.RecordError(err)
@@ -69,18 +125,90 @@ func FetchAndPushLogs(ctx context.Context) error {
 	logging.DebugHTTPCtx(ctx, "GET", flightDataURL, resp.StatusCode, duration)
 
 	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+		err := &statusError{code: resp.StatusCode, status: resp.Status}
 		span.RecordError(err)
 		logging.ErrorCtx(ctx, "HTTP request returned non-200 status", "status_code", resp.StatusCode, "status", resp.Status)
 		return err
 	}
 
-	var data models.Dump1090fa
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	data := acquireFlightData()
+	defer releaseFlightData(data)
+
+	// Keep a copy of the document while decoding it if it is to be recorded
+	format := sourceFormat(flightDataURL)
+	var body io.Reader = resp.Body
+	var raw *bytes.Buffer
+	if recorder != nil {
+		raw = recorder.buffer()
+		if format == formatJSON {
+			body = io.TeeReader(resp.Body, raw)
+		}
+	}
+
+	_, decodeSpan := tracer.Start(ctx, "flightdata.decode", trace.WithAttributes(attribute.String("data.format", format)))
+	if err := decodeSource(body, format, data); err != nil {
+		if raw != nil {
+			recorder.discard(raw)
+		}
+		decodeSpan.RecordError(err)
+		decodeSpan.End()
 		span.RecordError(err)
 		logging.ErrorCtx(ctx, "Failed to decode dump1090-fa data", "error", err)
 		return fmt.Errorf("failed to decode dump1090-fa data: %w", err)
 	}
+	decodeSpan.SetAttributes(
+		attribute.Int("aircraft.count", len(data.Aircraft)),
+		attribute.Int("data.messages", data.Messages),
+	)
+	decodeSpan.End()
+	fetched = true
+	if raw != nil {
+		// Other formats are recorded as aircraft.json, so captures can be replayed alike
+		if format != formatJSON {
+			if err := json.NewEncoder(raw).Encode(data); err != nil {
+				logging.WarnCtx(ctx, "Failed to encode the poll for recording, skipping it", "error", err)
+				recorder.discard(raw)
+				raw = nil
+			}
+		}
+		if raw != nil {
+			recorder.record(raw, data.Now)
+		}
+	}
+	return push(ctx, span, data, start.Add(duration), &cycle)
+}
+
+// Push runs a recorded poll through the pipeline like a fetched one, with the
+// receiver's timestamp taken as is, for replaying captures
+func Push(ctx context.Context, data *models.Dump1090fa) error {
+	ctx, span := tracer.Start(ctx, "flightdata.replay")
+	defer span.End()
+	return push(ctx, span, data, time.UnixMilli(int64(data.Now*1000)), &blackbox.Cycle{})
+}
+
+// pushReceivers runs the reports of the additional receivers through the
+// pipeline without the main receiver's, if any are configured, so they are
+// not lost while the main receiver is down
+func pushReceivers(ctx context.Context, cycle *blackbox.Cycle) {
+	if getReceiverPool() == nil {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "flightdata.push_receivers")
+	defer span.End()
+
+	// Without the receiver's timestamp the poll is stamped with the local time
+	data := acquireFlightData()
+	defer releaseFlightData(data)
+	if err := push(ctx, span, data, time.Now(), cycle); err != nil {
+		span.RecordError(err)
+		logging.ErrorCtx(ctx, "Failed to push the additional receivers' aircraft", "error", err)
+	}
+}
+
+// push filters and enriches a decoded poll, emits its records and writes it
+// to the sinks, with received the time the poll was fetched
+func push(ctx context.Context, span trace.Span, data *models.Dump1090fa, received time.Time, cycle *blackbox.Cycle) error {
+	messages.observe(ctx, data.Messages, data.Now)
 
 	span.SetAttributes(
 		attribute.Int("aircraft.count", len(data.Aircraft)),
@@ -90,71 +218,329 @@ func FetchAndPushLogs(ctx context.Context) error {
 
 	logging.DebugCtx(ctx, "Successfully parsed flight data", "aircraft_count", len(data.Aircraft), "timestamp", data.Now, "messages", data.Messages)
 
+	// Note the receiver's own aircraft for the coverage comparison, if configured
+	observeCoverage(data.Aircraft)
+
+	// Filter the poll and merge in positions from other feeds
+	filterCtx, filterSpan := tracer.Start(ctx, "flightdata.filter",
+		trace.WithAttributes(attribute.Int("aircraft.input", len(data.Aircraft))),
+	)
+
+	// Merge in the reports of the additional receivers, if configured
+	fromReceivers, overlapping := mergeReceivers(filterCtx, data)
+	if fromReceivers > 0 || overlapping > 0 {
+		logging.DebugCtx(ctx, "Merged aircraft from additional receivers", "added", fromReceivers, "merged", overlapping)
+		span.SetAttributes(
+			attribute.Int("aircraft.receivers_added", fromReceivers),
+			attribute.Int("aircraft.receivers_merged", overlapping),
+		)
+	}
+
+	// Drop marginal decodes before satellite positions, which carry no signal, are merged in
+	signalFilter := getSignalFilter()
+	var weak int
+	data.Aircraft, weak = signalFilter.apply(data.Aircraft)
+	if weak > 0 {
+		logging.DebugCtx(ctx, "Dropped aircraft with a weak signal", "aircraft_count", weak)
+		span.SetAttributes(attribute.Int("aircraft.weak_signal", weak))
+		weakSignalCounter.Add(ctx, int64(weak))
+	}
+
+	// Fill in positions from separately published MLAT results, if configured
+	merged := mergeMlat(filterCtx, data)
+	if merged > 0 {
+		logging.DebugCtx(ctx, "Merged MLAT positions", "aircraft_count", merged)
+		span.SetAttributes(attribute.Int("aircraft.mlat_merged", merged))
+	}
+
+	// Fill coverage gaps from the satellite feed, if configured
+	added := mergeSatellite(filterCtx, data)
+	if added > 0 {
+		logging.DebugCtx(ctx, "Added satellite positions", "aircraft_count", added)
+		span.SetAttributes(attribute.Int("aircraft.satellite", added))
+	}
+
+	// Add gliders and other FLARM traffic from the OGN feed, if configured
+	if gliders := mergeOGN(filterCtx, data); gliders > 0 {
+		span.SetAttributes(attribute.Int("aircraft.ogn", gliders))
+	}
+
+	// Add drones from the Remote ID receiver, if configured
+	if drones := mergeRemoteID(filterCtx, data); drones > 0 {
+		logging.DebugCtx(ctx, "Added Remote ID drones", "aircraft_count", drones)
+		span.SetAttributes(attribute.Int("aircraft.uas", drones))
+	}
+
+	// Add aircraft decoded from the raw Mode S input, if configured
+	if decoded := mergeRaw(filterCtx, data); decoded > 0 {
+		span.SetAttributes(attribute.Int("aircraft.raw", decoded))
+	}
+
+	// Drop aircraft the receiver stopped hearing from a while ago
+	ageFilter := getAgeFilter()
+	var stale int
+	data.Aircraft, stale = ageFilter.apply(data.Aircraft)
+	if stale > 0 {
+		logging.DebugCtx(ctx, "Dropped stale aircraft", "aircraft_count", stale)
+		span.SetAttributes(attribute.Int("aircraft.stale", stale))
+		staleCounter.Add(ctx, int64(stale))
+	}
+
+	// Merge or flag aircraft reported under more than one address
+	ghosts := dedupe.MergeGhosts(data.Aircraft, dedupe.GetMode())
+	if ghosts.Ghosts > 0 {
+		logging.DebugCtx(ctx, "Detected ghost aircraft", "ghosts", ghosts.Ghosts, "aliases", ghosts.Aliases)
+	}
+	span.SetAttributes(attribute.Int("aircraft.ghosts", ghosts.Ghosts))
+	filterSpan.SetAttributes(
+		attribute.Int("aircraft.count", len(ghosts.Aircraft)),
+		attribute.Int("aircraft.receivers_added", fromReceivers),
+		attribute.Int("aircraft.receivers_merged", overlapping),
+		attribute.Int("aircraft.weak_signal", weak),
+		attribute.Int("aircraft.mlat_merged", merged),
+		attribute.Int("aircraft.satellite", added),
+		attribute.Int("aircraft.stale", stale),
+		attribute.Int("aircraft.ghosts", ghosts.Ghosts),
+	)
+	filterSpan.End()
+
+	// Flagged ghosts are exported but not counted twice in the metrics
+	counted := ghosts.Primary()
+	aircraftGauge.Record(ctx, int64(len(counted)))
+	recordCategories(ctx, counted)
+	recordSourceTypes(ctx, counted)
+	recordCoverage(ctx, counted)
+	recordDistributions(ctx, counted)
+	cycle.Aircraft = len(ghosts.Aircraft)
+
+	timestamp := getSkewDetector().timestamp(ctx, data.Now, received)
+	if perAircraft := getAircraftMetrics(); perAircraft != nil {
+		perAircraft.observe(ctx, timestamp, counted)
+	}
+
 	// Get logger instance
 	logger := logs.GetLogger("flightdata")
+
+	// Detect rapid descents and airport movements whether or not OTLP logs
+	// are exported, as the detectors also log and count them and the
+	// movement tracker must see every poll to notice a takeoff or landing
+	routes := routing.Get()
+	descent := getDescentDetector()
+	defer descent.end()
+	airports := getMovementTracker()
+	var nearAirport [][]otellog.KeyValue
+	if airports != nil {
+		nearAirport = make([][]otellog.KeyValue, len(ghosts.Aircraft))
+		defer airports.prune(timestamp)
+	}
+	isMuted := make([]bool, len(ghosts.Aircraft))
+	muted := 0
+	for i := range ghosts.Aircraft {
+		aircraft := &ghosts.Aircraft[i]
+		crash.SetLastRecord(aircraft.Hex, timestamp)
+
+		if isMuted[i] = routes.Muted(aircraft); isMuted[i] {
+			muted++
+			continue
+		}
+		descent.check(ctx, logger, aircraft, timestamp)
+		if airports != nil {
+			nearAirport[i] = airports.observe(ctx, logger, aircraft, timestamp)
+		}
+	}
+	if muted > 0 {
+		span.SetAttributes(attribute.Int("aircraft.muted", muted))
+	}
+
 	if logger == nil {
-		logging.WarnCtx(ctx, "OpenTelemetry logger not initialized, skipping log emission")
+		// Without OTLP logs, e.g. SINKS=loki, only the sinks are written
+		if logs.Enabled() {
+			logging.WarnCtx(ctx, "OpenTelemetry logger not initialized, skipping log emission")
+		}
+		exportCtx, exportSpan := tracer.Start(ctx, "flightdata.export")
+		writeSinks(exportCtx, exportSpan, timestamp, ghosts.Aircraft)
+		exportSpan.End()
 		return nil
 	}
 
-	// Emit log records for each aircraft
-	timestamp := time.Unix(int64(data.Now), 0)
-	logsEmitted := 0
+	// Select the aircraft to emit records for and build their records
+	_, enrichSpan := tracer.Start(ctx, "flightdata.enrich")
+	exportFilter := fields.Get()
+	tagger := getInterestTagger()
+	severities := getSeverityMapper()
+	units := getUnitConverter()
+	deltas := getDeltaEncoder()
+	if deltas != nil {
+		defer deltas.prune(timestamp)
+	}
+	unchanged := 0
 
-	for i, aircraft := range data.Aircraft {
-		logging.DebugCtx(ctx, "Processing aircraft", "index", i, "hex", aircraft.Hex, "flight", aircraft.Flight, "lat", aircraft.Lat, "lon", aircraft.Lon, "alt_baro", aircraft.AltBaro.String())
+	candidates := make([]int, 0, len(ghosts.Aircraft))
+	for i := range ghosts.Aircraft {
+		if isMuted[i] {
+			continue
+		}
+		aircraft := &ghosts.Aircraft[i]
+		var values routing.Values
+		if routes.Conditional() || routes.Filtered(routing.OTLP) {
+			values = routing.ValuesOf(aircraft)
+		}
+		if routes.Active() && !routes.Allows(routing.Classify(aircraft), routing.OTLP, values) {
+			continue
+		}
+		if routes.Filtered(routing.OTLP) && !routes.Match(routing.OTLP, values) {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+
+	// Cap the records emitted per poll so busy airspace doesn't overload the backend
+	kept, dropped := getRecordLimit().apply(ghosts.Aircraft, candidates)
+	if dropped > 0 {
+		logsDroppedCounter.Add(ctx, int64(dropped))
+		logging.DebugCtx(ctx, "Dropped aircraft over the per-poll record limit", "dropped", dropped, "limit", len(kept))
+	}
+	span.SetAttributes(attribute.Int("otel.logs_dropped", dropped))
+
+	// How old aircraft.json was when fetched, left out when the clocks
+	// disagree by more than a poll interval
+	fileAge := received.Sub(time.UnixMilli(int64(data.Now * 1000))).Seconds()
+	if fileAge < 0 || fileAge > PollInterval.Seconds() {
+		fileAge = 0
+	}
+
+	records := make([]otellog.Record, 0, len(kept))
+	parseErrors := 0
+	for _, i := range kept {
+		aircraft := &ghosts.Aircraft[i]
 
-		aircraftJSON, err := json.Marshal(aircraft)
+		altitude, _ := aircraft.AltitudeFeet()
+		logging.DebugCtx(ctx, "Processing aircraft", "index", i, "hex", aircraft.Hex, "flight", aircraft.Flight, "has_position", aircraft.HasPosition(), "altitude_ft", altitude)
+
+		// A record that can't be built is skipped rather than failing the
+		// whole poll
+		aircraftJSON, err := encodeAircraft(aircraft)
+		if err != nil {
+			enrichSpan.RecordError(err)
+			parseErrorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", "marshal")))
+			logging.ErrorCtx(ctx, "Failed to marshal aircraft data, skipping it", "error", err, "aircraft_hex", aircraft.Hex)
+			parseErrors++
+			continue
+		}
+
+		// Drop excluded fields from the body and collect any extra attributes
+		aircraftJSON, extraAttrs, err := exportFilter.Apply(aircraftJSON)
 		if err != nil {
-			logging.ErrorCtx(ctx, "Failed to marshal aircraft data", "error", err, "aircraft_hex", aircraft.Hex)
-			return fmt.Errorf("failed to marshal aircraft data: %w", err)
+			enrichSpan.RecordError(err)
+			parseErrorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", "filter")))
+			logging.ErrorCtx(ctx, "Failed to apply export field filter, skipping the aircraft", "error", err, "aircraft_hex", aircraft.Hex)
+			parseErrors++
+			continue
 		}
 
 		// Build attributes for the log record
-		attrs := []otellog.KeyValue{
-			otellog.String("service", "adsb"),
-			otellog.String("aircraft.hex", aircraft.Hex),
-			otellog.String("aircraft.type", aircraft.Type),
+		attrs := aircraftAttributes(aircraft, exportFilter)
+		attrs = append(attrs, extraAttrs...)
+		attrs = units.apply(attrs)
+		attrs = append(attrs, ageFilter.attributes()...)
+		attrs = append(attrs, signalFilter.attributes()...)
+		if nearAirport != nil {
+			attrs = append(attrs, nearAirport[i]...)
 		}
 
-		// Add optional fields as attributes
-		if aircraft.Flight != "" {
-			attrs = append(attrs, otellog.String("aircraft.flight", aircraft.Flight))
+		if aliases := ghosts.Aliases[aircraft.Hex]; len(aliases) > 0 {
+			attrs = append(attrs, otellog.String("aircraft.aliases", strings.Join(aliases, ",")))
 		}
-		if aircraft.Lat != 0 {
-			attrs = append(attrs, otellog.Float64("aircraft.lat", aircraft.Lat))
+		if ghostOf := ghosts.GhostOf[i]; ghostOf != "" {
+			attrs = append(attrs, otellog.String("aircraft.ghost_of", ghostOf))
 		}
-		if aircraft.Lon != 0 {
-			attrs = append(attrs, otellog.Float64("aircraft.lon", aircraft.Lon))
+
+		if route, ok := enrich.LookupRoute(aircraft); ok {
+			attrs = append(attrs, route.Attributes()...)
 		}
-		if aircraft.AltBaro.String() != "" {
-			attrs = append(attrs, otellog.String("aircraft.alt_baro", aircraft.AltBaro.String()))
+
+		// Tag military, special-interest and watched aircraft and set the
+		// record's severity from the conditions it meets
+		categories := tagger.categories(aircraft)
+		attrs = append(attrs, tags(categories)...)
+		if severities.watched(aircraft) {
+			attrs = append(attrs, otellog.Bool("aircraft.watchlist", true))
 		}
-		if aircraft.Squawk != "" {
-			attrs = append(attrs, otellog.String("aircraft.squawk", aircraft.Squawk))
+		severity := severities.severity(aircraft, categories)
+
+		// In delta mode, reduce the record to what changed since the aircraft's previous one
+		if deltas != nil {
+			var changed bool
+			if aircraftJSON, attrs, changed = deltas.apply(aircraft.Hex, aircraftJSON, attrs, timestamp); !changed {
+				unchanged++
+				continue
+			}
 		}
 
 		// Create log record with trace context
 		record := otellog.Record{}
+		record.SetEventName(observationEvent)
 		record.SetTimestamp(timestamp)
-		record.SetSeverity(otellog.SeverityInfo)
-		record.SetBody(otellog.StringValue(string(aircraftJSON)))
-		
+		// The poll's fetch time, from which the export latency is measured
+		record.SetObservedTimestamp(received)
+		record.SetSeverity(severity)
+		record.SetBody(otellog.StringValue(aircraftJSON))
+
 		// Add attributes to the record
 		record.AddAttributes(attrs...)
+		records = append(records, record)
+		receiveLatency.Record(ctx, fileAge+aircraft.Seen)
+	}
 
-		// Emit log record
-		logger.Emit(ctx, record)
+	enrichSpan.SetAttributes(
+		attribute.Int("aircraft.muted", muted),
+		attribute.Int("aircraft.candidates", len(candidates)),
+		attribute.Int("otel.logs_dropped", dropped),
+		attribute.Int("otel.records", len(records)),
+		attribute.Int("otel.parse_errors", parseErrors),
+	)
+	if deltas != nil {
+		enrichSpan.SetAttributes(attribute.Int("otel.logs_unchanged", unchanged))
+	}
+	enrichSpan.End()
 
-		logsEmitted++
+	// Hand observations to any additional sinks and emit the log records
+	exportCtx, exportSpan := tracer.Start(ctx, "flightdata.export")
+	writeSinks(exportCtx, exportSpan, timestamp, ghosts.Aircraft)
+	for _, record := range records {
+		logger.Emit(exportCtx, record)
 	}
+	logsEmitted := len(records)
+	exportSpan.SetAttributes(attribute.Int("otel.logs_emitted", logsEmitted))
+	exportSpan.End()
 
 	logging.DebugCtx(ctx, "Converted aircraft data to OTel log records", "entries_count", logsEmitted)
 
 	span.SetAttributes(
 		attribute.Int("otel.logs_emitted", logsEmitted),
 	)
+	if deltas != nil {
+		span.SetAttributes(attribute.Int("otel.logs_unchanged", unchanged))
+	}
+	logsEmittedCounter.Add(ctx, int64(logsEmitted))
+	cycle.LogsEmitted = logsEmitted
 
-	logging.InfoCtx(ctx, "Successfully fetched and pushed aircraft data", "aircraft_count", len(data.Aircraft), "logs_emitted", logsEmitted)
+	logging.InfoCtx(ctx, "Successfully fetched and pushed aircraft data", "aircraft_count", len(ghosts.Aircraft), "logs_emitted", logsEmitted, "logs_dropped", dropped, "parse_errors", parseErrors)
 	return nil
 }
+
+// writeSinks hands a poll's aircraft to any additional sinks
+func writeSinks(ctx context.Context, span trace.Span, timestamp time.Time, aircraft []models.Aircraft) {
+	if !sinks.Enabled() {
+		return
+	}
+	observations := make([]sinks.Observation, len(aircraft))
+	for i := range aircraft {
+		observations[i] = sinks.Observation{Time: timestamp, Aircraft: aircraft[i]}
+	}
+	span.SetAttributes(attribute.Int("sink.observations", len(observations)))
+	if err := sinks.Write(ctx, observations); err != nil {
+		span.RecordError(err)
+		logging.ErrorCtx(ctx, "Failed to write observations to sinks", "error", err)
+	}
+}
@@ -0,0 +1,128 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/alerts"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Conditions detected from the receiver statistics, reported as the
+// receiver.condition attribute
+const (
+	conditionNoMessages     = "no_messages"
+	conditionStrongSignals  = "strong_signals"
+	conditionSamplesDropped = "samples_dropped"
+)
+
+var receiverAlertGauge, _ = meter.Int64Gauge("adsb2otel.receiver.alert",
+	metric.WithDescription("Whether a receiver health condition is active (1) or not (0), by condition"),
+	metric.WithUnit("1"),
+)
+
+// receiverHealth detects a receiver that stopped decoding, is overloaded by
+// too much gain or drops samples, as a wedged SDR otherwise goes unnoticed
+// while the decoder keeps serving an empty aircraft list
+// Each condition is reported once when it starts and once when it clears
+type receiverHealth struct {
+	// noMessages is how long no local messages may be received, 0 when disabled
+	noMessages time.Duration
+	// strongSignals is the percentage of messages above -3 dBFS that is too many, 0 when disabled
+	strongSignals int
+	// samplesDropped is the number of samples dropped per minute that is too many, 0 when disabled
+	samplesDropped int
+
+	// lastMessages is the message total seen and lastProgress when it last changed
+	lastMessages int64
+	lastProgress time.Time
+	active       map[string]bool
+}
+
+// newReceiverHealth returns the checks configured via STATS_ALERT_NO_MESSAGES,
+// STATS_ALERT_STRONG_SIGNALS and STATS_ALERT_SAMPLES_DROPPED, or nil if none is
+func newReceiverHealth() *receiverHealth {
+	h := &receiverHealth{
+		noMessages:     getEnvDurationOrDefault("STATS_ALERT_NO_MESSAGES", 0),
+		strongSignals:  getEnvIntOrDefault("STATS_ALERT_STRONG_SIGNALS", 0),
+		samplesDropped: getEnvIntOrDefault("STATS_ALERT_SAMPLES_DROPPED", 0),
+		lastMessages:   -1,
+		active:         make(map[string]bool),
+	}
+	if h.noMessages == 0 && h.strongSignals == 0 && h.samplesDropped == 0 {
+		return nil
+	}
+	logging.Info("Receiver health alerts enabled", "no_messages", h.noMessages, "strong_signals_percent", h.strongSignals, "samples_dropped", h.samplesDropped)
+	return h
+}
+
+// check evaluates the conditions against the latest statistics
+func (h *receiverHealth) check(ctx context.Context, stats *models.Stats, now time.Time) {
+	if h.noMessages > 0 {
+		// Messages demodulated locally, falling back to all messages for
+		// decoders without an SDR; a decoder that stopped updating
+		// stats.json shows no progress either
+		messages := stats.Total.Messages
+		if local := stats.Total.Local; local != nil {
+			messages = models.AcceptedMessages(local.Accepted)
+		}
+		if messages != h.lastMessages {
+			h.lastMessages, h.lastProgress = messages, now
+		}
+		silent := now.Sub(h.lastProgress)
+		h.update(ctx, conditionNoMessages, silent >= h.noMessages,
+			fmt.Sprintf("Receiver has not received any messages for %s", silent.Round(time.Second)),
+			otellog.Float64("receiver.silent_s", silent.Seconds()),
+		)
+	}
+
+	local := stats.Last1Min.Local
+	if local == nil {
+		return
+	}
+	if h.strongSignals > 0 {
+		if accepted := models.AcceptedMessages(local.Accepted); accepted > 0 {
+			percent := float64(local.StrongSignals) / float64(accepted) * 100
+			h.update(ctx, conditionStrongSignals, percent >= float64(h.strongSignals),
+				fmt.Sprintf("%.1f%% of messages are above -3 dBFS, the gain is likely too high", percent),
+				otellog.Float64("receiver.strong_signals_percent", percent),
+			)
+		}
+	}
+	if h.samplesDropped > 0 {
+		h.update(ctx, conditionSamplesDropped, local.SamplesDropped >= int64(h.samplesDropped),
+			fmt.Sprintf("Receiver dropped %d samples in the last minute, check the SDR's USB connection and CPU load", local.SamplesDropped),
+			otellog.Int64("receiver.samples_dropped", local.SamplesDropped),
+		)
+	}
+}
+
+// update records a condition and emits an event when it starts or clears
+func (h *receiverHealth) update(ctx context.Context, condition string, active bool, message string, attrs ...otellog.KeyValue) {
+	value := int64(0)
+	if active {
+		value = 1
+	}
+	receiverAlertGauge.Record(ctx, value, metric.WithAttributes(attribute.String("condition", condition)))
+	alerts.Get("receiver."+condition).Set("", active)
+
+	if active == h.active[condition] {
+		return
+	}
+	h.active[condition] = active
+
+	attrs = append(attrs, otellog.String("receiver.condition", condition))
+	if active {
+		logging.WarnCtx(ctx, message, "condition", condition)
+		emitReceiverEvent(ctx, "receiver.unhealthy", otellog.SeverityWarn, message, attrs...)
+		return
+	}
+	logging.InfoCtx(ctx, "Receiver health condition cleared", "condition", condition)
+	emitReceiverEvent(ctx, "receiver.healthy", otellog.SeverityInfo, "Receiver "+condition+" condition cleared", attrs...)
+}
@@ -0,0 +1,154 @@
+package flightdata
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// dbFlags bits set by readsb and tar1090 from their aircraft database
+const (
+	dbFlagMilitary    = 1 << 0
+	dbFlagInteresting = 1 << 1
+	dbFlagPIA         = 1 << 2
+	dbFlagLADD        = 1 << 3
+)
+
+// hexRange is an inclusive range of ICAO 24-bit addresses
+type hexRange struct {
+	from, to uint32
+}
+
+// Categories of aircraft tagged by the interest tagger, exported as
+// aircraft.<category> attributes
+const (
+	categoryMilitary    = "military"
+	categoryInteresting = "interesting"
+	categoryPIA         = "pia"
+	categoryLADD        = "ladd"
+)
+
+// interestTagger tags military and special-interest aircraft, from the
+// database flags the decoder reports and from configured address ranges
+type interestTagger struct {
+	military []hexRange
+}
+
+var (
+	interest     interestTagger
+	interestOnce sync.Once
+)
+
+// getInterestTagger returns the tagger configured via AIRCRAFT_MILITARY_HEX
+func getInterestTagger() interestTagger {
+	interestOnce.Do(func() {
+		var err error
+		if interest.military, err = parseHexRanges(os.Getenv("AIRCRAFT_MILITARY_HEX")); err != nil {
+			logging.Warn("Invalid AIRCRAFT_MILITARY_HEX, using database flags only", "error", err)
+		}
+		if len(interest.military) > 0 {
+			logging.Info("Military address ranges configured", "ranges", len(interest.military))
+		}
+	})
+	return interest
+}
+
+// categories returns the categories an aircraft falls in
+func (t interestTagger) categories(a *models.Aircraft) []string {
+	var categories []string
+	if a.DbFlags&dbFlagMilitary != 0 || t.isMilitary(a.Hex) {
+		categories = append(categories, categoryMilitary)
+	}
+	if a.DbFlags&dbFlagInteresting != 0 {
+		categories = append(categories, categoryInteresting)
+	}
+	if a.DbFlags&dbFlagPIA != 0 {
+		categories = append(categories, categoryPIA)
+	}
+	if a.DbFlags&dbFlagLADD != 0 {
+		categories = append(categories, categoryLADD)
+	}
+	return categories
+}
+
+// tags returns the attributes for categories
+func tags(categories []string) []otellog.KeyValue {
+	attrs := make([]otellog.KeyValue, len(categories))
+	for i, category := range categories {
+		attrs[i] = otellog.Bool("aircraft."+category, true)
+	}
+	return attrs
+}
+
+// isMilitary reports whether an address lies in a configured military range
+// Non-ICAO addresses, which decoders prefix with ~, never match
+func (t interestTagger) isMilitary(hex string) bool {
+	if len(t.military) == 0 || strings.HasPrefix(hex, "~") {
+		return false
+	}
+	addr, err := strconv.ParseUint(hex, 16, 24)
+	if err != nil {
+		return false
+	}
+	for _, r := range t.military {
+		if uint32(addr) >= r.from && uint32(addr) <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHexRanges parses comma separated address ranges such as
+// "ae0000-afffff" and prefixes such as "43c", which cover every address
+// starting with them
+func parseHexRanges(spec string) ([]hexRange, error) {
+	var ranges []hexRange
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry == "" {
+			continue
+		}
+
+		if from, to, ok := strings.Cut(entry, "-"); ok {
+			a, errFrom := strconv.ParseUint(strings.TrimSpace(from), 16, 24)
+			b, errTo := strconv.ParseUint(strings.TrimSpace(to), 16, 24)
+			if errFrom != nil || errTo != nil || a > b {
+				return nil, fmt.Errorf("invalid range %q", entry)
+			}
+			ranges = append(ranges, hexRange{from: uint32(a), to: uint32(b)})
+			continue
+		}
+
+		if len(entry) > 6 {
+			return nil, fmt.Errorf("invalid prefix %q", entry)
+		}
+		a, errFrom := strconv.ParseUint(entry+strings.Repeat("0", 6-len(entry)), 16, 24)
+		b, errTo := strconv.ParseUint(entry+strings.Repeat("f", 6-len(entry)), 16, 24)
+		if errFrom != nil || errTo != nil {
+			return nil, fmt.Errorf("invalid prefix %q", entry)
+		}
+		ranges = append(ranges, hexRange{from: uint32(a), to: uint32(b)})
+	}
+	return ranges, nil
+}
+
+// parseSeverity parses a log severity name
+func parseSeverity(name string) (otellog.Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return otellog.SeverityDebug, nil
+	case "info":
+		return otellog.SeverityInfo, nil
+	case "warn", "warning":
+		return otellog.SeverityWarn, nil
+	case "error":
+		return otellog.SeverityError, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q, expected debug, info, warn or error", name)
+}
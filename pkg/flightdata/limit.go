@@ -0,0 +1,92 @@
+package flightdata
+
+import (
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// Orders in which aircraft are dropped when a poll exceeds the record limit
+const (
+	// dropOldest drops the aircraft whose data is the most out of date first
+	dropOldest = "oldest"
+	// dropFurthest drops the aircraft furthest from the receiver first
+	dropFurthest = "furthest"
+)
+
+// recordLimit caps the log records emitted per poll
+type recordLimit struct {
+	max   int
+	order string
+}
+
+var (
+	limit     recordLimit
+	limitOnce sync.Once
+)
+
+// getRecordLimit returns the limit configured via LOGS_MAX_PER_POLL and LOGS_DROP_ORDER
+func getRecordLimit() recordLimit {
+	limitOnce.Do(func() {
+		if value := strings.TrimSpace(os.Getenv("LOGS_MAX_PER_POLL")); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				limit.max = n
+			} else {
+				logging.Warn("Invalid LOGS_MAX_PER_POLL, emitting every aircraft", "value", value)
+			}
+		}
+
+		limit.order = strings.ToLower(getEnvOrDefault("LOGS_DROP_ORDER", dropOldest))
+		if limit.order != dropOldest && limit.order != dropFurthest {
+			logging.Warn("Invalid LOGS_DROP_ORDER, using default", "value", limit.order, "default", dropOldest)
+			limit.order = dropOldest
+		}
+
+		if limit.max > 0 {
+			logging.Info("Log record limit enabled", "max_per_poll", limit.max, "drop_order", limit.order)
+		}
+	})
+	return limit
+}
+
+// apply returns the indices of the aircraft to emit, in their original order,
+// and how many were dropped to stay within the limit
+// Aircraft in an emergency are kept ahead of all others
+func (l recordLimit) apply(aircraft []models.Aircraft, candidates []int) ([]int, int) {
+	if l.max <= 0 || len(candidates) <= l.max {
+		return candidates, 0
+	}
+
+	ranked := slices.Clone(candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := &aircraft[ranked[i]], &aircraft[ranked[j]]
+		if ea, eb := routing.Classify(a) == routing.ClassEmergency, routing.Classify(b) == routing.ClassEmergency; ea != eb {
+			return ea
+		}
+		if l.order == dropFurthest {
+			return distance(a) < distance(b)
+		}
+		return a.Seen < b.Seen
+	})
+
+	kept := ranked[:l.max]
+	slices.Sort(kept)
+	return kept, len(candidates) - l.max
+}
+
+// distance returns the distance of an aircraft from the receiver, treating
+// aircraft without one as furthest away
+func distance(a *models.Aircraft) float64 {
+	if a.RDst == nil {
+		return math.Inf(1)
+	}
+	return *a.RDst
+}
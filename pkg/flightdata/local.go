@@ -0,0 +1,109 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// registerLocalProtocols lets a source's client fetch from local files and
+// from HTTP servers listening on unix sockets, for sources on the same host
+func registerLocalProtocols(transport *http.Transport) {
+	transport.RegisterProtocol("file", fileTransport{})
+	transport.RegisterProtocol("unix", &unixTransport{transports: make(map[string]*http.Transport)})
+}
+
+// filePath returns the local path of a file:// URL
+func filePath(u *url.URL) string {
+	path := u.Path
+	// file:///C:/dump1090/aircraft.json names C:/dump1090/aircraft.json
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// fileTransport serves file:// URLs, e.g. the aircraft.json dump1090-fa
+// writes to /run/dump1090-fa, as 200 responses or 404 if the file is missing
+type fileTransport struct{}
+
+func (fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		Proto:      "HTTP/1.0",
+		ProtoMajor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	f, err := os.Open(filePath(req.URL))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		resp.StatusCode, resp.Status = http.StatusNotFound, "404 Not Found"
+		resp.Body = http.NoBody
+		return resp, nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a file", filePath(req.URL))
+	}
+
+	resp.StatusCode, resp.Status = http.StatusOK, "200 OK"
+	resp.ContentLength = info.Size()
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	resp.Body = f
+	return resp, nil
+}
+
+// splitUnixURL returns the socket and the request path of a unix:// URL,
+// written as unix://<socket>:<path>, e.g.
+// unix:///run/readsb/http.sock:/data/aircraft.json
+func splitUnixURL(u *url.URL) (string, string, error) {
+	socket, path, found := strings.Cut(u.Path, ":")
+	if !found || socket == "" || !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("%q is not a unix://<socket>:<path> URL", u.String())
+	}
+	return socket, path, nil
+}
+
+// unixTransport serves unix:// URLs with HTTP requests over the socket,
+// keeping a transport, and so its idle connections, per socket
+type unixTransport struct {
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, path, err := splitUnixURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	transport, ok := t.transports[socket]
+	if !ok {
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+			MaxIdleConns: 2,
+		}
+		t.transports[socket] = transport
+	}
+	t.mu.Unlock()
+
+	req = req.Clone(req.Context())
+	req.URL = &url.URL{Scheme: "http", Host: "localhost", Path: path, RawQuery: req.URL.RawQuery}
+	req.Host = "localhost"
+	return transport.RoundTrip(req)
+}
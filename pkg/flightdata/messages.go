@@ -0,0 +1,70 @@
+package flightdata
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+var (
+	sourceMessages, _ = meter.Int64Counter("adsb2otel.source.messages",
+		metric.WithDescription("Messages received by the decoder since the service started, across decoder restarts"),
+		metric.WithUnit("{message}"),
+	)
+	sourceMessageRate, _ = meter.Float64Gauge("adsb2otel.source.message_rate",
+		metric.WithDescription("Messages received per second between the latest two polls"),
+		metric.WithUnit("{message}/s"),
+	)
+)
+
+// messageTracker turns the decoder's message total from aircraft.json, which
+// starts over from zero when the decoder restarts, into increments of a
+// counter that only grows and a rate that never goes negative
+type messageTracker struct {
+	mu sync.Mutex
+	// total and now are the message total and timestamp of the latest poll
+	total int
+	now   float64
+	seen  bool
+}
+
+var messages messageTracker
+
+// observe records the messages received since the previous poll
+// A total lower than the previous one means the decoder restarted, and the
+// messages since then are counted; no rate is recorded for that poll as the
+// time of the restart is unknown
+func (t *messageTracker) observe(ctx context.Context, total int, now float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Sources that don't count messages report none
+	if !t.seen {
+		if total == 0 {
+			return
+		}
+		t.total, t.now, t.seen = total, now, true
+		sourceMessages.Add(ctx, 0)
+		return
+	}
+	// The decoder hasn't written a new aircraft.json since the last poll
+	if now <= t.now {
+		return
+	}
+
+	elapsed := now - t.now
+	increase, reset := total-t.total, total < t.total
+	if reset {
+		increase = total
+		logging.InfoCtx(ctx, "Decoder message total went down, the decoder restarted", "previous", t.total, "total", total)
+	}
+	t.total, t.now = total, now
+
+	sourceMessages.Add(ctx, int64(increase))
+	if !reset {
+		sourceMessageRate.Record(ctx, float64(increase)/elapsed)
+	}
+}
@@ -0,0 +1,186 @@
+package flightdata
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+// Pipeline metrics, exported alongside the runtime and host metrics when
+// OTEL_METRICS_ENABLED is set; the instruments are no-ops otherwise
+var (
+	meter = metrics.Meter("flightdata-client")
+
+	fetchDuration, _ = meter.Float64Histogram("adsb2otel.fetch.duration",
+		metric.WithDescription("Duration of flight data fetches"),
+		metric.WithUnit("s"),
+	)
+	aircraftGauge, _ = meter.Int64Gauge("adsb2otel.aircraft",
+		metric.WithDescription("Aircraft reported in the latest poll"),
+		metric.WithUnit("{aircraft}"),
+	)
+	categoryGauge, _ = meter.Int64Gauge("adsb2otel.aircraft.category",
+		metric.WithDescription("Aircraft reported in the latest poll, by emitter category class"),
+		metric.WithUnit("{aircraft}"),
+	)
+	sourceTypeGauge, _ = meter.Int64Gauge("adsb2otel.aircraft.source_type",
+		metric.WithDescription("Aircraft reported in the latest poll, by how they were received"),
+		metric.WithUnit("{aircraft}"),
+	)
+	withPositionGauge, _ = meter.Int64Gauge("adsb2otel.aircraft.with_position",
+		metric.WithDescription("Aircraft reported with a position in the latest poll, by position source"),
+		metric.WithUnit("{aircraft}"),
+	)
+	withoutPositionGauge, _ = meter.Int64Gauge("adsb2otel.aircraft.without_position",
+		metric.WithDescription("Aircraft reported without a position in the latest poll"),
+		metric.WithUnit("{aircraft}"),
+	)
+	maxRangeGauge, _ = meter.Float64Gauge("adsb2otel.aircraft.range.max",
+		metric.WithDescription("Distance from the receiver to the furthest aircraft in the latest poll"),
+		metric.WithUnit("[nmi_i]"),
+	)
+	rssiHistogram, _ = meter.Float64Histogram("adsb2otel.poll.aircraft.rssi",
+		metric.WithDescription("Signal strength of the aircraft received locally, recorded once per aircraft per poll"),
+		metric.WithUnit("dBFS"),
+		metric.WithExplicitBucketBoundaries(-40, -35, -30, -27, -24, -21, -18, -15, -12, -9, -6, -3),
+	)
+	altitudeHistogram, _ = meter.Float64Histogram("adsb2otel.poll.aircraft.altitude",
+		metric.WithDescription("Altitude of the aircraft reported, recorded once per aircraft per poll"),
+		metric.WithUnit("[ft_i]"),
+		metric.WithExplicitBucketBoundaries(1000, 2000, 5000, 10000, 15000, 20000, 25000, 30000, 35000, 40000, 45000),
+	)
+	distanceHistogram, _ = meter.Float64Histogram("adsb2otel.poll.aircraft.distance",
+		metric.WithDescription("Distance from the receiver to the aircraft received locally, recorded once per aircraft per poll"),
+		metric.WithUnit("[nmi_i]"),
+		metric.WithExplicitBucketBoundaries(10, 25, 50, 75, 100, 125, 150, 175, 200, 250, 300),
+	)
+	logsEmittedCounter, _ = meter.Int64Counter("adsb2otel.logs.emitted",
+		metric.WithDescription("Aircraft log records emitted"),
+		metric.WithUnit("{record}"),
+	)
+	staleCounter, _ = meter.Int64Counter("adsb2otel.aircraft.stale",
+		metric.WithDescription("Aircraft dropped because they were not heard from recently"),
+		metric.WithUnit("{aircraft}"),
+	)
+	weakSignalCounter, _ = meter.Int64Counter("adsb2otel.aircraft.weak_signal",
+		metric.WithDescription("Aircraft dropped because their signal was below MIN_RSSI or MIN_MESSAGES"),
+		metric.WithUnit("{aircraft}"),
+	)
+	logsDroppedCounter, _ = meter.Int64Counter("adsb2otel.logs.dropped",
+		metric.WithDescription("Aircraft log records dropped by the per-poll record limit"),
+		metric.WithUnit("{record}"),
+	)
+	receiveLatency, _ = meter.Float64Histogram("adsb2otel.latency.receive",
+		metric.WithDescription("Time from the last message of an aircraft to the fetch of the poll it is exported from, recorded once per record emitted"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.25, 0.5, 1, 2, 3, 5, 10, 15, 30, 60),
+	)
+	parseErrorCounter, _ = meter.Int64Counter("adsb2otel.parse_errors",
+		metric.WithDescription("Aircraft skipped because their log record could not be built, by stage"),
+		metric.WithUnit("{record}"),
+	)
+)
+
+// recordCategories records how many aircraft of each category class were
+// reported, including classes no aircraft fell into so gauges drop to zero
+func recordCategories(ctx context.Context, aircraft []models.Aircraft) {
+	counts := make(map[string]int64, len(models.CategoryClasses))
+	for i := range aircraft {
+		counts[aircraft[i].CategoryClass()]++
+	}
+	for _, class := range models.CategoryClasses {
+		categoryGauge.Record(ctx, counts[class], metric.WithAttributes(attribute.String("category", class)))
+	}
+}
+
+// recordSourceTypes records how many aircraft were received directly,
+// rebroadcast via TIS-B or ADS-R, or multilaterated, including zeroes
+func recordSourceTypes(ctx context.Context, aircraft []models.Aircraft) {
+	counts := make(map[string]int64, len(models.SourceTypes))
+	for i := range aircraft {
+		counts[aircraft[i].SourceType()]++
+	}
+	for _, source := range models.SourceTypes {
+		sourceTypeGauge.Record(ctx, counts[source], metric.WithAttributes(attribute.String("source_type", source)))
+	}
+}
+
+// recordCoverage records how many aircraft were reported with and without
+// a position and the distance to the furthest one received locally, as
+// graphed by graphs1090, and the distribution of their distances
+// Distances beyond the maximum plausible range are ignored as bad positions
+func recordCoverage(ctx context.Context, aircraft []models.Aircraft) {
+	receiver, hasReceiver := geo.Receiver()
+	maxRange := geo.MaxRangeNM()
+
+	counts := make(map[string]int64, len(models.PositionSources))
+	var without int64
+	furthest := -1.0
+	for i := range aircraft {
+		a := &aircraft[i]
+		pos, ok := a.Position()
+		if !ok {
+			without++
+			continue
+		}
+		counts[a.PositionSource()]++
+		// Positions from networks and other radios say nothing about the receiver's range
+		if a.Source == models.SourceSatellite || a.Source == models.SourceOGN || a.Source == models.SourceRemoteID {
+			continue
+		}
+
+		d := -1.0
+		if a.RDst != nil {
+			d = *a.RDst
+		} else if hasReceiver {
+			d = geo.DistanceNM(receiver, pos)
+		}
+		if d < 0 || d > maxRange {
+			continue
+		}
+		distanceHistogram.Record(ctx, d)
+		furthest = max(furthest, d)
+	}
+
+	for _, source := range models.PositionSources {
+		withPositionGauge.Record(ctx, counts[source], metric.WithAttributes(attribute.String("position_source", source)))
+	}
+	withoutPositionGauge.Record(ctx, without)
+	if furthest >= 0 {
+		maxRangeGauge.Record(ctx, furthest)
+	}
+}
+
+// recordDistributions records the signal strength of the aircraft received
+// locally and the altitude of every aircraft, so shifts in their
+// distributions show up, e.g. after moving the antenna
+func recordDistributions(ctx context.Context, aircraft []models.Aircraft) {
+	for i := range aircraft {
+		a := &aircraft[i]
+		if altitude, ok := a.AltitudeFeet(); ok {
+			altitudeHistogram.Record(ctx, float64(altitude))
+		}
+		// Remote ID signals are measured by another radio
+		if a.Rssi != 0 && a.Source != models.SourceSatellite && a.Source != models.SourceRemoteID {
+			rssiHistogram.Record(ctx, a.Rssi)
+		}
+	}
+}
+
+// recordFetch records the duration and outcome of a flight data fetch
+func recordFetch(ctx context.Context, duration time.Duration, err error) {
+	if err != nil {
+		fetchDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+			attribute.String("outcome", "error"),
+			attribute.String("error.type", classifyFailure(err)),
+		))
+		return
+	}
+	fetchDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(attribute.String("outcome", "success")))
+}
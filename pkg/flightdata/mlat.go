@@ -0,0 +1,134 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// mlatSource fetches multilateration results published separately from the
+// receiver's aircraft.json, e.g. by an mlat client writing its own JSON
+type mlatSource struct {
+	url    string
+	client *http.Client
+}
+
+var (
+	mlat     *mlatSource
+	mlatOnce sync.Once
+)
+
+// getMlatSource returns the source configured via MLAT_DATA_URL, or nil
+func getMlatSource() *mlatSource {
+	mlatOnce.Do(func() {
+		url := os.Getenv("MLAT_DATA_URL")
+		if url == "" {
+			return
+		}
+		client, err := newHTTPClient("MLAT_")
+		if err != nil {
+			logging.Error("MLAT source disabled", "error", err)
+			return
+		}
+		mlat = &mlatSource{url: url, client: client}
+		logging.Info("MLAT source enabled", "url", url)
+	})
+	return mlat
+}
+
+// fetch retrieves the MLAT results
+func (s *mlatSource) fetch(ctx context.Context) ([]models.Aircraft, error) {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_mlat")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to fetch MLAT data: %w", err)
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("MLAT request failed with status: %s", resp.Status)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var data models.Dump1090fa
+	if err := decodeFlightData(resp.Body, &data); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to decode MLAT data: %w", err)
+	}
+	span.SetAttributes(attribute.Int("aircraft.count", len(data.Aircraft)))
+	return data.Aircraft, nil
+}
+
+// mergeMlat fills in positions from the MLAT results for aircraft the
+// receiver has no position for, and adds aircraft it does not list at all
+// Merged positions are marked as multilaterated in the mlat field
+// Returns how many positions were merged
+func mergeMlat(ctx context.Context, data *models.Dump1090fa) int {
+	source := getMlatSource()
+	if source == nil {
+		return 0
+	}
+
+	results, err := source.fetch(ctx)
+	if err != nil {
+		logging.WarnCtx(ctx, "Failed to fetch MLAT results", "error", err)
+		return 0
+	}
+
+	local := make(map[string]int, len(data.Aircraft))
+	for i := range data.Aircraft {
+		local[strings.ToLower(data.Aircraft[i].Hex)] = i
+	}
+
+	merged := 0
+	for _, result := range results {
+		if !result.HasPosition() {
+			continue
+		}
+		i, ok := local[strings.ToLower(result.Hex)]
+		if !ok {
+			result.Mlat = markMlat(result.Mlat, "lat", "lon")
+			data.Aircraft = append(data.Aircraft, result)
+			merged++
+			continue
+		}
+		if a := &data.Aircraft[i]; !a.HasPosition() {
+			a.Lat, a.Lon, a.SeenPos = result.Lat, result.Lon, result.SeenPos
+			a.Mlat = markMlat(a.Mlat, "lat", "lon")
+			merged++
+		}
+	}
+	return merged
+}
+
+// markMlat adds fields to an mlat field list
+func markMlat(mlat []interface{}, fields ...string) []interface{} {
+	for _, field := range fields {
+		if !slices.Contains(mlat, any(field)) {
+			mlat = append(mlat, field)
+		}
+	}
+	return mlat
+}
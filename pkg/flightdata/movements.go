@@ -0,0 +1,150 @@
+package flightdata
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Events emitted when an aircraft is seen taking off from or landing at an airport
+const (
+	movementTakeoff = "aircraft.takeoff"
+	movementLanding = "aircraft.landing"
+)
+
+// movementExpiry is how long an aircraft's air/ground state is kept after
+// it was last seen
+const movementExpiry = 10 * time.Minute
+
+var movementCounter, _ = meter.Int64Counter("adsb2otel.airport.movements",
+	metric.WithDescription("Takeoffs and landings inferred at nearby airports, by type and airport"),
+	metric.WithUnit("{movement}"),
+)
+
+// groundState is whether an aircraft was last seen on the ground
+type groundState struct {
+	ground bool
+	seen   time.Time
+}
+
+// movementTracker tags aircraft close to an airport at low altitude and
+// infers takeoffs and landings from aircraft switching between reporting
+// themselves airborne and on the ground near one
+type movementTracker struct {
+	airports    *enrich.AirportIndex
+	radiusNM    float64
+	maxAltitude int
+
+	mu     sync.Mutex
+	states map[string]groundState
+}
+
+var (
+	movements     *movementTracker
+	movementsOnce sync.Once
+)
+
+// getMovementTracker returns the tracker configured via AIRPORT_PROXIMITY_NM
+// and AIRPORT_MAX_ALTITUDE, or nil when disabled
+func getMovementTracker() *movementTracker {
+	movementsOnce.Do(func() {
+		radius := getEnvIntOrDefault("AIRPORT_PROXIMITY_NM", 0)
+		if radius == 0 {
+			return
+		}
+		index := enrich.Airports()
+		if index == nil {
+			return
+		}
+		movements = &movementTracker{
+			airports:    index,
+			radiusNM:    float64(radius),
+			maxAltitude: getEnvIntOrDefault("AIRPORT_MAX_ALTITUDE", 2000),
+			states:      make(map[string]groundState),
+		}
+		logging.Info("Airport proximity enabled", "radius_nm", radius, "max_altitude_ft", movements.maxAltitude)
+	})
+	return movements
+}
+
+// observe returns the airport attributes for an aircraft close to one, and
+// emits a takeoff or landing event when it switched between the air and the ground there
+func (t *movementTracker) observe(ctx context.Context, logger otellog.Logger, a *models.Aircraft, timestamp time.Time) []otellog.KeyValue {
+	ground := a.OnGround()
+	altitude, altitudeKnown := a.AltitudeFeet()
+	if !ground && !altitudeKnown {
+		return nil
+	}
+
+	t.mu.Lock()
+	prev, tracked := t.states[a.Hex]
+	t.states[a.Hex] = groundState{ground: ground, seen: timestamp}
+	t.mu.Unlock()
+
+	pos, ok := a.Position()
+	if !ok {
+		return nil
+	}
+	airport, distance, ok := t.airports.Nearest(pos, t.radiusNM)
+	if !ok || !ground && altitude-airport.ElevationFt > t.maxAltitude {
+		return nil
+	}
+
+	attrs := airport.Attributes(distance)
+	if tracked && prev.ground != ground {
+		event, body := movementTakeoff, "Takeoff from "
+		if ground {
+			event, body = movementLanding, "Landing at "
+		}
+		t.emit(ctx, logger, a, timestamp, event, body+airport.Ident, attrs)
+	}
+	return attrs
+}
+
+func (t *movementTracker) emit(ctx context.Context, logger otellog.Logger, a *models.Aircraft, timestamp time.Time, event, body string, airportAttrs []otellog.KeyValue) {
+	callsign := strings.TrimSpace(a.Flight)
+	airport := airportAttrs[0].Value.AsString()
+	logging.DebugCtx(ctx, "Inferred airport movement", "event", event, "hex", a.Hex, "flight", callsign, "airport", airport)
+	movementCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("type", event), attribute.String("airport", airport)))
+
+	attrs := []otellog.KeyValue{
+		otellog.String("service", "adsb"),
+		otellog.String("aircraft.hex", a.Hex),
+	}
+	if callsign != "" {
+		attrs = append(attrs, otellog.String("aircraft.flight", callsign))
+	}
+	attrs = append(attrs, airportAttrs...)
+
+	// Without OTLP logs the movement is only logged and counted
+	if logger == nil {
+		return
+	}
+	record := otellog.Record{}
+	record.SetEventName(event)
+	record.SetTimestamp(timestamp)
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetBody(otellog.StringValue(body))
+	record.AddAttributes(attrs...)
+	logger.Emit(ctx, record)
+}
+
+// prune forgets aircraft that have not been seen for a while
+func (t *movementTracker) prune(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for hex, state := range t.states {
+		if now.Sub(state.seen) > movementExpiry {
+			delete(t.states, hex)
+		}
+	}
+}
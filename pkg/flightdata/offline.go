@@ -0,0 +1,107 @@
+package flightdata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+)
+
+// Kinds of fetch failure, reported as the error.type attribute
+const (
+	failureHTTPStatus        = "http_status"
+	failureTimeout           = "timeout"
+	failureConnectionRefused = "connection_refused"
+	failureDNS               = "dns"
+	failureNetwork           = "network"
+	failureInvalidResponse   = "invalid_response"
+)
+
+var (
+	sourceFailures, _ = meter.Int64Counter("adsb2otel.source.failures",
+		metric.WithDescription("Failed flight data fetches, by error.type"),
+		metric.WithUnit("{fetch}"),
+	)
+	sourceDowntime, _ = meter.Float64Histogram("adsb2otel.source.downtime",
+		metric.WithDescription("Time the flight data source was offline, recorded when it comes back"),
+		metric.WithUnit("s"),
+	)
+)
+
+// statusError is returned when the receiver answers with a status other than 200
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "HTTP request failed with status: " + e.status
+}
+
+// classifyFailure returns the kind of a failed fetch
+func classifyFailure(err error) string {
+	var statusErr *statusError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return failureHTTPStatus
+	case errors.Is(err, context.DeadlineExceeded):
+		return failureTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return failureConnectionRefused
+	case errors.As(err, &dnsErr):
+		return failureDNS
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return failureTimeout
+		}
+		return failureNetwork
+	default:
+		return failureInvalidResponse
+	}
+}
+
+// emitReceiverEvent emits a receiver log event, such as receiver.offline or
+// receiver.unhealthy, so the health of the feeder itself can be charted next
+// to its aircraft
+func emitReceiverEvent(ctx context.Context, name string, severity otellog.Severity, body string, attrs ...otellog.KeyValue) {
+	logger := logs.GetLogger("flightdata")
+	if logger == nil {
+		return
+	}
+
+	record := otellog.Record{}
+	record.SetEventName(name)
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(severity)
+	record.SetBody(otellog.StringValue(body))
+	record.AddAttributes(otellog.String("receiver.url", receiverURL()))
+	record.AddAttributes(attrs...)
+	logger.Emit(ctx, record)
+}
+
+// receiverURL returns FLIGHT_DATA_URL without any credentials
+func receiverURL() string {
+	raw := os.Getenv("FLIGHT_DATA_URL")
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// failureAttr returns the metric attribute for a kind of failure
+func failureAttr(kind string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("error.type", kind))
+}
@@ -0,0 +1,334 @@
+package flightdata
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// ognKeepalive is how often a comment is sent so the APRS server keeps the
+// connection open
+const ognKeepalive = 4 * time.Minute
+
+// ognPosition matches an APRS position report with the OGN extensions, e.g.
+// FLRDDA5BA>OGFLR,qAS,LFMX:/160829h4415.41N/00600.03E'342/049/A=005524 !W52! id0ADDA5BA -454fpm
+var ognPosition = regexp.MustCompile(`^([A-Za-z0-9-]+)>([A-Za-z0-9-]+)[^:]*:[/@](\d{6})h(\d{2})(\d{2}\.\d{2})([NS]).(\d{3})(\d{2}\.\d{2})([EW]).(?:(\d{3})/(\d{3}))?/A=(-?\d{6})(.*)$`)
+
+var (
+	ognPrecision = regexp.MustCompile(`!W(\d)(\d)!`)
+	ognID        = regexp.MustCompile(`\bid([0-9A-Fa-f]{2})([0-9A-Fa-f]{6})\b`)
+	ognClimb     = regexp.MustCompile(`([+-]\d+)fpm`)
+)
+
+// ognCategories maps the OGN aircraft types to emitter categories
+var ognCategories = map[int]string{
+	1: "B1", 2: "A1", 3: "A7", 4: "B3", 5: "A1", 6: "B4", 7: "B4",
+	8: "A1", 9: "A3", 11: "B2", 12: "B2", 13: "B6", 14: "C2", 15: "C3",
+}
+
+// ognProtocols maps the APRS destination of OGN packets to the protocol the
+// aircraft was received with
+var ognProtocols = map[string]string{
+	"APRS": "flarm", "OGFLR": "flarm", "OGNTRK": "ogn", "OGNFNT": "fanet",
+	"OGPAW": "pilotaware", "OGSKY": "safesky", "OGSPOT": "spot", "OGNSKY": "safesky",
+}
+
+var ognPacketCounter, _ = meter.Int64Counter("adsb2otel.ogn.packets",
+	metric.WithDescription("OGN position reports received, by protocol"),
+	metric.WithUnit("{packet}"),
+)
+
+// ognSource keeps the latest position of each aircraft reported by the Open
+// Glider Network, FLARM and other low power traffic that dump1090 can't hear
+type ognSource struct {
+	addr   string
+	filter string
+	maxAge time.Duration
+
+	mu       sync.Mutex
+	aircraft map[string]ognReport
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ognReport is the latest report of an aircraft
+type ognReport struct {
+	aircraft models.Aircraft
+	at       time.Time
+}
+
+// ogn is the running OGN source, nil unless configured
+var ogn *ognSource
+
+// InitOGN connects to the APRS server at OGN_APRS_ADDR, the Open Glider
+// Network's or a local OGN receiver's, and merges the gliders, paragliders
+// and drones it reports into each poll
+// The returned function disconnects
+func InitOGN() (func(), error) {
+	addr := os.Getenv("OGN_APRS_ADDR")
+	if addr == "" {
+		return func() {}, nil
+	}
+	filter, err := ognFilter()
+	if err != nil {
+		return func() {}, err
+	}
+
+	s := &ognSource{
+		addr:     addr,
+		filter:   filter,
+		maxAge:   getEnvDurationOrDefault("OGN_MAX_AGE", time.Minute),
+		aircraft: make(map[string]ognReport),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	ogn = s
+
+	logging.Info("OGN source enabled", "addr", addr, "filter", filter)
+	return func() {
+		ogn = nil
+		close(s.stop)
+		<-s.done
+	}, nil
+}
+
+// ognFilter returns the APRS server-side filter: OGN_FILTER, or a range of
+// OGN_RANGE_KM around the receiver
+func ognFilter() (string, error) {
+	if filter := strings.TrimSpace(os.Getenv("OGN_FILTER")); filter != "" {
+		return filter, nil
+	}
+	pos, ok := geo.Receiver()
+	if !ok {
+		return "", errors.New("OGN_FILTER or RECEIVER_LAT and RECEIVER_LON must be set for the OGN source")
+	}
+	return fmt.Sprintf("r/%.4f/%.4f/%d", pos.Lat, pos.Lon, getEnvIntOrDefault("OGN_RANGE_KM", 100)), nil
+}
+
+// run keeps a connection to the APRS server, reconnecting with a backoff
+func (s *ognSource) run() {
+	defer close(s.done)
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.connect()
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		logging.Warn("OGN connection lost, reconnecting", "addr", s.addr, "error", err, "retry_in", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+		backoff = min(backoff*2, 5*time.Minute)
+	}
+}
+
+// connect logs in to the APRS server and reads reports until the connection
+// fails or the source is stopped
+func (s *ognSource) connect() error {
+	conn, err := net.DialTimeout("tcp", s.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		ticker := time.NewTicker(ognKeepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(conn, "# keepalive\r\n")
+			case <-s.stop:
+				conn.Close()
+				return
+			case <-closed:
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	// A receive-only login, which needs no passcode
+	if _, err := fmt.Fprintf(conn, "user N0CALL pass -1 vers adsb2otel %s filter %s\r\n", version.Get(), s.filter); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if strings.Contains(line, "logresp") {
+				logging.Debug("Logged in to the OGN APRS server", "response", line)
+			}
+			continue
+		}
+		a, protocol, ok := parseOGN(line, time.Now())
+		if !ok {
+			continue
+		}
+		ognPacketCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("protocol", protocol)))
+		s.mu.Lock()
+		s.aircraft[a.Hex] = ognReport{aircraft: a, at: time.Now()}
+		s.mu.Unlock()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("connection closed by server")
+}
+
+// parseOGN parses an OGN position report into an aircraft, skipping reports
+// without an OGN id, such as receiver beacons, and aircraft that asked not
+// to be tracked
+func parseOGN(line string, now time.Time) (models.Aircraft, string, bool) {
+	m := ognPosition.FindStringSubmatch(line)
+	if m == nil {
+		return models.Aircraft{}, "", false
+	}
+	id := ognID.FindStringSubmatch(m[13])
+	if id == nil {
+		return models.Aircraft{}, "", false
+	}
+	details, _ := strconv.ParseUint(id[1], 16, 8)
+	// The no-tracking flag is set by pilots who opted out of being tracked
+	if details&0x40 != 0 {
+		return models.Aircraft{}, "", false
+	}
+
+	latDeg, _ := strconv.Atoi(m[4])
+	latMin, _ := strconv.ParseFloat(m[5], 64)
+	lonDeg, _ := strconv.Atoi(m[7])
+	lonMin, _ := strconv.ParseFloat(m[8], 64)
+	// !Wab! adds a third decimal to the minutes of latitude and longitude
+	if w := ognPrecision.FindStringSubmatch(m[13]); w != nil {
+		latMin += float64(w[1][0]-'0') / 1000
+		lonMin += float64(w[2][0]-'0') / 1000
+	}
+	lat := float64(latDeg) + latMin/60
+	if m[6] == "S" {
+		lat = -lat
+	}
+	lon := float64(lonDeg) + lonMin/60
+	if m[9] == "W" {
+		lon = -lon
+	}
+
+	a := models.Aircraft{
+		Hex:      strings.ToLower(id[2]),
+		Type:     "other",
+		Lat:      &lat,
+		Lon:      &lon,
+		Source:   models.SourceOGN,
+		Protocol: "ogn",
+	}
+	// Addresses other than ICAO ones are marked as non-ICAO, the way readsb does
+	if details&0x03 != 1 {
+		a.Hex = "~" + a.Hex
+	}
+	if protocol, ok := ognProtocols[m[2]]; ok {
+		a.Protocol = protocol
+	}
+	if category, ok := ognCategories[int(details>>2&0x0f)]; ok {
+		a.Category = category
+	}
+	if feet, err := strconv.Atoi(m[12]); err == nil {
+		a.AltGeom = &feet
+		a.AltBaro = &models.Altitude{Feet: feet}
+	}
+	if m[10] != "" {
+		track, _ := strconv.ParseFloat(m[10], 64)
+		gs, _ := strconv.ParseFloat(m[11], 64)
+		// A course of 0 means unknown, north is sent as 360
+		if track > 0 {
+			track = float64(int(track) % 360)
+			a.Track = &track
+		}
+		a.Gs = &gs
+	}
+	if climb := ognClimb.FindStringSubmatch(m[13]); climb != nil {
+		if rate, err := strconv.Atoi(climb[1]); err == nil {
+			a.GeomRate = &rate
+		}
+	}
+
+	// The time of the report, hhmmss UTC of the current or the previous day
+	if t, err := time.Parse("150405", m[3]); err == nil {
+		utc := now.UTC()
+		at := time.Date(utc.Year(), utc.Month(), utc.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		if at.After(utc.Add(time.Hour)) {
+			at = at.AddDate(0, 0, -1)
+		}
+		seen := max(now.Sub(at).Seconds(), 0)
+		a.SeenPos = &seen
+	}
+	return a, a.Protocol, true
+}
+
+// mergeOGN adds the aircraft reported by OGN that the receiver does not see,
+// as ADS-B reports are more complete
+// Returns how many aircraft were added
+func mergeOGN(ctx context.Context, data *models.Dump1090fa) int {
+	s := ogn
+	if s == nil {
+		return 0
+	}
+
+	local := make(map[string]bool, len(data.Aircraft))
+	for i := range data.Aircraft {
+		local[strings.ToLower(data.Aircraft[i].Hex)] = true
+	}
+
+	now := time.Now()
+	added := 0
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hex, report := range s.aircraft {
+		age := now.Sub(report.at)
+		if age > s.maxAge {
+			delete(s.aircraft, hex)
+			continue
+		}
+		if local[hex] {
+			continue
+		}
+		a := report.aircraft
+		a.Seen = age.Seconds()
+		if a.SeenPos != nil {
+			seenPos := *a.SeenPos + age.Seconds()
+			a.SeenPos = &seenPos
+		}
+		data.Aircraft = append(data.Aircraft, a)
+		added++
+	}
+	if added > 0 {
+		logging.DebugCtx(ctx, "Added OGN aircraft", "aircraft_count", added)
+	}
+	return added
+}
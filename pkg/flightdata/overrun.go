@@ -0,0 +1,55 @@
+package flightdata
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// overrunWarnAfter is the number of consecutive cycles outlasting the poll
+// interval after which a warning is logged
+const overrunWarnAfter = 3
+
+var skippedCyclesCounter, _ = meter.Int64Counter("adsb2otel.cycles.skipped",
+	metric.WithDescription("Poll ticks skipped because the previous fetch cycle was still running"),
+	metric.WithUnit("{cycle}"),
+)
+
+// Overrun tracks fetch cycles that outlast the poll interval
+// The ticker keeps one of the ticks that fire while a cycle runs and delivers
+// it as soon as the cycle ends; rather than starting the next cycle back to
+// back, the poll loop skips ticks older than the end of the last cycle, and
+// Overrun counts every tick missed that way
+type Overrun struct {
+	consecutive int
+	finished    time.Time
+}
+
+// Observe records a cycle that started at start and has just finished
+func (o *Overrun) Observe(ctx context.Context, start time.Time) {
+	o.finished = time.Now()
+	duration := o.finished.Sub(start)
+	skipped := int64(duration / PollInterval)
+	if skipped == 0 {
+		if o.consecutive >= overrunWarnAfter {
+			logging.InfoCtx(ctx, "Fetch cycles are back within the poll interval", "overruns", o.consecutive)
+		}
+		o.consecutive = 0
+		return
+	}
+
+	o.consecutive++
+	skippedCyclesCounter.Add(ctx, skipped)
+	logging.DebugCtx(ctx, "Fetch cycle outlasted the poll interval", "duration", duration, "skipped", skipped)
+	if o.consecutive == overrunWarnAfter {
+		logging.WarnCtx(ctx, "Fetch cycles keep outlasting the poll interval, skipping ticks", "duration", duration, "poll_interval", PollInterval, "consecutive", o.consecutive)
+	}
+}
+
+// Stale reports whether a tick fired while the last cycle was running
+func (o *Overrun) Stale(tick time.Time) bool {
+	return tick.Before(o.finished)
+}
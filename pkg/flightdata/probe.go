@@ -0,0 +1,270 @@
+package flightdata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/modes"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// ProbeResult describes a flight data endpoint as seen by a single fetch
+type ProbeResult struct {
+	URL          string
+	Status       string
+	ContentType  string
+	Latency      time.Duration
+	Bytes        int
+	Schema       string
+	Keys         []string
+	Aircraft     int
+	WithPosition int
+	Messages     int
+	Timestamp    time.Time
+}
+
+// Probe fetches a flight data URL once and reports its schema, aircraft count
+// and latency, using the same client (and TLS settings) as the fetch loop
+func Probe(ctx context.Context, target string) (ProbeResult, error) {
+	result := ProbeResult{URL: target}
+
+	client, err := getHTTPClient()
+	if err != nil {
+		return result, fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	result.Latency = time.Since(start)
+	result.Status = resp.Status
+	result.ContentType = resp.Header.Get("Content-Type")
+	result.Bytes = len(body)
+	if err != nil {
+		return result, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("request failed with status: %s", resp.Status)
+	}
+
+	var data models.Dump1090fa
+	if sourceFormat(target) == formatBinCraft {
+		if err := decodeBinCraft(bytes.NewReader(body), &data); err != nil {
+			return result, fmt.Errorf("failed to decode: %w", err)
+		}
+		result.Schema = "readsb binCraft"
+		result.summarize(&data)
+		return result, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return result, fmt.Errorf("response is not a JSON object: %w", err)
+	}
+	for key := range keys {
+		result.Keys = append(result.Keys, key)
+	}
+	sort.Strings(result.Keys)
+
+	switch {
+	case keys["aircraft"] != nil:
+		result.Schema = "dump1090/readsb aircraft.json"
+	case keys["ac"] != nil:
+		result.Schema = "ADSBExchange v2 API (ac)"
+	default:
+		return result, fmt.Errorf("no aircraft or ac array found")
+	}
+
+	if err := decodeFlightData(bytes.NewReader(body), &data); err != nil {
+		return result, fmt.Errorf("failed to decode: %w", err)
+	}
+	result.summarize(&data)
+	return result, nil
+}
+
+// summarize fills in what a decoded document holds
+func (result *ProbeResult) summarize(data *models.Dump1090fa) {
+	result.Aircraft = len(data.Aircraft)
+	result.Messages = data.Messages
+	if data.Now > 0 {
+		result.Timestamp = time.Unix(0, int64(data.Now*float64(time.Second)))
+	}
+	for i := range data.Aircraft {
+		if data.Aircraft[i].HasPosition() {
+			result.WithPosition++
+		}
+	}
+}
+
+// CheckConfig returns problems with the flight data source configuration
+func CheckConfig() []error {
+	var errs []error
+
+	raw := os.Getenv("FLIGHT_DATA_URL")
+	if raw == "" {
+		errs = append(errs, fmt.Errorf("FLIGHT_DATA_URL is not set"))
+	} else if err := checkURL(raw); err != nil {
+		errs = append(errs, fmt.Errorf("FLIGHT_DATA_URL: %w", err))
+	}
+	if _, err := tlsConfigFromEnv("FLIGHT_DATA_"); err != nil {
+		errs = append(errs, err)
+	}
+	if format := strings.ToLower(strings.TrimSpace(os.Getenv("FLIGHT_DATA_FORMAT"))); format != "" && format != "auto" && format != formatJSON && format != formatBinCraft {
+		errs = append(errs, fmt.Errorf("FLIGHT_DATA_FORMAT must be auto, json or bincraft, got %q", format))
+	}
+	if schema := strings.ToLower(strings.TrimSpace(os.Getenv("FLIGHT_DATA_SCHEMA"))); schema != "" && schema != schemaStrict && schema != schemaTolerant {
+		errs = append(errs, fmt.Errorf("FLIGHT_DATA_SCHEMA must be strict or tolerant, got %q", schema))
+	}
+	if _, err := headersFromEnv("FLIGHT_DATA_"); err != nil {
+		errs = append(errs, err)
+	}
+
+	if raw := os.Getenv("SATELLITE_DATA_URL"); raw != "" {
+		if err := checkURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("SATELLITE_DATA_URL: %w", err))
+		}
+		if _, err := tlsConfigFromEnv("SATELLITE_"); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := headersFromEnv("SATELLITE_"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("RECEIVERS"); raw != "" {
+		if _, err := parseReceivers(raw); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := tlsConfigFromEnv("RECEIVERS_"); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := headersFromEnv("RECEIVERS_"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("REMOTEID_URL"); raw != "" {
+		if err := checkURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("REMOTEID_URL: %w", err))
+		}
+		if _, err := tlsConfigFromEnv("REMOTEID_"); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := headersFromEnv("REMOTEID_"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("COMPARE_URL"); raw != "" {
+		if _, err := newCoverageComparison(raw); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("OGN_APRS_ADDR"); raw != "" {
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			errs = append(errs, fmt.Errorf("OGN_APRS_ADDR: %w", err))
+		}
+		if _, err := ognFilter(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("RAW_INPUT_ADDR"); raw != "" {
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			errs = append(errs, fmt.Errorf("RAW_INPUT_ADDR: %w", err))
+		}
+		if _, err := modes.NewReader(strings.NewReader(""), getEnvOrDefault("RAW_INPUT_FORMAT", "beast")); err != nil {
+			errs = append(errs, fmt.Errorf("RAW_INPUT_FORMAT: %w", err))
+		}
+	}
+
+	if raw := os.Getenv("MLAT_DATA_URL"); raw != "" {
+		if err := checkURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("MLAT_DATA_URL: %w", err))
+		}
+		if _, err := tlsConfigFromEnv("MLAT_"); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := headersFromEnv("MLAT_"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if raw := os.Getenv("STATS_URL"); raw != "" {
+		if err := checkURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("STATS_URL: %w", err))
+		}
+		if _, err := tlsConfigFromEnv("STATS_"); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := headersFromEnv("STATS_"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := parseHexRanges(os.Getenv("AIRCRAFT_MILITARY_HEX")); err != nil {
+		errs = append(errs, fmt.Errorf("AIRCRAFT_MILITARY_HEX: %w", err))
+	}
+	if _, err := severityMapFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, pattern := range parseWatchlist(os.Getenv("AIRCRAFT_WATCHLIST")) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("AIRCRAFT_WATCHLIST: invalid pattern %q", pattern))
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("AIRCRAFT_LOW_RSSI")); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err != nil || f > 0 {
+			errs = append(errs, fmt.Errorf("AIRCRAFT_LOW_RSSI: %q is not a dBFS value of 0 or below", value))
+		}
+	}
+	return errs
+}
+
+// checkURL checks that a source URL is an http(s) URL, a file:// path or a
+// unix://<socket>:<path> URL
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return fmt.Errorf("%q has no path", raw)
+		}
+		return nil
+	case "unix":
+		_, _, err := splitUnixURL(u)
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s), file or unix URL", raw)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package flightdata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/cpr"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/modes"
+)
+
+var (
+	rawMessageCounter, _ = meter.Int64Counter("adsb2otel.raw.messages",
+		metric.WithDescription("Extended squitters received on the raw Mode S input, by address type"),
+		metric.WithUnit("{message}"),
+	)
+	cprRejectedCounter, _ = meter.Int64Counter("adsb2otel.cpr.rejected",
+		metric.WithDescription("Positions decoded from the raw Mode S input that failed the plausibility checks and were not exported"),
+		metric.WithUnit("{position}"),
+	)
+)
+
+// rawSource decodes the extended squitters of a raw Mode S output, such as
+// dump1090's Beast output, into aircraft, with positions decoded from CPR
+// and checked against the receiver's location
+type rawSource struct {
+	addr    string
+	format  string
+	maxAge  time.Duration
+	decoder *cpr.Decoder
+
+	mu       sync.Mutex
+	aircraft map[string]*rawAircraft
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// rawAircraft is the state built from an aircraft's messages
+type rawAircraft struct {
+	aircraft models.Aircraft
+	at       time.Time
+	posAt    time.Time
+}
+
+// rawInput is the running raw Mode S input, nil unless configured
+// It is set and cleared by InitRaw while polls read it
+var rawInput atomic.Pointer[rawSource]
+
+// InitRaw connects to the raw Mode S output at RAW_INPUT_ADDR and merges the
+// aircraft decoded from it into each poll
+// The returned function disconnects
+func InitRaw() (func(), error) {
+	addr := os.Getenv("RAW_INPUT_ADDR")
+	if addr == "" {
+		return func() {}, nil
+	}
+	format := strings.ToLower(getEnvOrDefault("RAW_INPUT_FORMAT", "beast"))
+	if _, err := modes.NewReader(strings.NewReader(""), format); err != nil {
+		return func() {}, err
+	}
+
+	s := &rawSource{
+		addr:     addr,
+		format:   format,
+		maxAge:   getEnvDurationOrDefault("RAW_MAX_AGE", time.Minute),
+		decoder:  cpr.NewDecoderFromEnv(),
+		aircraft: make(map[string]*rawAircraft),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	rawInput.Store(s)
+
+	logging.Info("Raw Mode S input enabled", "addr", addr, "format", format)
+	return func() {
+		rawInput.Store(nil)
+		close(s.stop)
+		<-s.done
+	}, nil
+}
+
+// run keeps a connection to the raw output, reconnecting with a backoff
+func (s *rawSource) run() {
+	defer close(s.done)
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.connect()
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		logging.Warn("Raw Mode S connection lost, reconnecting", "addr", s.addr, "error", err, "retry_in", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+		backoff = min(backoff*2, 5*time.Minute)
+	}
+}
+
+// connect reads frames until the connection fails or the source is stopped
+func (s *rawSource) connect() error {
+	conn, err := net.DialTimeout("tcp", s.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-s.stop:
+		case <-closed:
+		}
+		conn.Close()
+	}()
+
+	reader, err := modes.NewReader(conn, s.format)
+	if err != nil {
+		return err
+	}
+	for {
+		frame, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		msg, err := modes.Parse(frame.Data)
+		if err != nil {
+			continue
+		}
+		s.handle(msg, frame.RSSI, time.Now())
+	}
+}
+
+// handle updates the aircraft a message is from
+func (s *rawSource) handle(msg *modes.Message, rssi float64, now time.Time) {
+	hex, ok := msg.Hex()
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	rawMessageCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("type", msg.AddressType())))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.aircraft[hex]
+	if !ok {
+		state = &rawAircraft{aircraft: models.Aircraft{Hex: hex}}
+		s.aircraft[hex] = state
+	}
+	a := &state.aircraft
+	a.Type = msg.AddressType()
+	a.Messages++
+	a.Rssi = rssi
+	state.at = now
+
+	if callsign, category, ok := msg.Identification(); ok {
+		a.Flight = callsign
+		if category != "" {
+			a.Category = category
+		}
+		return
+	}
+	if v, ok := msg.Velocity(); ok {
+		a.Gs, a.Track = &v.GroundSpeed, &v.Track
+		a.BaroRate, a.GeomRate = nil, nil
+		if v.GNSS {
+			a.GeomRate = v.VerticalRate
+		} else {
+			a.BaroRate = v.VerticalRate
+		}
+		return
+	}
+	p, ok := msg.Position()
+	if !ok {
+		return
+	}
+	if p.Altitude != nil {
+		if p.GNSS {
+			a.AltGeom = p.Altitude
+		} else {
+			a.AltBaro = &models.Altitude{Feet: *p.Altitude}
+		}
+	}
+	if p.Surface {
+		a.AltBaro = &models.Altitude{Ground: true}
+		if p.GroundSpeed != nil {
+			a.Gs = p.GroundSpeed
+		}
+		if p.Track != nil {
+			a.Track = p.Track
+		}
+	}
+	pos, err := s.decoder.Decode(hex, cpr.Frame{LatCPR: p.LatCPR, LonCPR: p.LonCPR, Odd: p.Odd, Surface: p.Surface, Time: now})
+	switch {
+	case err == nil:
+		a.Lat, a.Lon = &pos.Lat, &pos.Lon
+		state.posAt = now
+	case errors.Is(err, cpr.ErrImpossibleDecode):
+		// The position is counted and dropped rather than exported; the
+		// previous one is kept, its age growing until a plausible decode
+		cprRejectedCounter.Add(ctx, 1, metric.WithAttributes(attribute.Bool("surface", p.Surface)))
+		logging.Debug("Rejected impossible CPR decode", "hex", hex, "error", err)
+	}
+}
+
+// mergeRaw adds the aircraft decoded from the raw input that the receiver's
+// aircraft.json does not report
+// Returns how many aircraft were added
+func mergeRaw(ctx context.Context, data *models.Dump1090fa) int {
+	s := rawInput.Load()
+	if s == nil {
+		return 0
+	}
+
+	local := make(map[string]bool, len(data.Aircraft))
+	for i := range data.Aircraft {
+		local[strings.ToLower(data.Aircraft[i].Hex)] = true
+	}
+
+	now := time.Now()
+	added := 0
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hex, state := range s.aircraft {
+		age := now.Sub(state.at)
+		if age > s.maxAge {
+			delete(s.aircraft, hex)
+			s.decoder.Forget(hex)
+			continue
+		}
+		if local[hex] {
+			continue
+		}
+		a := state.aircraft
+		a.Seen = age.Seconds()
+		if a.Lat != nil {
+			seenPos := now.Sub(state.posAt).Seconds()
+			a.SeenPos = &seenPos
+		}
+		data.Aircraft = append(data.Aircraft, a)
+		added++
+	}
+	if added > 0 {
+		logging.DebugCtx(ctx, "Added aircraft from the raw Mode S input", "aircraft_count", added)
+	}
+	return added
+}
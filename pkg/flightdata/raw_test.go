@@ -0,0 +1,75 @@
+package flightdata
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/cpr"
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/modes"
+)
+
+// feedRaw passes messages from "The 1090MHz Riddle" for aircraft 40621D to a
+// raw source with the given receiver, a second apart
+func feedRaw(t *testing.T, receiver geo.Position) *rawSource {
+	t.Helper()
+	s := &rawSource{
+		maxAge:   time.Minute,
+		decoder:  cpr.NewDecoder(&receiver, 250),
+		aircraft: make(map[string]*rawAircraft),
+	}
+	now := time.Now().Add(-2 * time.Second)
+	for i, msg := range []string{"8D40621D58C386435CC412692AD6", "8D40621D58C382D690C8AC2863A7"} {
+		b, _ := hex.DecodeString(msg)
+		m, err := modes.Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.handle(m, -20, now.Add(time.Duration(i)*time.Second))
+	}
+	return s
+}
+
+func TestRawSource(t *testing.T) {
+	rawInput.Store(feedRaw(t, geo.Position{Lat: 52.3, Lon: 4.76}))
+	defer rawInput.Store(nil)
+
+	data := &models.Dump1090fa{Aircraft: []models.Aircraft{{Hex: "4840d6"}}}
+	if added := mergeRaw(context.Background(), data); added != 1 {
+		t.Fatalf("added %d aircraft, want 1", added)
+	}
+	a := data.Aircraft[1]
+	if a.Hex != "40621d" || a.Type != "adsb_icao" || a.Messages != 2 {
+		t.Errorf("hex %q, type %q, messages %d", a.Hex, a.Type, a.Messages)
+	}
+	if a.AltBaro == nil || a.AltBaro.Feet != 38000 {
+		t.Errorf("alt_baro %v", a.AltBaro)
+	}
+	if a.Lat == nil || math.Abs(*a.Lat-52.2572) > 1e-4 || math.Abs(*a.Lon-3.91937) > 1e-4 || a.SeenPos == nil {
+		t.Errorf("position %v,%v", a.Lat, a.Lon)
+	}
+
+	// Aircraft the receiver reports itself are not added twice
+	if added := mergeRaw(context.Background(), data); added != 0 {
+		t.Errorf("added %d aircraft again", added)
+	}
+}
+
+func TestRawSourceRejectsImpossibleDecode(t *testing.T) {
+	// About 500nm from the decoded position, beyond the 250nm range
+	rawInput.Store(feedRaw(t, geo.Position{Lat: 55.95, Lon: -3.37}))
+	defer rawInput.Store(nil)
+
+	data := &models.Dump1090fa{}
+	mergeRaw(context.Background(), data)
+	if len(data.Aircraft) != 1 {
+		t.Fatalf("%d aircraft, want 1", len(data.Aircraft))
+	}
+	if a := data.Aircraft[0]; a.Lat != nil || a.SeenPos != nil {
+		t.Errorf("impossible position %v,%v exported", *a.Lat, *a.Lon)
+	}
+}
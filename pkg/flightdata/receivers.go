@@ -0,0 +1,288 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+var (
+	receiverFetchDuration, _ = meter.Float64Histogram("adsb2otel.receivers.fetch.duration",
+		metric.WithDescription("Duration of fetches from the additional receivers, by receiver and outcome"),
+		metric.WithUnit("s"),
+	)
+	receiverAircraftGauge, _ = meter.Int64Gauge("adsb2otel.receivers.aircraft",
+		metric.WithDescription("Aircraft reported by each additional receiver in the latest poll"),
+		metric.WithUnit("{aircraft}"),
+	)
+)
+
+// remoteReceiver is one of the additional receivers listed in RECEIVERS
+type remoteReceiver struct {
+	name string
+	url  string
+}
+
+// receiverPool polls the additional receivers in parallel, with at most
+// workers fetches in flight and each fetch bounded by timeout, so dozens of
+// remote feeders can be polled within one cycle
+type receiverPool struct {
+	receivers []remoteReceiver
+	main      string
+	client    *http.Client
+	workers   int
+	timeout   time.Duration
+}
+
+// receiverResult is the outcome of polling one receiver
+type receiverResult struct {
+	receiver remoteReceiver
+	aircraft []models.Aircraft
+	err      error
+}
+
+var (
+	receivers     *receiverPool
+	receiversOnce sync.Once
+)
+
+// getReceiverPool returns the pool configured via RECEIVERS, or nil
+func getReceiverPool() *receiverPool {
+	receiversOnce.Do(func() {
+		list, err := parseReceivers(os.Getenv("RECEIVERS"))
+		if err != nil {
+			logging.Error("Additional receivers disabled", "error", err)
+			return
+		}
+		if len(list) == 0 {
+			return
+		}
+		client, err := newHTTPClient("RECEIVERS_")
+		if err != nil {
+			logging.Error("Additional receivers disabled", "error", err)
+			return
+		}
+		receivers = &receiverPool{
+			receivers: list,
+			main:      receiverName(os.Getenv("FLIGHT_DATA_URL")),
+			client:    client,
+			workers:   max(getEnvIntOrDefault("RECEIVERS_WORKERS", 8), 1),
+			timeout:   getEnvDurationOrDefault("RECEIVERS_TIMEOUT", getFetchTimeout()),
+		}
+		logging.Info("Additional receivers enabled", "receivers", len(list), "workers", receivers.workers, "timeout", receivers.timeout)
+	})
+	return receivers
+}
+
+// parseReceivers parses a comma separated list of receiver URLs, each
+// optionally named as name=url; unnamed receivers are named by their host
+func parseReceivers(value string) ([]remoteReceiver, error) {
+	var list []remoteReceiver
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var r remoteReceiver
+		if name, target, found := strings.Cut(entry, "="); found && !strings.ContainsAny(name, ":/") {
+			r.name, r.url = strings.TrimSpace(name), strings.TrimSpace(target)
+		} else {
+			r.url = entry
+		}
+		if err := checkURL(r.url); err != nil {
+			return nil, fmt.Errorf("RECEIVERS: %w", err)
+		}
+		if r.name == "" {
+			r.name = receiverName(r.url)
+		}
+		if seen[r.name] {
+			return nil, fmt.Errorf("RECEIVERS: receiver %q is listed twice", r.name)
+		}
+		seen[r.name] = true
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// receiverName returns the name of an unnamed receiver, its host, or the
+// path of a local source
+func receiverName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.Host != "" {
+		return u.Host
+	}
+	return u.Path
+}
+
+// fetchAll polls every receiver, returning the results in the order the
+// receivers are listed
+func (p *receiverPool) fetchAll(ctx context.Context) []receiverResult {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_receivers")
+	defer span.End()
+	span.SetAttributes(attribute.Int("receivers.count", len(p.receivers)), attribute.Int("receivers.workers", p.workers))
+
+	results := make([]receiverResult, len(p.receivers))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(p.workers, len(p.receivers)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = p.fetch(ctx, p.receivers[i])
+			}
+		}()
+	}
+	for i := range p.receivers {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+	span.SetAttributes(attribute.Int("receivers.failed", failed))
+	return results
+}
+
+// fetch polls a single receiver within the pool's timeout and records the
+// outcome
+func (p *receiverPool) fetch(ctx context.Context, r remoteReceiver) receiverResult {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_receiver")
+	defer span.End()
+	span.SetAttributes(attribute.String("receiver.name", r.name))
+
+	start := time.Now()
+	aircraft, err := p.get(ctx, r)
+	duration := time.Since(start)
+
+	name := attribute.String("receiver", r.name)
+	if err != nil {
+		span.RecordError(err)
+		receiverFetchDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+			name, attribute.String("outcome", "error"), attribute.String("error.type", classifyFailure(err)),
+		))
+		logging.WarnCtx(ctx, "Failed to fetch from receiver", "receiver", r.name, "error", err, "duration_ms", duration.Milliseconds())
+		return receiverResult{receiver: r, err: err}
+	}
+	receiverFetchDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(name, attribute.String("outcome", "success")))
+	receiverAircraftGauge.Record(ctx, int64(len(aircraft)), metric.WithAttributes(name))
+	span.SetAttributes(attribute.Int("aircraft.count", len(aircraft)))
+	return receiverResult{receiver: r, aircraft: aircraft}
+}
+
+func (p *receiverPool) get(ctx context.Context, r remoteReceiver) ([]models.Aircraft, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	var data models.Dump1090fa
+	if err := decodeSource(resp.Body, urlFormat(r.url), &data); err != nil {
+		return nil, fmt.Errorf("failed to decode receiver data: %w", err)
+	}
+	for i := range data.Aircraft {
+		data.Aircraft[i].Hex = strings.ToLower(data.Aircraft[i].Hex)
+	}
+	return data.Aircraft, nil
+}
+
+// mergeReceivers polls the additional receivers and merges their reports
+// with the main receiver's into one entry per aircraft, so an aircraft seen
+// by several receivers is exported once: the freshest position and the best
+// signal are kept, fields missing from one report are filled in from the
+// others, and the receivers that contributed are listed in Receivers
+// Returns how many aircraft were added and how many were merged
+func mergeReceivers(ctx context.Context, data *models.Dump1090fa) (int, int) {
+	pool := getReceiverPool()
+	if pool == nil {
+		return 0, 0
+	}
+
+	index := make(map[string]int, len(data.Aircraft))
+	for i := range data.Aircraft {
+		a := &data.Aircraft[i]
+		index[strings.ToLower(a.Hex)] = i
+		a.Receivers = []string{pool.main}
+	}
+
+	added, merged := 0, 0
+	for _, result := range pool.fetchAll(ctx) {
+		for _, a := range result.aircraft {
+			i, ok := index[a.Hex]
+			if !ok {
+				// The distance and bearing are from the receiver that reported them
+				a.RDst, a.RDir = nil, nil
+				a.Receivers = []string{result.receiver.name}
+				index[a.Hex] = len(data.Aircraft)
+				data.Aircraft = append(data.Aircraft, a)
+				added++
+				continue
+			}
+			mergeReport(&data.Aircraft[i], &a, result.receiver.name)
+			merged++
+		}
+	}
+	return added, merged
+}
+
+// mergeReport merges another receiver's report of an aircraft into dst
+func mergeReport(dst, src *models.Aircraft, receiver string) {
+	dst.Receivers = append(dst.Receivers, receiver)
+
+	if src.HasPosition() && (!dst.HasPosition() || positionAge(src) < positionAge(dst)) {
+		dst.Lat, dst.Lon, dst.SeenPos, dst.Nic, dst.Rc = src.Lat, src.Lon, src.SeenPos, src.Nic, src.Rc
+		dst.Mlat, dst.Tisb = src.Mlat, src.Tisb
+		dst.RDst, dst.RDir = nil, nil
+	}
+	// Receivers that did not measure the signal report 0
+	if src.Rssi != 0 && (dst.Rssi == 0 || src.Rssi > dst.Rssi) {
+		dst.Rssi = src.Rssi
+	}
+	dst.Seen = min(dst.Seen, src.Seen)
+	// The receivers hear the same messages, so the counts are not added up
+	dst.Messages = max(dst.Messages, src.Messages)
+
+	dst.FillFrom(src)
+}
+
+// positionAge returns how long ago an aircraft's position was received
+func positionAge(a *models.Aircraft) float64 {
+	if a.SeenPos != nil {
+		return *a.SeenPos
+	}
+	return a.Seen
+}
@@ -0,0 +1,102 @@
+package flightdata
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestMergeReportPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst, src models.Aircraft
+		lat      *float64
+		rssi     float64
+	}{
+		{
+			name: "fresher position and stronger signal",
+			dst:  models.Aircraft{Lat: ptr(51.0), Lon: ptr(-1.0), SeenPos: ptr(4.0), RDst: ptr(12.0), Rssi: -25},
+			src:  models.Aircraft{Lat: ptr(51.1), Lon: ptr(-1.1), SeenPos: ptr(0.5), Rssi: -12},
+			lat:  ptr(51.1), rssi: -12,
+		},
+		{
+			name: "older position and weaker signal",
+			dst:  models.Aircraft{Lat: ptr(51.0), Lon: ptr(-1.0), SeenPos: ptr(0.5), RDst: ptr(12.0), Rssi: -12},
+			src:  models.Aircraft{Lat: ptr(51.1), Lon: ptr(-1.1), SeenPos: ptr(4.0), Rssi: -25},
+			lat:  ptr(51.0), rssi: -12,
+		},
+		{
+			name: "position only from the other receiver",
+			dst:  models.Aircraft{Rssi: -20},
+			src:  models.Aircraft{Lat: ptr(51.1), Lon: ptr(-1.1), SeenPos: ptr(9.0)},
+			lat:  ptr(51.1), rssi: -20,
+		},
+		{
+			name: "no position from either",
+			dst:  models.Aircraft{},
+			src:  models.Aircraft{Rssi: -30},
+			rssi: -30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, src := tt.dst, tt.src
+			dst.Receivers = []string{"main"}
+			mergeReport(&dst, &src, "north")
+
+			if (dst.Lat == nil) != (tt.lat == nil) || dst.Lat != nil && *dst.Lat != *tt.lat {
+				t.Errorf("lat %v, want %v", dst.Lat, tt.lat)
+			}
+			if dst.Lat != tt.dst.Lat && dst.RDst != nil {
+				t.Errorf("distance %v kept from the main receiver for another receiver's position", *dst.RDst)
+			}
+			if dst.Rssi != tt.rssi {
+				t.Errorf("rssi %v, want %v", dst.Rssi, tt.rssi)
+			}
+			if !slices.Equal(dst.Receivers, []string{"main", "north"}) {
+				t.Errorf("receivers %v", dst.Receivers)
+			}
+		})
+	}
+}
+
+func TestMergeReportFillsMissingFields(t *testing.T) {
+	dst := models.Aircraft{Hex: "4ca7b4", Flight: "EIN12B  ", Seen: 3, Messages: 100, Gs: ptr(250.0)}
+	src := models.Aircraft{Hex: "4ca7b4", Flight: "OTHER", Squawk: "2345", Category: "A3", Seen: 1, Messages: 80, Gs: ptr(300.0), Track: ptr(90.0)}
+	mergeReport(&dst, &src, "north")
+
+	// Fields the main receiver reported are kept
+	if dst.Flight != "EIN12B  " || *dst.Gs != 250 {
+		t.Errorf("flight %q, gs %v overwritten", dst.Flight, *dst.Gs)
+	}
+	if dst.Squawk != "2345" || dst.Category != "A3" || dst.Track == nil || *dst.Track != 90 {
+		t.Errorf("squawk %q, category %q, track %v not filled in", dst.Squawk, dst.Category, dst.Track)
+	}
+	if dst.Seen != 1 || dst.Messages != 100 {
+		t.Errorf("seen %v, messages %d", dst.Seen, dst.Messages)
+	}
+}
+
+func TestParseReceivers(t *testing.T) {
+	list, err := parseReceivers(" north=http://10.0.1.10/data/aircraft.json, http://feeder.local:8080/data/aircraft.binCraft.zst ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].name != "north" || list[0].url != "http://10.0.1.10/data/aircraft.json" || list[1].name != "feeder.local:8080" {
+		t.Errorf("receivers %+v", list)
+	}
+
+	tests := map[string]string{
+		"north=http://10.0.1.10/aircraft.json,north=http://10.0.2.10/aircraft.json": `"north" is listed twice`,
+		"http://10.0.1.10/a.json,http://10.0.1.10/b.json":                           `"10.0.1.10" is listed twice`,
+	}
+	for value, want := range tests {
+		if _, err := parseReceivers(value); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseReceivers(%q) error = %v, want %s", value, err, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package flightdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// recordingLayout is the directory each capture is written to below
+// RECORD_DIR, by the hour of the receiver's timestamp in UTC
+const recordingLayout = "2006/01/02/15"
+
+// recorder archives the raw aircraft.json of each poll, nil unless recording
+// is enabled
+var recorder *captureRecorder
+
+// capture is a fetched aircraft.json waiting to be written
+type capture struct {
+	body *bytes.Buffer
+	at   time.Time
+}
+
+// captureRecorder writes captures gzipped, one file per poll, in hourly
+// directories, and removes the directories older than the retention
+type captureRecorder struct {
+	dir       string
+	retention time.Duration
+
+	buffers  sync.Pool
+	captures chan capture
+	done     chan struct{}
+	hour     string
+}
+
+// InitRecorder starts archiving the raw aircraft.json of each poll to
+// RECORD_DIR, for replaying or reprocessing later, keeping RECORD_RETENTION
+// The returned function writes the pending captures and stops recording
+func InitRecorder() (func(), error) {
+	dir := os.Getenv("RECORD_DIR")
+	if dir == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return func() {}, fmt.Errorf("failed to create RECORD_DIR: %w", err)
+	}
+
+	r := &captureRecorder{
+		dir:       dir,
+		retention: getEnvDurationOrDefault("RECORD_RETENTION", 7*24*time.Hour),
+		buffers:   sync.Pool{New: func() any { return new(bytes.Buffer) }},
+		// A few polls of slack for slow storage such as SD cards
+		captures: make(chan capture, 4),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	recorder = r
+
+	logging.Info("Recording raw flight data", "dir", dir, "retention", r.retention)
+	return func() {
+		recorder = nil
+		close(r.captures)
+		<-r.done
+	}, nil
+}
+
+// buffer returns an empty buffer to read a poll into
+func (r *captureRecorder) buffer() *bytes.Buffer {
+	buf := r.buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// discard returns the buffer of a poll that is not recorded
+func (r *captureRecorder) discard(buf *bytes.Buffer) {
+	r.buffers.Put(buf)
+}
+
+// record queues a poll to be written, dropping it if the writer is behind
+func (r *captureRecorder) record(buf *bytes.Buffer, now float64) {
+	at := time.Now()
+	if now > 0 {
+		at = time.UnixMilli(int64(now * 1000))
+	}
+	select {
+	case r.captures <- capture{body: buf, at: at}:
+	default:
+		logging.Warn("Recording is behind, dropping a capture", "dir", r.dir)
+		r.buffers.Put(buf)
+	}
+}
+
+func (r *captureRecorder) run() {
+	defer close(r.done)
+	zw := gzip.NewWriter(nil)
+	for c := range r.captures {
+		if err := r.write(zw, c); err != nil {
+			logging.Error("Failed to record flight data", "dir", r.dir, "error", err)
+		}
+		r.buffers.Put(c.body)
+	}
+}
+
+// write writes a capture to a temporary file and renames it into place, so
+// a replay never reads a partial file
+func (r *captureRecorder) write(zw *gzip.Writer, c capture) error {
+	hour := c.at.UTC().Format(recordingLayout)
+	if hour != r.hour {
+		r.hour = hour
+		r.prune(c.at)
+	}
+
+	dir := filepath.Join(r.dir, filepath.FromSlash(hour))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("aircraft-%d.json.gz", c.at.UnixMilli()))
+	f, err := os.CreateTemp(dir, ".capture-*")
+	if err != nil {
+		return err
+	}
+	zw.Reset(f)
+	_, err = c.body.WriteTo(zw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// prune removes the hourly directories that have passed the retention, and
+// the day, month and year directories left empty
+func (r *captureRecorder) prune(now time.Time) {
+	if r.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-r.retention)
+	hours, _ := filepath.Glob(filepath.Join(r.dir, "[0-9]*", "[0-9]*", "[0-9]*", "[0-9]*"))
+	for _, path := range hours {
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil {
+			continue
+		}
+		hour, err := time.Parse(recordingLayout, filepath.ToSlash(rel))
+		if err != nil || !hour.Add(time.Hour).Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			logging.Warn("Failed to remove expired recordings", "path", path, "error", err)
+			continue
+		}
+		// Removing a parent only succeeds once it is empty
+		for parent := filepath.Dir(path); parent != r.dir; parent = filepath.Dir(parent) {
+			if os.Remove(parent) != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package flightdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// Values OpenDroneID uses for fields that are not available
+const (
+	remoteIDNoAltitude  = -1000
+	remoteIDNoDirection = 361
+	remoteIDNoSpeed     = 255
+	remoteIDNoClimb     = 63
+)
+
+// remoteIDTypes names the OpenDroneID identification types
+var remoteIDTypes = map[int]string{
+	1: "serial_number",
+	2: "caa_registration",
+	3: "utm_assigned",
+	4: "specific_session",
+}
+
+// remoteIDUATypes names the OpenDroneID aircraft types
+var remoteIDUATypes = map[int]string{
+	1: "aeroplane", 2: "multirotor", 3: "gyroplane", 4: "hybrid_lift", 5: "ornithopter",
+	6: "glider", 7: "kite", 8: "free_balloon", 9: "captive_balloon", 10: "airship",
+	11: "parachute", 12: "rocket", 13: "tethered_powered_aircraft", 14: "ground_obstacle", 15: "other",
+}
+
+// remoteIDSource polls a Remote ID receiver, such as a companion service
+// decoding OpenDroneID broadcasts over Bluetooth and Wi-Fi, for the drones
+// around the receiver
+type remoteIDSource struct {
+	url    string
+	client *http.Client
+	maxAge time.Duration
+}
+
+// remoteIDDrone is a drone as reported by the receiver, with the field names
+// of the OpenDroneID message set, in SI units
+type remoteIDDrone struct {
+	ID            string   `json:"id"`
+	IDType        int      `json:"id_type"`
+	UAType        int      `json:"ua_type"`
+	MAC           string   `json:"mac"`
+	Lat           *float64 `json:"lat"`
+	Lon           *float64 `json:"lon"`
+	AltGeo        *float64 `json:"alt_geo"`
+	AltBaro       *float64 `json:"alt_baro"`
+	Height        *float64 `json:"height"`
+	Speed         *float64 `json:"speed"`
+	SpeedVertical *float64 `json:"speed_vertical"`
+	Direction     *float64 `json:"direction"`
+	OperatorID    string   `json:"operator_id"`
+	OperatorLat   *float64 `json:"operator_lat"`
+	OperatorLon   *float64 `json:"operator_lon"`
+	RSSI          float64  `json:"rssi"`
+	Seen          *float64 `json:"seen"`
+	Timestamp     *float64 `json:"timestamp"`
+}
+
+var (
+	remoteID     *remoteIDSource
+	remoteIDOnce sync.Once
+)
+
+// getRemoteIDSource returns the source configured via REMOTEID_URL, or nil
+func getRemoteIDSource() *remoteIDSource {
+	remoteIDOnce.Do(func() {
+		url := os.Getenv("REMOTEID_URL")
+		if url == "" {
+			return
+		}
+		client, err := newHTTPClient("REMOTEID_")
+		if err != nil {
+			logging.Error("Remote ID source disabled", "error", err)
+			return
+		}
+		remoteID = &remoteIDSource{
+			url:    url,
+			client: client,
+			maxAge: getEnvDurationOrDefault("REMOTEID_MAX_AGE", 30*time.Second),
+		}
+		logging.Info("Remote ID source enabled", "url", url)
+	})
+	return remoteID
+}
+
+// fetch retrieves the drones the receiver currently sees
+func (s *remoteIDSource) fetch(ctx context.Context) ([]remoteIDDrone, error) {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_remoteid")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to fetch Remote ID data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	logging.DebugHTTPCtx(ctx, "GET", s.url, resp.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("request to the Remote ID receiver failed with status: %s", resp.Status)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	drones, err := decodeRemoteID(resp.Body)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to decode Remote ID data: %w", err)
+	}
+	span.SetAttributes(attribute.Int("aircraft.count", len(drones)))
+	return drones, nil
+}
+
+// decodeRemoteID decodes a list of drones, given as an array or as the
+// drones or uas array of an object
+func decodeRemoteID(r io.Reader) ([]remoteIDDrone, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var drones []remoteIDDrone
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &drones)
+		return drones, err
+	}
+	var wrapped struct {
+		Drones []remoteIDDrone `json:"drones"`
+		UAS    []remoteIDDrone `json:"uas"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, err
+	}
+	return append(wrapped.Drones, wrapped.UAS...), nil
+}
+
+// aircraft normalizes a drone into the aircraft model, converting to the
+// units dump1090 reports in
+// The second return value is false for drones without an identity or
+// reported too long ago
+func (d *remoteIDDrone) aircraft(now time.Time, maxAge time.Duration) (models.Aircraft, bool) {
+	id := strings.TrimSpace(d.ID)
+	if id == "" {
+		id = strings.ToLower(d.MAC)
+	}
+	if id == "" {
+		return models.Aircraft{}, false
+	}
+
+	var seen float64
+	switch {
+	case d.Seen != nil:
+		seen = *d.Seen
+	case d.Timestamp != nil:
+		seen = max(float64(now.UnixMilli())/1000-*d.Timestamp, 0)
+	}
+	if seen > maxAge.Seconds() {
+		return models.Aircraft{}, false
+	}
+
+	// Drones have no ICAO address, so one is derived from the UAS ID and
+	// marked as non-ICAO the way readsb does
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	a := models.Aircraft{
+		Hex:      fmt.Sprintf("~%06x", h.Sum32()&0xffffff),
+		Type:     "other",
+		R:        id,
+		Category: "B6",
+		Seen:     seen,
+		Rssi:     d.RSSI,
+		Source:   models.SourceRemoteID,
+		Protocol: "remote_id",
+		Class:    models.ClassUAS,
+		UAS: &models.UAS{
+			ID:         id,
+			IDType:     remoteIDTypes[d.IDType],
+			UAType:     remoteIDUATypes[d.UAType],
+			OperatorID: strings.TrimSpace(d.OperatorID),
+		},
+	}
+	// 0,0 is sent when the drone has no position fix yet
+	if d.Lat != nil && d.Lon != nil && (*d.Lat != 0 || *d.Lon != 0) {
+		a.Lat, a.Lon = d.Lat, d.Lon
+		a.SeenPos = &seen
+	}
+	if d.OperatorLat != nil && d.OperatorLon != nil && (*d.OperatorLat != 0 || *d.OperatorLon != 0) {
+		a.UAS.OperatorLat, a.UAS.OperatorLon = d.OperatorLat, d.OperatorLon
+	}
+	if d.AltGeo != nil && *d.AltGeo > remoteIDNoAltitude {
+		feet := int(*d.AltGeo / 0.3048)
+		a.AltGeom = &feet
+	}
+	if d.AltBaro != nil && *d.AltBaro > remoteIDNoAltitude {
+		a.AltBaro = &models.Altitude{Feet: int(*d.AltBaro / 0.3048)}
+	}
+	if d.Height != nil && *d.Height > remoteIDNoAltitude {
+		a.UAS.Height = d.Height
+	}
+	if d.Speed != nil && *d.Speed < remoteIDNoSpeed {
+		knots := *d.Speed * 3600 / 1852
+		a.Gs = &knots
+	}
+	if d.SpeedVertical != nil && *d.SpeedVertical < remoteIDNoClimb {
+		fpm := int(*d.SpeedVertical * 60 / 0.3048)
+		a.GeomRate = &fpm
+	}
+	if d.Direction != nil && *d.Direction < remoteIDNoDirection {
+		a.Track = d.Direction
+	}
+	return a, true
+}
+
+// mergeRemoteID adds the drones seen by the Remote ID receiver, which are
+// never reported over ADS-B
+// Returns how many drones were added
+func mergeRemoteID(ctx context.Context, data *models.Dump1090fa) int {
+	source := getRemoteIDSource()
+	if source == nil {
+		return 0
+	}
+
+	drones, err := source.fetch(ctx)
+	if err != nil {
+		logging.WarnCtx(ctx, "Failed to fetch Remote ID drones", "error", err)
+		return 0
+	}
+
+	seen := make(map[string]bool, len(data.Aircraft))
+	for i := range data.Aircraft {
+		seen[strings.ToLower(data.Aircraft[i].Hex)] = true
+	}
+
+	now := time.Now()
+	added := 0
+	for i := range drones {
+		a, ok := drones[i].aircraft(now, source.maxAge)
+		if !ok || seen[a.Hex] {
+			continue
+		}
+		seen[a.Hex] = true
+		data.Aircraft = append(data.Aircraft, a)
+		added++
+	}
+	return added
+}
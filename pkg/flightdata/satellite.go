@@ -0,0 +1,165 @@
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// satelliteSource polls a satellite-based position API (ADS-C or space-based
+// ADS-B as offered by aggregators) to fill oceanic gaps in local coverage
+// These APIs are usually rate limited, so they are polled on their own interval
+type satelliteSource struct {
+	url       string
+	apiKey    string
+	keyHeader string
+	interval  time.Duration
+	client    *http.Client
+
+	mu          sync.Mutex
+	lastFetched time.Time
+}
+
+var (
+	satellite     *satelliteSource
+	satelliteOnce sync.Once
+)
+
+// getSatelliteSource returns the source configured via SATELLITE_DATA_URL, or nil
+func getSatelliteSource() *satelliteSource {
+	satelliteOnce.Do(func() {
+		url := os.Getenv("SATELLITE_DATA_URL")
+		if url == "" {
+			return
+		}
+		client, err := newHTTPClient("SATELLITE_")
+		if err != nil {
+			logging.Error("Satellite source disabled", "error", err)
+			return
+		}
+		satellite = &satelliteSource{
+			url:       url,
+			apiKey:    os.Getenv("SATELLITE_API_KEY"),
+			keyHeader: getEnvOrDefault("SATELLITE_API_KEY_HEADER", "api-auth"),
+			interval:  getEnvDurationOrDefault("SATELLITE_POLL_INTERVAL", time.Minute),
+			client:    client,
+		}
+		logging.Info("Satellite source enabled", "url", url, "interval", satellite.interval)
+	})
+	return satellite
+}
+
+// due reports whether the poll interval has passed, marking the source as fetched if so
+func (s *satelliteSource) due(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastFetched) < s.interval {
+		return false
+	}
+	s.lastFetched = now
+	return true
+}
+
+// fetch retrieves the satellite positions and marks them with source=satellite
+func (s *satelliteSource) fetch(ctx context.Context) ([]models.Aircraft, error) {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_satellite")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+	if s.apiKey != "" {
+		req.Header.Set(s.keyHeader, s.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to fetch satellite data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	logging.DebugHTTPCtx(ctx, "GET", s.url, resp.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("satellite request failed with status: %s", resp.Status)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var data models.Dump1090fa
+	if err := decodeFlightData(resp.Body, &data); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to decode satellite data: %w", err)
+	}
+
+	for i := range data.Aircraft {
+		data.Aircraft[i].Hex = strings.ToLower(data.Aircraft[i].Hex)
+		data.Aircraft[i].Source = models.SourceSatellite
+	}
+	span.SetAttributes(attribute.Int("aircraft.count", len(data.Aircraft)))
+	return data.Aircraft, nil
+}
+
+// mergeSatellite adds satellite positions for aircraft the local receiver
+// does not see, local reports always take precedence
+func mergeSatellite(ctx context.Context, data *models.Dump1090fa) int {
+	source := getSatelliteSource()
+	if source == nil || !source.due(time.Now()) {
+		return 0
+	}
+
+	aircraft, err := source.fetch(ctx)
+	if err != nil {
+		logging.WarnCtx(ctx, "Failed to fetch satellite positions", "error", err)
+		return 0
+	}
+
+	local := make(map[string]bool, len(data.Aircraft))
+	for i := range data.Aircraft {
+		local[strings.ToLower(data.Aircraft[i].Hex)] = true
+	}
+
+	added := 0
+	for _, a := range aircraft {
+		if !local[a.Hex] {
+			data.Aircraft = append(data.Aircraft, a)
+			added++
+		}
+	}
+	return added
+}
+
+// getEnvOrDefault returns the value of an environment variable or the default if not set
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDurationOrDefault returns a positive duration environment variable or the default
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		logging.Warn("Invalid duration, using default", "key", key, "value", value, "default", defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,113 @@
+package flightdata
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Schema modes selected by FLIGHT_DATA_SCHEMA
+const (
+	schemaStrict   = "strict"
+	schemaTolerant = "tolerant"
+)
+
+var (
+	fieldErrorCounter, _ = meter.Int64Counter("adsb2otel.decode.field_errors",
+		metric.WithDescription("Aircraft fields that failed to parse in the tolerant schema mode, by field"),
+		metric.WithUnit("{field}"),
+	)
+
+	tolerant     bool
+	tolerantOnce sync.Once
+
+	// aircraftFields maps the lower case JSON names of the aircraft fields
+	// to their index in models.Aircraft
+	aircraftFields     map[string]int
+	aircraftFieldsOnce sync.Once
+
+	// unknownFields remembers the unknown fields already logged
+	unknownFields sync.Map
+)
+
+// isTolerant reports whether aircraft are decoded field by field, as
+// configured via FLIGHT_DATA_SCHEMA
+// Forks of dump1090 differ in the fields they write: in the default strict
+// mode an unknown field is dropped and a field of an unexpected type fails
+// the poll, in tolerant mode both are kept in Aircraft.Extra and exported as
+// attributes, and parse errors are counted per field
+func isTolerant() bool {
+	tolerantOnce.Do(func() {
+		switch mode := strings.ToLower(strings.TrimSpace(getEnvOrDefault("FLIGHT_DATA_SCHEMA", schemaStrict))); mode {
+		case schemaStrict:
+		case schemaTolerant:
+			tolerant = true
+			logging.Info("Tolerant schema mode enabled, unknown aircraft fields are exported as attributes")
+		default:
+			logging.Warn("Invalid FLIGHT_DATA_SCHEMA, using strict", "value", mode)
+		}
+	})
+	return tolerant
+}
+
+// getAircraftFields returns the index of each aircraft field by JSON name
+func getAircraftFields() map[string]int {
+	aircraftFieldsOnce.Do(func() {
+		t := reflect.TypeOf(models.Aircraft{})
+		aircraftFields = make(map[string]int, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				aircraftFields[strings.ToLower(name)] = i
+			}
+		}
+	})
+	return aircraftFields
+}
+
+// decodeAircraftTolerant decodes the next aircraft object field by field,
+// keeping the fields that are unknown or fail to parse in a.Extra
+// The fields that parse are then decoded together, so the aircraft is
+// decoded like in strict mode
+func decodeAircraftTolerant(dec *json.Decoder, a *models.Aircraft) error {
+	var raw map[string]json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	known := getAircraftFields()
+	t := reflect.TypeOf(*a)
+	for name, value := range raw {
+		i, ok := known[strings.ToLower(name)]
+		if ok {
+			field := reflect.New(t.Field(i).Type)
+			if err := json.Unmarshal(value, field.Interface()); err == nil {
+				continue
+			}
+			fieldErrorCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("field", name)))
+			logging.Debug("Failed to parse aircraft field", "field", name, "value", string(value))
+		} else if _, logged := unknownFields.LoadOrStore(name, true); !logged {
+			logging.Info("Capturing unknown aircraft field", "field", name)
+		}
+
+		if a.Extra == nil {
+			a.Extra = make(map[string]json.RawMessage)
+		}
+		a.Extra[name] = value
+		delete(raw, name)
+	}
+
+	parsed, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(parsed, a)
+}
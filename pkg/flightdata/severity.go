@@ -0,0 +1,171 @@
+package flightdata
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// Conditions records can be given a severity for, besides the interest categories
+const (
+	conditionEmergency = "emergency"
+	conditionWatchlist = "watchlist"
+	conditionOnGround  = "on_ground"
+	conditionLowRSSI   = "low_rssi"
+)
+
+// severityConditions lists every condition a severity can be configured for
+var severityConditions = []string{
+	conditionEmergency, categoryMilitary, categoryInteresting, categoryPIA, categoryLADD,
+	conditionWatchlist, conditionOnGround, conditionLowRSSI,
+}
+
+// severityMapper sets the severity of aircraft records from the conditions
+// they meet, so the collector can route records by severity
+// A record meeting several conditions gets the highest of their severities,
+// and one meeting none is info
+type severityMapper struct {
+	severities map[string]otellog.Severity
+	// watchlist holds lower case glob patterns of addresses, callsigns and registrations
+	watchlist []string
+	// lowRSSI is the signal strength in dBFS below which low_rssi applies
+	lowRSSI float64
+}
+
+var (
+	severities     severityMapper
+	severitiesOnce sync.Once
+)
+
+// getSeverityMapper returns the mapper configured via AIRCRAFT_SEVERITY,
+// AIRCRAFT_TAGGED_SEVERITY, AIRCRAFT_WATCHLIST and AIRCRAFT_LOW_RSSI
+func getSeverityMapper() severityMapper {
+	severitiesOnce.Do(func() {
+		var err error
+		if severities.severities, err = severityMapFromEnv(); err != nil {
+			logging.Warn("Invalid record severity configuration, using info", "error", err)
+			severities.severities = nil
+		}
+		severities.watchlist = parseWatchlist(os.Getenv("AIRCRAFT_WATCHLIST"))
+		severities.lowRSSI = -30
+		if value := strings.TrimSpace(os.Getenv("AIRCRAFT_LOW_RSSI")); value != "" {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f <= 0 {
+				severities.lowRSSI = f
+			} else {
+				logging.Warn("Invalid AIRCRAFT_LOW_RSSI, expected dBFS of 0 or below", "value", value, "default", severities.lowRSSI)
+			}
+		}
+		if len(severities.severities) > 0 {
+			logging.Info("Record severities configured", "conditions", len(severities.severities), "watchlist", len(severities.watchlist))
+		}
+	})
+	return severities
+}
+
+// severityMapFromEnv reads the severity of each condition from
+// AIRCRAFT_SEVERITY, with AIRCRAFT_TAGGED_SEVERITY as the default for the
+// interest categories
+func severityMapFromEnv() (map[string]otellog.Severity, error) {
+	tagged, err := parseSeverity(getEnvOrDefault("AIRCRAFT_TAGGED_SEVERITY", "info"))
+	if err != nil {
+		return nil, fmt.Errorf("AIRCRAFT_TAGGED_SEVERITY: %w", err)
+	}
+	m := make(map[string]otellog.Severity)
+	if tagged != otellog.SeverityInfo {
+		for _, category := range []string{categoryMilitary, categoryInteresting, categoryPIA, categoryLADD} {
+			m[category] = tagged
+		}
+	}
+
+	spec := strings.TrimSpace(os.Getenv("AIRCRAFT_SEVERITY"))
+	if spec == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		condition, name, ok := strings.Cut(entry, "=")
+		condition = strings.ToLower(strings.TrimSpace(condition))
+		if !ok || !slices.Contains(severityConditions, condition) {
+			return nil, fmt.Errorf("AIRCRAFT_SEVERITY: invalid entry %q, expected condition=severity with a condition of %s", strings.TrimSpace(entry), strings.Join(severityConditions, ", "))
+		}
+		severity, err := parseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("AIRCRAFT_SEVERITY: %w", err)
+		}
+		m[condition] = severity
+	}
+	return m, nil
+}
+
+// parseWatchlist splits a comma separated list of patterns
+func parseWatchlist(spec string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// watched reports whether an aircraft's address, callsign or registration
+// matches the watchlist
+func (m severityMapper) watched(a *models.Aircraft) bool {
+	if len(m.watchlist) == 0 {
+		return false
+	}
+	ids := []string{strings.ToLower(a.Hex), strings.ToLower(strings.TrimSpace(a.Flight)), strings.ToLower(a.R)}
+	for _, pattern := range m.watchlist {
+		for _, id := range ids {
+			if id == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, id); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// severity returns the severity of a record for an aircraft in the given
+// interest categories
+func (m severityMapper) severity(a *models.Aircraft, categories []string) otellog.Severity {
+	if len(m.severities) == 0 {
+		return otellog.SeverityInfo
+	}
+
+	conditions := slices.Clone(categories)
+	if routing.Classify(a) == routing.ClassEmergency {
+		conditions = append(conditions, conditionEmergency)
+	}
+	if m.watched(a) {
+		conditions = append(conditions, conditionWatchlist)
+	}
+	if a.OnGround() {
+		conditions = append(conditions, conditionOnGround)
+	}
+	if a.Rssi != 0 && a.Rssi < m.lowRSSI {
+		conditions = append(conditions, conditionLowRSSI)
+	}
+
+	var severity otellog.Severity
+	for _, condition := range conditions {
+		if s, ok := m.severities[condition]; ok {
+			severity = max(severity, s)
+		}
+	}
+	if severity == 0 {
+		return otellog.SeverityInfo
+	}
+	return severity
+}
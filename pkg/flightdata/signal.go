@@ -0,0 +1,76 @@
+package flightdata
+
+import (
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// signalFilter drops aircraft decoded from too weak a signal or too few
+// messages, whose positions are often corrupt
+type signalFilter struct {
+	// minRSSI is the minimum signal strength in dBFS, -Inf when disabled
+	minRSSI     float64
+	minMessages int
+}
+
+var (
+	signal     signalFilter
+	signalOnce sync.Once
+)
+
+// getSignalFilter returns the filter configured via MIN_RSSI and MIN_MESSAGES
+func getSignalFilter() signalFilter {
+	signalOnce.Do(func() {
+		signal = signalFilter{minRSSI: math.Inf(-1)}
+		if value := strings.TrimSpace(os.Getenv("MIN_RSSI")); value != "" {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f <= 0 {
+				signal.minRSSI = f
+			} else {
+				logging.Warn("Invalid MIN_RSSI, expected dBFS of 0 or below", "value", value)
+			}
+		}
+		signal.minMessages = getEnvIntOrDefault("MIN_MESSAGES", 0)
+		if signal.active() {
+			logging.Info("Signal quality filter enabled", "min_rssi", signal.minRSSI, "min_messages", signal.minMessages)
+		}
+	})
+	return signal
+}
+
+func (f signalFilter) active() bool {
+	return !math.IsInf(f.minRSSI, -1) || f.minMessages > 0
+}
+
+// apply removes aircraft below the thresholds in place and returns the
+// remaining aircraft and how many were removed
+func (f signalFilter) apply(aircraft []models.Aircraft) ([]models.Aircraft, int) {
+	if !f.active() {
+		return aircraft, 0
+	}
+	n := len(aircraft)
+	aircraft = slices.DeleteFunc(aircraft, func(a models.Aircraft) bool {
+		return a.Rssi < f.minRSSI || a.Messages < f.minMessages
+	})
+	return aircraft, n - len(aircraft)
+}
+
+// attributes returns the thresholds the exported aircraft passed
+func (f signalFilter) attributes() []otellog.KeyValue {
+	var attrs []otellog.KeyValue
+	if !math.IsInf(f.minRSSI, -1) {
+		attrs = append(attrs, otellog.Float64("filter.min_rssi", f.minRSSI))
+	}
+	if f.minMessages > 0 {
+		attrs = append(attrs, otellog.Int("filter.min_messages", f.minMessages))
+	}
+	return attrs
+}
@@ -0,0 +1,282 @@
+package flightdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/useragent"
+)
+
+// statsPoller scrapes the receiver's stats.json and exports its counters as
+// metrics, so the health of the receiver is monitored alongside its traffic
+type statsPoller struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	// health raises alerts from the statistics, nil when no alert is configured
+	health *receiverHealth
+
+	mu sync.Mutex
+	// latest is the last statistics fetched, nil while they are unavailable
+	latest *models.Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// InitStats starts scraping the stats.json at STATS_URL every STATS_INTERVAL
+// and registers the receiver metrics it is exported through
+// The returned function stops scraping
+func InitStats() (func(), error) {
+	url := os.Getenv("STATS_URL")
+	if url == "" {
+		return func() {}, nil
+	}
+	if err := checkURL(url); err != nil {
+		return func() {}, fmt.Errorf("invalid STATS_URL: %w", err)
+	}
+	client, err := newHTTPClient("STATS_")
+	if err != nil {
+		return func() {}, err
+	}
+
+	p := &statsPoller{
+		url:      url,
+		interval: getEnvDurationOrDefault("STATS_INTERVAL", time.Minute),
+		client:   client,
+		health:   newReceiverHealth(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	reg, err := p.registerMetrics()
+	if err != nil {
+		return func() {}, fmt.Errorf("failed to register receiver metrics: %w", err)
+	}
+
+	go p.run()
+
+	logging.Info("Receiver stats enabled", "url", url, "interval", p.interval)
+	return func() {
+		close(p.stop)
+		<-p.done
+		if err := reg.Unregister(); err != nil {
+			logging.Warn("Failed to unregister receiver metrics", "error", err)
+		}
+	}, nil
+}
+
+func (p *statsPoller) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll()
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// poll fetches the statistics, which stay unavailable until the next
+// successful fetch if it fails so the metrics are not reported stale
+func (p *statsPoller) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.client.Timeout)
+	defer cancel()
+
+	stats, err := p.fetch(ctx)
+	if err != nil {
+		logging.Warn("Failed to fetch receiver stats", "error", err, "url", p.url)
+	} else {
+		logging.Debug("Fetched receiver stats", "messages", stats.Total.Messages)
+		if p.health != nil {
+			p.health.check(ctx, stats, time.Now())
+		}
+	}
+
+	p.mu.Lock()
+	p.latest = stats
+	p.mu.Unlock()
+}
+
+func (p *statsPoller) fetch(ctx context.Context) (*models.Stats, error) {
+	ctx, span := tracer.Start(ctx, "flightdata.fetch_stats")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	useragent.Set(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		err := &statusError{code: resp.StatusCode, status: resp.Status}
+		span.RecordError(err)
+		return nil, err
+	}
+
+	var stats models.Stats
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&stats); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to decode receiver stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// snapshot returns the latest statistics, or nil if they are unavailable
+func (p *statsPoller) snapshot() *models.Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latest
+}
+
+// registerMetrics creates the receiver metrics, observed from the latest
+// statistics at each export
+// Counters are the decoder's totals since it started, and reset with it;
+// gauges are over the last minute
+func (p *statsPoller) registerMetrics() (metric.Registration, error) {
+	messages, err1 := meter.Int64ObservableCounter("adsb2otel.receiver.messages",
+		metric.WithDescription("Messages received by the decoder"),
+		metric.WithUnit("{message}"),
+	)
+	decoded, err2 := meter.Int64ObservableCounter("adsb2otel.receiver.messages.decoded",
+		metric.WithDescription("Mode S messages decoded, by input and result"),
+		metric.WithUnit("{message}"),
+	)
+	strong, err3 := meter.Int64ObservableCounter("adsb2otel.receiver.strong_signals",
+		metric.WithDescription("Messages received above -3 dBFS, a sign of too much gain"),
+		metric.WithUnit("{message}"),
+	)
+	dropped, err4 := meter.Int64ObservableCounter("adsb2otel.receiver.samples.dropped",
+		metric.WithDescription("Samples dropped because the decoder could not keep up"),
+		metric.WithUnit("{sample}"),
+	)
+	cpu, err5 := meter.Float64ObservableCounter("adsb2otel.receiver.cpu.time",
+		metric.WithDescription("CPU time spent by the decoder, by thread"),
+		metric.WithUnit("s"),
+	)
+	tracks, err6 := meter.Int64ObservableCounter("adsb2otel.receiver.tracks",
+		metric.WithDescription("Aircraft tracks created"),
+		metric.WithUnit("{track}"),
+	)
+	singleTracks, err7 := meter.Int64ObservableCounter("adsb2otel.receiver.tracks.single_message",
+		metric.WithDescription("Aircraft tracks that only ever received one message"),
+		metric.WithUnit("{track}"),
+	)
+	rate, err8 := meter.Float64ObservableGauge("adsb2otel.receiver.message_rate",
+		metric.WithDescription("Messages received per second over the last minute"),
+		metric.WithUnit("{message}/s"),
+	)
+	signal, err9 := meter.Float64ObservableGauge("adsb2otel.receiver.signal",
+		metric.WithDescription("Signal level of messages over the last minute, by mean and peak"),
+		metric.WithUnit("dBFS"),
+	)
+	noise, err10 := meter.Float64ObservableGauge("adsb2otel.receiver.noise",
+		metric.WithDescription("Noise floor over the last minute"),
+		metric.WithUnit("dBFS"),
+	)
+	gain, err11 := meter.Float64ObservableGauge("adsb2otel.receiver.gain",
+		metric.WithDescription("Gain the SDR is set to"),
+		metric.WithUnit("dB"),
+	)
+	newTracks, err12 := meter.Int64ObservableGauge("adsb2otel.receiver.tracks.new",
+		metric.WithDescription("Aircraft tracks created over the last minute, by type"),
+		metric.WithUnit("{track}"),
+	)
+	strongRatio, err13 := meter.Float64ObservableGauge("adsb2otel.receiver.strong_signals.ratio",
+		metric.WithDescription("Share of messages received above -3 dBFS over the last minute"),
+		metric.WithUnit("1"),
+	)
+	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8, err9, err10, err11, err12, err13); err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := p.snapshot()
+		if stats == nil {
+			return nil
+		}
+
+		total := stats.Total
+		o.ObserveInt64(messages, total.Messages)
+		if local := total.Local; local != nil {
+			observeDecoded(o, decoded, "local", models.AcceptedMessages(local.Accepted), local.Bad, local.UnknownICAO)
+			o.ObserveInt64(strong, local.StrongSignals)
+			o.ObserveInt64(dropped, local.SamplesDropped)
+		}
+		if remote := total.Remote; remote != nil {
+			observeDecoded(o, decoded, "remote", models.AcceptedMessages(remote.Accepted), remote.Bad, remote.UnknownICAO)
+		}
+		if c := total.CPU; c != nil {
+			for thread, ms := range map[string]int64{"demod": c.Demod, "reader": c.Reader, "background": c.Background} {
+				o.ObserveFloat64(cpu, float64(ms)/1000, metric.WithAttributes(attribute.String("thread", thread)))
+			}
+		}
+		if t := total.Tracks; t != nil {
+			o.ObserveInt64(tracks, t.All)
+			o.ObserveInt64(singleTracks, t.SingleMessage)
+		}
+
+		last := stats.Last1Min
+		if seconds := last.Seconds(); seconds > 0 {
+			o.ObserveFloat64(rate, float64(last.Messages)/seconds)
+		}
+		gainDB := stats.GainDB
+		if local := last.Local; local != nil {
+			if local.Signal != nil {
+				o.ObserveFloat64(signal, *local.Signal, metric.WithAttributes(attribute.String("stat", "mean")))
+			}
+			if local.PeakSignal != nil {
+				o.ObserveFloat64(signal, *local.PeakSignal, metric.WithAttributes(attribute.String("stat", "peak")))
+			}
+			if local.Noise != nil {
+				o.ObserveFloat64(noise, *local.Noise)
+			}
+			if local.GainDB != nil {
+				gainDB = local.GainDB
+			}
+			if accepted := models.AcceptedMessages(local.Accepted); accepted > 0 {
+				o.ObserveFloat64(strongRatio, float64(local.StrongSignals)/float64(accepted))
+			}
+		}
+		if t := last.Tracks; t != nil {
+			o.ObserveInt64(newTracks, t.All, metric.WithAttributes(attribute.String("type", "all")))
+			o.ObserveInt64(newTracks, t.SingleMessage, metric.WithAttributes(attribute.String("type", "single_message")))
+		}
+		if gainDB != nil {
+			o.ObserveFloat64(gain, *gainDB)
+		}
+		return nil
+	}, messages, decoded, strong, dropped, cpu, tracks, singleTracks, rate, signal, noise, gain, newTracks, strongRatio)
+}
+
+// observeDecoded observes the decoded message counts of an input
+func observeDecoded(o metric.Observer, decoded metric.Int64ObservableCounter, input string, accepted, bad, unknownICAO int64) {
+	for result, n := range map[string]int64{"accepted": accepted, "bad": bad, "unknown_icao": unknownICAO} {
+		o.ObserveInt64(decoded, n, metric.WithAttributes(attribute.String("input", input), attribute.String("result", result)))
+	}
+}
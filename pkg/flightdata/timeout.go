@@ -0,0 +1,32 @@
+package flightdata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// PollInterval is the time between fetch cycles
+const PollInterval = 5 * time.Second
+
+var (
+	fetchTimeout     time.Duration
+	fetchTimeoutOnce sync.Once
+)
+
+// getFetchTimeout returns the deadline for fetching and decoding flight data,
+// configured via FLIGHT_DATA_TIMEOUT
+// It is kept below the poll interval so that a stalled receiver cannot hold a
+// cycle past the next tick, which would then be missed
+func getFetchTimeout() time.Duration {
+	fetchTimeoutOnce.Do(func() {
+		limit := PollInterval * 4 / 5
+		fetchTimeout = getEnvDurationOrDefault("FLIGHT_DATA_TIMEOUT", limit)
+		if fetchTimeout > limit {
+			logging.Warn("FLIGHT_DATA_TIMEOUT must be shorter than the poll interval, capping it", "timeout", fetchTimeout, "cap", limit, "poll_interval", PollInterval)
+			fetchTimeout = limit
+		}
+	})
+	return fetchTimeout
+}
@@ -0,0 +1,130 @@
+package flightdata
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// Modes for exporting converted values
+const (
+	// unitsBoth exports converted values alongside the raw ones
+	unitsBoth = "both"
+	// unitsConvert exports converted values in place of the raw ones
+	unitsConvert = "convert"
+)
+
+// unit converts a value from the unit dump1090 reports it in
+type unit struct {
+	suffix string
+	factor float64
+}
+
+// Units exported values can be converted to, by quantity
+var (
+	altitudeUnits = map[string]*unit{"ft": nil, "m": {suffix: "m", factor: 0.3048}}
+	speedUnits    = map[string]*unit{"kt": nil, "kmh": {suffix: "kmh", factor: 1.852}, "ms": {suffix: "ms", factor: 1852.0 / 3600}}
+	distanceUnits = map[string]*unit{"nm": nil, "km": {suffix: "km", factor: 1.852}}
+)
+
+// Attributes holding altitudes in feet, speeds in knots and distances in nautical miles
+var (
+	altitudeAttributes = []string{"aircraft.alt_baro", "aircraft.alt_geom", "aircraft.nav_altitude_mcp", "aircraft.nav_altitude_fms"}
+	speedAttributes    = []string{"aircraft.gs", "aircraft.ias", "aircraft.tas"}
+	distanceAttributes = []string{"aircraft.r_dst"}
+)
+
+// unitConverter converts exported attributes to the configured units
+type unitConverter struct {
+	// units maps the attributes to convert to their target unit
+	units   map[string]*unit
+	replace bool
+}
+
+var (
+	converter     unitConverter
+	converterOnce sync.Once
+)
+
+// getUnitConverter returns the converter configured via EXPORT_ALTITUDE_UNIT,
+// EXPORT_SPEED_UNIT, EXPORT_DISTANCE_UNIT and EXPORT_UNITS
+func getUnitConverter() unitConverter {
+	converterOnce.Do(func() {
+		converter.units = make(map[string]*unit)
+		for _, quantity := range []struct {
+			key        string
+			defaultKey string
+			units      map[string]*unit
+			attributes []string
+		}{
+			{"EXPORT_ALTITUDE_UNIT", "ft", altitudeUnits, altitudeAttributes},
+			{"EXPORT_SPEED_UNIT", "kt", speedUnits, speedAttributes},
+			{"EXPORT_DISTANCE_UNIT", "nm", distanceUnits, distanceAttributes},
+		} {
+			name := strings.ToLower(getEnvOrDefault(quantity.key, quantity.defaultKey))
+			u, ok := quantity.units[name]
+			if !ok {
+				logging.Warn("Invalid unit, exporting raw values", "key", quantity.key, "value", name)
+				continue
+			}
+			if u == nil {
+				continue
+			}
+			for _, attr := range quantity.attributes {
+				converter.units[attr] = u
+			}
+		}
+
+		mode := strings.ToLower(getEnvOrDefault("EXPORT_UNITS", unitsBoth))
+		if mode != unitsBoth && mode != unitsConvert {
+			logging.Warn("Invalid EXPORT_UNITS, using default", "value", mode, "default", unitsBoth)
+			mode = unitsBoth
+		}
+		converter.replace = mode == unitsConvert
+
+		if len(converter.units) > 0 {
+			logging.Info("Unit conversion enabled", "mode", mode)
+		}
+	})
+	return converter
+}
+
+// apply adds a converted copy of each attribute in a configured unit, named
+// after the original with the unit as suffix (e.g. aircraft.alt_baro_m), and
+// drops the original when replacing
+func (c unitConverter) apply(attrs []otellog.KeyValue) []otellog.KeyValue {
+	if len(c.units) == 0 {
+		return attrs
+	}
+	out := make([]otellog.KeyValue, 0, len(attrs)+2)
+	for _, attr := range attrs {
+		u, ok := c.units[attr.Key]
+		if !ok {
+			out = append(out, attr)
+			continue
+		}
+
+		var value float64
+		switch attr.Value.Kind() {
+		case otellog.KindInt64:
+			value = float64(attr.Value.AsInt64())
+		case otellog.KindFloat64:
+			value = attr.Value.AsFloat64()
+		default:
+			// e.g. alt_baro "ground" exported as an extra attribute
+			out = append(out, attr)
+			continue
+		}
+
+		if !c.replace {
+			out = append(out, attr)
+		}
+		converted := math.Round(value*u.factor*10) / 10
+		out = append(out, otellog.Float64(attr.Key+"_"+u.suffix, converted))
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package flightdata
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Watch returns a channel receiving a value when the file FLIGHT_DATA_URL
+// points at has been rewritten, at most once per PollInterval, so a local
+// aircraft.json is read when the decoder writes it instead of on a timer
+// The channel is nil if the source is not a file:// URL or FLIGHT_DATA_WATCH
+// is false, and is closed if watching stops, e.g. when the directory is removed
+func Watch(ctx context.Context) (<-chan struct{}, error) {
+	u, err := url.Parse(os.Getenv("FLIGHT_DATA_URL"))
+	if err != nil || u.Scheme != "file" || !isTrue(getEnvOrDefault("FLIGHT_DATA_WATCH", "true")) {
+		return nil, nil
+	}
+
+	events, err := watchFile(ctx, filePath(u))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		var last time.Time
+		var pending <-chan time.Time
+		notify := func() {
+			last = time.Now()
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				if pending != nil {
+					continue
+				}
+				// Decoders write every second, cycles still run at most once per interval
+				if wait := PollInterval - time.Since(last); wait > 0 {
+					pending = time.After(wait)
+					continue
+				}
+				notify()
+			case <-pending:
+				pending = nil
+				notify()
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package flightdata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// watchFile reports changes to path with inotify
+// The directory is watched rather than the file, as decoders replace the
+// file by renaming a new one over it
+func watchFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	dir, name := filepath.Split(path)
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	// Non-blocking, so reads go through the poller and closing unblocks them
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	events := make(chan struct{}, 1)
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		defer close(events)
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			changed := false
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + syscall.SizeofInotifyEvent
+				end := min(start+int(event.Len), n)
+				offset = end
+				if event.Mask&syscall.IN_IGNORED != 0 {
+					// The directory was removed
+					f.Close()
+					return
+				}
+				if event.Mask&syscall.IN_Q_OVERFLOW != 0 || strings.TrimRight(string(buf[start:end]), "\x00") == name {
+					changed = true
+				}
+			}
+			if changed {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package flightdata
+
+import (
+	"context"
+	"errors"
+)
+
+// watchFile is only supported with inotify, elsewhere file sources are polled
+func watchFile(context.Context, string) (<-chan struct{}, error) {
+	return nil, errors.New("watching files is only supported on Linux")
+}
@@ -0,0 +1,89 @@
+package geo
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusNM = 3440.065
+
+// Position is a WGS84 latitude/longitude pair in decimal degrees
+type Position struct {
+	Lat float64
+	Lon float64
+}
+
+// Valid reports whether the position lies within the WGS84 coordinate range
+func (p Position) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// DistanceNM returns the great-circle distance between two positions in nautical miles
+func DistanceNM(a, b Position) float64 {
+	lat1 := toRadians(a.Lat)
+	lat2 := toRadians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusNM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Bearing returns the initial great-circle bearing from a to b in degrees (0-360)
+func Bearing(a, b Position) float64 {
+	lat1 := toRadians(a.Lat)
+	lat2 := toRadians(b.Lat)
+	dLon := toRadians(b.Lon - a.Lon)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Receiver returns the receiver location configured via RECEIVER_LAT and RECEIVER_LON
+// The second return value is false if the location is not configured or invalid
+func Receiver() (Position, bool) {
+	lat, latOK := parseFloat(os.Getenv("RECEIVER_LAT"))
+	lon, lonOK := parseFloat(os.Getenv("RECEIVER_LON"))
+	if !latOK || !lonOK {
+		return Position{}, false
+	}
+
+	pos := Position{Lat: lat, Lon: lon}
+	if !pos.Valid() {
+		return Position{}, false
+	}
+	return pos, true
+}
+
+// MaxRangeNM returns the maximum plausible reception range configured via
+// RECEIVER_MAX_RANGE_NM, defaulting to 300nm which is beyond the radio horizon
+// of any ground-based receiver
+func MaxRangeNM() float64 {
+	if value, ok := parseFloat(os.Getenv("RECEIVER_MAX_RANGE_NM")); ok && value > 0 {
+		return value
+	}
+	return 300
+}
+
+func parseFloat(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/blackbox"
+	"github.com/burnettdev/adsb2otel/pkg/config"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// bundleInfo describes the process a diagnostic bundle was taken from
+type bundleInfo struct {
+	Version    string    `json:"version"`
+	GoVersion  string    `json:"go_version"`
+	Platform   string    `json:"platform"`
+	Hostname   string    `json:"hostname,omitempty"`
+	Started    time.Time `json:"started"`
+	CreatedAt  time.Time `json:"created_at"`
+	Goroutines int       `json:"goroutines"`
+	Ready      bool      `json:"ready"`
+	NotReady   string    `json:"not_ready,omitempty"`
+}
+
+// bundleHandler returns a zip with recent logs, fetch cycle summaries, the
+// redacted configuration and a goroutine dump, for attaching to bug reports
+// GET /debug/bundle
+func bundleHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+
+	info := bundleInfo{
+		Version:    version.Get(),
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Started:    blackbox.Started().UTC(),
+		CreatedAt:  now,
+		Goroutines: runtime.NumGoroutine(),
+		Ready:      true,
+	}
+	info.Hostname, _ = os.Hostname()
+	if err := Ready(); err != nil {
+		info.Ready = false
+		info.NotReady = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="adsb2otel-bundle-%s.zip"`, now.Format("20060102T150405Z")))
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"info.json", func(w io.Writer) error { return writeJSON(w, info) }},
+		{"config.json", config.Export},
+		{"cycles.json", func(w io.Writer) error { return writeJSON(w, blackbox.Cycles()) }},
+		{"logs.txt", func(w io.Writer) error {
+			lines := blackbox.Logs()
+			if len(lines) == 0 {
+				return nil
+			}
+			_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+			return err
+		}},
+		{"goroutines.txt", func(w io.Writer) error { return pprof.Lookup("goroutine").WriteTo(w, 1) }},
+	}
+
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err == nil {
+			err = file.write(fw)
+		}
+		if err != nil {
+			logging.Warn("Failed to write diagnostic bundle", "file", file.name, "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logging.Warn("Failed to write diagnostic bundle", "error", err)
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
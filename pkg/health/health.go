@@ -0,0 +1,110 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+var (
+	components = make(map[string]error)
+	mu         sync.RWMutex
+)
+
+// Set records the readiness of a component, nil meaning ready
+// A component that has never been set does not affect readiness
+func Set(component string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	prev, known := components[component]
+	components[component] = err
+
+	switch {
+	case err != nil && (!known || prev == nil):
+		logging.Warn("Component not ready", "component", component, "error", err)
+	case err == nil && known && prev != nil:
+		logging.Info("Component ready", "component", component)
+	}
+}
+
+// Ready returns nil if every registered component is ready, otherwise an
+// error listing the components that are not
+func Ready() error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := components[name]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// InitHealth starts the health HTTP server if HEALTH_ADDR is set
+// /healthz reports liveness and /readyz reports readiness of all components,
+// /debug/bundle returns a diagnostic bundle unless HEALTH_DEBUG_BUNDLE is false
+func InitHealth() (func(), error) {
+	addr := os.Getenv("HEALTH_ADDR")
+	if addr == "" {
+		return func() {}, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	if value := strings.ToLower(strings.TrimSpace(os.Getenv("HEALTH_DEBUG_BUNDLE"))); value != "false" && value != "0" {
+		mux.HandleFunc("GET /debug/bundle", bundleHandler)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return func() {}, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Error("Health server stopped", "error", err)
+		}
+	}()
+
+	log.Printf("Health server listening on %s", listener.Addr())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down health server: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,148 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// Info identifies a running instance
+type Info struct {
+	PID           int       `json:"pid"`
+	Hostname      string    `json:"hostname,omitempty"`
+	Version       string    `json:"version"`
+	Started       time.Time `json:"started"`
+	FlightDataURL string    `json:"flight_data_url,omitempty"`
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("pid %d on %s (version %s, started %s, fetching %s)",
+		i.PID, i.Hostname, i.Version, i.Started.Format(time.RFC3339), i.FlightDataURL)
+}
+
+// ErrAlreadyRunning is returned when another instance holds the lock
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+// self describes this process
+func self() Info {
+	info := Info{
+		PID:           os.Getpid(),
+		Version:       version.Get(),
+		Started:       time.Now().UTC(),
+		FlightDataURL: redactURL(os.Getenv("FLIGHT_DATA_URL")),
+	}
+	info.Hostname, _ = os.Hostname()
+	return info
+}
+
+// Acquire guards against a second copy of the service exporting the same
+// receiver, e.g. when a systemd unit and a container overlap
+// INSTANCE_LOCK_FILE takes an exclusive lock on a file, which must be on a
+// volume shared by all copies; INSTANCE_LOCK_PORT binds a loopback port,
+// which works across containers using the host network
+// The returned function releases the guards
+func Acquire() (func(), error) {
+	info := self()
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	if path := os.Getenv("INSTANCE_LOCK_FILE"); path != "" {
+		unlock, err := lockFile(path, info)
+		if err != nil {
+			return func() {}, err
+		}
+		releases = append(releases, unlock)
+		log.Printf("Instance lock acquired: %s", path)
+	}
+
+	if port := os.Getenv("INSTANCE_LOCK_PORT"); port != "" {
+		unlock, err := lockPort(net.JoinHostPort("127.0.0.1", port), info)
+		if err != nil {
+			release()
+			return func() {}, err
+		}
+		releases = append(releases, unlock)
+		log.Printf("Instance lock acquired: port %s", port)
+	}
+
+	return release, nil
+}
+
+// lockPort binds addr and serves this instance's info on it, so that a second
+// instance failing to bind can report which process holds the port
+func lockPort(addr string, info Info) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		if other, ok := queryPort(addr); ok {
+			return nil, fmt.Errorf("%w: %s holds %s", ErrAlreadyRunning, other, addr)
+		}
+		return nil, fmt.Errorf("failed to bind instance lock port %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(info)
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go server.Serve(listener)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// queryPort asks the process holding addr to identify itself
+func queryPort(addr string) (Info, bool) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get("http://" + addr + "/")
+	if err != nil {
+		return Info{}, false
+	}
+	defer resp.Body.Close()
+
+	var info Info
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil || info.PID == 0 {
+		return Info{}, false
+	}
+	return info, true
+}
+
+// redactURL removes credentials from a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// readInfo parses the info written to a lock file, returning whatever could be read
+func readInfo(data []byte) string {
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil || info.PID == 0 {
+		if text := strings.TrimSpace(string(data)); text != "" {
+			return text
+		}
+		return "unknown process"
+	}
+	return info.String()
+}
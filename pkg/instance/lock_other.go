@@ -0,0 +1,31 @@
+//go:build !unix
+
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// lockFile creates path exclusively and writes this instance's info into it
+// Without advisory locks a lock file left behind by a crashed process has to
+// be removed by hand, the error names the process that created it
+func lockFile(path string, info Info) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			data, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%w: %s holds %s (remove it if that process is gone)", ErrAlreadyRunning, readInfo(data), path)
+		}
+		return nil, fmt.Errorf("failed to create instance lock file: %w", err)
+	}
+
+	data, _ := json.Marshal(info)
+	f.Write(append(data, '\n'))
+	f.Close()
+
+	return func() {
+		os.Remove(path)
+	}, nil
+}
@@ -2,6 +2,8 @@ package logging
 
 import (
 	"context"
+	"io"
+	"log"
 	"log/slog"
 	"os"
 	"regexp"
@@ -9,12 +11,24 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/burnettdev/adsb2otel/pkg/blackbox"
 )
 
 type Logger struct {
 	*slog.Logger
 }
 
+// output replaces stdout and stderr as the destination of logs if set
+var output io.Writer
+
+// SetOutput sends logs to w instead of stdout and stderr, for processes
+// without a console such as a Windows service
+// It must be called before Init
+func SetOutput(w io.Writer) {
+	output = w
+}
+
 type logLevel int
 
 const (
@@ -69,9 +83,10 @@ func Init() {
 	}
 
 	logLevel := parseLogLevel(logLevelStr)
+	levels, invalidLevels := parseModuleLevels(logLevel)
 
 	opts := &slog.HandlerOptions{
-		Level: logLevel.toSlogLevel(),
+		Level: levels.minimum(),
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
 				if t, ok := a.Value.Any().(time.Time); ok {
@@ -85,7 +100,18 @@ func Init() {
 		},
 	}
 
-	handler := slog.NewTextHandler(os.Stdout, opts)
+	// Recent output is also kept for diagnostic bundles
+	recent := blackbox.LogWriter()
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if output != nil {
+		stdout, stderr = output, output
+	}
+	log.SetOutput(io.MultiWriter(stderr, recent))
+
+	var handler slog.Handler = slog.NewTextHandler(io.MultiWriter(stdout, recent), opts)
+	if len(levels.modules) > 0 {
+		handler = &moduleHandler{Handler: handler, levels: levels}
+	}
 	logger := slog.New(handler)
 
 	globalLogger = &Logger{Logger: logger}
@@ -94,8 +120,14 @@ func Init() {
 		"level", logLevelStr,
 		"format", "logfmt",
 	)
+	if len(levels.modules) > 0 || levels.floor > slog.LevelDebug {
+		globalLogger.Info("Module log levels configured", "levels", os.Getenv("LOG_LEVELS"), "floor", levels.floor)
+	}
+	if len(invalidLevels) > 0 {
+		globalLogger.Warn("Ignoring invalid LOG_LEVELS entries", "entries", invalidLevels)
+	}
 
-	if logLevel == LevelDebug {
+	if levels.forModule("logging") == slog.LevelDebug {
 		globalLogger.logEnvironmentVariables()
 	}
 }
@@ -130,6 +162,11 @@ func (l *Logger) logEnvironmentVariables() {
 }
 
 func (l *Logger) isSensitiveEnvVar(varName string) bool {
+	return IsSensitiveEnvVar(varName)
+}
+
+// IsSensitiveEnvVar reports whether an environment variable likely holds a secret
+func IsSensitiveEnvVar(varName string) bool {
 	upperVarName := strings.ToUpper(varName)
 
 	for _, sensitive := range sensitiveEnvVars {
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// moduleLevels holds the level configuration for internal logging:
+// LOG_LEVEL is the default, LOG_LEVELS overrides it per module and
+// LOG_LEVEL_FLOOR is a minimum that no module can go below
+type moduleLevels struct {
+	defaultLevel slog.Level
+	floor        slog.Level
+	modules      map[string]slog.Level
+}
+
+// parseModuleLevels reads the level configuration from the environment
+// Returns the entries of LOG_LEVELS that could not be parsed
+func parseModuleLevels(defaultLevel logLevel) (moduleLevels, []string) {
+	levels := moduleLevels{
+		defaultLevel: defaultLevel.toSlogLevel(),
+		floor:        slog.LevelDebug,
+	}
+	if floor := os.Getenv("LOG_LEVEL_FLOOR"); floor != "" {
+		levels.floor = parseLogLevel(floor).toSlogLevel()
+	}
+
+	var invalid []string
+	for _, entry := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		module, level, ok := strings.Cut(entry, "=")
+		module = strings.ToLower(strings.TrimSpace(module))
+		if !ok || module == "" || !validLogLevel(level) {
+			invalid = append(invalid, entry)
+			continue
+		}
+		if levels.modules == nil {
+			levels.modules = make(map[string]slog.Level)
+		}
+		levels.modules[module] = parseLogLevel(strings.TrimSpace(level)).toSlogLevel()
+	}
+	return levels, invalid
+}
+
+// minimum returns the lowest level any module logs at
+func (m moduleLevels) minimum() slog.Level {
+	lowest := m.defaultLevel
+	for _, level := range m.modules {
+		lowest = min(lowest, level)
+	}
+	return max(lowest, m.floor)
+}
+
+// forModule returns the level a module logs at
+func (m moduleLevels) forModule(module string) slog.Level {
+	level, ok := m.modules[module]
+	if !ok {
+		level = m.defaultLevel
+	}
+	return max(level, m.floor)
+}
+
+func validLogLevel(level string) bool {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug", "info", "warn", "warning", "error":
+		return true
+	}
+	return false
+}
+
+// moduleHandler drops records below the level of the module that logged them
+// The module is the package of the first caller outside logging and slog,
+// e.g. flightdata, sinks or main
+type moduleHandler struct {
+	slog.Handler
+	levels moduleLevels
+}
+
+func (h *moduleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.minimum() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levels.forModule(callerModule()) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &moduleHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels}
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{Handler: h.Handler.WithGroup(name), levels: h.levels}
+}
+
+// moduleCache maps the program counter of a logging call site to its module
+var moduleCache sync.Map
+
+// callerModule returns the package name of the code that emitted a log record
+func callerModule() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLoggingFrame(frame.Function) {
+			if module, ok := moduleCache.Load(frame.PC); ok {
+				return module.(string)
+			}
+			module := packageName(frame.Function)
+			moduleCache.Store(frame.PC, module)
+			return module
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+const loggingPackage = "github.com/burnettdev/adsb2otel/pkg/logging."
+
+func isLoggingFrame(function string) bool {
+	return strings.HasPrefix(function, loggingPackage) || strings.HasPrefix(function, "log/slog.")
+}
+
+// packageName returns the last element of the package path of a function,
+// e.g. flightdata for github.com/burnettdev/adsb2otel/pkg/flightdata.FetchAndPushLogs
+func packageName(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	if i := strings.Index(function, "."); i >= 0 {
+		function = function[:i]
+	}
+	return function
+}
@@ -0,0 +1,65 @@
+package models
+
+// Emitter category classes, named after the ADS-B emitter category each code
+// in aircraft.json stands for
+const (
+	CategoryLight            = "light"
+	CategorySmall            = "small"
+	CategoryLarge            = "large"
+	CategoryHighVortexLarge  = "high_vortex_large"
+	CategoryHeavy            = "heavy"
+	CategoryHighPerformance  = "high_performance"
+	CategoryRotorcraft       = "rotorcraft"
+	CategoryGlider           = "glider"
+	CategoryLighterThanAir   = "lighter_than_air"
+	CategoryParachutist      = "parachutist"
+	CategoryUltralight       = "ultralight"
+	CategoryUAV              = "uav"
+	CategorySpaceVehicle     = "space_vehicle"
+	CategoryEmergencyVehicle = "emergency_vehicle"
+	CategoryServiceVehicle   = "service_vehicle"
+	CategoryObstacle         = "obstacle"
+	// CategoryUnknown is used when no category or one without meaning is reported
+	CategoryUnknown = "unknown"
+)
+
+// categoryClasses maps emitter category codes (A0–D7) to their class
+// Codes not listed, such as A0 and B0 (no information) and the reserved
+// codes, are unknown
+var categoryClasses = map[string]string{
+	"A1": CategoryLight,
+	"A2": CategorySmall,
+	"A3": CategoryLarge,
+	"A4": CategoryHighVortexLarge,
+	"A5": CategoryHeavy,
+	"A6": CategoryHighPerformance,
+	"A7": CategoryRotorcraft,
+	"B1": CategoryGlider,
+	"B2": CategoryLighterThanAir,
+	"B3": CategoryParachutist,
+	"B4": CategoryUltralight,
+	"B6": CategoryUAV,
+	"B7": CategorySpaceVehicle,
+	"C1": CategoryEmergencyVehicle,
+	"C2": CategoryServiceVehicle,
+	"C3": CategoryObstacle,
+	"C4": CategoryObstacle,
+	"C5": CategoryObstacle,
+}
+
+// CategoryClasses lists every class CategoryClass returns
+var CategoryClasses = []string{
+	CategoryLight, CategorySmall, CategoryLarge, CategoryHighVortexLarge, CategoryHeavy,
+	CategoryHighPerformance, CategoryRotorcraft, CategoryGlider, CategoryLighterThanAir,
+	CategoryParachutist, CategoryUltralight, CategoryUAV, CategorySpaceVehicle,
+	CategoryEmergencyVehicle, CategoryServiceVehicle, CategoryObstacle, CategoryUnknown,
+}
+
+// CategoryClass returns the readable class of the aircraft's emitter
+// category, such as heavy for A5 or rotorcraft for A7
+func (a *Aircraft) CategoryClass() string {
+	if class, ok := categoryClasses[a.Category]; ok {
+		return class
+	}
+	return CategoryUnknown
+}
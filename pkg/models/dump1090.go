@@ -3,113 +3,265 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
 )
 
-// FlexibleString can unmarshal both strings and numbers from JSON
-type FlexibleString string
+// Altitude is a barometric altitude, which dump1090 reports either as a
+// number of feet or as the literal string "ground"
+type Altitude struct {
+	Feet   int
+	Ground bool
+	// unparsed is set for strings that are neither "ground" nor a number,
+	// such as the empty string some forks write, so Aircraft.UnmarshalJSON
+	// drops the altitude
+	unparsed bool
+}
 
-func (fs *FlexibleString) UnmarshalJSON(data []byte) error {
-	// Handle null values
-	if string(data) == "null" {
-		*fs = FlexibleString("")
+func (a *Altitude) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("cannot unmarshal %s into Altitude", string(data))
+		}
+		if strings.EqualFold(strings.TrimSpace(s), "ground") {
+			*a = Altitude{Ground: true}
+			return nil
+		}
+		feet, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsNaN(feet) || math.IsInf(feet, 0) {
+			*a = Altitude{unparsed: true}
+			return nil
+		}
+		*a = Altitude{Feet: int(math.Round(feet))}
 		return nil
 	}
-	
-	// Try to unmarshal as string first
-	var s string
-	if err := json.Unmarshal(data, &s); err == nil {
-		*fs = FlexibleString(s)
+
+	if string(data) == "null" {
 		return nil
 	}
-	
-	// If that fails, try as number and convert to string
-	var n float64
-	if err := json.Unmarshal(data, &n); err == nil {
-		*fs = FlexibleString(fmt.Sprintf("%.0f", n))
-		return nil
+	// Numbers are parsed directly, as most altitudes are numbers
+	feet, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal %s into Altitude", string(data))
 	}
-	
-	// Try as integer as well
-	var i int64
-	if err := json.Unmarshal(data, &i); err == nil {
-		*fs = FlexibleString(fmt.Sprintf("%d", i))
-		return nil
+	*a = Altitude{Feet: int(math.Round(feet))}
+	return nil
+}
+
+// MarshalJSON writes the altitude back in dump1090's own format
+func (a Altitude) MarshalJSON() ([]byte, error) {
+	if a.Ground {
+		return []byte(`"ground"`), nil
 	}
-	
-	return fmt.Errorf("cannot unmarshal %s into FlexibleString", string(data))
+	return strconv.AppendInt(nil, int64(a.Feet), 10), nil
 }
 
-// String returns the string value
-func (fs FlexibleString) String() string {
-	return string(fs)
+// String returns the altitude as dump1090 reports it
+func (a Altitude) String() string {
+	if a.Ground {
+		return "ground"
+	}
+	return strconv.Itoa(a.Feet)
 }
 
 type Dump1090fa struct {
-	Now      float64 `json:"now"`
-	Messages int     `json:"messages"`
-	Aircraft []struct {
-		Hex            string        `json:"hex"`
-		Type           string        `json:"type"`
-		Flight         string        `json:"flight,omitempty"`
-		R              string        `json:"r"`
-		T              string        `json:"t"`
-		Desc           string        `json:"desc"`
-		AltBaro        FlexibleString `json:"alt_baro,omitempty"`
-		AltGeom        int           `json:"alt_geom,omitempty"`
-		Gs             float64       `json:"gs,omitempty"`
-		Ias            int           `json:"ias,omitempty"`
-		Tas            int           `json:"tas,omitempty"`
-		Mach           float64       `json:"mach,omitempty"`
-		Wd             int           `json:"wd,omitempty"`
-		Ws             int           `json:"ws,omitempty"`
-		Oat            int           `json:"oat,omitempty"`
-		Tat            int           `json:"tat,omitempty"`
-		Track          float64       `json:"track,omitempty"`
-		TrackRate      float64       `json:"track_rate,omitempty"`
-		Roll           float64       `json:"roll,omitempty"`
-		MagHeading     float64       `json:"mag_heading,omitempty"`
-		TrueHeading    float64       `json:"true_heading,omitempty"`
-		BaroRate       int           `json:"baro_rate,omitempty"`
-		GeomRate       int           `json:"geom_rate,omitempty"`
-		Squawk         string        `json:"squawk,omitempty"`
-		Category       string        `json:"category,omitempty"`
-		NavQnh         float64       `json:"nav_qnh,omitempty"`
-		NavAltitudeMcp int           `json:"nav_altitude_mcp,omitempty"`
-		NavHeading     float64       `json:"nav_heading,omitempty"`
-		Lat            float64       `json:"lat,omitempty"`
-		Lon            float64       `json:"lon,omitempty"`
-		Nic            int           `json:"nic,omitempty"`
-		Rc             int           `json:"rc,omitempty"`
-		SeenPos        float64       `json:"seen_pos,omitempty"`
-		RDst           float64       `json:"r_dst,omitempty"`
-		RDir           float64       `json:"r_dir,omitempty"`
-		Version        int           `json:"version,omitempty"`
-		NicBaro        int           `json:"nic_baro,omitempty"`
-		NacP           int           `json:"nac_p,omitempty"`
-		NacV           int           `json:"nac_v,omitempty"`
-		Sil            int           `json:"sil,omitempty"`
-		SilType        string        `json:"sil_type"`
-		Gva            int           `json:"gva,omitempty"`
-		Sda            int           `json:"sda,omitempty"`
-		Alert          int           `json:"alert,omitempty"`
-		Spi            int           `json:"spi,omitempty"`
-		Mlat           []interface{} `json:"mlat"`
-		Tisb           []interface{} `json:"tisb"`
-		Messages       int           `json:"messages"`
-		Seen           float64       `json:"seen"`
-		Rssi           float64       `json:"rssi"`
-		NavAltitudeFms int           `json:"nav_altitude_fms,omitempty"`
-		OwnOp          string        `json:"ownOp,omitempty"`
-		Year           string        `json:"year,omitempty"`
-		Emergency      string        `json:"emergency,omitempty"`
-		NavModes       []string      `json:"nav_modes,omitempty"`
-		DbFlags        int           `json:"dbFlags,omitempty"`
-		LastPosition   struct {
-			Lat     float64 `json:"lat"`
-			Lon     float64 `json:"lon"`
-			Nic     int     `json:"nic"`
-			Rc      int     `json:"rc"`
-			SeenPos float64 `json:"seen_pos"`
-		} `json:"lastPosition,omitempty"`
-	} `json:"aircraft"`
+	Now      float64    `json:"now"`
+	Messages int        `json:"messages"`
+	Aircraft []Aircraft `json:"aircraft"`
+}
+
+// Aircraft is a single entry from the aircraft.json "aircraft" array
+// Optional numeric fields are pointers so that an absent field can be told
+// apart from a genuine zero value such as lat 0 or a vertical rate of 0
+type Aircraft struct {
+	Hex            string        `json:"hex"`
+	Type           string        `json:"type"`
+	Flight         string        `json:"flight,omitempty"`
+	R              string        `json:"r"`
+	T              string        `json:"t"`
+	Desc           string        `json:"desc"`
+	AltBaro        *Altitude     `json:"alt_baro,omitempty"`
+	AltGeom        *int          `json:"alt_geom,omitempty"`
+	Gs             *float64      `json:"gs,omitempty"`
+	Ias            *int          `json:"ias,omitempty"`
+	Tas            *int          `json:"tas,omitempty"`
+	Mach           *float64      `json:"mach,omitempty"`
+	Wd             *int          `json:"wd,omitempty"`
+	Ws             *int          `json:"ws,omitempty"`
+	Oat            *int          `json:"oat,omitempty"`
+	Tat            *int          `json:"tat,omitempty"`
+	Track          *float64      `json:"track,omitempty"`
+	TrackRate      *float64      `json:"track_rate,omitempty"`
+	Roll           *float64      `json:"roll,omitempty"`
+	MagHeading     *float64      `json:"mag_heading,omitempty"`
+	TrueHeading    *float64      `json:"true_heading,omitempty"`
+	BaroRate       *int          `json:"baro_rate,omitempty"`
+	GeomRate       *int          `json:"geom_rate,omitempty"`
+	Squawk         string        `json:"squawk,omitempty"`
+	Category       string        `json:"category,omitempty"`
+	NavQnh         *float64      `json:"nav_qnh,omitempty"`
+	NavAltitudeMcp *int          `json:"nav_altitude_mcp,omitempty"`
+	NavHeading     *float64      `json:"nav_heading,omitempty"`
+	Lat            *float64      `json:"lat,omitempty"`
+	Lon            *float64      `json:"lon,omitempty"`
+	Nic            *int          `json:"nic,omitempty"`
+	Rc             *int          `json:"rc,omitempty"`
+	SeenPos        *float64      `json:"seen_pos,omitempty"`
+	RDst           *float64      `json:"r_dst,omitempty"`
+	RDir           *float64      `json:"r_dir,omitempty"`
+	Version        *int          `json:"version,omitempty"`
+	NicBaro        *int          `json:"nic_baro,omitempty"`
+	NacP           *int          `json:"nac_p,omitempty"`
+	NacV           *int          `json:"nac_v,omitempty"`
+	Sil            *int          `json:"sil,omitempty"`
+	SilType        string        `json:"sil_type"`
+	Gva            *int          `json:"gva,omitempty"`
+	Sda            *int          `json:"sda,omitempty"`
+	Alert          *int          `json:"alert,omitempty"`
+	Spi            *int          `json:"spi,omitempty"`
+	Mlat           []interface{} `json:"mlat"`
+	Tisb           []interface{} `json:"tisb"`
+	Messages       int           `json:"messages"`
+	Seen           float64       `json:"seen"`
+	Rssi           float64       `json:"rssi"`
+	NavAltitudeFms *int          `json:"nav_altitude_fms,omitempty"`
+	OwnOp          string        `json:"ownOp,omitempty"`
+	Year           string        `json:"year,omitempty"`
+	Emergency      string        `json:"emergency,omitempty"`
+	NavModes       []string      `json:"nav_modes,omitempty"`
+	DbFlags        int           `json:"dbFlags,omitempty"`
+	LastPosition   *LastPosition `json:"lastPosition,omitempty"`
+
+	// RecentReceiverIDs lists the receivers that recently contributed
+	// positions, as reported by readsb aggregators
+	RecentReceiverIDs []string `json:"recentReceiverIds,omitempty"`
+
+	// Receivers lists the receivers whose reports were merged into the entry
+	// when several receivers are polled
+	Receivers []string `json:"receivers,omitempty"`
+
+	// Source is where the entry came from when it is not the local receiver,
+	// e.g. "satellite" for positions filled in from a satellite feed
+	Source string `json:"source,omitempty"`
+
+	// Protocol is the radio protocol of entries not received over 1090 MHz,
+	// e.g. "flarm" for gliders reported by the Open Glider Network
+	Protocol string `json:"protocol,omitempty"`
+
+	// Class is the class of aircraft for entries that are not manned
+	// aircraft, "uas" for drones reported over Remote ID
+	Class string `json:"class,omitempty"`
+
+	// UAS holds the Remote ID identification of drones
+	UAS *UAS `json:"uas,omitempty"`
+
+	// Extra holds the fields not recognized, or that failed to parse, when
+	// decoding in the tolerant schema mode
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Source values for aircraft that did not come from the local receiver
+const (
+	SourceReceiver  = "receiver"
+	SourceSatellite = "satellite"
+	SourceOGN       = "ogn"
+	SourceRemoteID  = "remote_id"
+)
+
+// ClassUAS is the class of unmanned aircraft
+const ClassUAS = "uas"
+
+// UAS is the Remote ID identification of a drone and its operator
+type UAS struct {
+	// ID is the UAS ID, a serial number or a registration
+	ID string `json:"id"`
+	// IDType is what the ID is: serial_number, caa_registration,
+	// utm_assigned or specific_session
+	IDType string `json:"id_type,omitempty"`
+	// UAType is the kind of aircraft, e.g. multirotor or aeroplane
+	UAType      string   `json:"ua_type,omitempty"`
+	OperatorID  string   `json:"operator_id,omitempty"`
+	OperatorLat *float64 `json:"operator_lat,omitempty"`
+	OperatorLon *float64 `json:"operator_lon,omitempty"`
+	// Height is the height above the takeoff location in meters
+	Height *float64 `json:"height,omitempty"`
+}
+
+// LastPosition is the most recent position reported for an aircraft whose
+// current position has gone stale
+type LastPosition struct {
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Nic     int     `json:"nic"`
+	Rc      int     `json:"rc"`
+	SeenPos float64 `json:"seen_pos"`
+}
+
+// HasPosition reports whether the aircraft has a current latitude and longitude
+func (a *Aircraft) HasPosition() bool {
+	return a.Lat != nil && a.Lon != nil
+}
+
+// Position returns the aircraft's current position, if known
+func (a *Aircraft) Position() (geo.Position, bool) {
+	if !a.HasPosition() {
+		return geo.Position{}, false
+	}
+	return geo.Position{Lat: *a.Lat, Lon: *a.Lon}, true
+}
+
+// positionFields describe the position and are only taken together, from
+// the report with the freshest position
+var positionFields = map[string]bool{
+	"Lat": true, "Lon": true, "SeenPos": true, "Nic": true, "Rc": true,
+	"RDst": true, "RDir": true, "Mlat": true, "Tisb": true,
+}
+
+// FillFrom sets the fields of a that are unset from another report of the
+// same aircraft, except the position and the fields describing it
+func (a *Aircraft) FillFrom(src *Aircraft) {
+	d, s := reflect.ValueOf(a).Elem(), reflect.ValueOf(src).Elem()
+	for i := range d.NumField() {
+		if positionFields[d.Type().Field(i).Name] {
+			continue
+		}
+		if field := d.Field(i); field.IsZero() && !s.Field(i).IsZero() {
+			field.Set(s.Field(i))
+		}
+	}
+}
+
+// UnmarshalJSON decodes an aircraft, leaving out an altitude reported as an
+// empty or unparseable string so it reads as absent rather than as 0 feet
+func (a *Aircraft) UnmarshalJSON(data []byte) error {
+	type aircraft Aircraft
+	if err := json.Unmarshal(data, (*aircraft)(a)); err != nil {
+		return err
+	}
+	if a.AltBaro != nil && a.AltBaro.unparsed {
+		a.AltBaro = nil
+	}
+	return nil
+}
+
+// OnGround reports whether the aircraft reports its barometric altitude as "ground"
+func (a *Aircraft) OnGround() bool {
+	return a.AltBaro != nil && a.AltBaro.Ground
+}
+
+// AltitudeFeet returns the barometric altitude in feet, falling back to the
+// geometric altitude when no numeric barometric altitude is available
+func (a *Aircraft) AltitudeFeet() (int, bool) {
+	if a.AltBaro != nil && !a.AltBaro.Ground {
+		return a.AltBaro.Feet, true
+	}
+	if a.AltGeom != nil {
+		return *a.AltGeom, true
+	}
+	return 0, false
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAltitudeUnmarshal(t *testing.T) {
+	tests := []struct {
+		json  string
+		want  *Altitude
+		isErr bool
+	}{
+		{`{"alt_baro": 35000}`, &Altitude{Feet: 35000}, false},
+		{`{"alt_baro": 1250.4}`, &Altitude{Feet: 1250}, false},
+		{`{"alt_baro": "ground"}`, &Altitude{Ground: true}, false},
+		{`{"alt_baro": " Ground "}`, &Altitude{Ground: true}, false},
+		{`{"alt_baro": "4500"}`, &Altitude{Feet: 4500}, false},
+		{`{"alt_baro": null}`, nil, false},
+		{`{}`, nil, false},
+		{`{"alt_baro": ""}`, nil, false},
+		{`{"alt_baro": "n/a"}`, nil, false},
+		{`{"alt_baro": "NaN"}`, nil, false},
+		{`{"alt_baro": true}`, nil, true},
+	}
+	for _, tt := range tests {
+		var a Aircraft
+		err := json.Unmarshal([]byte(tt.json), &a)
+		if (err != nil) != tt.isErr {
+			t.Errorf("%s: err = %v", tt.json, err)
+			continue
+		}
+		switch {
+		case tt.isErr:
+		case tt.want == nil && a.AltBaro != nil:
+			t.Errorf("%s: alt_baro = %+v, want absent", tt.json, *a.AltBaro)
+		case tt.want != nil && (a.AltBaro == nil || *a.AltBaro != *tt.want):
+			t.Errorf("%s: alt_baro = %+v, want %+v", tt.json, a.AltBaro, *tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Normalized sources of the messages an aircraft was last heard through
+const (
+	SourceTypeADSB  = "adsb"
+	SourceTypeADSR  = "adsr"
+	SourceTypeTISB  = "tisb"
+	SourceTypeMLAT  = "mlat"
+	SourceTypeADSC  = "adsc"
+	SourceTypeModeS = "mode_s"
+	SourceTypeOther = "other"
+	// SourceTypeUnknown is used when the decoder does not report a type
+	SourceTypeUnknown = "unknown"
+)
+
+// SourceTypes lists every type SourceType returns
+var SourceTypes = []string{
+	SourceTypeADSB, SourceTypeADSR, SourceTypeTISB, SourceTypeMLAT,
+	SourceTypeADSC, SourceTypeModeS, SourceTypeOther, SourceTypeUnknown,
+}
+
+// SourceType normalizes the type reported by the decoder (adsb_icao,
+// adsb_icao_nt, adsr_icao, tisb_trackfile, mode_s, mlat, ...) into whether
+// the aircraft is received directly or rebroadcast, and how
+func (a *Aircraft) SourceType() string {
+	switch {
+	case a.Type == "":
+		return SourceTypeUnknown
+	case strings.HasPrefix(a.Type, "adsb"):
+		return SourceTypeADSB
+	case strings.HasPrefix(a.Type, "adsr"):
+		return SourceTypeADSR
+	case strings.HasPrefix(a.Type, "tisb"):
+		return SourceTypeTISB
+	case a.Type == "mlat":
+		return SourceTypeMLAT
+	case a.Type == "adsc":
+		return SourceTypeADSC
+	case a.Type == "mode_s":
+		return SourceTypeModeS
+	}
+	return SourceTypeOther
+}
+
+// Sources of an aircraft's position, from most to least trusted
+const (
+	// PositionADSB is a position the aircraft broadcast itself
+	PositionADSB = "adsb"
+	// PositionADSC is a position reported over ADS-C, usually via satellite
+	PositionADSC = "adsc"
+	// PositionADSR is an ADS-B position rebroadcast from another data link
+	PositionADSR = "adsr"
+	// PositionTISB is a position derived from ground radar and rebroadcast
+	PositionTISB = "tisb"
+	// PositionMLAT is a position computed by multilateration
+	PositionMLAT = "mlat"
+	// PositionOther is a position from any other source
+	PositionOther = "other"
+)
+
+// PositionSources lists every source PositionSource returns for an aircraft with a position
+var PositionSources = []string{PositionADSB, PositionADSC, PositionADSR, PositionTISB, PositionMLAT, PositionOther}
+
+// PositionSource returns where the aircraft's current position came from,
+// or an empty string if it has none. The mlat and tisb fields list the
+// fields derived from multilateration and TIS-B, readsb also reports the
+// source of the latest message in type
+func (a *Aircraft) PositionSource() string {
+	if !a.HasPosition() {
+		return ""
+	}
+	if slices.Contains(a.Mlat, any("lat")) {
+		return PositionMLAT
+	}
+	if slices.Contains(a.Tisb, any("lat")) {
+		return PositionTISB
+	}
+	switch a.SourceType() {
+	case SourceTypeADSB, SourceTypeUnknown:
+		return PositionADSB
+	case SourceTypeADSR:
+		return PositionADSR
+	case SourceTypeTISB:
+		return PositionTISB
+	case SourceTypeMLAT:
+		return PositionMLAT
+	case SourceTypeADSC:
+		return PositionADSC
+	}
+	return PositionOther
+}
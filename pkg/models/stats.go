@@ -0,0 +1,82 @@
+package models
+
+// Stats is the receiver statistics file (stats.json) written by dump1090-fa
+// and readsb next to aircraft.json
+// Each period holds the counters accumulated over it, fields a decoder does
+// not report are nil
+type Stats struct {
+	Last1Min StatsPeriod `json:"last1min"`
+	Total    StatsPeriod `json:"total"`
+
+	// readsb reports the aircraft currently tracked and the gain at the top level
+	AircraftWithPos    *int     `json:"aircraft_with_pos"`
+	AircraftWithoutPos *int     `json:"aircraft_without_pos"`
+	GainDB             *float64 `json:"gain_db"`
+}
+
+// StatsPeriod is the statistics for one period, with start and end as Unix timestamps
+type StatsPeriod struct {
+	Start    float64      `json:"start"`
+	End      float64      `json:"end"`
+	Messages int64        `json:"messages"`
+	Local    *LocalStats  `json:"local"`
+	Remote   *RemoteStats `json:"remote"`
+	CPU      *CPUStats    `json:"cpu"`
+	Tracks   *TrackStats  `json:"tracks"`
+}
+
+// Seconds returns the length of the period
+func (p StatsPeriod) Seconds() float64 {
+	return p.End - p.Start
+}
+
+// LocalStats counts the messages demodulated by the local SDR
+// Signal levels are in dBFS and missing when no messages were received
+type LocalStats struct {
+	SamplesProcessed int64    `json:"samples_processed"`
+	SamplesDropped   int64    `json:"samples_dropped"`
+	ModeAC           int64    `json:"modeac"`
+	ModeS            int64    `json:"modes"`
+	Bad              int64    `json:"bad"`
+	UnknownICAO      int64    `json:"unknown_icao"`
+	Accepted         []int64  `json:"accepted"`
+	Signal           *float64 `json:"signal"`
+	Noise            *float64 `json:"noise"`
+	PeakSignal       *float64 `json:"peak_signal"`
+	StrongSignals    int64    `json:"strong_signals"`
+	// GainDB is the gain reported by dump1090-fa
+	GainDB *float64 `json:"gain_db"`
+}
+
+// RemoteStats counts the messages received from network inputs
+type RemoteStats struct {
+	ModeAC      int64   `json:"modeac"`
+	ModeS       int64   `json:"modes"`
+	Bad         int64   `json:"bad"`
+	UnknownICAO int64   `json:"unknown_icao"`
+	Accepted    []int64 `json:"accepted"`
+}
+
+// CPUStats is the CPU time spent by the decoder's threads, in milliseconds
+type CPUStats struct {
+	Demod      int64 `json:"demod"`
+	Reader     int64 `json:"reader"`
+	Background int64 `json:"background"`
+}
+
+// TrackStats counts the aircraft tracks created
+type TrackStats struct {
+	All int64 `json:"all"`
+	// SingleMessage is tracks that only ever received one message, usually noise
+	SingleMessage int64 `json:"single_message"`
+}
+
+// AcceptedMessages returns the messages accepted, summed over the numbers
+// of bits corrected
+func AcceptedMessages(accepted []int64) int64 {
+	var n int64
+	for _, count := range accepted {
+		n += count
+	}
+	return n
+}
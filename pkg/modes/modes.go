@@ -0,0 +1,274 @@
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// crcGenerator is the Mode S CRC-24 generator polynomial
+const crcGenerator = 0x1fff409
+
+// callsignCharset maps the 6-bit characters of identification messages
+const callsignCharset = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+var (
+	// ErrUnsupported means the message is not an extended squitter, the only
+	// kind that carries an address and ADS-B data without the interrogation
+	ErrUnsupported = errors.New("unsupported Mode S message")
+	// ErrChecksum means the message failed its CRC, usually a bad decode
+	ErrChecksum = errors.New("Mode S checksum mismatch")
+)
+
+// Message is a decoded extended squitter (DF17 or DF18)
+type Message struct {
+	DF int
+	// CA is the capability of DF17 messages and the control field of DF18 ones
+	CA      int
+	Address uint32
+	// ME is the 56-bit ADS-B message
+	ME []byte
+}
+
+// Parse checks and decodes a 112-bit extended squitter
+func Parse(b []byte) (*Message, error) {
+	if len(b) != 14 {
+		return nil, ErrUnsupported
+	}
+	df := int(b[0] >> 3)
+	if df != 17 && df != 18 {
+		return nil, ErrUnsupported
+	}
+	if checksum(b) != uint32(b[11])<<16|uint32(b[12])<<8|uint32(b[13]) {
+		return nil, ErrChecksum
+	}
+	return &Message{
+		DF:      df,
+		CA:      int(b[0] & 0x07),
+		Address: uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]),
+		ME:      b[4:11],
+	}, nil
+}
+
+// checksum computes the CRC-24 of a message's data, without its parity field
+func checksum(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b[:len(b)-3] {
+		crc ^= uint32(c) << 16
+		for range 8 {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crcGenerator
+			}
+		}
+	}
+	return crc & 0xffffff
+}
+
+// TypeCode returns the ADS-B type code of the message
+func (m *Message) TypeCode() int {
+	return int(m.ME[0] >> 3)
+}
+
+// Hex returns the address the way dump1090 reports it, with a ~ prefix for
+// addresses other than ICAO ones
+// The second return value is false for messages relaying TIS-B track files
+// or in reserved formats, whose address is not an aircraft's own
+func (m *Message) Hex() (string, bool) {
+	hex := fmt.Sprintf("%06x", m.Address)
+	if m.DF == 17 {
+		return hex, true
+	}
+	switch m.CA {
+	case 0, 2, 6:
+		return hex, true
+	case 1, 5:
+		return "~" + hex, true
+	}
+	return "", false
+}
+
+// AddressType returns the address type dump1090 reports the aircraft with
+func (m *Message) AddressType() string {
+	if m.DF == 17 {
+		return "adsb_icao"
+	}
+	switch m.CA {
+	case 0:
+		return "adsb_icao_nt"
+	case 1:
+		return "adsb_other"
+	case 2:
+		return "tisb_icao"
+	case 5:
+		return "tisb_other"
+	case 6:
+		return "adsr_icao"
+	}
+	return "unknown"
+}
+
+// bits returns n bits of the ME field starting at bit start, counting the
+// most significant bit as 0
+func (m *Message) bits(start, n int) uint32 {
+	var v uint32
+	for i := start; i < start+n; i++ {
+		v = v<<1 | uint32(m.ME[i/8]>>(7-i%8)&1)
+	}
+	return v
+}
+
+// Identification decodes the callsign and emitter category of an
+// identification message (type codes 1-4)
+func (m *Message) Identification() (callsign, category string, ok bool) {
+	tc := m.TypeCode()
+	if tc < 1 || tc > 4 {
+		return "", "", false
+	}
+	var sb strings.Builder
+	for i := range 8 {
+		sb.WriteByte(callsignCharset[m.bits(8+6*i, 6)])
+	}
+	callsign = strings.TrimRight(strings.ReplaceAll(sb.String(), "#", ""), " ")
+	if ca := m.bits(5, 3); ca != 0 {
+		category = fmt.Sprintf("%c%d", 'A'+4-tc, ca)
+	}
+	return callsign, category, true
+}
+
+// Position is the CPR-encoded position of an airborne or surface position
+// message
+type Position struct {
+	LatCPR  uint32
+	LonCPR  uint32
+	Odd     bool
+	Surface bool
+	// Altitude is the barometric altitude in feet, or the GNSS height for
+	// type codes 20-22; nil if unavailable or surface
+	Altitude *int
+	// GNSS is true when Altitude is the GNSS height
+	GNSS bool
+	// GroundSpeed in knots and Track in degrees, for surface messages
+	GroundSpeed *float64
+	Track       *float64
+}
+
+// Position decodes an airborne (type codes 9-18 and 20-22) or surface
+// (type codes 5-8) position message
+func (m *Message) Position() (Position, bool) {
+	tc := m.TypeCode()
+	p := Position{
+		Odd:    m.bits(21, 1) == 1,
+		LatCPR: m.bits(22, 17),
+		LonCPR: m.bits(39, 17),
+	}
+	switch {
+	case tc >= 5 && tc <= 8:
+		p.Surface = true
+		if gs, ok := groundSpeed(int(m.bits(5, 7))); ok {
+			p.GroundSpeed = &gs
+		}
+		if m.bits(12, 1) == 1 {
+			track := float64(m.bits(13, 7)) * 360 / 128
+			p.Track = &track
+		}
+	case tc >= 9 && tc <= 18:
+		p.Altitude = altitude(m.bits(8, 12))
+	case tc >= 20 && tc <= 22:
+		p.Altitude = altitude(m.bits(8, 12))
+		p.GNSS = true
+	default:
+		return Position{}, false
+	}
+	return p, true
+}
+
+// altitude decodes the 12-bit altitude field of airborne position messages
+// Only the 25 foot encoding is supported; Gillham coded altitudes, used
+// above 50175 feet, are left out
+func altitude(v uint32) *int {
+	if v == 0 || v&0x10 == 0 {
+		return nil
+	}
+	n := int(v>>5)<<4 | int(v&0x0f)
+	feet := n*25 - 1000
+	return &feet
+}
+
+// groundSpeed decodes the movement field of surface position messages
+func groundSpeed(mov int) (float64, bool) {
+	switch {
+	case mov == 1:
+		return 0, true
+	case mov >= 2 && mov <= 8:
+		return 0.125 + float64(mov-2)*0.125, true
+	case mov >= 9 && mov <= 12:
+		return 1 + float64(mov-9)*0.25, true
+	case mov >= 13 && mov <= 38:
+		return 2 + float64(mov-13)*0.5, true
+	case mov >= 39 && mov <= 93:
+		return 15 + float64(mov-39), true
+	case mov >= 94 && mov <= 108:
+		return 70 + float64(mov-94)*2, true
+	case mov >= 109 && mov <= 123:
+		return 100 + float64(mov-109)*5, true
+	case mov == 124:
+		return 175, true
+	}
+	return 0, false
+}
+
+// Velocity is the ground speed and vertical rate of an airborne velocity
+// message
+type Velocity struct {
+	// GroundSpeed in knots and Track in degrees
+	GroundSpeed float64
+	Track       float64
+	// VerticalRate in feet per minute, nil if unavailable
+	VerticalRate *int
+	// GNSS is true when the vertical rate is geometric rather than barometric
+	GNSS bool
+}
+
+// Velocity decodes an airborne velocity message (type code 19) with ground
+// speed (subtypes 1 and 2); airspeed subtypes are left out
+func (m *Message) Velocity() (Velocity, bool) {
+	if m.TypeCode() != 19 {
+		return Velocity{}, false
+	}
+	st := m.bits(5, 3)
+	if st != 1 && st != 2 {
+		return Velocity{}, false
+	}
+	vew, vns := m.bits(14, 10), m.bits(25, 10)
+	if vew == 0 || vns == 0 {
+		return Velocity{}, false
+	}
+	scale := 1.0
+	if st == 2 {
+		scale = 4
+	}
+	ew := float64(vew-1) * scale
+	if m.bits(13, 1) == 1 {
+		ew = -ew
+	}
+	ns := float64(vns-1) * scale
+	if m.bits(24, 1) == 1 {
+		ns = -ns
+	}
+
+	v := Velocity{
+		GroundSpeed: math.Hypot(ew, ns),
+		Track:       math.Mod(math.Atan2(ew, ns)*180/math.Pi+360, 360),
+		GNSS:        m.bits(35, 1) == 0,
+	}
+	if vr := m.bits(37, 9); vr != 0 {
+		rate := int(vr-1) * 64
+		if m.bits(36, 1) == 1 {
+			rate = -rate
+		}
+		v.VerticalRate = &rate
+	}
+	return v, true
+}
@@ -0,0 +1,223 @@
+package modes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Messages from "The 1090MHz Riddle"
+const (
+	identification = "8D4840D6202CC371C32CE0576098"
+	positionEven   = "8D40621D58C382D690C8AC2863A7"
+	positionOdd    = "8D40621D58C386435CC412692AD6"
+	velocity       = "8D485020994409940838175B284F"
+)
+
+func parse(t *testing.T, s string) *Message {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", s, err)
+	}
+	return m
+}
+
+func TestParse(t *testing.T) {
+	m := parse(t, identification)
+	if m.DF != 17 || m.Address != 0x4840d6 || m.TypeCode() != 4 {
+		t.Errorf("DF %d, address %06x, type code %d", m.DF, m.Address, m.TypeCode())
+	}
+	if hex, ok := m.Hex(); !ok || hex != "4840d6" || m.AddressType() != "adsb_icao" {
+		t.Errorf("Hex() = %q, %v, type %q", hex, ok, m.AddressType())
+	}
+
+	corrupt, _ := hex.DecodeString(identification)
+	corrupt[5] ^= 0x01
+	if _, err := Parse(corrupt); !errors.Is(err, ErrChecksum) {
+		t.Errorf("err = %v, want ErrChecksum", err)
+	}
+	// DF11 all-call replies carry no ADS-B data
+	short, _ := hex.DecodeString("5D4840D6E3A2B1")
+	if _, err := Parse(short); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestHexNonICAO(t *testing.T) {
+	tests := []struct {
+		ca       int
+		hex, typ string
+		ok       bool
+	}{
+		{0, "abc123", "adsb_icao_nt", true},
+		{1, "~abc123", "adsb_other", true},
+		{2, "abc123", "tisb_icao", true},
+		{3, "", "unknown", false},
+		{5, "~abc123", "tisb_other", true},
+		{6, "abc123", "adsr_icao", true},
+	}
+	for _, tt := range tests {
+		m := &Message{DF: 18, CA: tt.ca, Address: 0xabc123}
+		hex, ok := m.Hex()
+		if hex != tt.hex || ok != tt.ok || m.AddressType() != tt.typ {
+			t.Errorf("CF %d: Hex() = %q, %v, type %q", tt.ca, hex, ok, m.AddressType())
+		}
+	}
+}
+
+func TestIdentification(t *testing.T) {
+	callsign, category, ok := parse(t, identification).Identification()
+	if !ok || callsign != "KLM1023" || category != "" {
+		t.Errorf("Identification() = %q, %q, %v", callsign, category, ok)
+	}
+	if _, _, ok := parse(t, positionEven).Identification(); ok {
+		t.Error("position message decoded as identification")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		msg            string
+		odd            bool
+		latCPR, lonCPR uint32
+	}{
+		{positionEven, false, 93000, 51372},
+		{positionOdd, true, 74158, 50194},
+	}
+	for _, tt := range tests {
+		p, ok := parse(t, tt.msg).Position()
+		if !ok {
+			t.Fatalf("%s: not a position", tt.msg)
+		}
+		if p.Odd != tt.odd || p.LatCPR != tt.latCPR || p.LonCPR != tt.lonCPR || p.Surface {
+			t.Errorf("%s: %+v", tt.msg, p)
+		}
+		if p.Altitude == nil || *p.Altitude != 38000 || p.GNSS {
+			t.Errorf("%s: altitude %v", tt.msg, p.Altitude)
+		}
+	}
+}
+
+func TestVelocity(t *testing.T) {
+	v, ok := parse(t, velocity).Velocity()
+	if !ok {
+		t.Fatal("not a velocity")
+	}
+	if math.Abs(v.GroundSpeed-159.20) > 0.01 || math.Abs(v.Track-182.88) > 0.01 {
+		t.Errorf("ground speed %.2f, track %.2f", v.GroundSpeed, v.Track)
+	}
+	if v.VerticalRate == nil || *v.VerticalRate != -832 {
+		t.Errorf("vertical rate %v", v.VerticalRate)
+	}
+}
+
+func TestGroundSpeed(t *testing.T) {
+	tests := []struct {
+		mov  int
+		want float64
+		ok   bool
+	}{
+		{0, 0, false},
+		{1, 0, true},
+		{2, 0.125, true},
+		{9, 1, true},
+		{13, 2, true},
+		{39, 15, true},
+		{94, 70, true},
+		{109, 100, true},
+		{124, 175, true},
+		{125, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := groundSpeed(tt.mov); got != tt.want || ok != tt.ok {
+			t.Errorf("groundSpeed(%d) = %v, %v", tt.mov, got, ok)
+		}
+	}
+}
+
+func TestAVRReader(t *testing.T) {
+	input := strings.Join([]string{
+		"*" + identification + ";",
+		"garbage",
+		"@0123456789AB" + positionEven + ";",
+		"*8D40;",
+	}, "\n")
+	r, err := NewReader(strings.NewReader(input), "avr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{identification, positionEven} {
+		f, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.ToUpper(hex.EncodeToString(f.Data)); got != want {
+			t.Errorf("frame %s, want %s", got, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("err = %v, want EOF", err)
+	}
+}
+
+// beastFrame encodes a long message as a Beast frame, doubling escape bytes
+func beastFrame(msg []byte, signal byte) []byte {
+	body := append([]byte{0, 0, 0, 0, 0, 0x1a, signal}, msg...)
+	out := []byte{beastEscape, '3'}
+	for _, c := range body {
+		out = append(out, c)
+		if c == beastEscape {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestBeastReader(t *testing.T) {
+	first, _ := hex.DecodeString(identification)
+	second, _ := hex.DecodeString(velocity)
+
+	var input bytes.Buffer
+	input.WriteString("noise")
+	input.Write(beastFrame(first, 255))
+	// A frame cut short by the start of the next one is skipped
+	input.Write([]byte{beastEscape, '3', 1, 2, 3})
+	input.Write(beastFrame(second, 0))
+	// Status frames carry no message
+	input.Write([]byte{beastEscape, '4', 1, 2})
+
+	r, err := NewReader(&input, "beast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, first) || f.RSSI != 0 {
+		t.Errorf("frame %x, rssi %v", f.Data, f.RSSI)
+	}
+	f, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, second) {
+		t.Errorf("frame %x, want %x", f.Data, second)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("err = %v, want EOF", err)
+	}
+
+	if _, err := NewReader(&input, "sbs"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
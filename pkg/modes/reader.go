@@ -0,0 +1,155 @@
+package modes
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// beastEscape starts each Beast frame and is doubled inside one
+const beastEscape = 0x1a
+
+// Frame is a raw Mode S message as received from the decoder
+type Frame struct {
+	Data []byte
+	// RSSI is the signal level in dBFS, 0 when the format carries none
+	RSSI float64
+}
+
+// Reader reads frames from a raw output of dump1090 or readsb
+type Reader interface {
+	Read() (Frame, error)
+}
+
+// NewReader returns a reader for the Beast binary output (port 30005) or
+// the AVR text output (port 30002), by format name
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch strings.ToLower(format) {
+	case "beast":
+		return &beastReader{r: bufio.NewReader(r)}, nil
+	case "avr":
+		return &avrReader{s: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("unknown raw format %q, expected beast or avr", format)
+}
+
+// avrReader reads lines such as *8D4840D6202CC371C32CE0576098; and the
+// @-prefixed variant with a 48-bit timestamp
+type avrReader struct {
+	s *bufio.Scanner
+}
+
+func (a *avrReader) Read() (Frame, error) {
+	for a.s.Scan() {
+		if data, ok := parseAVR(a.s.Text()); ok {
+			return Frame{Data: data}, nil
+		}
+	}
+	if err := a.s.Err(); err != nil {
+		return Frame{}, err
+	}
+	return Frame{}, io.EOF
+}
+
+// parseAVR decodes one line of AVR output, skipping malformed lines
+func parseAVR(line string) ([]byte, bool) {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "*"):
+		line = line[1:]
+	case strings.HasPrefix(line, "@") && len(line) > 13:
+		line = line[13:]
+	default:
+		return nil, false
+	}
+	line = strings.TrimSuffix(line, ";")
+	data, err := hex.DecodeString(line)
+	if err != nil || (len(data) != 7 && len(data) != 14) {
+		return nil, false
+	}
+	return data, true
+}
+
+// beastReader reads the Beast binary format: an escape byte, a type, a
+// 48-bit timestamp, a signal level and the message, with escape bytes in the
+// rest of the frame doubled
+type beastReader struct {
+	r *bufio.Reader
+	// started is set when the escape byte of the next frame has already
+	// been read, ending a truncated one
+	started bool
+}
+
+func (b *beastReader) Read() (Frame, error) {
+	for {
+		if !b.started {
+			c, err := b.r.ReadByte()
+			if err != nil {
+				return Frame{}, err
+			}
+			if c != beastEscape {
+				continue
+			}
+		}
+		b.started = false
+		kind, err := b.r.ReadByte()
+		if err != nil {
+			return Frame{}, err
+		}
+		var n int
+		switch kind {
+		case '1':
+			n = 2
+		case '2':
+			n = 7
+		case '3':
+			n = 14
+		default:
+			// Not a frame start, or a frame type carrying no message
+			continue
+		}
+		buf := make([]byte, 7+n)
+		if err := b.readEscaped(buf); err != nil {
+			if errors.Is(err, errBeastResync) {
+				continue
+			}
+			return Frame{}, err
+		}
+		f := Frame{Data: buf[7:]}
+		if signal := float64(buf[6]) / 255; signal > 0 {
+			f.RSSI = 10 * math.Log10(signal*signal)
+		}
+		return f, nil
+	}
+}
+
+// errBeastResync is returned when a frame ends early, at the start of
+// another
+var errBeastResync = errors.New("unexpected frame start")
+
+// readEscaped fills buf, collapsing doubled escape bytes
+func (b *beastReader) readEscaped(buf []byte) error {
+	for i := range buf {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == beastEscape {
+			next, err := b.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if next != beastEscape {
+				b.r.UnreadByte()
+				b.started = true
+				return errBeastResync
+			}
+		}
+		buf[i] = c
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+// Causes an export failure is attributed to
+const (
+	// CauseNetwork means the collector could not be reached
+	CauseNetwork = "network"
+	// CauseBackend means the collector is up but reports itself unhealthy,
+	// usually because its own exporters cannot reach the backend
+	CauseBackend = "backend"
+	// CauseRejected means the collector is healthy but refused the export,
+	// e.g. because of authentication or a wrong endpoint path
+	CauseRejected = "rejected"
+	// CauseUnknown means there is not enough information to tell
+	CauseUnknown = "unknown"
+)
+
+// Collector states as seen through its health_check extension
+const (
+	stateUnknown     = "unknown"
+	stateHealthy     = "healthy"
+	stateUnhealthy   = "unhealthy"
+	stateUnreachable = "unreachable"
+)
+
+var (
+	meter = metrics.Meter("collector")
+
+	exportErrors, _ = meter.Int64Counter("adsb2otel.export.errors",
+		metric.WithDescription("OpenTelemetry export failures by likely cause"),
+		metric.WithUnit("{error}"),
+	)
+	collectorHealthy, _ = meter.Int64Gauge("adsb2otel.collector.healthy",
+		metric.WithDescription("Whether the collector health check reported healthy (1) or not (0)"),
+		metric.WithUnit("1"),
+	)
+
+	mu    sync.RWMutex
+	state = stateUnknown
+)
+
+// Init installs an OpenTelemetry error handler that counts export failures
+// and logs them with their likely cause. If OTEL_COLLECTOR_HEALTH_URL is set
+// the collector's health_check extension is polled so that failures can be
+// told apart: network problems when the collector is unreachable, backend
+// problems when it reports itself unhealthy
+// The returned function stops polling
+func Init() (func(), error) {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(handleError))
+
+	url := os.Getenv("OTEL_COLLECTOR_HEALTH_URL")
+	if url == "" {
+		return func() {}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if resolver := dnscache.Get(); resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+	p := &poller{
+		url:      url,
+		client:   &http.Client{Transport: transport},
+		interval: getEnvDuration("OTEL_COLLECTOR_HEALTH_INTERVAL", 15*time.Second),
+		timeout:  getEnvDuration("OTEL_COLLECTOR_HEALTH_TIMEOUT", 5*time.Second),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	// Check once up front so the first export failure can already be attributed
+	p.check()
+	go p.run()
+
+	log.Printf("Collector health checks enabled (url: %s, interval: %s)", url, p.interval)
+	return func() {
+		close(p.stop)
+		<-p.done
+	}, nil
+}
+
+// handleError receives errors reported by the OpenTelemetry SDK, which are
+// mostly failed exports
+func handleError(err error) {
+	state := currentState()
+	cause := classify(err, state)
+	exportErrors.Add(context.Background(), 1, metric.WithAttributes(attribute.String("cause", cause)))
+	logging.Warn("OpenTelemetry export failed", "cause", cause, "collector", state, "error", err)
+}
+
+// classify attributes an export failure to a cause from the error and what
+// the collector's health check last reported
+func classify(err error, state string) string {
+	switch {
+	case state == stateUnreachable || isNetworkError(err):
+		return CauseNetwork
+	case state == stateUnhealthy:
+		return CauseBackend
+	case state == stateHealthy:
+		return CauseRejected
+	}
+	return CauseUnknown
+}
+
+// isNetworkError reports whether err looks like a failure to reach the collector
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return true
+		}
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection refused") || strings.Contains(msg, "no such host") || strings.Contains(msg, "deadline exceeded")
+}
+
+func currentState() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state
+}
+
+// setState records the collector state, logging transitions
+func setState(next string, err error) {
+	mu.Lock()
+	prev := state
+	state = next
+	mu.Unlock()
+
+	healthy := int64(0)
+	if next == stateHealthy {
+		healthy = 1
+	}
+	collectorHealthy.Record(context.Background(), healthy)
+
+	switch {
+	case next == prev:
+	case next == stateHealthy:
+		logging.Info("Collector healthy", "previous", prev)
+	default:
+		logging.Warn("Collector not healthy", "state", next, "previous", prev, "error", err)
+	}
+}
+
+// poller polls the health_check extension of the collector
+type poller struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	timeout  time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (p *poller) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// check polls the health endpoint once and records the collector state
+// The extension answers 200 when the collector is healthy and 503 otherwise
+func (p *poller) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		setState(stateUnknown, err)
+		return
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		setState(stateUnreachable, err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode == http.StatusOK {
+		setState(stateHealthy, nil)
+		return
+	}
+	err = fmt.Errorf("health check returned %s", resp.Status)
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Status != "" {
+		err = fmt.Errorf("health check returned %s: %s", resp.Status, payload.Status)
+	}
+	setState(stateUnhealthy, err)
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %s=%s, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
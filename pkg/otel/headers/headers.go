@@ -0,0 +1,120 @@
+package headers
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// FromEnv returns the OTLP export headers for a signal (LOGS, TRACES or METRICS)
+//
+// Headers are merged from, lowest precedence first:
+// OTEL_EXPORTER_OTLP_<SIGNAL>_HEADERS_FILE, OTEL_EXPORTER_OTLP_<SIGNAL>_HEADERS,
+// OTEL_EXPORTER_OTLP_HEADERS_FILE and OTEL_EXPORTER_OTLP_HEADERS, so shared
+// settings take precedence over signal-specific ones as for the other OTLP options
+func FromEnv(signal string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_" + signal + "_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS"} {
+		if path := os.Getenv(key + "_FILE"); path != "" {
+			fileHeaders, err := ParseFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s_FILE: %w", key, err)
+			}
+			for k, v := range fileHeaders {
+				headers[k] = v
+			}
+		}
+		envHeaders, err := Parse(os.Getenv(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		for k, v := range envHeaders {
+			headers[k] = v
+		}
+	}
+	return headers, nil
+}
+
+// Parse parses headers in the format of the OpenTelemetry specification:
+// comma separated key=value pairs where values are percent-encoded as in W3C
+// Baggage, so a comma in a value is written as %2C. A value may contain '='
+// unencoded, e.g. base64 padding, as only the first '=' separates the key
+func Parse(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for i, member := range strings.Split(s, ",") {
+		if strings.TrimSpace(member) == "" {
+			continue
+		}
+		key, value, err := parseMember(i+1, member)
+		if err != nil {
+			return nil, err
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// ParseFile reads headers from a file with one key=value pair per line, for
+// credentials too long or awkward to keep in an environment variable
+// Values are taken literally, without percent-decoding, and blank lines and
+// lines starting with # are ignored
+func ParseFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open headers file: %w", err)
+	}
+	defer f.Close()
+
+	headers := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validKey(key) {
+			return nil, fmt.Errorf("line %d: expected key=value", line)
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read headers file: %w", err)
+	}
+	return headers, nil
+}
+
+// parseMember parses the n-th key=value pair, decoding its value
+// Errors never include values, as they are usually credentials
+func parseMember(n int, member string) (string, string, error) {
+	key, value, ok := strings.Cut(member, "=")
+	key = strings.TrimSpace(key)
+	if !ok || !validKey(key) {
+		return "", "", fmt.Errorf("invalid header %d, expected key=value with commas in values encoded as %%2C", n)
+	}
+	decoded, err := url.PathUnescape(strings.TrimSpace(value))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid percent-encoding in value of header %s", key)
+	}
+	return key, decoded, nil
+}
+
+// validKey reports whether key is a valid HTTP header name (an RFC 7230 token)
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
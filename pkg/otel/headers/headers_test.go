@@ -0,0 +1,132 @@
+package headers
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"pairs", "Authorization=Bearer token, api-key=key", map[string]string{"Authorization": "Bearer token", "api-key": "key"}},
+		{"trailing comma", "api-key=key,", map[string]string{"api-key": "key"}},
+		{"= in value", "Authorization=Basic dXNlcjpwYXNz==", map[string]string{"Authorization": "Basic dXNlcjpwYXNz=="}},
+		{"encoded comma", "X-List=a%2Cb", map[string]string{"X-List": "a,b"}},
+		{"encoded =", "X-Eq=a%3Db", map[string]string{"X-Eq": "a=b"}},
+		{"encoded percent", "X-Pct=100%25", map[string]string{"X-Pct": "100%"}},
+		{"empty value", "X-Empty=", map[string]string{"X-Empty": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"unencoded comma", "X-List=a,b", "invalid header 2"},
+		{"missing =", "Authorization", "invalid header 1"},
+		{"empty key", "=value", "invalid header 1"},
+		{"invalid key", "X Key=value", "invalid header 1"},
+		{"bad escape", "Authorization=Bearer secret%zz", "invalid percent-encoding in value of header Authorization"},
+		{"truncated escape", "Authorization=secret%2", "invalid percent-encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse(%q) error = %v, want %s", tt.value, err, tt.want)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("error %q includes the value", err)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "literal values",
+			content: "# Grafana Cloud\nAuthorization = Basic dXNlcjpwYXNz==\n\nX-List=a,b%2Cc\n",
+			want:    map[string]string{"Authorization": "Basic dXNlcjpwYXNz==", "X-List": "a,b%2Cc"},
+		},
+		{name: "empty", content: "", want: map[string]string{}},
+		{name: "missing =", content: "X-Scope-OrgID=tenant\nAuthorization\n", err: "line 2: expected key=value"},
+		{name: "invalid key", content: "X Scope=tenant\n", err: "line 1: expected key=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "headers")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFile(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseFile error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ParseFile = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ParseFile succeeded for a missing file")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "headers")
+	if err := os.WriteFile(path, []byte("Authorization=Basic abc==\nX-Scope-OrgID=from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_LOGS_HEADERS_FILE", path)
+	t.Setenv("OTEL_EXPORTER_OTLP_LOGS_HEADERS", "X-Scope-OrgID=logs")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS_FILE", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Shared=yes")
+
+	got, err := FromEnv("LOGS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Authorization": "Basic abc==", "X-Scope-OrgID": "logs", "X-Shared": "yes"}
+	if !maps.Equal(got, want) {
+		t.Errorf("FromEnv = %v, want %v", got, want)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS_FILE", filepath.Join(dir, "missing"))
+	if _, err := FromEnv("LOGS"); err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_HEADERS_FILE") {
+		t.Errorf("FromEnv error = %v for an unreadable file", err)
+	}
+}
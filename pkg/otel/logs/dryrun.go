@@ -0,0 +1,110 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/otel/resource"
+)
+
+// InitDryRun sets up a logger provider that prints each log record to w in a
+// readable form instead of exporting it, for verifying a configuration
+// Records are printed as soon as they are emitted
+func InitDryRun(w io.Writer) (func(), error) {
+	res, err := resource.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(&textExporter{w: w})),
+		sdklog.WithResource(res),
+	)
+
+	mu.Lock()
+	globalLoggerProvider = lp
+	mu.Unlock()
+
+	return func() {
+		if err := lp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down logger provider: %v", err)
+		}
+	}, nil
+}
+
+// textExporter writes a record per block: the severity and body on the first
+// line, followed by the attributes sorted by key
+type textExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *textExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var b strings.Builder
+	for i := range records {
+		r := &records[i]
+
+		severity := r.SeverityText()
+		if severity == "" {
+			severity = r.Severity().String()
+		}
+		scope := strings.TrimPrefix(r.InstrumentationScope().Name, scopePrefix)
+		if event := r.EventName(); event != "" {
+			fmt.Fprintf(&b, "[%s] %s %s %s\n", scope, severity, event, formatLogValue(r.Body()))
+		} else {
+			fmt.Fprintf(&b, "[%s] %s %s\n", scope, severity, formatLogValue(r.Body()))
+		}
+
+		var attrs []string
+		r.WalkAttributes(func(kv otellog.KeyValue) bool {
+			attrs = append(attrs, fmt.Sprintf("    %s = %s\n", kv.Key, formatLogValue(kv.Value)))
+			return true
+		})
+		sort.Strings(attrs)
+		b.WriteString(strings.Join(attrs, ""))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *textExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *textExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// formatLogValue formats a value, quoting strings so empty values stay visible
+func formatLogValue(v otellog.Value) string {
+	switch v.Kind() {
+	case otellog.KindString:
+		return fmt.Sprintf("%q", v.AsString())
+	case otellog.KindSlice:
+		items := make([]string, 0, len(v.AsSlice()))
+		for _, item := range v.AsSlice() {
+			items = append(items, formatLogValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case otellog.KindMap:
+		items := make([]string, 0, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			items = append(items, kv.Key+": "+formatLogValue(kv.Value))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,366 @@
+package logs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// jsonExporter writes log records as OTLP-JSON, one export request per line,
+// the format read by the OpenTelemetry Collector's otlpjsonfile receiver
+// It is meant for verifying what would be sent before wiring a collector
+type jsonExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newJSONExporter creates the exporter selected by OTEL_LOGS_EXPORTER:
+// console writes to stdout, file to a rotating file
+func newJSONExporter(name string) (*jsonExporter, error) {
+	if name == "console" {
+		return &jsonExporter{w: os.Stdout}, nil
+	}
+
+	path := getEnv("OTEL_LOGS_EXPORTER_FILE_PATH", "adsb2otel-logs.jsonl")
+	maxSize, err := strconv.Atoi(getEnv("OTEL_LOGS_EXPORTER_FILE_MAX_SIZE_MB", "100"))
+	if err != nil || maxSize <= 0 {
+		return nil, fmt.Errorf("invalid OTEL_LOGS_EXPORTER_FILE_MAX_SIZE_MB")
+	}
+	maxBackups, err := strconv.Atoi(getEnv("OTEL_LOGS_EXPORTER_FILE_MAX_BACKUPS", "5"))
+	if err != nil || maxBackups < 0 {
+		return nil, fmt.Errorf("invalid OTEL_LOGS_EXPORTER_FILE_MAX_BACKUPS")
+	}
+
+	w, err := newRotatingFile(path, int64(maxSize)<<20, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonExporter{w: w}, nil
+}
+
+func (e *jsonExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(toOTLPJSON(records))
+	if err != nil {
+		return fmt.Errorf("failed to encode log records: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+func (e *jsonExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (e *jsonExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// OTLP-JSON structures, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+// 64 bit integers are encoded as strings and trace and span IDs as hex
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	SchemaURL string          `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+	SchemaURL  string          `json:"schemaUrl,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	EventName            string         `json:"eventName,omitempty"`
+	Body                 *otlpAnyValue  `json:"body,omitempty"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	Flags                uint32         `json:"flags,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	BytesValue  []byte          `json:"bytesValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlist     `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvlist struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// toOTLPJSON groups records by resource and instrumentation scope
+func toOTLPJSON(records []sdklog.Record) otlpLogsRequest {
+	var req otlpLogsRequest
+	resourceIndex := make(map[*resource.Resource]int)
+	type scopeKey struct {
+		resource      int
+		name, version string
+	}
+	scopeIndex := make(map[scopeKey]int)
+
+	for i := range records {
+		r := &records[i]
+
+		res := r.Resource()
+		ri, ok := resourceIndex[res]
+		if !ok {
+			ri = len(req.ResourceLogs)
+			resourceIndex[res] = ri
+			rl := otlpResourceLogs{Resource: otlpResource{Attributes: []otlpKeyValue{}}}
+			if res != nil {
+				rl.SchemaURL = res.SchemaURL()
+				for _, kv := range res.Attributes() {
+					rl.Resource.Attributes = append(rl.Resource.Attributes, otlpKeyValue{Key: string(kv.Key), Value: fromAttribute(kv.Value)})
+				}
+			}
+			req.ResourceLogs = append(req.ResourceLogs, rl)
+		}
+
+		scope := r.InstrumentationScope()
+		key := scopeKey{ri, scope.Name, scope.Version}
+		si, ok := scopeIndex[key]
+		if !ok {
+			si = len(req.ResourceLogs[ri].ScopeLogs)
+			scopeIndex[key] = si
+			req.ResourceLogs[ri].ScopeLogs = append(req.ResourceLogs[ri].ScopeLogs, otlpScopeLogs{
+				Scope:     otlpScope{Name: scope.Name, Version: scope.Version},
+				SchemaURL: scope.SchemaURL,
+			})
+		}
+
+		sl := &req.ResourceLogs[ri].ScopeLogs[si]
+		sl.LogRecords = append(sl.LogRecords, fromRecord(r))
+	}
+	return req
+}
+
+func fromRecord(r *sdklog.Record) otlpLogRecord {
+	rec := otlpLogRecord{
+		SeverityNumber: int(r.Severity()),
+		SeverityText:   r.SeverityText(),
+		EventName:      r.EventName(),
+		Flags:          uint32(r.TraceFlags()),
+	}
+	if t := r.Timestamp(); !t.IsZero() {
+		rec.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+	}
+	if t := r.ObservedTimestamp(); !t.IsZero() {
+		rec.ObservedTimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+	}
+	if body := r.Body(); !body.Empty() {
+		v := fromLogValue(body)
+		rec.Body = &v
+	}
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		rec.Attributes = append(rec.Attributes, otlpKeyValue{Key: kv.Key, Value: fromLogValue(kv.Value)})
+		return true
+	})
+	if id := r.TraceID(); id.IsValid() {
+		rec.TraceID = hex.EncodeToString(id[:])
+	}
+	if id := r.SpanID(); id.IsValid() {
+		rec.SpanID = hex.EncodeToString(id[:])
+	}
+	return rec
+}
+
+func fromLogValue(v otellog.Value) otlpAnyValue {
+	switch v.Kind() {
+	case otellog.KindBool:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case otellog.KindInt64:
+		s := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &s}
+	case otellog.KindFloat64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case otellog.KindString:
+		s := v.AsString()
+		return otlpAnyValue{StringValue: &s}
+	case otellog.KindBytes:
+		return otlpAnyValue{BytesValue: v.AsBytes()}
+	case otellog.KindSlice:
+		values := []otlpAnyValue{}
+		for _, item := range v.AsSlice() {
+			values = append(values, fromLogValue(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case otellog.KindMap:
+		values := []otlpKeyValue{}
+		for _, kv := range v.AsMap() {
+			values = append(values, otlpKeyValue{Key: kv.Key, Value: fromLogValue(kv.Value)})
+		}
+		return otlpAnyValue{KvlistValue: &otlpKvlist{Values: values}}
+	default:
+		return otlpAnyValue{}
+	}
+}
+
+func fromAttribute(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		s := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &s}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.STRING:
+		s := v.AsString()
+		return otlpAnyValue{StringValue: &s}
+	case attribute.BOOLSLICE, attribute.INT64SLICE, attribute.FLOAT64SLICE, attribute.STRINGSLICE:
+		values := []otlpAnyValue{}
+		for _, item := range attributeSlice(v) {
+			values = append(values, fromAttribute(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func attributeSlice(v attribute.Value) []attribute.Value {
+	var values []attribute.Value
+	switch v.Type() {
+	case attribute.BOOLSLICE:
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, attribute.BoolValue(b))
+		}
+	case attribute.INT64SLICE:
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, attribute.Int64Value(i))
+		}
+	case attribute.FLOAT64SLICE:
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, attribute.Float64Value(f))
+		}
+	case attribute.STRINGSLICE:
+		for _, s := range v.AsStringSlice() {
+			values = append(values, attribute.StringValue(s))
+		}
+	}
+	return values
+}
+
+// rotatingFile appends to a file and rotates it once it reaches its maximum
+// size, keeping up to maxBackups old files as path.1 (newest) to path.N
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups == 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", r.path, err)
+		}
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
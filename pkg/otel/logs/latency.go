@@ -0,0 +1,42 @@
+package logs
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+var exportLatency, _ = meter.Float64Histogram("adsb2otel.latency.export",
+	metric.WithDescription("Time from a log record's observed timestamp to the acknowledgment of its export, by event name"),
+	metric.WithUnit("s"),
+	metric.WithExplicitBucketBoundaries(0.25, 0.5, 1, 2, 3, 5, 10, 15, 30, 60),
+)
+
+// latencyExporter records how long after they were observed records are
+// acknowledged by the backend, including the time spent queued in the
+// batch processor and any retries of the exporter
+type latencyExporter struct {
+	sdklog.Exporter
+}
+
+func (e latencyExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if err := e.Exporter.Export(ctx, records); err != nil {
+		return err
+	}
+	acked := time.Now()
+	// Records are exported in batches of mostly one event, so the attribute
+	// set is only rebuilt when the event changes
+	var event string
+	var opt metric.RecordOption
+	for i := range records {
+		if opt == nil || records[i].EventName() != event {
+			event = records[i].EventName()
+			opt = metric.WithAttributes(attribute.String("event.name", event))
+		}
+		exportLatency.Record(ctx, acked.Sub(records[i].ObservedTimestamp()).Seconds(), opt)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package logs
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+
+	otelmetrics "github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+)
+
+const (
+	// defaultQueueSize matches the batch processor's default queue size
+	defaultQueueSize = 2048
+	// memoryCheckInterval is how often heap usage is compared to the limit
+	memoryCheckInterval = time.Second
+	// heapMetric is the runtime metric for memory occupied by heap objects
+	heapMetric = "/memory/classes/heap/objects:bytes"
+)
+
+// Reasons records are shed for
+const (
+	shedQueueFull   = "queue_full"
+	shedMemoryLimit = "memory_limit"
+)
+
+var (
+	meter = otelmetrics.Meter("logs")
+
+	shedCounter, _ = meter.Int64Counter("adsb2otel.logs.shed",
+		metric.WithDescription("Log records refused before export because the export queue was full or memory was over the limit"),
+		metric.WithUnit("{record}"),
+	)
+)
+
+// limitProcessor sheds log records in front of the batch processor, like
+// the Collector's memory_limiter, when the export queue is full or heap
+// usage is over the limit, so a slow OTLP endpoint can't make the service
+// grow without bound
+type limitProcessor struct {
+	next sdklog.Processor
+	// capacity bounds queued, and is the batch processor's queue size so it
+	// never has to drop records itself
+	capacity int64
+	// queued counts records accepted and not yet exported
+	queued *atomic.Int64
+	// memory is nil if no memory limit is set
+	memory *memoryLimiter
+
+	registration metric.Registration
+}
+
+// newLimitProcessor creates a batch processor exporting to exporter with the
+// queue size from EXPORT_QUEUE_SIZE (or OTEL_BLRP_MAX_QUEUE_SIZE), shedding
+// records beyond it and while heap usage is over EXPORT_MEMORY_LIMIT_MIB
+func newLimitProcessor(exporter sdklog.Exporter) *limitProcessor {
+	capacity := getEnvInt("EXPORT_QUEUE_SIZE", getEnvInt("OTEL_BLRP_MAX_QUEUE_SIZE", defaultQueueSize))
+	queued := new(atomic.Int64)
+	p := &limitProcessor{
+		next:     sdklog.NewBatchProcessor(countingExporter{Exporter: latencyExporter{exporter}, queued: queued}, sdklog.WithMaxQueueSize(capacity)),
+		capacity: int64(capacity),
+		queued:   queued,
+		memory:   newMemoryLimiterFromEnv(),
+	}
+
+	queueSize, err1 := meter.Int64ObservableGauge("adsb2otel.logs.queue.size",
+		metric.WithDescription("Log records waiting to be exported"),
+		metric.WithUnit("{record}"),
+	)
+	queueCapacity, err2 := meter.Int64ObservableGauge("adsb2otel.logs.queue.capacity",
+		metric.WithDescription("Log records that can wait to be exported before new ones are shed"),
+		metric.WithUnit("{record}"),
+	)
+	if err1 == nil && err2 == nil {
+		p.registration, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			o.ObserveInt64(queueSize, p.queued.Load())
+			o.ObserveInt64(queueCapacity, p.capacity)
+			return nil
+		}, queueSize, queueCapacity)
+	}
+	return p
+}
+
+func (p *limitProcessor) Enabled(ctx context.Context, param sdklog.EnabledParameters) bool {
+	return p.next.Enabled(ctx, param)
+}
+
+// OnEmit passes the record on unless it has to be shed
+func (p *limitProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if p.memory != nil && p.memory.refusing() {
+		shedCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", shedMemoryLimit)))
+		return nil
+	}
+	if p.queued.Add(1) > p.capacity {
+		p.queued.Add(-1)
+		shedCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", shedQueueFull)))
+		return nil
+	}
+	return p.next.OnEmit(ctx, r)
+}
+
+func (p *limitProcessor) Shutdown(ctx context.Context) error {
+	if p.memory != nil {
+		p.memory.stop()
+	}
+	if p.registration != nil {
+		_ = p.registration.Unregister()
+	}
+	return p.next.Shutdown(ctx)
+}
+
+func (p *limitProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// countingExporter marks records as no longer queued once an export of
+// them is over, whether it succeeded or not
+type countingExporter struct {
+	sdklog.Exporter
+	queued *atomic.Int64
+}
+
+func (e countingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	defer e.queued.Add(-int64(len(records)))
+	return e.Exporter.Export(ctx, records)
+}
+
+// memoryLimiter checks heap usage periodically, refusing records while it
+// is above the soft limit (the limit less the spike allowance) and forcing
+// a garbage collection when it reaches the limit
+type memoryLimiter struct {
+	limit uint64
+	soft  uint64
+
+	refuse   atomic.Bool
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newMemoryLimiterFromEnv starts a memory limiter if EXPORT_MEMORY_LIMIT_MIB
+// is set, with a spike allowance of EXPORT_MEMORY_SPIKE_LIMIT_MIB (default
+// a fifth of the limit)
+func newMemoryLimiterFromEnv() *memoryLimiter {
+	limitMiB := getEnvInt("EXPORT_MEMORY_LIMIT_MIB", 0)
+	if limitMiB <= 0 {
+		return nil
+	}
+	spikeMiB := getEnvInt("EXPORT_MEMORY_SPIKE_LIMIT_MIB", limitMiB/5)
+	if spikeMiB >= limitMiB {
+		log.Printf("EXPORT_MEMORY_SPIKE_LIMIT_MIB must be below EXPORT_MEMORY_LIMIT_MIB, using %d", limitMiB/5)
+		spikeMiB = limitMiB / 5
+	}
+
+	m := &memoryLimiter{
+		limit: uint64(limitMiB) << 20,
+		soft:  uint64(limitMiB-spikeMiB) << 20,
+		done:  make(chan struct{}),
+	}
+	go m.run()
+	log.Printf("Export memory limiter enabled (limit: %d MiB, spike: %d MiB)", limitMiB, spikeMiB)
+	return m
+}
+
+func (m *memoryLimiter) run() {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check updates whether records are refused from the current heap usage
+func (m *memoryLimiter) check() {
+	heap := heapBytes()
+	if heap >= m.limit {
+		runtime.GC()
+		heap = heapBytes()
+	}
+	refuse := heap >= m.soft
+	if was := m.refuse.Swap(refuse); was != refuse {
+		if refuse {
+			log.Printf("Heap usage of %d MiB is over the export memory limit, shedding log records", heap>>20)
+		} else {
+			log.Printf("Heap usage of %d MiB is back under the export memory limit, accepting log records", heap>>20)
+		}
+	}
+}
+
+func (m *memoryLimiter) refusing() bool {
+	return m.refuse.Load()
+}
+
+func (m *memoryLimiter) stop() {
+	m.stopOnce.Do(func() { close(m.done) })
+}
+
+// heapBytes returns the memory occupied by heap objects, live or not yet swept
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// getEnvInt returns a positive integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := strings.TrimSpace(getEnv(key, "")); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		log.Printf("Invalid integer %s=%s, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
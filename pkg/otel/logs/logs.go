@@ -3,24 +3,41 @@ package logs
 import (
 	"context"
 	"log"
+	"net"
+	"net/http"
 	"os"
-	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	otellog "go.opentelemetry.io/otel/log"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"google.golang.org/grpc"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+	"github.com/burnettdev/adsb2otel/pkg/otel/resource"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+	"github.com/burnettdev/adsb2otel/pkg/version"
 )
 
+// scopePrefix qualifies logger names into instrumentation scope names
+const scopePrefix = "github.com/burnettdev/adsb2otel/"
+
 var (
 	globalLoggerProvider *sdklog.LoggerProvider
 	mu                   sync.RWMutex
 )
 
+// Enabled reports whether OpenTelemetry logging is configured, so that a
+// missing logger means initialization failed rather than logging being off
+func Enabled() bool {
+	return isTrue(getEnv("OTEL_LOGS_ENABLED", "true")) && routing.Selected(routing.OTLP)
+}
+
 // InitLogs initializes OpenTelemetry logging with support for both gRPC and HTTP protocols
 func InitLogs() (func(), error) {
 	// Check if logging is enabled
@@ -28,6 +45,10 @@ func InitLogs() (func(), error) {
 		log.Println("OpenTelemetry logging is disabled")
 		return func() {}, nil
 	}
+	if !routing.Selected(routing.OTLP) {
+		log.Println("OpenTelemetry logging is disabled, otlp is not listed in SINKS")
+		return func() {}, nil
+	}
 
 	// Get OTLP endpoint from environment variables (shared first, then signal-specific)
 	endpoint := getOTLPEndpoint()
@@ -36,7 +57,10 @@ func InitLogs() (func(), error) {
 	insecure := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", "OTEL_EXPORTER_OTLP_LOGS_INSECURE", true)
 
 	// Parse headers if provided (shared first, then signal-specific)
-	headers := parseHeaders(getEnvWithFallback("OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_LOGS_HEADERS", ""))
+	otlpHeaders, err := headers.FromEnv("LOGS")
+	if err != nil {
+		return func() {}, err
+	}
 
 	// Determine protocol (http or grpc) - shared first, then signal-specific
 	protocol := strings.ToLower(getEnvWithFallback("OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL", "http"))
@@ -46,10 +70,12 @@ func InitLogs() (func(), error) {
 	}
 
 	var exporter sdklog.Exporter
-	var err error
 
-	// Create exporter based on protocol
-	if protocol == "grpc" {
+	// Create exporter, either writing OTLP-JSON locally for debugging or OTLP based on protocol
+	exporterName := strings.ToLower(getEnv("OTEL_LOGS_EXPORTER", "otlp"))
+	if exporterName == "console" || exporterName == "file" {
+		exporter, err = newJSONExporter(exporterName)
+	} else if protocol == "grpc" {
 		opts := []otlploggrpc.Option{
 			otlploggrpc.WithEndpoint(endpoint),
 		}
@@ -58,8 +84,14 @@ func InitLogs() (func(), error) {
 			opts = append(opts, otlploggrpc.WithInsecure())
 		}
 
-		if len(headers) > 0 {
-			opts = append(opts, otlploggrpc.WithHeaders(headers))
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlploggrpc.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			opts = append(opts, otlploggrpc.WithDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return resolver.DialContext(ctx, "tcp", addr)
+			})))
 		}
 
 		exporter, err = otlploggrpc.New(context.Background(), opts...)
@@ -72,8 +104,17 @@ func InitLogs() (func(), error) {
 			opts = append(opts, otlploghttp.WithInsecure())
 		}
 
-		if len(headers) > 0 {
-			opts = append(opts, otlploghttp.WithHeaders(headers))
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DialContext = resolver.DialContext
+			opts = append(opts, otlploghttp.WithHTTPClient(&http.Client{
+				Transport: transport,
+				Timeout:   10 * time.Second,
+			}))
 		}
 
 		exporter, err = otlploghttp.New(context.Background(), opts...)
@@ -85,31 +126,14 @@ func InitLogs() (func(), error) {
 		return func() {}, nil
 	}
 
-	// Create resource with Go-specific attributes
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			// Service identification
-			semconv.ServiceName("adsb2otel"),
-			semconv.ServiceVersion("1.0.0"),
-
-			// Process and runtime information
-			semconv.ProcessRuntimeName("go"),
-			semconv.ProcessRuntimeVersion(runtime.Version()),
-			semconv.ProcessRuntimeDescription("Go runtime"),
-			semconv.ProcessPID(os.Getpid()),
-
-			// Telemetry SDK information
-			semconv.TelemetrySDKName("opentelemetry"),
-			semconv.TelemetrySDKLanguageGo,
-			semconv.TelemetrySDKVersion("1.31.0"),
-		),
-	)
+	// Shared resource with service, runtime and detected environment attributes
+	res, err := resource.Get()
 	if err != nil {
 		return nil, err
 	}
 
-	// Create batch processor
-	processor := sdklog.NewBatchProcessor(exporter)
+	// Create batch processor behind a bounded queue and memory limiter
+	processor := newLimitProcessor(exporter)
 
 	// Create logger provider
 	lp := sdklog.NewLoggerProvider(
@@ -122,9 +146,25 @@ func InitLogs() (func(), error) {
 	globalLoggerProvider = lp
 	mu.Unlock()
 
+	if exporterName == "console" || exporterName == "file" {
+		log.Printf("OpenTelemetry logging initialized successfully (exporter: %s)", exporterName)
+		return func() {
+			if err := lp.Shutdown(context.Background()); err != nil {
+				log.Printf("Error shutting down logger provider: %v", err)
+			}
+		}, nil
+	}
+
 	log.Printf("OpenTelemetry logging initialized successfully (protocol: %s, endpoint: %s)", protocol, endpoint)
 
+	// Optionally reflect whether the collector accepts exports in readiness
+	stopProbe, err := startCollectorProbe(protocol, endpoint, insecure, otlpHeaders)
+	if err != nil {
+		log.Printf("Failed to start collector probe: %v", err)
+	}
+
 	return func() {
+		stopProbe()
 		if err := lp.Shutdown(context.Background()); err != nil {
 			log.Printf("Error shutting down logger provider: %v", err)
 		}
@@ -138,7 +178,8 @@ func GetLoggerProvider() *sdklog.LoggerProvider {
 	return globalLoggerProvider
 }
 
-// GetLogger returns a logger instance for the given name
+// GetLogger returns a logger instance for the given name, with an
+// instrumentation scope named after the module and carrying the build version
 func GetLogger(name string) otellog.Logger {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -146,7 +187,10 @@ func GetLogger(name string) otellog.Logger {
 		// Return nil logger if not initialized - caller should check
 		return nil
 	}
-	return globalLoggerProvider.Logger(name)
+	return globalLoggerProvider.Logger(scopePrefix+name,
+		otellog.WithInstrumentationVersion(version.Get()),
+		otellog.WithSchemaURL(semconv.SchemaURL),
+	)
 }
 
 // getEnv returns the value of an environment variable or a default value if not set
@@ -215,20 +259,3 @@ func getEnvBool(primary, secondary string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
-
-// parseHeaders parses header string in format "key1=value1,key2=value2"
-func parseHeaders(headerStr string) map[string]string {
-	headers := make(map[string]string)
-	if headerStr == "" {
-		return headers
-	}
-
-	pairs := strings.Split(headerStr, ",")
-	for _, pair := range pairs {
-		if kv := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(kv) == 2 {
-			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-		}
-	}
-
-	return headers
-}
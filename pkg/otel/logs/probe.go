@@ -0,0 +1,208 @@
+package logs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpcinsecure "google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/health"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+const collectorComponent = "collector"
+
+// collectorProbe periodically sends an empty OTLP logs export to the collector
+// and reports whether it was accepted as the collector's readiness
+// This is meant for sidecar deployments where the collector restarts independently
+type collectorProbe struct {
+	endpoint string
+	insecure bool
+	headers  map[string]string
+	interval time.Duration
+	timeout  time.Duration
+
+	httpClient *http.Client
+	grpcClient collogspb.LogsServiceClient
+	grpcConn   *grpc.ClientConn
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startCollectorProbe starts probing the collector if OTEL_COLLECTOR_PROBE_ENABLED is set
+// The returned function stops the probe, it is a no-op if probing is disabled
+func startCollectorProbe(protocol, endpoint string, insecure bool, headers map[string]string) (func(), error) {
+	if !isTrue(getEnv("OTEL_COLLECTOR_PROBE_ENABLED", "false")) {
+		return func() {}, nil
+	}
+
+	p, err := newCollectorProbe(protocol, endpoint, insecure, headers)
+	if err != nil {
+		return func() {}, err
+	}
+
+	// Probe once up front so readiness is known before the first poll
+	p.check()
+	go p.run()
+
+	return func() {
+		close(p.stop)
+		<-p.done
+		p.close()
+	}, nil
+}
+
+// newCollectorProbe creates a probe of the collector at endpoint
+func newCollectorProbe(protocol, endpoint string, insecure bool, headers map[string]string) (*collectorProbe, error) {
+	p := &collectorProbe{
+		endpoint: endpoint,
+		insecure: insecure,
+		headers:  headers,
+		interval: getEnvDuration("OTEL_COLLECTOR_PROBE_INTERVAL", 15*time.Second),
+		timeout:  getEnvDuration("OTEL_COLLECTOR_PROBE_TIMEOUT", 5*time.Second),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if protocol == "grpc" {
+		creds := credentials.NewTLS(&tls.Config{})
+		if insecure {
+			creds = grpcinsecure.NewCredentials()
+		}
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		if resolver := dnscache.Get(); resolver != nil {
+			opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return resolver.DialContext(ctx, "tcp", addr)
+			}))
+		}
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collector probe connection: %w", err)
+		}
+		p.grpcConn = conn
+		p.grpcClient = collogspb.NewLogsServiceClient(conn)
+	} else {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if resolver := dnscache.Get(); resolver != nil {
+			transport.DialContext = resolver.DialContext
+		}
+		p.httpClient = &http.Client{Transport: transport}
+	}
+	return p, nil
+}
+
+// CheckExport sends a single empty export to the OTLP logs endpoint and
+// returns why it was refused, e.g. rejected credentials, so problems show up
+// at startup rather than with the first batch
+// It reports false without checking if log records are not exported over OTLP
+func CheckExport(ctx context.Context) (bool, error) {
+	if !isTrue(getEnv("OTEL_LOGS_ENABLED", "true")) || !routing.Selected(routing.OTLP) || strings.ToLower(getEnv("OTEL_LOGS_EXPORTER", "otlp")) != "otlp" {
+		return false, nil
+	}
+
+	otlpHeaders, err := headers.FromEnv("LOGS")
+	if err != nil {
+		return true, err
+	}
+	protocol := strings.ToLower(getEnvWithFallback("OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL", "http"))
+	insecure := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", "OTEL_EXPORTER_OTLP_LOGS_INSECURE", true)
+
+	p, err := newCollectorProbe(protocol, getOTLPEndpoint(), insecure, otlpHeaders)
+	if err != nil {
+		return true, err
+	}
+	defer p.close()
+	return true, p.probe(ctx)
+}
+
+// close releases the probe's connection
+func (p *collectorProbe) close() {
+	if p.grpcConn != nil {
+		p.grpcConn.Close()
+	}
+}
+
+func (p *collectorProbe) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// check probes the collector and records the result as its readiness
+func (p *collectorProbe) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	health.Set(collectorComponent, p.probe(ctx))
+}
+
+// probe sends an export request without any log records
+func (p *collectorProbe) probe(ctx context.Context) error {
+	if p.grpcClient != nil {
+		if len(p.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(p.headers))
+		}
+		_, err := p.grpcClient.Export(ctx, &collogspb.ExportLogsServiceRequest{})
+		return err
+	}
+
+	scheme := "https"
+	if p.insecure {
+		scheme = "http"
+	}
+	// An empty body is the protobuf encoding of an empty ExportLogsServiceRequest
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+"://"+p.endpoint+"/v1/logs", http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := getEnv(key, ""); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %s=%s, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	otelruntime "go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"google.golang.org/grpc"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+	"github.com/burnettdev/adsb2otel/pkg/otel/resource"
+)
+
+// InitMetrics initializes the OpenTelemetry meter provider and, if enabled,
+// the Go runtime and host instrumentation
+func InitMetrics() (func(), error) {
+	// Check if metrics are enabled
+	if enabled := getEnv("OTEL_METRICS_ENABLED", "false"); !isTrue(enabled) {
+		log.Println("OpenTelemetry metrics are disabled")
+		return func() {}, nil
+	}
+
+	// Get OTLP endpoint from environment variables (shared first, then signal-specific)
+	endpoint := getOTLPEndpoint()
+
+	// Check if connection should be insecure (shared first, then signal-specific)
+	insecure := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", "OTEL_EXPORTER_OTLP_METRICS_INSECURE", true)
+
+	// Parse headers if provided (shared first, then signal-specific)
+	otlpHeaders, err := headers.FromEnv("METRICS")
+	if err != nil {
+		return func() {}, err
+	}
+
+	// Determine protocol (http or grpc) - shared first, then signal-specific
+	protocol := strings.ToLower(getEnvWithFallback("OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "http"))
+	if protocol != "http" && protocol != "grpc" {
+		log.Printf("Invalid protocol %s, defaulting to http", protocol)
+		protocol = "http"
+	}
+
+	var exporter sdkmetric.Exporter
+
+	// Create exporter based on protocol
+	if protocol == "grpc" {
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(endpoint),
+		}
+
+		if insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			opts = append(opts, otlpmetricgrpc.WithDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return resolver.DialContext(ctx, "tcp", addr)
+			})))
+		}
+
+		exporter, err = otlpmetricgrpc.New(context.Background(), opts...)
+	} else {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(endpoint),
+		}
+
+		if insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DialContext = resolver.DialContext
+			opts = append(opts, otlpmetrichttp.WithHTTPClient(&http.Client{
+				Transport: transport,
+				Timeout:   10 * time.Second,
+			}))
+		}
+
+		exporter, err = otlpmetrichttp.New(context.Background(), opts...)
+	}
+	if err != nil {
+		log.Printf("Failed to create OTLP metric exporter, using noop: %v", err)
+		// Return a noop shutdown function if exporter creation fails
+		return func() {}, nil
+	}
+
+	// Shared resource with service, runtime and detected environment attributes
+	res, err := resource.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := getEnvDuration("OTEL_METRIC_EXPORT_INTERVAL", 60*time.Second)
+	filterName, filter := getExemplarFilter()
+
+	// Create meter provider
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(filter),
+	)
+
+	// Set global meter provider so instrumented packages pick it up
+	otel.SetMeterProvider(mp)
+
+	// Go runtime metrics (memory, GC, goroutines)
+	if isTrue(getEnv("OTEL_RUNTIME_METRICS_ENABLED", "true")) {
+		if err := otelruntime.Start(otelruntime.WithMeterProvider(mp)); err != nil {
+			log.Printf("Failed to start runtime metrics: %v", err)
+		}
+	}
+
+	// Host metrics (CPU, memory, network)
+	if isTrue(getEnv("OTEL_HOST_METRICS_ENABLED", "true")) {
+		if err := host.Start(host.WithMeterProvider(mp)); err != nil {
+			log.Printf("Failed to start host metrics: %v", err)
+		}
+	}
+
+	log.Printf("OpenTelemetry metrics initialized successfully (protocol: %s, endpoint: %s, interval: %s, exemplars: %s)", protocol, endpoint, interval, filterName)
+
+	return func() {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down meter provider: %v", err)
+		}
+	}, nil
+}
+
+// Meter returns a meter from the global meter provider, which is a no-op
+// until InitMetrics has enabled metrics
+func Meter(name string) metric.Meter {
+	return otel.Meter(name)
+}
+
+// getExemplarFilter returns the exemplar filter selected by
+// OTEL_METRICS_EXEMPLAR_FILTER and its name
+// The default trace_based attaches exemplars to measurements made within a
+// sampled span, such as the fetch span, linking metric spikes to their traces
+func getExemplarFilter() (string, exemplar.Filter) {
+	switch name := strings.ToLower(strings.TrimSpace(getEnv("OTEL_METRICS_EXEMPLAR_FILTER", "trace_based"))); name {
+	case "trace_based":
+		return name, exemplar.TraceBasedFilter
+	case "always_on":
+		return name, exemplar.AlwaysOnFilter
+	case "always_off":
+		return name, exemplar.AlwaysOffFilter
+	default:
+		log.Printf("Invalid OTEL_METRICS_EXEMPLAR_FILTER %s, using trace_based", name)
+		return "trace_based", exemplar.TraceBasedFilter
+	}
+}
+
+// getEnv returns the value of an environment variable or a default value if not set
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+// A plain number is read as milliseconds, as in the OpenTelemetry specification
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	if d, err := time.ParseDuration(value + "ms"); err == nil && d > 0 {
+		return d
+	}
+	log.Printf("Invalid duration %s=%s, using default %s", key, value, defaultValue)
+	return defaultValue
+}
+
+// getOTLPEndpoint determines the OTLP endpoint from environment variables
+// Uses shared OTEL_EXPORTER_OTLP_ENDPOINT first, then signal-specific override
+func getOTLPEndpoint() string {
+	// Check for shared OTLP endpoint first
+	if endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); endpoint != "" {
+		return cleanEndpoint(endpoint)
+	}
+
+	// Fall back to metrics-specific endpoint if shared is not set
+	if endpoint := getEnv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", ""); endpoint != "" {
+		return cleanEndpoint(endpoint)
+	}
+
+	// Default to localhost (HTTP default port)
+	return "localhost:4318"
+}
+
+// cleanEndpoint removes protocol and path from endpoint URL
+func cleanEndpoint(endpoint string) string {
+	// Remove http:// or https:// prefix if present
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+
+	// Remove /v1/metrics suffix if present since WithEndpoint handles the path separately
+	endpoint = strings.TrimSuffix(endpoint, "/v1/metrics")
+
+	// Remove any trailing slashes
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	return endpoint
+}
+
+// getEnvWithFallback returns the value of the primary env var, or falls back to secondary
+func getEnvWithFallback(primary, secondary, defaultValue string) string {
+	if value := getEnv(primary, ""); value != "" {
+		return value
+	}
+	return getEnv(secondary, defaultValue)
+}
+
+// getEnvBool returns a boolean value, checking primary env var first, then secondary
+func getEnvBool(primary, secondary string, defaultValue bool) bool {
+	if value := getEnv(primary, ""); value != "" {
+		return isTrue(value)
+	}
+	if value := getEnv(secondary, ""); value != "" {
+		return isTrue(value)
+	}
+	return defaultValue
+}
@@ -0,0 +1,183 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+
+	"github.com/burnettdev/adsb2otel/pkg/config"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// serviceAccountNamespace is mounted into every pod that has a service account token
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// instanceNamespace is the UUID namespace service instance IDs are derived in
+var instanceNamespace = uuid.MustParse("4d9f3a5e-6b1c-5e8a-9f0d-2a7c3b1e8d64")
+
+var (
+	res     *sdkresource.Resource
+	resErr  error
+	resOnce sync.Once
+)
+
+// Get returns the resource shared by all OpenTelemetry signals
+// The service, build and runtime attributes are extended by the detectors selected in
+// OTEL_RESOURCE_DETECTORS (host, os, container, k8s), and OTEL_RESOURCE_ATTRIBUTES
+// is applied last so it can override any detected value
+func Get() (*sdkresource.Resource, error) {
+	resOnce.Do(func() {
+		name := ServiceName()
+		opts := []sdkresource.Option{
+			sdkresource.WithSchemaURL(semconv.SchemaURL),
+			sdkresource.WithAttributes(
+				// Service identification
+				semconv.ServiceName(name),
+				semconv.ServiceVersion(version.Get()),
+				semconv.ServiceInstanceID(instanceID(name)),
+
+				// Build information and the optional features enabled
+				attribute.StringSlice("adsb2otel.features", config.Features()),
+
+				// Process and runtime information
+				semconv.ProcessRuntimeName("go"),
+				semconv.ProcessRuntimeVersion(runtime.Version()),
+				semconv.ProcessRuntimeDescription("Go runtime"),
+				semconv.ProcessPID(os.Getpid()),
+			),
+			sdkresource.WithTelemetrySDK(),
+		}
+		if revision := version.Revision(); revision != "" {
+			opts = append(opts, sdkresource.WithAttributes(semconv.VCSRefHeadRevision(revision)))
+		}
+		if date := version.Date(); date != "" {
+			opts = append(opts, sdkresource.WithAttributes(attribute.String("adsb2otel.build.date", date)))
+		}
+
+		for _, name := range detectors() {
+			switch name {
+			case "host":
+				opts = append(opts, sdkresource.WithHost())
+			case "os":
+				opts = append(opts, sdkresource.WithOS())
+			case "container":
+				opts = append(opts, sdkresource.WithContainer())
+			case "k8s":
+				opts = append(opts, sdkresource.WithDetectors(kubernetesDetector{}))
+			case "none":
+			default:
+				log.Printf("Unknown resource detector %s, ignoring", name)
+			}
+		}
+
+		// Explicit attributes take precedence over detected ones
+		opts = append(opts, sdkresource.WithFromEnv())
+
+		res, resErr = sdkresource.New(context.Background(), opts...)
+		if errors.Is(resErr, sdkresource.ErrPartialResource) {
+			// Some detectors could not run (e.g. no container ID outside a container),
+			// the attributes that were detected are still usable
+			log.Printf("Resource detection incomplete: %v", resErr)
+			resErr = nil
+		}
+	})
+	return res, resErr
+}
+
+// ServiceName returns the service name from OTEL_SERVICE_NAME, or adsb2otel
+func ServiceName() string {
+	if name := strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME")); name != "" {
+		return name
+	}
+	return "adsb2otel"
+}
+
+// InstanceID returns the service instance ID, see instanceID
+func InstanceID() string {
+	return instanceID(ServiceName())
+}
+
+// instanceID returns OTEL_SERVICE_INSTANCE_ID, or an ID derived from the
+// service name, receiver URL and machine ID, if there is one, which stays the
+// same across restarts but differs between exporters feeding the same collector
+// The hostname is left out, as containers get a new one on every start
+func instanceID(serviceName string) string {
+	if id := strings.TrimSpace(os.Getenv("OTEL_SERVICE_INSTANCE_ID")); id != "" {
+		return id
+	}
+
+	parts := []string{serviceName, os.Getenv("FLIGHT_DATA_URL")}
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if machineID := strings.TrimSpace(string(data)); machineID != "" {
+				parts = append(parts, machineID)
+			}
+			break
+		}
+	}
+
+	return uuid.NewSHA1(instanceNamespace, []byte(strings.Join(parts, "\n"))).String()
+}
+
+// detectors returns the detectors selected via OTEL_RESOURCE_DETECTORS
+func detectors() []string {
+	value := os.Getenv("OTEL_RESOURCE_DETECTORS")
+	if value == "" {
+		value = "host,os,container,k8s"
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// kubernetesDetector reads pod metadata exposed through the downward API
+// as environment variables, e.g. K8S_POD_NAME from the metadata.name field
+type kubernetesDetector struct{}
+
+// Detect returns the Kubernetes attributes, or an empty resource outside a cluster
+func (kubernetesDetector) Detect(context.Context) (*sdkresource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return sdkresource.Empty(), nil
+	}
+
+	var attrs []attribute.KeyValue
+	add := func(key attribute.Key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			attrs = append(attrs, key.String(value))
+		}
+	}
+
+	podName := os.Getenv("K8S_POD_NAME")
+	if podName == "" {
+		// Pods use their name as hostname unless spec.hostname is set
+		podName = os.Getenv("HOSTNAME")
+	}
+	namespace := os.Getenv("K8S_NAMESPACE_NAME")
+	if namespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = string(data)
+		}
+	}
+
+	add(semconv.K8SPodNameKey, podName)
+	add(semconv.K8SNamespaceNameKey, namespace)
+	add(semconv.K8SPodUIDKey, os.Getenv("K8S_POD_UID"))
+	add(semconv.K8SNodeNameKey, os.Getenv("K8S_NODE_NAME"))
+	add(semconv.K8SClusterNameKey, os.Getenv("K8S_CLUSTER_NAME"))
+	add(semconv.K8SDeploymentNameKey, os.Getenv("K8S_DEPLOYMENT_NAME"))
+
+	return sdkresource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
@@ -0,0 +1,472 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Values are the fields of an aircraft a filter is evaluated against, keyed by
+// their aircraft.json name, plus distance_nm as an alias of r_dst
+type Values map[string]any
+
+// ValuesOf returns the filter values of an aircraft
+func ValuesOf(a *models.Aircraft) Values {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return Values{}
+	}
+	var values Values
+	if err := json.Unmarshal(data, &values); err != nil {
+		return Values{}
+	}
+	for key, value := range values {
+		// Callsigns are padded with spaces in aircraft.json
+		if s, ok := value.(string); ok {
+			values[key] = strings.TrimSpace(s)
+		}
+	}
+	if dst, ok := values["r_dst"]; ok {
+		values["distance_nm"] = dst
+	}
+	if a.Category != "" {
+		values["category_class"] = a.CategoryClass()
+	}
+	values["source_type"] = a.SourceType()
+	if source := a.PositionSource(); source != "" {
+		values["position_source"] = source
+	}
+	return values
+}
+
+// SummaryValues returns the filter values of a summary of an aircraft, which
+// are its hex and flight
+func SummaryValues(hex, flight string) Values {
+	values := Values{"hex": hex}
+	if flight = strings.TrimSpace(flight); flight != "" {
+		values["flight"] = flight
+	}
+	return values
+}
+
+// Filter is a boolean expression over aircraft fields, e.g.
+// alt_baro < 10000 && distance_nm < 50 or category in ["A5", "A7"]
+//
+// Fields are compared with ==, !=, <, <=, > and >=, or tested against a list
+// with in. Conditions are combined with &&, || and !, and grouped with
+// parentheses. Literals are numbers, strings in single or double quotes, true,
+// false and null. A field on its own is true if it is set and not false, zero
+// or empty. Missing fields are null, so comparisons other than != with them
+// are false. Strings holding numbers compare as numbers against number
+// literals, e.g. squawk == 7700, but as strings against strings, so
+// hex == "7e0012" does not match 7e12
+type Filter struct {
+	source string
+	root   node
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return &Filter{source: expr, root: root}, nil
+}
+
+// String returns the expression the filter was parsed from
+func (f *Filter) String() string {
+	return f.source
+}
+
+// Match reports whether an aircraft with the given values passes the filter
+func (f *Filter) Match(values Values) bool {
+	return truthy(f.root.eval(values))
+}
+
+type node interface {
+	eval(values Values) any
+}
+
+type literalNode struct{ value any }
+
+type fieldNode struct{ name string }
+
+type notNode struct{ operand node }
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	// numeric is set if either side is a number literal
+	numeric bool
+}
+
+type inNode struct {
+	operand node
+	list    []any
+}
+
+func (n literalNode) eval(Values) any { return n.value }
+
+func (n fieldNode) eval(values Values) any { return values[n.name] }
+
+func (n notNode) eval(values Values) any { return !truthy(n.operand.eval(values)) }
+
+func (n logicalNode) eval(values Values) any {
+	left := truthy(n.left.eval(values))
+	if n.and {
+		return left && truthy(n.right.eval(values))
+	}
+	return left || truthy(n.right.eval(values))
+}
+
+func (n compareNode) eval(values Values) any {
+	left, right := n.left.eval(values), n.right.eval(values)
+	switch n.op {
+	case "==":
+		return equal(left, right, n.numeric)
+	case "!=":
+		return !equal(left, right, n.numeric)
+	}
+
+	if a, b, ok := numbers(left, right, n.numeric); ok {
+		switch n.op {
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		}
+	}
+	a, aok := left.(string)
+	b, bok := right.(string)
+	if !aok || !bok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func (n inNode) eval(values Values) any {
+	value := n.operand.eval(values)
+	for _, item := range n.list {
+		_, numeric := item.(float64)
+		if equal(value, item, numeric) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares two values, treating strings that hold numbers as numbers
+// if numeric is set
+func equal(a, b any, numeric bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if x, y, ok := numbers(a, b, numeric); ok {
+		return x == y
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	}
+	return false
+}
+
+// numbers returns both values as numbers if they are numbers, or numeric
+// strings if parse is set
+func numbers(a, b any, parse bool) (float64, float64, bool) {
+	x, ok := number(a, parse)
+	if !ok {
+		return 0, 0, false
+	}
+	y, ok := number(b, parse)
+	return x, y, ok
+}
+
+func number(v any, parse bool) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		if !parse {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	}
+	return true
+}
+
+type token struct {
+	kind string // "ident", "number", "string" or the operator itself
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{kind: "string", text: expr[i+1 : i+1+end], pos: i})
+			i += end + 2
+			continue
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			j := i + 1
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "number", text: expr[i:j], pos: i})
+			i = j
+			continue
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || expr[j] >= '0' && expr[j] <= '9' || unicode.IsLetter(rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: "ident", text: expr[i:j], pos: i})
+			i = j
+			continue
+		}
+
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, token{kind: op, text: op, pos: i})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: "end", text: "end of expression", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) expect(kind string) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("expected %q, got %q", kind, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	if p.peek().kind == "(" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == "==" || t.kind == "!=" || t.kind == "<" || t.kind == "<=" || t.kind == ">" || t.kind == ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: t.kind, left: left, right: right, numeric: isNumber(left) || isNumber(right)}, nil
+	case t.kind == "ident" && t.text == "in":
+		p.next()
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return inNode{operand: left, list: list}, nil
+	}
+	return left, nil
+}
+
+// isNumber reports whether a node is a number literal
+func isNumber(n node) bool {
+	literal, ok := n.(literalNode)
+	if !ok {
+		return false
+	}
+	_, ok = literal.value.(float64)
+	return ok
+}
+
+func (p *parser) parseList() ([]any, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var list []any
+	for p.peek().kind != "]" {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := item.(literalNode)
+		if !ok {
+			return nil, fmt.Errorf("lists may only contain literals")
+		}
+		list = append(list, literal.value)
+		if p.peek().kind != "," {
+			break
+		}
+		p.next()
+	}
+	return list, p.expect("]")
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case "number":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return literalNode{value: f}, nil
+	case "string":
+		return literalNode{value: t.text}, nil
+	case "ident":
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected \"in\" at position %d", t.pos)
+		}
+		return fieldNode{name: t.text}, nil
+	}
+	return nil, fmt.Errorf("expected a field or value, got %q", t.text)
+}
@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+func TestFilterMatch(t *testing.T) {
+	values := Values{
+		"hex":      "7e12",
+		"squawk":   "0700",
+		"flight":   "BAW123",
+		"alt_baro": 3000.0,
+		"category": "A5",
+		"alert":    false,
+		"emerg":    "",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// && binds tighter than ||
+		{`flight == "X" || alt_baro < 5000 && category == "A1"`, false},
+		{`flight == "BAW123" || alt_baro > 5000 && category == "A1"`, true},
+		{`(flight == "BAW123" || alt_baro > 5000) && category == "A1"`, false},
+		{`!alert`, true},
+		{`!(alt_baro < 5000)`, false},
+		{`!!flight`, true},
+		{`category in ["A5", "A7"]`, true},
+		{`category in ["A1"]`, false},
+		{`squawk in [700, 7700]`, true},
+		{`alt_baro in []`, false},
+		// Missing fields are null
+		{`gs == null`, true},
+		{`gs != 100`, true},
+		{`gs < 100`, false},
+		{`gs >= 100`, false},
+		{`gs`, false},
+		{`!gs`, true},
+		{`gs in [null]`, true},
+		// Strings compare as numbers only against number literals
+		{`squawk == 700`, true},
+		{`squawk == "0700"`, true},
+		{`squawk == "700"`, false},
+		{`hex == "7e0012"`, false},
+		{`hex == "7e12"`, true},
+		{`squawk < 1000`, true},
+		{`flight > "BAW100"`, true},
+		{`alt_baro == "3000"`, false},
+		{`emerg`, false},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(values); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"-",
+		".",
+		`flight == "BAW123`,
+		`flight == 'BAW123`,
+		"",
+		"alt_baro <",
+		"alt_baro < 1000 &&",
+		"(alt_baro < 1000",
+		"alt_baro < 1000)",
+		"category in A5",
+		`category in ["A5"`,
+		"category in [gs]",
+		"in",
+		"alt_baro # 1000",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestValuesOf(t *testing.T) {
+	dst := 12.5
+	values := ValuesOf(&models.Aircraft{Hex: "4ca7b4", Flight: "EIN12B  ", Category: "A3", RDst: &dst})
+	if values["flight"] != "EIN12B" || values["distance_nm"] != 12.5 || values["category_class"] == nil {
+		t.Errorf("values %v", values)
+	}
+	if _, ok := values["gs"]; ok {
+		t.Errorf("gs set without a ground speed: %v", values["gs"])
+	}
+}
@@ -0,0 +1,343 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Class is the kind of record an aircraft observation is routed as
+type Class string
+
+const (
+	// ClassEmergency is an aircraft declaring an emergency or squawking 7500/7600/7700
+	ClassEmergency Class = "emergency"
+	// ClassPosition is an aircraft with a current position
+	ClassPosition Class = "position"
+	// ClassOther is any other aircraft, e.g. Mode S only without a position
+	ClassOther Class = "other"
+	// ClassSummary is a summary of what was seen of an aircraft, i.e. the
+	// aircraft.track events and logbook sessions
+	ClassSummary Class = "summary"
+)
+
+// OTLP is the sink name used for the OpenTelemetry log records
+const OTLP = "otlp"
+
+var (
+	classes = []Class{ClassEmergency, ClassPosition, ClassOther, ClassSummary}
+
+	defaultRules *Rules
+	once         sync.Once
+)
+
+// filterPrefix is the prefix of the environment variables holding sink filters
+const filterPrefix = "SINK_FILTER_"
+
+// Rules maps record classes to the sinks they are sent to
+// Classes without a rule use the "*" rule, or go to every sink if there is none
+// Sinks may additionally have a filter that observations must match, and
+// aircraft in muted sectors are sent nowhere
+type Rules struct {
+	routes  map[string][]route
+	filters map[string]*Filter
+
+	sectors  []Sector
+	receiver *geo.Position
+}
+
+// Get returns the rules configured via SINK_ROUTES, SINK_FILTER_<SINK> and MUTED_SECTORS
+func Get() *Rules {
+	once.Do(func() {
+		rules, err := ParseRules(os.Getenv("SINK_ROUTES"))
+		if err != nil {
+			log.Printf("Ignoring invalid sink routes: %v", err)
+		}
+		defaultRules = rules
+		filters, errs := FiltersFromEnv()
+		for _, err := range errs {
+			log.Printf("Ignoring invalid sink filter: %v", err)
+		}
+		defaultRules.filters = filters
+
+		sectors, err := ParseSectors(os.Getenv("MUTED_SECTORS"))
+		if err != nil {
+			log.Printf("Ignoring invalid MUTED_SECTORS: %v", err)
+		}
+		defaultRules.sectors = sectors
+		if receiver, ok := geo.Receiver(); ok {
+			defaultRules.receiver = &receiver
+		}
+	})
+	return defaultRules
+}
+
+// FiltersFromEnv parses the SINK_FILTER_<SINK> environment variables, e.g.
+// SINK_FILTER_NATS for the nats sink, returning the filters by sink name and
+// an error for each filter that could not be parsed
+func FiltersFromEnv() (map[string]*Filter, []error) {
+	filters := make(map[string]*Filter)
+	var errs []error
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, filterPrefix) || strings.TrimSpace(value) == "" {
+			continue
+		}
+		filter, err := ParseFilter(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		filters[strings.ToLower(strings.TrimPrefix(key, filterPrefix))] = filter
+	}
+	return filters, errs
+}
+
+// route is a rule sending a class of records to sinks, if its condition matches
+type route struct {
+	condition *Filter
+	sinks     []string
+}
+
+// New parses routing rules like ParseRules, logging and ignoring invalid ones
+func New(spec string) *Rules {
+	r, err := ParseRules(spec)
+	if err != nil {
+		log.Printf("Ignoring invalid sink routes: %v", err)
+	}
+	return r
+}
+
+// ParseRules parses routing rules in the format "class:sink,sink;class:sink"
+// Sink names may be glob patterns and "none" drops the class entirely,
+// e.g. "emergency:*;position:otlp,clickhouse;*:otlp"
+// A class may be followed by a filter expression in parentheses that the
+// record must match for the rule to apply, e.g. "position(distance_nm < 50):nats"
+// The rules of a class are tried in order and the first that applies is used
+// The returned rules hold every valid rule, alongside an error for the others
+func ParseRules(spec string) (*Rules, error) {
+	r := &Rules{routes: make(map[string][]route)}
+
+	var errs []error
+	for _, rule := range splitRules(spec) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		class, rt, err := parseRule(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", rule, err))
+			continue
+		}
+		r.routes[class] = append(r.routes[class], rt)
+	}
+
+	return r, errors.Join(errs...)
+}
+
+// parseRule parses a single "class(condition):sink,sink" rule
+func parseRule(rule string) (string, route, error) {
+	var rt route
+	end := strings.IndexAny(rule, "(:")
+	if end < 0 {
+		return "", rt, errors.New("missing \":\" before the sinks")
+	}
+	class := strings.ToLower(strings.TrimSpace(rule[:end]))
+	if !validClass(class) {
+		return "", rt, fmt.Errorf("unknown class %q", class)
+	}
+
+	rest := rule[end:]
+	if rest[0] == '(' {
+		closing := closingParen(rest)
+		if closing < 0 {
+			return "", rt, errors.New("unterminated condition")
+		}
+		condition, err := ParseFilter(rest[1:closing])
+		if err != nil {
+			return "", rt, fmt.Errorf("invalid condition: %w", err)
+		}
+		rt.condition = condition
+		rest = strings.TrimSpace(rest[closing+1:])
+	}
+	sinks, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return "", rt, errors.New("missing \":\" before the sinks")
+	}
+
+	rt.sinks = []string{}
+	for _, sink := range strings.Split(sinks, ",") {
+		if sink = strings.ToLower(strings.TrimSpace(sink)); sink != "" && sink != "none" {
+			rt.sinks = append(rt.sinks, sink)
+		}
+	}
+	return class, rt, nil
+}
+
+// splitRules splits rules on ";", except within quoted strings of conditions
+func splitRules(spec string) []string {
+	var rules []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(spec); i++ {
+		switch c := spec[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			rules = append(rules, spec[start:i])
+			start = i + 1
+		}
+	}
+	return append(rules, spec[start:])
+}
+
+// closingParen returns the index of the parenthesis closing the one s starts
+// with, skipping quoted strings, or -1 if it is not closed
+func closingParen(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Active reports whether any routing rules, filters or muted sectors are configured
+func (r *Rules) Active() bool {
+	return len(r.routes) > 0 || len(r.filters) > 0 || len(r.sectors) > 0
+}
+
+// Conditional reports whether any routing rule has a condition, so Allows
+// needs the values of the records
+func (r *Rules) Conditional() bool {
+	for _, routes := range r.routes {
+		for _, rt := range routes {
+			if rt.condition != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filters returns the sink filters by sink name
+func (r *Rules) Filters() map[string]*Filter {
+	return r.filters
+}
+
+// Filtered reports whether the named sink has a filter
+func (r *Rules) Filtered(sink string) bool {
+	return r.filters[sink] != nil
+}
+
+// Match reports whether an aircraft passes the filter of the named sink
+// Sinks without a filter accept every aircraft
+func (r *Rules) Match(sink string, values Values) bool {
+	filter, ok := r.filters[sink]
+	return !ok || filter.Match(values)
+}
+
+// Allows reports whether records of the given class with the given values
+// are sent to the named sink
+// Values are only needed if the rules are Conditional and may be nil otherwise
+// Summaries are only routed by summary rules, as "*" rules are written for
+// the aircraft observations
+func (r *Rules) Allows(class Class, sink string, values Values) bool {
+	rt, ok := r.route(string(class), values)
+	if !ok && class != ClassSummary {
+		rt, ok = r.route("*", values)
+	}
+	if !ok {
+		return true
+	}
+	for _, pattern := range rt.sinks {
+		if matched, _ := path.Match(pattern, sink); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ConditionalRoute returns the rule, in the SINK_ROUTES format, that routes
+// records of the given class with the given values, if it has a condition
+func (r *Rules) ConditionalRoute(class Class, values Values) (string, bool) {
+	name := string(class)
+	rt, ok := r.route(name, values)
+	if !ok && class != ClassSummary {
+		name = "*"
+		rt, ok = r.route(name, values)
+	}
+	if !ok || rt.condition == nil {
+		return "", false
+	}
+	return name + "(" + rt.condition.String() + "):" + strings.Join(rt.sinks, ","), true
+}
+
+// route returns the first rule of a class whose condition matches the values
+func (r *Rules) route(class string, values Values) (route, bool) {
+	for _, rt := range r.routes[class] {
+		if rt.condition == nil || rt.condition.Match(values) {
+			return rt, true
+		}
+	}
+	return route{}, false
+}
+
+// Classify returns the class an aircraft is routed as
+func Classify(a *models.Aircraft) Class {
+	switch {
+	case isEmergency(a):
+		return ClassEmergency
+	case a.HasPosition():
+		return ClassPosition
+	default:
+		return ClassOther
+	}
+}
+
+func isEmergency(a *models.Aircraft) bool {
+	if a.Emergency != "" && a.Emergency != "none" {
+		return true
+	}
+	switch a.Squawk {
+	case "7500", "7600", "7700":
+		return true
+	}
+	return false
+}
+
+func validClass(class string) bool {
+	if class == "*" {
+		return true
+	}
+	for _, c := range classes {
+		if string(c) == class {
+			return true
+		}
+	}
+	return false
+}
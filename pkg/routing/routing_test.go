@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		spec    string
+		classes int
+		invalid bool
+	}{
+		{"", 0, false},
+		{"emergency:*;position:otlp,clickhouse;*:otlp", 3, false},
+		{" Position : OTLP ; other:none ", 2, false},
+		{`position(category in ["A5", "A7"] && flight != "a;b"):nats;position:otlp`, 1, false},
+		{"summary(flight == 'BAW123'):logbook", 1, false},
+		{"position:otlp;squawks:loki", 1, true},
+		{"otlp", 0, true},
+		{"position(alt_baro <):otlp", 0, true},
+		{"position(alt_baro < 1000:otlp", 0, true},
+		{"position(alt_baro < 1000) otlp", 0, true},
+	}
+	for _, tt := range tests {
+		r, err := ParseRules(tt.spec)
+		if (err != nil) != tt.invalid {
+			t.Errorf("ParseRules(%q) error = %v, want invalid %v", tt.spec, err, tt.invalid)
+		}
+		if r == nil || len(r.routes) != tt.classes {
+			t.Errorf("ParseRules(%q) = %+v, want %d classes", tt.spec, r, tt.classes)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	r, err := ParseRules(`emergency:*;position(distance_nm < 50):nats,otlp;position:otlp,click*;summary(flight != null):logbook;*:otlp`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Conditional() {
+		t.Error("rules with conditions not conditional")
+	}
+
+	near := Values{"distance_nm": 20.0}
+	far := Values{"distance_nm": 80.0}
+	tests := []struct {
+		class  Class
+		sink   string
+		values Values
+		want   bool
+	}{
+		{ClassEmergency, "alertmanager", nil, true},
+		{ClassPosition, "nats", near, true},
+		{ClassPosition, "clickhouse", near, false},
+		{ClassPosition, "nats", far, false},
+		{ClassPosition, "clickhouse", far, true},
+		{ClassPosition, "otlp", far, true},
+		{ClassOther, "otlp", nil, true},
+		{ClassOther, "nats", nil, false},
+		{ClassSummary, "logbook", SummaryValues("4ca7b4", "EIN12B  "), true},
+		// Summaries don't fall back to the "*" rule
+		{ClassSummary, "otlp", SummaryValues("4ca7b4", "EIN12B"), false},
+		{ClassSummary, "otlp", SummaryValues("4ca7b4", ""), true},
+	}
+	for _, tt := range tests {
+		if got := r.Allows(tt.class, tt.sink, tt.values); got != tt.want {
+			t.Errorf("Allows(%s, %s, %v) = %v, want %v", tt.class, tt.sink, tt.values, got, tt.want)
+		}
+	}
+
+	if rule, ok := r.ConditionalRoute(ClassPosition, near); !ok || rule != "position(distance_nm < 50):nats,otlp" {
+		t.Errorf("ConditionalRoute(position, near) = %q, %v", rule, ok)
+	}
+	if rule, ok := r.ConditionalRoute(ClassPosition, far); ok {
+		t.Errorf("ConditionalRoute(position, far) = %q for a rule without a condition", rule)
+	}
+
+	// Without rules every class goes everywhere
+	none := New("")
+	if none.Active() || none.Conditional() || !none.Allows(ClassOther, "nats", nil) {
+		t.Error("empty rules restrict routing")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	lat, lon := 53.42, -6.27
+	tests := []struct {
+		aircraft models.Aircraft
+		want     Class
+	}{
+		{models.Aircraft{Squawk: "7700"}, ClassEmergency},
+		{models.Aircraft{Emergency: "lifeguard", Lat: &lat, Lon: &lon}, ClassEmergency},
+		{models.Aircraft{Emergency: "none", Lat: &lat, Lon: &lon}, ClassPosition},
+		{models.Aircraft{Squawk: "1200"}, ClassOther},
+	}
+	for _, tt := range tests {
+		if got := Classify(&tt.aircraft); got != tt.want {
+			t.Errorf("Classify(%+v) = %s, want %s", tt.aircraft, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// Sector is a wedge around the receiver in which aircraft are muted, bounded
+// by bearings measured clockwise from true north, distances from the receiver
+// and optionally a maximum altitude
+type Sector struct {
+	FromBearing, ToBearing float64
+	MinNM, MaxNM           float64
+	// MaxAltitude is the altitude in feet up to which aircraft are muted, 0 for any
+	MaxAltitude int
+}
+
+// ParseSectors parses sectors in the format "from-to:min-max[:alt]" separated
+// by ";", e.g. "20-80:0-5:3000" for aircraft up to 3000ft within 5NM between
+// bearings 20 and 80. Bearings may wrap through north (e.g. 340-20) and the
+// distance range may be left out to cover any distance
+func ParseSectors(spec string) ([]Sector, error) {
+	var sectors []Sector
+	for _, entry := range strings.Split(spec, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		sector, err := parseSector(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sector %q: %w", entry, err)
+		}
+		sectors = append(sectors, sector)
+	}
+	return sectors, nil
+}
+
+func parseSector(entry string) (Sector, error) {
+	parts := strings.Split(entry, ":")
+	if len(parts) > 3 {
+		return Sector{}, fmt.Errorf("expected from-to:min-max[:alt]")
+	}
+
+	s := Sector{MaxNM: math.Inf(1)}
+	var err error
+	if s.FromBearing, s.ToBearing, err = parseRange(parts[0]); err != nil {
+		return Sector{}, fmt.Errorf("bearings: %w", err)
+	}
+	if s.FromBearing > 360 || s.ToBearing > 360 {
+		return Sector{}, fmt.Errorf("bearings must be between 0 and 360")
+	}
+	if len(parts) > 1 {
+		if s.MinNM, s.MaxNM, err = parseRange(parts[1]); err != nil {
+			return Sector{}, fmt.Errorf("distances: %w", err)
+		}
+		if s.MinNM > s.MaxNM {
+			return Sector{}, fmt.Errorf("minimum distance exceeds maximum")
+		}
+	}
+	if len(parts) > 2 {
+		if s.MaxAltitude, err = strconv.Atoi(strings.TrimSpace(parts[2])); err != nil || s.MaxAltitude <= 0 {
+			return Sector{}, fmt.Errorf("altitude must be a positive number of feet")
+		}
+	}
+	return s, nil
+}
+
+// parseRange parses "a-b" into two non-negative numbers
+func parseRange(s string) (float64, float64, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a range like 20-80")
+	}
+	a, err := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	if err != nil || a < 0 {
+		return 0, 0, fmt.Errorf("invalid number %q", from)
+	}
+	b, err := strconv.ParseFloat(strings.TrimSpace(to), 64)
+	if err != nil || b < 0 {
+		return 0, 0, fmt.Errorf("invalid number %q", to)
+	}
+	return a, b, nil
+}
+
+// contains reports whether a bearing, distance and altitude lie in the sector
+func (s Sector) contains(bearing, distance float64, altitude int, altitudeKnown bool) bool {
+	if distance < s.MinNM || distance > s.MaxNM {
+		return false
+	}
+	if s.MaxAltitude > 0 && (!altitudeKnown || altitude > s.MaxAltitude) {
+		return false
+	}
+	if s.FromBearing <= s.ToBearing {
+		return bearing >= s.FromBearing && bearing <= s.ToBearing
+	}
+	// The sector wraps through north
+	return bearing >= s.FromBearing || bearing <= s.ToBearing
+}
+
+// String returns the sector in the format it is configured in
+func (s Sector) String() string {
+	str := formatNumber(s.FromBearing) + "-" + formatNumber(s.ToBearing)
+	if s.MinNM > 0 || !math.IsInf(s.MaxNM, 1) || s.MaxAltitude > 0 {
+		str += ":" + formatNumber(s.MinNM) + "-" + formatNumber(s.MaxNM)
+	}
+	if s.MaxAltitude > 0 {
+		str += ":" + strconv.Itoa(s.MaxAltitude)
+	}
+	return str
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Muted reports whether an aircraft lies in one of the muted sectors
+// Aircraft in an emergency are never muted
+func (r *Rules) Muted(a *models.Aircraft) bool {
+	_, ok := r.MutedBy(a)
+	return ok
+}
+
+// MutedBy returns the first muted sector an aircraft lies in
+func (r *Rules) MutedBy(a *models.Aircraft) (Sector, bool) {
+	if len(r.sectors) == 0 || isEmergency(a) {
+		return Sector{}, false
+	}
+
+	bearing, distance, ok := polar(a, r.receiver)
+	if !ok {
+		return Sector{}, false
+	}
+	altitude, altitudeKnown := a.AltitudeFeet()
+	if a.OnGround() {
+		altitude, altitudeKnown = 0, true
+	}
+
+	for _, sector := range r.sectors {
+		if sector.contains(bearing, distance, altitude, altitudeKnown) {
+			return sector, true
+		}
+	}
+	return Sector{}, false
+}
+
+// polar returns the bearing and distance of an aircraft from the receiver,
+// as reported by the decoder or else computed from its position
+func polar(a *models.Aircraft, receiver *geo.Position) (float64, float64, bool) {
+	if a.RDir != nil && a.RDst != nil {
+		return *a.RDir, *a.RDst, true
+	}
+	if receiver == nil {
+		return 0, 0, false
+	}
+	pos, ok := a.Position()
+	if !ok {
+		return 0, 0, false
+	}
+	return geo.Bearing(*receiver, pos), geo.DistanceNM(*receiver, pos), true
+}
@@ -0,0 +1,22 @@
+package routing
+
+import (
+	"os"
+	"strings"
+)
+
+// Selected reports whether the named sink is listed in SINKS, which selects
+// the outputs to use, e.g. SINKS=otlp,loki
+// Without SINKS every configured sink is used alongside the OpenTelemetry log records
+func Selected(sink string) bool {
+	spec := strings.TrimSpace(os.Getenv("SINKS"))
+	if spec == "" {
+		return true
+	}
+	for _, name := range strings.Split(spec, ",") {
+		if strings.EqualFold(strings.TrimSpace(name), sink) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/alerts"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// emergencyRule tracks the emergency alerts for the alert rule metrics
+var emergencyRule = alerts.Get("aircraft.emergency")
+
+// alertmanagerAlert is an alert in the Alertmanager v2 API format
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// alertmanagerSink raises an Alertmanager alert for every aircraft in an
+// emergency so that existing routing, grouping and silences apply. Active
+// alerts are re-sent periodically and resolved once the emergency ends; if
+// the aircraft goes out of range the alert expires after the resolve timeout
+type alertmanagerSink struct {
+	alertsURL      string
+	token          string
+	labels         map[string]string
+	generatorURL   string
+	resolveTimeout time.Duration
+	repeatInterval time.Duration
+	client         *http.Client
+
+	mu       sync.Mutex
+	active   map[string]*alertmanagerAlert
+	lastSent time.Time
+}
+
+// newAlertmanagerFromEnv creates the Alertmanager notifier if ALERTMANAGER_URL is set
+func newAlertmanagerFromEnv() (Sink, bool, error) {
+	baseURL := os.Getenv("ALERTMANAGER_URL")
+	if baseURL == "" {
+		return nil, false, nil
+	}
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return nil, false, fmt.Errorf("ALERTMANAGER_URL must use the http or https scheme")
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("ALERTMANAGER_LABELS"), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	s := &alertmanagerSink{
+		alertsURL:      strings.TrimSuffix(baseURL, "/") + "/api/v2/alerts",
+		token:          os.Getenv("ALERTMANAGER_TOKEN"),
+		labels:         labels,
+		generatorURL:   os.Getenv("ALERTMANAGER_GENERATOR_URL"),
+		resolveTimeout: getEnvDuration("ALERTMANAGER_RESOLVE_TIMEOUT", 5*time.Minute),
+		repeatInterval: getEnvDuration("ALERTMANAGER_REPEAT_INTERVAL", time.Minute),
+		client:         newHTTPClient(10 * time.Second),
+		active:         make(map[string]*alertmanagerAlert),
+	}
+	return s, true, nil
+}
+
+func (s *alertmanagerSink) Name() string {
+	return "alertmanager"
+}
+
+// Write updates the active alerts from a poll's observations and sends them
+// when an alert starts or resolves, or the repeat interval has passed
+func (s *alertmanagerSink) Write(ctx context.Context, observations []Observation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	var resolved []*alertmanagerAlert
+
+	for i := range observations {
+		a := &observations[i].Aircraft
+		alert, firing := s.active[a.Hex]
+
+		if routing.Classify(a) != routing.ClassEmergency {
+			if firing {
+				alert.EndsAt = now
+				resolved = append(resolved, alert)
+				delete(s.active, a.Hex)
+				emergencyRule.Resolve(a.Hex)
+			}
+			continue
+		}
+
+		if !firing {
+			alert = &alertmanagerAlert{StartsAt: now, GeneratorURL: s.generatorURL}
+			s.active[a.Hex] = alert
+			emergencyRule.Fire(a.Hex)
+			changed = true
+			logging.Info("Aircraft emergency alert firing", "hex", a.Hex, "squawk", a.Squawk, "emergency", a.Emergency)
+		}
+		alert.Labels, alert.Annotations = s.describe(a)
+	}
+
+	// Alerts for aircraft that are no longer seen expire on their own through EndsAt
+	for hex, alert := range s.active {
+		if now.After(alert.EndsAt) && !alert.EndsAt.IsZero() {
+			delete(s.active, hex)
+			emergencyRule.Resolve(hex)
+		}
+	}
+
+	if !changed && len(resolved) == 0 && now.Sub(s.lastSent) < s.repeatInterval {
+		return nil
+	}
+
+	alerts := resolved
+	for _, alert := range s.active {
+		alert.EndsAt = now.Add(s.resolveTimeout)
+		alerts = append(alerts, alert)
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	if err := s.send(ctx, alerts); err != nil {
+		return err
+	}
+	s.lastSent = now
+	return nil
+}
+
+func (s *alertmanagerSink) Close(_ context.Context) error {
+	return nil
+}
+
+// describe builds the labels and annotations for an aircraft's alert
+func (s *alertmanagerSink) describe(a *models.Aircraft) (map[string]string, map[string]string) {
+	labels := map[string]string{
+		"alertname": "AircraftEmergency",
+		"severity":  "critical",
+		"hex":       a.Hex,
+	}
+	for k, v := range s.labels {
+		labels[k] = v
+	}
+	flight := strings.TrimSpace(a.Flight)
+	if flight != "" {
+		labels["flight"] = flight
+	}
+	if a.Squawk != "" {
+		labels["squawk"] = a.Squawk
+	}
+	if a.Emergency != "" && a.Emergency != "none" {
+		labels["emergency"] = a.Emergency
+	}
+
+	name := flight
+	if name == "" {
+		name = a.Hex
+	}
+	reason := a.Emergency
+	if reason == "" || reason == "none" {
+		reason = "squawk " + a.Squawk
+	}
+
+	annotations := map[string]string{
+		"summary": fmt.Sprintf("Aircraft %s is reporting an emergency (%s)", name, reason),
+	}
+	if pos, ok := a.Position(); ok {
+		annotations["position"] = fmt.Sprintf("%.5f,%.5f", pos.Lat, pos.Lon)
+	}
+	if alt, ok := a.AltitudeFeet(); ok {
+		annotations["altitude_ft"] = fmt.Sprint(alt)
+	}
+	if a.R != "" {
+		annotations["registration"] = a.R
+	}
+	return labels, annotations
+}
+
+func (s *alertmanagerSink) send(ctx context.Context, alerts []*alertmanagerAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	addPayloadBytes(ctx, len(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.alertsURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Alertmanager returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	logging.Debug("Sent alerts to Alertmanager", "alerts", len(alerts))
+	return nil
+}
@@ -0,0 +1,141 @@
+package sinks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/burnettdev/adsb2otel/pkg/crash"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// batcher buffers observations for sinks that insert in batches, flushing in
+// the background when the batch size is reached or the flush interval passes
+type batcher struct {
+	name        string
+	size        int
+	maxBuffered int
+	flush       func(ctx context.Context, batch []Observation) error
+
+	mu      sync.Mutex
+	buf     []Observation
+	flushMu sync.Mutex
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newBatcher(name string, size int, interval time.Duration, flush func(ctx context.Context, batch []Observation) error) *batcher {
+	b := &batcher{
+		name: name,
+		size: size,
+		// Keep a bounded backlog while the destination is unavailable
+		maxBuffered: size * 10,
+		flush:       flush,
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// Add queues observations, triggering a background flush once a full batch is buffered
+func (b *batcher) Add(observations []Observation) {
+	b.mu.Lock()
+	b.buf = append(b.buf, observations...)
+	if dropped := len(b.buf) - b.maxBuffered; dropped > 0 {
+		b.buf = b.buf[dropped:]
+		logging.Warn("Sink backlog full, dropped oldest observations", "sink", b.name, "dropped", dropped)
+	}
+	full := len(b.buf) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes everything currently buffered in batches of at most size
+// Observations from a failed batch are put back to be retried later
+func (b *batcher) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		b.mu.Lock()
+		n := min(len(b.buf), b.size)
+		if n == 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		batch := make([]Observation, n)
+		copy(batch, b.buf[:n])
+		b.buf = b.buf[n:]
+		b.mu.Unlock()
+
+		if err := b.flushBatch(ctx, batch); err != nil {
+			for i := range batch {
+				batch[i].attempts++
+			}
+			b.mu.Lock()
+			b.buf = append(batch, b.buf...)
+			b.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// flushBatch writes a batch in a span linked to the fetch cycles its
+// observations came from, as flushes run outside of them
+func (b *batcher) flushBatch(ctx context.Context, batch []Observation) error {
+	retries := 0
+	for i := range batch {
+		retries = max(retries, batch[i].attempts)
+	}
+
+	ctx, span := startSpan(ctx, "sink.flush", b.name, len(batch),
+		trace.WithLinks(fetchLinks(batch)...),
+		trace.WithAttributes(attribute.Int("sink.retry_count", retries)),
+	)
+	err := b.flush(ctx, batch)
+	span.end(err)
+	return err
+}
+
+// Close stops the background flusher and flushes any remaining observations
+func (b *batcher) Close(ctx context.Context) error {
+	close(b.stop)
+	<-b.done
+	return b.Flush(ctx)
+}
+
+func (b *batcher) run(interval time.Duration) {
+	defer crash.Recover("sink." + b.name)
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval+30*time.Second)
+		if err := b.Flush(ctx); err != nil {
+			logging.Warn("Sink flush failed, will retry", "sink", b.name, "error", err)
+		}
+		cancel()
+	}
+}
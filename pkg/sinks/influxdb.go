@@ -0,0 +1,180 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+var (
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	fieldStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxSink writes observations to an InfluxDB v2 bucket using line protocol
+// Each observation becomes a point with hex/flight tags and position, altitude
+// and speed fields, which Grafana geomap panels can use directly
+type influxSink struct {
+	writeURL    string
+	token       string
+	measurement string
+	client      *http.Client
+	batcher     *batcher
+}
+
+// newInfluxDBFromEnv creates the InfluxDB sink if INFLUXDB_URL is set
+func newInfluxDBFromEnv() (Sink, bool, error) {
+	baseURL := os.Getenv("INFLUXDB_URL")
+	if baseURL == "" {
+		return nil, false, nil
+	}
+
+	org := os.Getenv("INFLUXDB_ORG")
+	bucket := os.Getenv("INFLUXDB_BUCKET")
+	if org == "" || bucket == "" {
+		return nil, false, fmt.Errorf("INFLUXDB_ORG and INFLUXDB_BUCKET are required when INFLUXDB_URL is set")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/api/v2/write")
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid INFLUXDB_URL: %w", err)
+	}
+	params := url.Values{}
+	params.Set("org", org)
+	params.Set("bucket", bucket)
+	params.Set("precision", "ms")
+	u.RawQuery = params.Encode()
+
+	s := &influxSink{
+		writeURL:    u.String(),
+		token:       os.Getenv("INFLUXDB_TOKEN"),
+		measurement: getEnv("INFLUXDB_MEASUREMENT", "aircraft"),
+		client:      newHTTPClient(30 * time.Second),
+	}
+
+	batchSize := getEnvInt("INFLUXDB_BATCH_SIZE", 5000)
+	flushInterval := getEnvDuration("INFLUXDB_FLUSH_INTERVAL", 10*time.Second)
+	s.batcher = newBatcher(s.Name(), batchSize, flushInterval, s.write)
+
+	return s, true, nil
+}
+
+func (s *influxSink) Name() string {
+	return "influxdb"
+}
+
+func (s *influxSink) Write(_ context.Context, observations []Observation) error {
+	s.batcher.Add(observations)
+	return nil
+}
+
+func (s *influxSink) Close(ctx context.Context) error {
+	return s.batcher.Close(ctx)
+}
+
+func (s *influxSink) write(ctx context.Context, batch []Observation) error {
+	var body bytes.Buffer
+	for _, o := range batch {
+		s.appendPoint(&body, o)
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	addPayloadBytes(ctx, body.Len())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("InfluxDB returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	logging.Debug("Wrote observations to InfluxDB", "points", len(batch))
+	return nil
+}
+
+// appendPoint writes a single observation as a line protocol point
+// Observations without any numeric fields are skipped since InfluxDB requires at least one field
+func (s *influxSink) appendPoint(buf *bytes.Buffer, o Observation) {
+	a := &o.Aircraft
+
+	var fieldSet []string
+	addFloat := func(key string, v *float64) {
+		if v != nil {
+			fieldSet = append(fieldSet, key+"="+strconv.FormatFloat(*v, 'f', -1, 64))
+		}
+	}
+	addInt := func(key string, v *int) {
+		if v != nil {
+			fieldSet = append(fieldSet, key+"="+strconv.Itoa(*v)+"i")
+		}
+	}
+
+	addFloat("lat", a.Lat)
+	addFloat("lon", a.Lon)
+	if alt, ok := a.AltitudeFeet(); ok && !a.OnGround() {
+		fieldSet = append(fieldSet, "altitude="+strconv.Itoa(alt)+"i")
+	}
+	addInt("alt_geom", a.AltGeom)
+	addFloat("gs", a.Gs)
+	addFloat("track", a.Track)
+	addInt("baro_rate", a.BaroRate)
+	if a.AltBaro != nil {
+		fieldSet = append(fieldSet, "on_ground="+strconv.FormatBool(a.OnGround()))
+	}
+	if a.Squawk != "" {
+		fieldSet = append(fieldSet, `squawk="`+fieldStringEscaper.Replace(a.Squawk)+`"`)
+	}
+	if len(fieldSet) == 0 {
+		return
+	}
+	fieldSet = append(fieldSet,
+		"rssi="+strconv.FormatFloat(a.Rssi, 'f', -1, 64),
+		"messages="+strconv.Itoa(a.Messages)+"i",
+	)
+
+	buf.WriteString(measurementEscaper.Replace(s.measurement))
+	writeTag(buf, "hex", a.Hex)
+	writeTag(buf, "flight", strings.TrimSpace(a.Flight))
+	writeTag(buf, "type", a.Type)
+	writeTag(buf, "category", a.Category)
+	buf.WriteByte(' ')
+	buf.WriteString(strings.Join(fieldSet, ","))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(o.Time.UnixMilli(), 10))
+	buf.WriteByte('\n')
+}
+
+// writeTag appends a tag, skipping empty values which line protocol does not allow
+func writeTag(buf *bytes.Buffer, key, value string) {
+	if value == "" {
+		return
+	}
+	buf.WriteByte(',')
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(tagEscaper.Replace(value))
+}
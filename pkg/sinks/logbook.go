@@ -0,0 +1,271 @@
+package sinks
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// logbookTimeFormat is the timestamp format used in logbook files (UTC)
+const logbookTimeFormat = "2006-01-02 15:04:05"
+
+// logbookHeader lists the logbook columns, modeled on the report exports of
+// Virtual Radar Server so the files import into common spotting tools
+var logbookHeader = []string{
+	"ICAO", "Registration", "Callsign", "Type", "Operator", "Squawk",
+	"First Seen", "Last Seen", "Duration",
+	"First Altitude", "Last Altitude", "Min Altitude", "Max Altitude", "Max Speed",
+	"First Latitude", "First Longitude", "Last Latitude", "Last Longitude", "Max Distance",
+	"Messages", "Emergency", "Source",
+}
+
+// logbookSession accumulates what was seen of an aircraft while it stayed in range
+type logbookSession struct {
+	hex, registration, callsign, aircraftType, operator, squawk, emergency, source string
+
+	firstSeen, lastSeen time.Time
+	lastWall            time.Time
+
+	firstAlt, lastAlt, minAlt, maxAlt *int
+	maxSpeed                          *float64
+	firstPos, lastPos                 *[2]float64
+	maxDistance                       *float64
+
+	messages     int
+	lastMessages int
+}
+
+// logbookSink writes a row per completed session to daily CSV files
+// A session completes once the aircraft has not been seen for the session
+// timeout; sessions still open at shutdown are written as they are
+type logbookSink struct {
+	dir     string
+	timeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*logbookSession
+}
+
+// newLogbookFromEnv creates the logbook sink if LOGBOOK_DIR is set
+func newLogbookFromEnv() (Sink, bool, error) {
+	dir := os.Getenv("LOGBOOK_DIR")
+	if dir == "" {
+		return nil, false, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, false, fmt.Errorf("failed to create LOGBOOK_DIR: %w", err)
+	}
+
+	s := &logbookSink{
+		dir:      dir,
+		timeout:  getEnvDuration("LOGBOOK_SESSION_TIMEOUT", 10*time.Minute),
+		sessions: make(map[string]*logbookSession),
+	}
+	return s, true, nil
+}
+
+func (s *logbookSink) Name() string {
+	return "logbook"
+}
+
+// Write updates the open sessions and writes the ones that have completed
+func (s *logbookSink) Write(ctx context.Context, observations []Observation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i := range observations {
+		o := &observations[i]
+		session, ok := s.sessions[o.Aircraft.Hex]
+		if !ok {
+			session = &logbookSession{hex: o.Aircraft.Hex, firstSeen: o.Time}
+			s.sessions[o.Aircraft.Hex] = session
+		}
+		session.update(o.Time, &o.Aircraft)
+		session.lastWall = now
+	}
+
+	var completed []*logbookSession
+	for hex, session := range s.sessions {
+		if now.Sub(session.lastWall) >= s.timeout {
+			completed = append(completed, session)
+			delete(s.sessions, hex)
+		}
+	}
+	return s.append(completed)
+}
+
+// Close writes the sessions that are still open
+func (s *logbookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open := make([]*logbookSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		open = append(open, session)
+	}
+	s.sessions = make(map[string]*logbookSession)
+	return s.append(open)
+}
+
+// append writes sessions to the file of the day they were last seen on,
+// leaving out those the routing rules don't send to the logbook
+func (s *logbookSink) append(sessions []*logbookSession) error {
+	routes := routing.Get()
+	sessions = slices.DeleteFunc(sessions, func(session *logbookSession) bool {
+		return !routes.Allows(routing.ClassSummary, s.Name(), routing.SummaryValues(session.hex, session.callsign))
+	})
+	if len(sessions) == 0 {
+		return nil
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].firstSeen.Before(sessions[j].firstSeen) })
+
+	byDay := make(map[string][]*logbookSession)
+	for _, session := range sessions {
+		day := session.lastSeen.UTC().Format("2006-01-02")
+		byDay[day] = append(byDay[day], session)
+	}
+
+	for day, sessions := range byDay {
+		path := filepath.Join(s.dir, "logbook-"+day+".csv")
+		if err := appendLogbook(path, sessions); err != nil {
+			return err
+		}
+		logging.Debug("Wrote logbook sessions", "path", path, "sessions", len(sessions))
+	}
+	return nil
+}
+
+func appendLogbook(path string, sessions []*logbookSession) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open logbook: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		w.Write(logbookHeader)
+	}
+	for _, session := range sessions {
+		w.Write(session.row())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write logbook: %w", err)
+	}
+	return f.Close()
+}
+
+// update folds an observation into the session
+func (s *logbookSession) update(t time.Time, a *models.Aircraft) {
+	s.lastSeen = t
+
+	setString(&s.registration, a.R)
+	setString(&s.callsign, strings.TrimSpace(a.Flight))
+	setString(&s.aircraftType, a.T)
+	setString(&s.operator, a.OwnOp)
+	setString(&s.squawk, a.Squawk)
+	setString(&s.source, a.Source)
+	if a.Emergency != "" && a.Emergency != "none" {
+		s.emergency = a.Emergency
+	}
+
+	if alt, ok := a.AltitudeFeet(); ok || a.OnGround() {
+		if a.OnGround() {
+			alt = 0
+		}
+		if s.firstAlt == nil {
+			s.firstAlt = &alt
+		}
+		s.lastAlt = &alt
+		if s.minAlt == nil || alt < *s.minAlt {
+			s.minAlt = &alt
+		}
+		if s.maxAlt == nil || alt > *s.maxAlt {
+			s.maxAlt = &alt
+		}
+	}
+	if a.Gs != nil && (s.maxSpeed == nil || *a.Gs > *s.maxSpeed) {
+		gs := *a.Gs
+		s.maxSpeed = &gs
+	}
+	if pos, ok := a.Position(); ok {
+		p := [2]float64{pos.Lat, pos.Lon}
+		if s.firstPos == nil {
+			s.firstPos = &p
+		}
+		s.lastPos = &p
+	}
+	if a.RDst != nil && (s.maxDistance == nil || *a.RDst > *s.maxDistance) {
+		d := *a.RDst
+		s.maxDistance = &d
+	}
+
+	// The receiver's message counter restarts when it loses and reacquires the aircraft
+	if a.Messages >= s.lastMessages {
+		s.messages += a.Messages - s.lastMessages
+	} else {
+		s.messages += a.Messages
+	}
+	s.lastMessages = a.Messages
+}
+
+// row returns the session as a logbook row
+func (s *logbookSession) row() []string {
+	source := s.source
+	if source == "" {
+		source = models.SourceReceiver
+	}
+	var firstLat, firstLon, lastLat, lastLon string
+	if s.firstPos != nil {
+		firstLat, firstLon = formatCoordinate(s.firstPos[0]), formatCoordinate(s.firstPos[1])
+		lastLat, lastLon = formatCoordinate(s.lastPos[0]), formatCoordinate(s.lastPos[1])
+	}
+
+	return []string{
+		strings.ToUpper(s.hex), s.registration, s.callsign, s.aircraftType, s.operator, s.squawk,
+		s.firstSeen.UTC().Format(logbookTimeFormat), s.lastSeen.UTC().Format(logbookTimeFormat),
+		strconv.Itoa(int(s.lastSeen.Sub(s.firstSeen).Seconds())),
+		formatInt(s.firstAlt), formatInt(s.lastAlt), formatInt(s.minAlt), formatInt(s.maxAlt), formatFloat(s.maxSpeed, 0),
+		firstLat, firstLon, lastLat, lastLon, formatFloat(s.maxDistance, 1),
+		strconv.Itoa(s.messages), s.emergency, source,
+	}
+}
+
+// setString keeps the latest non-empty value
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatFloat(v *float64, precision int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', precision, 64)
+}
+
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', 5, 64)
+}
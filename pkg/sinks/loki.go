@@ -0,0 +1,332 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+)
+
+// Sources of the timestamps of Loki lines
+const (
+	// lokiTimestampSource uses the receiver's time of the observation
+	lokiTimestampSource = "source"
+	// lokiTimestampIngestion uses the time of the push
+	lokiTimestampIngestion = "ingestion"
+)
+
+// Policies for lines Loki rejects as too old or out of order
+const (
+	lokiRejectDrop    = "drop"
+	lokiRejectRequeue = "requeue"
+)
+
+// lokiRejections match the reasons Loki gives for refusing lines because of
+// their timestamps, across its ordered and unordered write modes
+var lokiRejections = []string{"too far behind", "out of order", "timestamp too old"}
+
+// lokiOldestAcceptable extracts the cutoff from Loki's "entry too far behind" error
+var lokiOldestAcceptable = regexp.MustCompile(`oldest acceptable timestamp is: ([0-9T:.+\-Z]+)`)
+
+// lokiPush is a request to the Loki push API
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiSink pushes every observation to Loki as a log line holding the
+// aircraft JSON, in a single stream labeled with service="adsb" and any
+// configured labels, for deployments that ship to Loki directly rather than
+// through a collector
+type lokiSink struct {
+	pushURL string
+	labels  map[string]string
+	headers map[string]string
+	client  *http.Client
+	batcher *batcher
+
+	timestamps   string
+	rejectPolicy string
+	// gzipMinBytes is the payload size from which pushes are compressed,
+	// or 0 if they never are
+	gzipMinBytes int
+	// gz is reused across pushes, which never overlap
+	gz *gzip.Writer
+	// last is the newest timestamp pushed to the stream, which later lines
+	// are kept at or after; only write uses it, and flushes never overlap
+	last time.Time
+}
+
+// newLokiFromEnv creates the Loki sink if LOKI_URL is set
+// LOKI_TENANT_ID, LOKI_USERNAME/LOKI_PASSWORD, LOKI_TOKEN and LOKI_HEADERS
+// each set their own headers, so a tenant can be combined with either kind
+// of credentials
+func newLokiFromEnv() (Sink, bool, error) {
+	baseURL := os.Getenv("LOKI_URL")
+	if baseURL == "" {
+		return nil, false, nil
+	}
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return nil, false, fmt.Errorf("LOKI_URL must use the http or https scheme")
+	}
+
+	labels := map[string]string{"service": "adsb"}
+	for _, pair := range strings.Split(os.Getenv("LOKI_LABELS"), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	hdrs, err := lokiHeadersFromEnv()
+	if err != nil {
+		return nil, false, err
+	}
+
+	timestamps := strings.ToLower(getEnv("LOKI_TIMESTAMP", lokiTimestampSource))
+	if timestamps != lokiTimestampSource && timestamps != lokiTimestampIngestion {
+		return nil, false, fmt.Errorf("LOKI_TIMESTAMP must be source or ingestion, got %q", timestamps)
+	}
+	rejectPolicy := strings.ToLower(getEnv("LOKI_REJECT_POLICY", lokiRejectDrop))
+	if rejectPolicy != lokiRejectDrop && rejectPolicy != lokiRejectRequeue {
+		return nil, false, fmt.Errorf("LOKI_REJECT_POLICY must be drop or requeue, got %q", rejectPolicy)
+	}
+
+	gzipMinBytes := 0
+	switch compression := strings.ToLower(getEnv("LOKI_COMPRESSION", "gzip")); compression {
+	case "gzip":
+		gzipMinBytes = getEnvInt("LOKI_COMPRESSION_MIN_BYTES", 1024)
+	case "none":
+	default:
+		return nil, false, fmt.Errorf("LOKI_COMPRESSION must be gzip or none, got %q", compression)
+	}
+
+	s := &lokiSink{
+		pushURL:      strings.TrimSuffix(baseURL, "/") + "/loki/api/v1/push",
+		labels:       labels,
+		headers:      hdrs,
+		client:       newHTTPClient(30 * time.Second),
+		timestamps:   timestamps,
+		rejectPolicy: rejectPolicy,
+		gzipMinBytes: gzipMinBytes,
+	}
+
+	batchSize := getEnvInt("LOKI_BATCH_SIZE", 1000)
+	flushInterval := getEnvDuration("LOKI_FLUSH_INTERVAL", 5*time.Second)
+	s.batcher = newBatcher(s.Name(), batchSize, flushInterval, s.write)
+
+	return s, true, nil
+}
+
+// lokiHeadersFromEnv builds the headers sent with every push
+// Custom headers from LOKI_HEADERS are applied last so they can override the others
+func lokiHeadersFromEnv() (map[string]string, error) {
+	hdrs := make(map[string]string)
+	if tenant := os.Getenv("LOKI_TENANT_ID"); tenant != "" {
+		hdrs["X-Scope-OrgID"] = tenant
+	}
+
+	username, password, token := os.Getenv("LOKI_USERNAME"), os.Getenv("LOKI_PASSWORD"), os.Getenv("LOKI_TOKEN")
+	switch {
+	case token != "" && username != "":
+		return nil, fmt.Errorf("LOKI_TOKEN and LOKI_USERNAME are mutually exclusive")
+	case token != "":
+		hdrs["Authorization"] = "Bearer " + token
+	case username != "":
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		hdrs["Authorization"] = req.Header.Get("Authorization")
+	}
+
+	custom, err := headers.Parse(os.Getenv("LOKI_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("LOKI_HEADERS: %w", err)
+	}
+	for k, v := range custom {
+		hdrs[k] = v
+	}
+	return hdrs, nil
+}
+
+func (s *lokiSink) Name() string {
+	return "loki"
+}
+
+func (s *lokiSink) Write(_ context.Context, observations []Observation) error {
+	s.batcher.Add(observations)
+	return nil
+}
+
+func (s *lokiSink) Close(ctx context.Context) error {
+	return s.batcher.Close(ctx)
+}
+
+func (s *lokiSink) write(ctx context.Context, batch []Observation) error {
+	// Older Loki versions reject lines older than the last one in a stream
+	batch = slices.Clone(batch)
+	slices.SortStableFunc(batch, func(a, b Observation) int {
+		return a.Time.Compare(b.Time)
+	})
+	now := time.Now()
+	for i := range batch {
+		batch[i].Time = s.timestamp(batch[i].Time, now)
+	}
+
+	stream := lokiStream{Stream: s.labels, Values: make([][2]string, 0, len(batch))}
+	for _, o := range batch {
+		line, err := json.Marshal(&o.Aircraft)
+		if err != nil {
+			return err
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(o.Time.UnixNano(), 10), string(line)})
+	}
+
+	body, err := json.Marshal(lokiPush{Streams: []lokiStream{stream}})
+	if err != nil {
+		return err
+	}
+	encoding := ""
+	if s.gzipMinBytes > 0 && len(body) >= s.gzipMinBytes {
+		if body, err = s.compress(body); err != nil {
+			return err
+		}
+		encoding = "gzip"
+	}
+	addPayloadBytes(ctx, len(body))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if resp.StatusCode == http.StatusBadRequest && isLokiRejection(string(msg)) {
+			// Loki keeps the lines it accepted from a partially rejected push
+			s.last = batch[len(batch)-1].Time
+			s.rejected(ctx, batch, string(msg))
+			return nil
+		}
+		if len(msg) > 1024 {
+			msg = msg[:1024]
+		}
+		return fmt.Errorf("Loki returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	s.last = batch[len(batch)-1].Time
+	logging.Debug("Pushed observations to Loki", "lines", len(batch))
+	return nil
+}
+
+// compress gzips a push payload
+func (s *lokiSink) compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if s.gz == nil {
+		s.gz = gzip.NewWriter(&buf)
+	} else {
+		s.gz.Reset(&buf)
+	}
+	if _, err := s.gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := s.gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// timestamp normalizes the timestamp of a line pushed at now: ingestion time
+// if configured, otherwise the observation time brought back from the future
+// if the receiver's clock is ahead, and kept from going backwards in the
+// stream when the receiver's clock steps back or a backlog is flushed
+func (s *lokiSink) timestamp(t, now time.Time) time.Time {
+	if s.timestamps == lokiTimestampIngestion {
+		return now
+	}
+	if t.After(now) {
+		t = now
+	}
+	if t.Before(s.last) {
+		t = s.last
+	}
+	return t
+}
+
+// rejected handles lines of a batch Loki refused as too old or out of
+// order, dropping them or queueing them again with the current time as
+// their timestamp, once
+// Only lines older than the cutoff in Loki's error are requeued if it gives
+// one, otherwise all of them are, and lines Loki did accept are duplicated
+func (s *lokiSink) rejected(ctx context.Context, batch []Observation, msg string) {
+	rejected := batch
+	if m := lokiOldestAcceptable.FindStringSubmatch(msg); m != nil {
+		if cutoff, err := time.Parse(time.RFC3339Nano, m[1]); err == nil {
+			n, _ := slices.BinarySearchFunc(batch, cutoff, func(o Observation, t time.Time) int {
+				return o.Time.Compare(t)
+			})
+			rejected = batch[:n]
+		}
+	}
+
+	var requeue []Observation
+	if s.rejectPolicy == lokiRejectRequeue {
+		now := time.Now()
+		for _, o := range rejected {
+			if !o.requeued {
+				o.Time, o.requeued = now, true
+				requeue = append(requeue, o)
+			}
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("loki.rejected", len(rejected)),
+		attribute.Int("loki.requeued", len(requeue)),
+	)
+	logging.Warn("Loki rejected lines as too old or out of order",
+		"lines", len(rejected), "requeued", len(requeue), "dropped", len(rejected)-len(requeue), "policy", s.rejectPolicy)
+	if len(requeue) > 0 {
+		s.batcher.Add(requeue)
+	}
+}
+
+// isLokiRejection reports whether a Loki error is about line timestamps
+func isLokiRejection(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, reason := range lokiRejections {
+		if strings.Contains(msg, reason) {
+			return true
+		}
+	}
+	return false
+}
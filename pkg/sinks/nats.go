@@ -0,0 +1,156 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+// natsEvent is the message published for each observation
+type natsEvent struct {
+	Time     time.Time        `json:"time"`
+	Aircraft *models.Aircraft `json:"aircraft"`
+}
+
+// natsSink publishes each observation to a per-aircraft NATS subject,
+// optionally through JetStream so that consumers can replay them
+type natsSink struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// natsDialer adapts the DNS cache to the NATS custom dialer interface
+type natsDialer struct {
+	resolver *dnscache.Resolver
+}
+
+func (d natsDialer) Dial(network, address string) (net.Conn, error) {
+	return d.resolver.DialContext(context.Background(), network, address)
+}
+
+// newNATSFromEnv creates the NATS sink if NATS_URL is set
+func newNATSFromEnv() (Sink, bool, error) {
+	natsURL := os.Getenv("NATS_URL")
+	if natsURL == "" {
+		return nil, false, nil
+	}
+
+	subject := strings.Trim(getEnv("NATS_SUBJECT", "adsb.aircraft"), ".")
+	if strings.ContainsAny(subject, " *>") {
+		return nil, false, fmt.Errorf("invalid NATS_SUBJECT %q", subject)
+	}
+
+	opts := []nats.Option{
+		nats.Name("adsb2otel"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logging.Warn("Disconnected from NATS", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logging.Info("Reconnected to NATS", "url", nc.ConnectedUrlRedacted())
+		}),
+	}
+	if creds := os.Getenv("NATS_CREDS"); creds != "" {
+		opts = append(opts, nats.UserCredentials(creds))
+	}
+	if token := os.Getenv("NATS_TOKEN"); token != "" {
+		opts = append(opts, nats.Token(token))
+	}
+	if resolver := dnscache.Get(); resolver != nil {
+		opts = append(opts, nats.SetCustomDialer(natsDialer{resolver: resolver}))
+	}
+
+	conn, err := nats.Connect(natsURL, opts...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	s := &natsSink{conn: conn, subject: subject}
+
+	if isTrue(getEnv("NATS_JETSTREAM", "false")) {
+		js, err := jetstream.New(conn, jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, msg *nats.Msg, err error) {
+			logging.Warn("JetStream publish failed", "sink", s.Name(), "subject", msg.Subject, "error", err)
+		}))
+		if err != nil {
+			conn.Close()
+			return nil, false, fmt.Errorf("failed to create JetStream context: %w", err)
+		}
+		s.js = js
+
+		if stream := os.Getenv("NATS_STREAM"); stream != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+				Name:     stream,
+				Subjects: []string{subject + ".>"},
+				MaxAge:   getEnvDurationOrZero("NATS_STREAM_MAX_AGE"),
+			})
+			if err != nil {
+				conn.Close()
+				return nil, false, fmt.Errorf("failed to create stream %s: %w", stream, err)
+			}
+			logging.Debug("NATS stream ensured", "stream", stream, "subjects", subject+".>")
+		}
+	}
+
+	return s, true, nil
+}
+
+func (s *natsSink) Name() string {
+	return "nats"
+}
+
+// Write publishes the observations without waiting for acknowledgements,
+// relying on the client's buffering while the server is unavailable
+func (s *natsSink) Write(ctx context.Context, observations []Observation) error {
+	for i := range observations {
+		o := &observations[i]
+		data, err := json.Marshal(natsEvent{Time: o.Time, Aircraft: &o.Aircraft})
+		if err != nil {
+			logging.Warn("Skipping observation that could not be encoded", "sink", s.Name(), "hex", o.Aircraft.Hex, "error", err)
+			continue
+		}
+
+		msg := nats.NewMsg(s.subject + "." + strings.ToLower(o.Aircraft.Hex))
+		msg.Data = data
+		addPayloadBytes(ctx, len(data))
+
+		if s.js != nil {
+			// The message ID lets JetStream drop duplicates from retried publishes
+			msg.Header.Set(jetstream.MsgIDHeader, fmt.Sprintf("%s-%d", o.Aircraft.Hex, o.Time.UnixMilli()))
+			if _, err := s.js.PublishMsgAsync(msg); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *natsSink) Close(ctx context.Context) error {
+	if s.js != nil {
+		select {
+		case <-s.js.PublishAsyncComplete():
+		case <-ctx.Done():
+			logging.Warn("Timed out waiting for JetStream acknowledgements", "sink", s.Name(), "pending", s.js.PublishAsyncPending())
+		}
+	}
+	return s.conn.Drain()
+}
@@ -0,0 +1,209 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// parquetRow is a record in the column layout of the archived Parquet files
+type parquetRow struct {
+	Time      time.Time `parquet:"time,timestamp(millisecond)"`
+	Hex       string    `parquet:"hex,dict"`
+	Flight    string    `parquet:"flight,dict"`
+	Type      string    `parquet:"type,dict"`
+	Category  string    `parquet:"category,dict"`
+	Squawk    string    `parquet:"squawk,dict"`
+	Lat       *float64  `parquet:"lat,optional"`
+	Lon       *float64  `parquet:"lon,optional"`
+	AltBaro   *int32    `parquet:"alt_baro,optional"`
+	AltGeom   *int32    `parquet:"alt_geom,optional"`
+	OnGround  bool      `parquet:"on_ground"`
+	GS        *float64  `parquet:"gs,optional"`
+	Track     *float64  `parquet:"track,optional"`
+	BaroRate  *int32    `parquet:"baro_rate,optional"`
+	RSSI      float64   `parquet:"rssi"`
+	Messages  int64     `parquet:"messages"`
+	Seen      float64   `parquet:"seen"`
+	Emergency string    `parquet:"emergency,dict"`
+	Raw       string    `parquet:"raw,zstd"`
+}
+
+// parquetSink archives observations as Parquet files in an S3 compatible
+// bucket (AWS S3, GCS via its XML API, MinIO), partitioned by hour for
+// Athena/DuckDB style queries
+type parquetSink struct {
+	client   *minio.Client
+	bucket   string
+	prefix   string
+	hostname string
+	batcher  *batcher
+}
+
+// newParquetFromEnv creates the Parquet archival sink if ARCHIVE_BUCKET is set
+func newParquetFromEnv() (Sink, bool, error) {
+	bucket := os.Getenv("ARCHIVE_BUCKET")
+	if bucket == "" {
+		return nil, false, nil
+	}
+
+	endpoint := getEnv("ARCHIVE_ENDPOINT", "s3.amazonaws.com")
+	secure := true
+	switch {
+	case strings.HasPrefix(endpoint, "http://"):
+		secure = false
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+	}
+
+	// Fall back to the standard AWS environment variables when no keys are given
+	creds := credentials.NewEnvAWS()
+	if accessKey := os.Getenv("ARCHIVE_ACCESS_KEY"); accessKey != "" {
+		creds = credentials.NewStaticV4(accessKey, os.Getenv("ARCHIVE_SECRET_KEY"), "")
+	}
+
+	transport, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return nil, false, err
+	}
+	if resolver := dnscache.Get(); resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    secure,
+		Region:    os.Getenv("ARCHIVE_REGION"),
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid ARCHIVE_ENDPOINT: %w", err)
+	}
+
+	s := &parquetSink{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(getEnv("ARCHIVE_PREFIX", "adsb"), "/"),
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "adsb2otel"
+	}
+
+	batchSize := getEnvInt("ARCHIVE_BATCH_SIZE", 100000)
+	flushInterval := getEnvDuration("ARCHIVE_FLUSH_INTERVAL", time.Hour)
+	s.batcher = newBatcher(s.Name(), batchSize, flushInterval, s.upload)
+
+	return s, true, nil
+}
+
+func (s *parquetSink) Name() string {
+	return "parquet"
+}
+
+func (s *parquetSink) Write(_ context.Context, observations []Observation) error {
+	s.batcher.Add(observations)
+	return nil
+}
+
+func (s *parquetSink) Close(ctx context.Context) error {
+	return s.batcher.Close(ctx)
+}
+
+// upload writes a batch as one Parquet file per hour it spans
+func (s *parquetSink) upload(ctx context.Context, batch []Observation) error {
+	hours := make(map[time.Time][]parquetRow)
+	for _, o := range batch {
+		r, err := newRecord(o)
+		if err != nil {
+			logging.Warn("Skipping observation that could not be encoded", "sink", s.Name(), "hex", o.Aircraft.Hex, "error", err)
+			continue
+		}
+		hour := r.Time.Truncate(time.Hour)
+		hours[hour] = append(hours[hour], newParquetRow(r))
+	}
+
+	keys := make([]time.Time, 0, len(hours))
+	for hour := range hours {
+		keys = append(keys, hour)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Before(keys[j]) })
+
+	for _, hour := range keys {
+		if err := s.put(ctx, hour, hours[hour]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *parquetSink) put(ctx context.Context, hour time.Time, rows []parquetRow) error {
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetRow](&buf, parquet.Compression(&parquet.Zstd))
+	if _, err := w.Write(rows); err != nil {
+		return fmt.Errorf("failed to encode Parquet file: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encode Parquet file: %w", err)
+	}
+
+	// Hive style partitions let Athena and DuckDB prune by date
+	name := fmt.Sprintf("%s-%d.parquet", s.hostname, time.Now().UnixNano())
+	key := path.Join(s.prefix, hour.Format("year=2006/month=01/day=02/hour=15"), name)
+
+	addPayloadBytes(ctx, buf.Len())
+	_, err := s.client.PutObject(ctx, s.bucket, key, &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType: "application/vnd.apache.parquet",
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.Debug("Uploaded Parquet archive", "bucket", s.bucket, "key", key, "rows", len(rows))
+	return nil
+}
+
+func newParquetRow(r record) parquetRow {
+	return parquetRow{
+		Time:      r.Time,
+		Hex:       r.Hex,
+		Flight:    r.Flight,
+		Type:      r.Type,
+		Category:  r.Category,
+		Squawk:    r.Squawk,
+		Lat:       r.Lat,
+		Lon:       r.Lon,
+		AltBaro:   int32Ptr(r.AltBaro),
+		AltGeom:   int32Ptr(r.AltGeom),
+		OnGround:  r.OnGround,
+		GS:        r.GS,
+		Track:     r.Track,
+		BaroRate:  int32Ptr(r.BaroRate),
+		RSSI:      r.RSSI,
+		Messages:  int64(r.Messages),
+		Seen:      r.Seen,
+		Emergency: r.Emergency,
+		Raw:       r.Raw,
+	}
+}
+
+func int32Ptr(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	i := int32(*v)
+	return &i
+}
@@ -0,0 +1,66 @@
+package sinks
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// record is the flattened, column-oriented form of an observation shared by
+// the tabular sinks
+type record struct {
+	Time      time.Time `json:"-"`
+	Hex       string    `json:"hex"`
+	Flight    string    `json:"flight"`
+	Type      string    `json:"type"`
+	Category  string    `json:"category"`
+	Squawk    string    `json:"squawk"`
+	Lat       *float64  `json:"lat"`
+	Lon       *float64  `json:"lon"`
+	AltBaro   *int      `json:"alt_baro"`
+	AltGeom   *int      `json:"alt_geom"`
+	OnGround  bool      `json:"on_ground"`
+	GS        *float64  `json:"gs"`
+	Track     *float64  `json:"track"`
+	BaroRate  *int      `json:"baro_rate"`
+	RSSI      float64   `json:"rssi"`
+	Messages  int       `json:"messages"`
+	Seen      float64   `json:"seen"`
+	Emergency string    `json:"emergency"`
+	Raw       string    `json:"raw"`
+}
+
+func newRecord(o Observation) (record, error) {
+	a := &o.Aircraft
+
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return record{}, err
+	}
+
+	r := record{
+		Time:      o.Time.UTC(),
+		Hex:       a.Hex,
+		Flight:    strings.TrimSpace(a.Flight),
+		Type:      a.Type,
+		Category:  a.Category,
+		Squawk:    a.Squawk,
+		Lat:       a.Lat,
+		Lon:       a.Lon,
+		AltGeom:   a.AltGeom,
+		OnGround:  a.OnGround(),
+		GS:        a.Gs,
+		Track:     a.Track,
+		BaroRate:  a.BaroRate,
+		RSSI:      a.Rssi,
+		Messages:  a.Messages,
+		Seen:      a.Seen,
+		Emergency: a.Emergency,
+		Raw:       string(raw),
+	}
+	if a.AltBaro != nil && !a.AltBaro.Ground {
+		feet := a.AltBaro.Feet
+		r.AltBaro = &feet
+	}
+	return r, nil
+}
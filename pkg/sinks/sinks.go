@@ -0,0 +1,254 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// Observation is a single aircraft sighting handed to sinks
+// Aircraft is a copy, so sinks may keep observations beyond the Write call
+type Observation struct {
+	Time     time.Time
+	Aircraft models.Aircraft
+
+	// fetchSpan is the fetch cycle the observation came from, linked from
+	// the spans of batched writes that happen outside of it
+	fetchSpan trace.SpanContext
+	// attempts counts failed writes of a batched observation
+	attempts int
+	// requeued marks an observation that was rejected as too old and queued
+	// again with a new timestamp, so it is not requeued twice
+	requeued bool
+}
+
+// Sink is an output that aircraft observations are written to in addition to
+// the OpenTelemetry log records
+type Sink interface {
+	// Name identifies the sink in logs and errors
+	Name() string
+	// Write hands a poll's observations to the sink
+	Write(ctx context.Context, observations []Observation) error
+	// Close flushes any buffered observations and releases resources
+	Close(ctx context.Context) error
+}
+
+var (
+	activeSinks []Sink
+	mu          sync.RWMutex
+)
+
+// constructors create each kind of sink from its environment variables,
+// reporting false if it is not configured
+var constructors = []struct {
+	name string
+	new  func() (Sink, bool, error)
+}{
+	{"clickhouse", newClickHouseFromEnv},
+	{"influxdb", newInfluxDBFromEnv},
+	{"postgres", newPostgresFromEnv},
+	{"parquet", newParquetFromEnv},
+	{"nats", newNATSFromEnv},
+	{"alertmanager", newAlertmanagerFromEnv},
+	{"logbook", newLogbookFromEnv},
+	{"stream", newStreamFromEnv},
+	{"tracks", newTracksFromEnv},
+	{"loki", newLokiFromEnv},
+}
+
+// Names returns the names of all sinks, including otlp for the OpenTelemetry log records
+func Names() []string {
+	names := []string{routing.OTLP}
+	for _, c := range constructors {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// InitSinks creates every sink that has been configured through environment
+// variables and, if SINKS is set, is listed in it, and returns a function
+// that flushes and closes them
+func InitSinks() (func(), error) {
+	var created []Sink
+	var errs []error
+
+	for _, c := range constructors {
+		if !routing.Selected(c.name) {
+			continue
+		}
+		if sink, ok, err := c.new(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		} else if ok {
+			created = append(created, sink)
+		}
+	}
+
+	mu.Lock()
+	activeSinks = created
+	mu.Unlock()
+
+	for _, sink := range created {
+		logging.Info("Sink initialized", "sink", sink.Name())
+	}
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		for _, sink := range activeSinks {
+			if err := sink.Close(ctx); err != nil {
+				logging.Error("Error closing sink", "sink", sink.Name(), "error", err)
+			}
+		}
+		activeSinks = nil
+	}, errors.Join(errs...)
+}
+
+// Enabled reports whether any sinks are configured
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(activeSinks) > 0
+}
+
+// Write hands observations to every configured sink, subject to the routing
+// rules, sink filters and muted sectors
+// A failing sink does not prevent the others from receiving the observations
+func Write(ctx context.Context, observations []Observation) error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	rules := routing.Get()
+	var classes []routing.Class
+	var muted []bool
+	var values []routing.Values
+	if rules.Active() {
+		classes = make([]routing.Class, len(observations))
+		muted = make([]bool, len(observations))
+		// Filter values are only computed if a route or sink needs them, and then once for all sinks
+		values = make([]routing.Values, len(observations))
+		for i := range observations {
+			classes[i] = routing.Classify(&observations[i].Aircraft)
+			muted[i] = rules.Muted(&observations[i].Aircraft)
+			if rules.Conditional() && !muted[i] {
+				values[i] = routing.ValuesOf(&observations[i].Aircraft)
+			}
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		for i := range observations {
+			observations[i].fetchSpan = sc
+		}
+	}
+
+	var errs []error
+	for _, sink := range activeSinks {
+		routed := observations
+		if rules.Active() {
+			filtered := rules.Filtered(sink.Name())
+			routed = make([]Observation, 0, len(observations))
+			for i, o := range observations {
+				if muted[i] || !rules.Allows(classes[i], sink.Name(), values[i]) {
+					continue
+				}
+				if filtered {
+					if values[i] == nil {
+						values[i] = routing.ValuesOf(&observations[i].Aircraft)
+					}
+					if !rules.Match(sink.Name(), values[i]) {
+						continue
+					}
+				}
+				routed = append(routed, o)
+			}
+			if len(routed) == 0 {
+				continue
+			}
+		}
+		spanCtx, span := startSpan(ctx, "sink.write", sink.Name(), len(routed))
+		err := sink.Write(spanCtx, routed)
+		span.end(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newHTTPClient returns an instrumented HTTP client for sinks that talk HTTP
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if resolver := dnscache.Get(); resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+	return &http.Client{
+		Transport: otelhttp.NewTransport(transport),
+		Timeout:   timeout,
+	}
+}
+
+// getEnv returns the value of an environment variable or a default value if not set
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt returns a positive integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		logging.Warn("Invalid integer, using default", "key", key, "value", value, "default", defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		logging.Warn("Invalid duration, using default", "key", key, "value", value, "default", defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvDurationOrZero returns a positive duration environment variable, or
+// zero if it is unset or invalid so the feature it controls stays disabled
+func getEnvDurationOrZero(key string) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		logging.Warn("Invalid duration, ignoring", "key", key, "value", value)
+	}
+	return 0
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}
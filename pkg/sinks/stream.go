@@ -0,0 +1,47 @@
+package sinks
+
+import (
+	"context"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/stream"
+)
+
+// streamSink publishes observations to the in-process hub that backs the
+// live consumer API
+type streamSink struct {
+	hub *stream.Hub
+}
+
+// newStreamFromEnv creates the stream sink if the live consumer API is enabled
+func newStreamFromEnv() (Sink, bool, error) {
+	hub := stream.Get()
+	if hub == nil {
+		return nil, false, nil
+	}
+	return &streamSink{hub: hub}, true, nil
+}
+
+func (s *streamSink) Name() string {
+	return "stream"
+}
+
+func (s *streamSink) Write(_ context.Context, observations []Observation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+
+	batch := stream.Batch{
+		Time:    time.Now(),
+		Records: make([]stream.Record, len(observations)),
+	}
+	for i, o := range observations {
+		batch.Records[i] = stream.Record{Time: o.Time, Aircraft: o.Aircraft}
+	}
+	s.hub.Publish(batch)
+	return nil
+}
+
+func (s *streamSink) Close(_ context.Context) error {
+	return nil
+}
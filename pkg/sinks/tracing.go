@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxSpanLinks bounds the fetch cycles a flush span links to, as a backlog
+// built up during an outage can span many cycles
+const maxSpanLinks = 32
+
+var tracer = otel.Tracer("sinks")
+
+type payloadKey struct{}
+
+// startSpan starts a span for a sink operation, returning a context through
+// which the sink can report the size of what it sent with addPayloadBytes
+func startSpan(ctx context.Context, name, sink string, observations int, opts ...trace.SpanStartOption) (context.Context, *sinkSpan) {
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("sink.name", sink),
+		attribute.Int("sink.batch_size", observations),
+	))
+	ctx, span := tracer.Start(ctx, name, opts...)
+	s := &sinkSpan{span: span}
+	return context.WithValue(ctx, payloadKey{}, &s.bytes), s
+}
+
+// sinkSpan is a span for a sink operation that records the payload size on end
+type sinkSpan struct {
+	span  trace.Span
+	bytes atomic.Int64
+}
+
+// end records the outcome and ends the span
+func (s *sinkSpan) end(err error) {
+	if n := s.bytes.Load(); n > 0 {
+		s.span.SetAttributes(attribute.Int64("sink.bytes", n))
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// addPayloadBytes adds to the size of the payload sent in the current sink span
+func addPayloadBytes(ctx context.Context, n int) {
+	if counter, ok := ctx.Value(payloadKey{}).(*atomic.Int64); ok {
+		counter.Add(int64(n))
+	}
+}
+
+// fetchLinks returns links to the distinct fetch cycles observations came from
+func fetchLinks(observations []Observation) []trace.Link {
+	var links []trace.Link
+	seen := make(map[trace.SpanID]bool)
+	// Newest observations are at the end, so prefer their cycles when capping
+	for i := len(observations) - 1; i >= 0 && len(links) < maxSpanLinks; i-- {
+		sc := observations[i].fetchSpan
+		if !sc.IsValid() || seen[sc.SpanID()] {
+			continue
+		}
+		seen[sc.SpanID()] = true
+		links = append(links, trace.Link{SpanContext: sc})
+	}
+	return links
+}
@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/tracks"
+)
+
+// tracksSink records observations in the position history behind the
+// tracks API and the track summaries
+type tracksSink struct {
+	history       *tracks.History
+	stopSummaries func()
+}
+
+// newTracksFromEnv creates the tracks sink if the position history is enabled
+func newTracksFromEnv() (Sink, bool, error) {
+	history := tracks.Get()
+	if history == nil {
+		return nil, false, nil
+	}
+	return &tracksSink{history: history, stopSummaries: history.StartSummaries()}, true, nil
+}
+
+func (s *tracksSink) Name() string {
+	return "tracks"
+}
+
+func (s *tracksSink) Write(_ context.Context, observations []Observation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+
+	aircraft := make([]models.Aircraft, len(observations))
+	for i, o := range observations {
+		aircraft[i] = o.Aircraft
+	}
+	s.history.Add(observations[0].Time, aircraft)
+	return nil
+}
+
+func (s *tracksSink) Close(_ context.Context) error {
+	s.stopSummaries()
+	return nil
+}
@@ -0,0 +1,201 @@
+package stream
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+const (
+	defaultWindow     = 15 * time.Minute
+	defaultMaxRecords = 200000
+
+	// subscriberBuffer is the number of polls a consumer may lag behind
+	// before it is disconnected
+	subscriberBuffer = 16
+)
+
+// Record is a single aircraft observation in the live stream
+type Record struct {
+	Time     time.Time       `json:"time"`
+	Aircraft models.Aircraft `json:"aircraft"`
+}
+
+// Batch is the set of records from one poll
+// Time is when the batch was published, which the replay window is based on
+// so that a receiver clock that is off doesn't affect replays
+type Batch struct {
+	Time    time.Time
+	Records []Record
+}
+
+// Hub keeps a time-bounded trail of recent records and fans new batches out
+// to live subscribers, so consumers that attach late can replay recent
+// history before following the live stream
+type Hub struct {
+	window     time.Duration
+	maxRecords int
+
+	mu      sync.RWMutex
+	batches []Batch
+	count   int
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives batches published after it was created
+// C is closed when the subscriber falls too far behind or is cancelled
+type Subscription struct {
+	C <-chan Batch
+
+	c    chan Batch
+	hub  *Hub
+	once sync.Once
+}
+
+var (
+	defaultHub *Hub
+	once       sync.Once
+)
+
+// Get returns the hub if live consumers are enabled via API_ADDR, otherwise nil
+// The trail is configured via STREAM_BUFFER_WINDOW and STREAM_BUFFER_MAX_RECORDS
+func Get() *Hub {
+	once.Do(func() {
+		if os.Getenv("API_ADDR") == "" {
+			return
+		}
+		defaultHub = New(getEnvDuration("STREAM_BUFFER_WINDOW", defaultWindow), getEnvInt("STREAM_BUFFER_MAX_RECORDS", defaultMaxRecords))
+	})
+	return defaultHub
+}
+
+// New creates a hub keeping up to window of history, bounded to maxRecords
+func New(window time.Duration, maxRecords int) *Hub {
+	return &Hub{
+		window:     window,
+		maxRecords: maxRecords,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Window returns how much history the hub keeps
+func (h *Hub) Window() time.Duration {
+	return h.window
+}
+
+// Publish appends a poll's records to the trail and sends them to every subscriber
+func (h *Hub) Publish(batch Batch) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.batches = append(h.batches, batch)
+	h.count += len(batch.Records)
+	h.trim(batch.Time)
+
+	for sub := range h.subs {
+		select {
+		case sub.c <- batch:
+		default:
+			// Drop consumers that can't keep up rather than blocking the pipeline
+			delete(h.subs, sub)
+			sub.once.Do(func() { close(sub.c) })
+		}
+	}
+}
+
+// trim drops batches older than the window or beyond the record limit
+func (h *Hub) trim(now time.Time) {
+	cutoff := now.Add(-h.window)
+	drop := 0
+	for drop < len(h.batches)-1 {
+		b := h.batches[drop]
+		if !b.Time.Before(cutoff) && h.count <= h.maxRecords {
+			break
+		}
+		h.count -= len(b.Records)
+		drop++
+	}
+	if drop > 0 {
+		h.batches = append(h.batches[:0:0], h.batches[drop:]...)
+	}
+}
+
+// Latest returns the most recently published batch, if any
+func (h *Hub) Latest() (Batch, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.batches) == 0 {
+		return Batch{}, false
+	}
+	return h.batches[len(h.batches)-1], true
+}
+
+// Since returns the buffered batches published after t, oldest first
+func (h *Hub) Since(t time.Time) []Batch {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for i, b := range h.batches {
+		if b.Time.After(t) {
+			return append([]Batch(nil), h.batches[i:]...)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the batches buffered after since together with a
+// subscription to new batches. Taking both under one lock guarantees that no
+// batch is missed or delivered twice between the replay and the live stream.
+func (h *Hub) Subscribe(since time.Time) ([]Batch, *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Batch
+	for i, b := range h.batches {
+		if b.Time.After(since) {
+			replay = append([]Batch(nil), h.batches[i:]...)
+			break
+		}
+	}
+
+	c := make(chan Batch, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, hub: h}
+	h.subs[sub] = struct{}{}
+	return replay, sub
+}
+
+// Cancel stops the subscription and closes its channel
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subs, s)
+	s.once.Do(func() { close(s.c) })
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %q for %s, using default %s", value, key, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvInt returns a positive integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		log.Printf("Invalid integer %q for %s, using default %d", value, key, defaultValue)
+	}
+	return defaultValue
+}
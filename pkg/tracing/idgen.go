@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var (
+	customIDGenerator sdktrace.IDGenerator
+	idGeneratorMu     sync.RWMutex
+)
+
+// SetIDGenerator overrides the trace and span ID generator used by InitTracing
+// It must be called before InitTracing and takes precedence over OTEL_TRACES_ID_GENERATOR
+func SetIDGenerator(gen sdktrace.IDGenerator) {
+	idGeneratorMu.Lock()
+	defer idGeneratorMu.Unlock()
+	customIDGenerator = gen
+}
+
+// getIDGenerator returns the configured ID generator, or nil for the SDK's random default
+func getIDGenerator() sdktrace.IDGenerator {
+	idGeneratorMu.RLock()
+	gen := customIDGenerator
+	idGeneratorMu.RUnlock()
+	if gen != nil {
+		return gen
+	}
+
+	switch name := strings.ToLower(getEnv("OTEL_TRACES_ID_GENERATOR", "random")); name {
+	case "random":
+		return nil
+	case "timestamp", "xray":
+		return timestampIDGenerator{}
+	default:
+		log.Printf("Invalid ID generator %s, defaulting to random", name)
+		return nil
+	}
+}
+
+// timestampIDGenerator generates trace IDs whose first four bytes are the
+// current Unix time in seconds, followed by random bytes
+// This is the format AWS X-Ray requires and lets backends order traces by ID
+type timestampIDGenerator struct{}
+
+func (timestampIDGenerator) NewIDs(ctx context.Context) (oteltrace.TraceID, oteltrace.SpanID) {
+	var tid oteltrace.TraceID
+	binary.BigEndian.PutUint32(tid[:4], uint32(time.Now().Unix()))
+	randomBytes(tid[4:])
+	return tid, timestampIDGenerator{}.NewSpanID(ctx, tid)
+}
+
+func (timestampIDGenerator) NewSpanID(_ context.Context, _ oteltrace.TraceID) oteltrace.SpanID {
+	var sid oteltrace.SpanID
+	for !sid.IsValid() {
+		randomBytes(sid[:])
+	}
+	return sid
+}
+
+// randomBytes fills b from crypto/rand, which does not fail on supported platforms
+func randomBytes(b []byte) {
+	_, _ = rand.Read(b)
+}
@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// getPropagator returns the propagators listed in OTEL_PROPAGATORS
+// Supported are tracecontext, baggage, b3 (single header) and b3multi
+// (X-B3-* headers), or none; the default is tracecontext,baggage
+func getPropagator() propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range strings.Split(getEnv("OTEL_PROPAGATORS", "tracecontext,baggage"), ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "none", "":
+		default:
+			log.Printf("Unknown propagator %s, ignoring", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
@@ -3,17 +3,21 @@ package tracing
 import (
 	"context"
 	"log"
+	"net"
+	"net/http"
 	"os"
-	"runtime"
 	"strings"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+
+	"github.com/burnettdev/adsb2otel/pkg/dnscache"
+	"github.com/burnettdev/adsb2otel/pkg/otel/headers"
+	"github.com/burnettdev/adsb2otel/pkg/otel/resource"
 )
 
 func InitTracing() (func(), error) {
@@ -30,7 +34,10 @@ func InitTracing() (func(), error) {
 	insecure := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", "OTEL_EXPORTER_OTLP_TRACES_INSECURE", true)
 
 	// Parse headers if provided (shared first, then signal-specific)
-	headers := parseHeaders(getEnvWithFallback("OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS", ""))
+	otlpHeaders, err := headers.FromEnv("TRACES")
+	if err != nil {
+		return func() {}, err
+	}
 
 	// Determine protocol (http or grpc) - shared first, then signal-specific
 	protocol := strings.ToLower(getEnvWithFallback("OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http"))
@@ -40,7 +47,6 @@ func InitTracing() (func(), error) {
 	}
 
 	var exporter trace.SpanExporter
-	var err error
 
 	// Create exporter based on protocol
 	if protocol == "grpc" {
@@ -52,8 +58,14 @@ func InitTracing() (func(), error) {
 			opts = append(opts, otlptracegrpc.WithInsecure())
 		}
 
-		if len(headers) > 0 {
-			opts = append(opts, otlptracegrpc.WithHeaders(headers))
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return resolver.DialContext(ctx, "tcp", addr)
+			})))
 		}
 
 		exporter, err = otlptracegrpc.New(context.Background(), opts...)
@@ -66,8 +78,17 @@ func InitTracing() (func(), error) {
 			opts = append(opts, otlptracehttp.WithInsecure())
 		}
 
-		if len(headers) > 0 {
-			opts = append(opts, otlptracehttp.WithHeaders(headers))
+		if len(otlpHeaders) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(otlpHeaders))
+		}
+
+		if resolver := dnscache.Get(); resolver != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DialContext = resolver.DialContext
+			opts = append(opts, otlptracehttp.WithHTTPClient(&http.Client{
+				Transport: transport,
+				Timeout:   10 * time.Second,
+			}))
 		}
 
 		exporter, err = otlptracehttp.New(context.Background(), opts...)
@@ -78,38 +99,26 @@ func InitTracing() (func(), error) {
 		return func() {}, nil
 	}
 
-	// Create resource with Go-specific attributes
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			// Service identification
-			semconv.ServiceName("adsb2otel"),
-			semconv.ServiceVersion("1.0.0"),
-
-			// Process and runtime information
-			semconv.ProcessRuntimeName("go"),
-			semconv.ProcessRuntimeVersion(runtime.Version()),
-			semconv.ProcessRuntimeDescription("Go runtime"),
-			semconv.ProcessPID(os.Getpid()),
-
-			// Telemetry SDK information
-			semconv.TelemetrySDKName("opentelemetry"),
-			semconv.TelemetrySDKLanguageGo,
-			semconv.TelemetrySDKVersion("1.31.0"),
-		),
-	)
+	// Shared resource with service, runtime and detected environment attributes
+	res, err := resource.Get()
 	if err != nil {
 		return nil, err
 	}
 
-	// Create trace provider
-	tp := trace.NewTracerProvider(
+	providerOpts := []trace.TracerProviderOption{
 		trace.WithBatcher(exporter),
 		trace.WithResource(res),
-	)
+	}
+	if gen := getIDGenerator(); gen != nil {
+		providerOpts = append(providerOpts, trace.WithIDGenerator(gen))
+	}
+
+	// Create trace provider
+	tp := trace.NewTracerProvider(providerOpts...)
 
 	// Set global trace provider
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTextMapPropagator(getPropagator())
 
 	log.Printf("OpenTelemetry tracing initialized successfully (protocol: %s, endpoint: %s)", protocol, endpoint)
 
@@ -158,15 +167,15 @@ func cleanEndpoint(endpoint string) string {
 	endpoint = strings.TrimPrefix(endpoint, "https://")
 	// Remove grpc:// prefix if present
 	endpoint = strings.TrimPrefix(endpoint, "grpc://")
-	
+
 	// Remove /v1/traces suffix if present since WithEndpoint handles the path separately
 	if strings.HasSuffix(endpoint, "/v1/traces") {
 		endpoint = strings.TrimSuffix(endpoint, "/v1/traces")
 	}
-	
+
 	// Remove any trailing slashes
 	endpoint = strings.TrimSuffix(endpoint, "/")
-	
+
 	return endpoint
 }
 
@@ -188,20 +197,3 @@ func getEnvBool(primary, secondary string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
-
-// parseHeaders parses header string in format "key1=value1,key2=value2"
-func parseHeaders(headerStr string) map[string]string {
-	headers := make(map[string]string)
-	if headerStr == "" {
-		return headers
-	}
-
-	pairs := strings.Split(headerStr, ",")
-	for _, pair := range pairs {
-		if kv := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(kv) == 2 {
-			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-		}
-	}
-
-	return headers
-}
@@ -0,0 +1,201 @@
+package tracks
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"github.com/burnettdev/adsb2otel/pkg/geo"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// summaryEvent is the event emitted with the simplified path of an aircraft
+const summaryEvent = "aircraft.track"
+
+// StartSummaries emits, every TRACKS_SUMMARY_INTERVAL, an aircraft.track log
+// event per aircraft with the path it flew since the previous one,
+// simplified to within TRACKS_SIMPLIFY_NM
+// The returned function stops the summaries
+func (h *History) StartSummaries() func() {
+	interval := getEnvDuration("TRACKS_SUMMARY_INTERVAL", 0)
+	if interval == 0 {
+		return func() {}
+	}
+	tolerance := getEnvFloat("TRACKS_SIMPLIFY_NM", 0.1)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.summarize(context.Background(), tolerance)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	log.Printf("Track summaries enabled (interval: %s, tolerance: %gNM)", interval, tolerance)
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// summarize emits a summary of every aircraft that moved since the last one
+func (h *History) summarize(ctx context.Context, tolerance float64) {
+	logger := logs.GetLogger("tracks")
+	if logger == nil {
+		return
+	}
+
+	tracks := h.Unsummarized()
+	routes := routing.Get()
+	logging.Debug("Emitting track summaries", "aircraft", len(tracks))
+	for _, t := range tracks {
+		if !routes.Allows(routing.ClassSummary, routing.OTLP, routing.SummaryValues(t.Hex, t.Flight)) {
+			continue
+		}
+		first, last := t.Points[0], t.Points[len(t.Points)-1]
+		simplified := Simplify(t.Points, tolerance)
+
+		attrs := []otellog.KeyValue{
+			otellog.String("service", "adsb"),
+			otellog.String("aircraft.hex", t.Hex),
+			otellog.Int64("track.start_time", first.Time.Unix()),
+			otellog.Int64("track.end_time", last.Time.Unix()),
+			otellog.Int("track.points", len(t.Points)),
+			otellog.Int("track.simplified_points", len(simplified)),
+			otellog.Float64("track.distance_nm", math.Round(Distance(t.Points)*10)/10),
+			otellog.String("track.polyline", EncodePolyline(simplified)),
+		}
+		body := "Track of " + t.Hex
+		if t.Flight != "" {
+			attrs = append(attrs, otellog.String("aircraft.flight", t.Flight))
+			body = "Track of " + t.Flight
+		}
+
+		record := otellog.Record{}
+		record.SetEventName(summaryEvent)
+		record.SetTimestamp(last.Time)
+		record.SetObservedTimestamp(time.Now())
+		record.SetSeverity(otellog.SeverityInfo)
+		record.SetBody(otellog.StringValue(body))
+		record.AddAttributes(attrs...)
+		logger.Emit(ctx, record)
+	}
+}
+
+// Distance returns the length of the path through the points in nautical miles
+func Distance(points []Point) float64 {
+	var d float64
+	for i := 1; i < len(points); i++ {
+		d += geo.DistanceNM(position(points[i-1]), position(points[i]))
+	}
+	return d
+}
+
+// Simplify reduces the points of a path with the Ramer-Douglas-Peucker
+// algorithm, keeping every point that deviates more than toleranceNM from
+// the simplified path
+func Simplify(points []Point, toleranceNM float64) []Point {
+	if len(points) < 3 || toleranceNM <= 0 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	simplify(points, 0, len(points)-1, toleranceNM, keep)
+
+	var simplified []Point
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+func simplify(points []Point, first, last int, tolerance float64, keep []bool) {
+	furthest, index := 0.0, -1
+	for i := first + 1; i < last; i++ {
+		if d := crossTrackNM(points[i], points[first], points[last]); d > furthest {
+			furthest, index = d, i
+		}
+	}
+	if index < 0 || furthest <= tolerance {
+		return
+	}
+	keep[index] = true
+	simplify(points, first, index, tolerance, keep)
+	simplify(points, index, last, tolerance, keep)
+}
+
+// crossTrackNM returns the distance of p from the segment a-b, on a plane
+// projected around a, which is accurate enough for the short segments of a track
+func crossTrackNM(p, a, b Point) float64 {
+	scale := math.Cos(a.Lat * math.Pi / 180)
+	bx, by := (b.Lon-a.Lon)*scale*60, (b.Lat-a.Lat)*60
+	px, py := (p.Lon-a.Lon)*scale*60, (p.Lat-a.Lat)*60
+
+	length := bx*bx + by*by
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/length))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+// EncodePolyline encodes the points in the Encoded Polyline Algorithm Format
+// with a precision of 5 decimals, as read by most mapping libraries
+func EncodePolyline(points []Point) string {
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * 1e5))
+		lon := int64(math.Round(p.Lon * 1e5))
+		encodeValue(&b, lat-prevLat)
+		encodeValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+func position(p Point) geo.Position {
+	return geo.Position{Lat: p.Lat, Lon: p.Lon}
+}
+
+// getEnvFloat returns a non-negative float environment variable or the default
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 {
+			return f
+		}
+		log.Printf("Invalid number %q for %s, using default %g", value, key, defaultValue)
+	}
+	return defaultValue
+}
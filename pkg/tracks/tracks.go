@@ -0,0 +1,221 @@
+// Package tracks keeps a short in-memory history of the positions of each
+// aircraft, for reviewing what an aircraft did without a database
+package tracks
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+)
+
+const defaultMaxPoints = 1000
+
+// Point is a position of an aircraft at a time
+// Altitude is the barometric altitude in feet, nil if unknown or on the ground
+type Point struct {
+	Time     time.Time `json:"time"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Altitude *int      `json:"alt_baro,omitempty"`
+	OnGround bool      `json:"on_ground,omitempty"`
+	Gs       *float64  `json:"gs,omitempty"`
+	Track    *float64  `json:"track,omitempty"`
+}
+
+// Track is the recorded history of an aircraft, oldest point first
+type Track struct {
+	Hex    string  `json:"hex"`
+	Flight string  `json:"flight,omitempty"`
+	Points []Point `json:"points"`
+}
+
+// ring is the history of one aircraft, keeping the most recent points up to
+// the history's limit in a ring buffer that grows as points are added
+type ring struct {
+	flight string
+	points []Point
+	// start is the index of the oldest point once the buffer is full
+	start int
+	// summarized is the time of the last point included in a summary
+	summarized time.Time
+}
+
+func (r *ring) push(p Point, max int) {
+	if len(r.points) < max {
+		r.points = append(r.points, p)
+		return
+	}
+	r.points[r.start] = p
+	r.start = (r.start + 1) % max
+}
+
+// last returns the most recent point
+func (r *ring) last() Point {
+	if r.start == 0 {
+		return r.points[len(r.points)-1]
+	}
+	return r.points[r.start-1]
+}
+
+// since returns the points after t, oldest first
+func (r *ring) since(t time.Time) []Point {
+	var points []Point
+	for i := range r.points {
+		if p := r.points[(r.start+i)%len(r.points)]; p.Time.After(t) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// History keeps the positions of each aircraft seen within a time window,
+// up to a number of points per aircraft
+type History struct {
+	window    time.Duration
+	maxPoints int
+
+	mu     sync.RWMutex
+	tracks map[string]*ring
+}
+
+var (
+	defaultHistory *History
+	once           sync.Once
+)
+
+// Get returns the history if enabled via TRACKS_WINDOW, otherwise nil
+// TRACKS_MAX_POINTS bounds the points kept per aircraft
+func Get() *History {
+	once.Do(func() {
+		if os.Getenv("TRACKS_WINDOW") == "" {
+			return
+		}
+		defaultHistory = New(getEnvDuration("TRACKS_WINDOW", time.Hour), getEnvInt("TRACKS_MAX_POINTS", defaultMaxPoints))
+	})
+	return defaultHistory
+}
+
+// New creates a history keeping window of positions, up to maxPoints per aircraft
+func New(window time.Duration, maxPoints int) *History {
+	return &History{
+		window:    window,
+		maxPoints: maxPoints,
+		tracks:    make(map[string]*ring),
+	}
+}
+
+// Window returns how much history is kept
+func (h *History) Window() time.Duration {
+	return h.window
+}
+
+// Add records the positions of a poll's aircraft observed at t and forgets
+// aircraft whose last position is older than the window
+// Positions repeated while an aircraft sends no new ones are skipped
+func (h *History) Add(t time.Time, aircraft []models.Aircraft) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range aircraft {
+		a := &aircraft[i]
+		pos, ok := a.Position()
+		if !ok {
+			continue
+		}
+		p := Point{Time: t, Lat: pos.Lat, Lon: pos.Lon, OnGround: a.OnGround(), Gs: a.Gs, Track: a.Track}
+		if a.SeenPos != nil {
+			p.Time = t.Add(-time.Duration(*a.SeenPos * float64(time.Second)))
+		}
+		if alt, ok := a.AltitudeFeet(); ok && !p.OnGround {
+			p.Altitude = &alt
+		}
+
+		hex := strings.ToLower(a.Hex)
+		r, ok := h.tracks[hex]
+		if !ok {
+			r = &ring{}
+			h.tracks[hex] = r
+		} else if last := r.last(); last.Lat == p.Lat && last.Lon == p.Lon {
+			continue
+		}
+		if flight := strings.TrimSpace(a.Flight); flight != "" {
+			r.flight = flight
+		}
+		r.push(p, h.maxPoints)
+	}
+
+	cutoff := t.Add(-h.window)
+	for hex, r := range h.tracks {
+		if !r.last().Time.After(cutoff) {
+			delete(h.tracks, hex)
+		}
+	}
+}
+
+// Track returns the positions of an aircraft over the last window (at most
+// the history's window) up to its latest position, or false if none are recorded
+// The window is based on the receiver's timestamps so a receiver clock that
+// is off doesn't hide a track
+func (h *History) Track(hex string, window time.Duration) (Track, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	hex = strings.ToLower(hex)
+	r, ok := h.tracks[hex]
+	if !ok {
+		return Track{}, false
+	}
+	// The latest point is after the cutoff, so at least it is returned
+	points := r.since(r.last().Time.Add(-min(window, h.window)))
+	return Track{Hex: hex, Flight: r.flight, Points: points}, true
+}
+
+// Unsummarized returns, for every aircraft that moved since its last
+// summary, the points recorded since and marks them as summarized
+func (h *History) Unsummarized() []Track {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var tracks []Track
+	for hex, r := range h.tracks {
+		// Start from the last point of the previous summary, so that
+		// consecutive summaries join up
+		points, moved := r.since(r.summarized), 1
+		if !r.summarized.IsZero() {
+			points, moved = r.since(r.summarized.Add(-time.Nanosecond)), 2
+		}
+		if len(points) < moved {
+			continue
+		}
+		r.summarized = points[len(points)-1].Time
+		tracks = append(tracks, Track{Hex: hex, Flight: r.flight, Points: points})
+	}
+	return tracks
+}
+
+// getEnvDuration returns a positive duration environment variable or the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration %q for %s, using default %s", value, key, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvInt returns a positive integer environment variable or the default
+func getEnvInt(key string, defaultValue int) int {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			return i
+		}
+		log.Printf("Invalid integer %q for %s, using default %d", value, key, defaultValue)
+	}
+	return defaultValue
+}
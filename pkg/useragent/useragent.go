@@ -0,0 +1,70 @@
+// Package useragent identifies the service on outgoing requests, so they
+// can be told apart and correlated in the access logs of receivers and APIs
+package useragent
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/burnettdev/adsb2otel/pkg/otel/resource"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// defaultTemplate is the User-Agent sent unless HTTP_USER_AGENT is set
+const defaultTemplate = "adsb2otel/{version} (instance {instance})"
+
+// requestIDHeader is the header each request is identified by, if enabled
+const requestIDHeader = "X-Request-ID"
+
+var (
+	userAgent string
+	requestID bool
+	once      sync.Once
+)
+
+func load() {
+	once.Do(func() {
+		template := strings.TrimSpace(os.Getenv("HTTP_USER_AGENT"))
+		if template == "" {
+			template = defaultTemplate
+		}
+		userAgent = strings.NewReplacer(
+			"{version}", version.Get(),
+			"{instance}", resource.InstanceID(),
+		).Replace(template)
+		requestID = isTrue(os.Getenv("HTTP_REQUEST_ID"))
+	})
+}
+
+// Get returns the User-Agent sent on outgoing requests: HTTP_USER_AGENT, in
+// which {version} and {instance} are replaced with the build version and the
+// service instance ID, or adsb2otel/<version> (instance <id>)
+func Get() string {
+	load()
+	return userAgent
+}
+
+// Set sets the User-Agent of an outgoing request and, with HTTP_REQUEST_ID
+// enabled, a new X-Request-ID, which is recorded on the span of the
+// request's context so a request in a receiver's access log leads to its trace
+func Set(req *http.Request) {
+	load()
+	req.Header.Set("User-Agent", userAgent)
+	if !requestID {
+		return
+	}
+	id := uuid.NewString()
+	req.Header.Set(requestIDHeader, id)
+	trace.SpanFromContext(req.Context()).SetAttributes(attribute.StringSlice("http.request.header.x-request-id", []string{id}))
+}
+
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}
@@ -0,0 +1,92 @@
+package version
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Version is set at build time, e.g.
+// go build -ldflags "-X github.com/burnettdev/adsb2otel/pkg/version.Version=v1.2.0"
+var Version string
+
+// Commit and BuildDate are set at build time like Version, e.g.
+// -X github.com/burnettdev/adsb2otel/pkg/version.Commit=$(git rev-parse HEAD)
+// -X github.com/burnettdev/adsb2otel/pkg/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+var (
+	Commit    string
+	BuildDate string
+)
+
+var (
+	resolved     string
+	revision     string
+	buildDate    string
+	resolvedOnce sync.Once
+)
+
+// resolve fills in whatever was not set via ldflags from the build
+// information recorded by the Go toolchain
+func resolve() {
+	resolvedOnce.Do(func() {
+		resolved, revision, buildDate = Version, Commit, BuildDate
+
+		var vcsRevision, vcsTime, modified, moduleVersion string
+		if info, ok := debug.ReadBuildInfo(); ok {
+			moduleVersion = info.Main.Version
+			for _, setting := range info.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					vcsRevision = setting.Value
+				case "vcs.time":
+					vcsTime = setting.Value
+				case "vcs.modified":
+					modified = setting.Value
+				}
+			}
+		}
+		if revision == "" {
+			revision = vcsRevision
+		}
+		if buildDate == "" {
+			// The commit time is the closest the toolchain records
+			buildDate = vcsTime
+		}
+
+		if resolved != "" {
+			return
+		}
+		resolved = "dev"
+		if moduleVersion != "" && moduleVersion != "(devel)" {
+			resolved = moduleVersion
+			return
+		}
+		if vcsRevision != "" {
+			resolved = "dev-" + vcsRevision[:min(len(vcsRevision), 12)]
+			if modified == "true" {
+				resolved += "-dirty"
+			}
+		}
+	})
+}
+
+// Get returns the build version: the ldflags value if set, otherwise the module
+// version or VCS revision recorded by the Go toolchain, falling back to "dev"
+func Get() string {
+	resolve()
+	return resolved
+}
+
+// Revision returns the commit the binary was built from: the ldflags value
+// if set, otherwise the VCS revision recorded by the Go toolchain, or "" if
+// it is unknown
+func Revision() string {
+	resolve()
+	return revision
+}
+
+// Date returns when the binary was built: the ldflags value if set,
+// otherwise the time of the commit it was built from, or "" if it is unknown
+func Date() string {
+	resolve()
+	return buildDate
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/enrich"
+	"github.com/burnettdev/adsb2otel/pkg/flightdata"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/otel/metrics"
+	"github.com/burnettdev/adsb2otel/pkg/sinks"
+	"github.com/burnettdev/adsb2otel/pkg/tracing"
+)
+
+// compactFields are the aircraft fields of the arrays tar1090 writes to its
+// history chunks in place of aircraft objects, in order
+var compactFields = []string{"hex", "alt_baro", "gs", "track", "lat", "lon", "seen_pos", "type", "flight", "messages"}
+
+// runReplayCommand replays recorded aircraft.json captures through the
+// pipeline with their original timestamps, exporting them like live polls,
+// to backfill backends or test dashboards without a receiver
+func runReplayCommand(envErr error, args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 1, "replay speed relative to the recording, 0 to replay as fast as possible")
+	maxGap := fs.Duration("max-gap", time.Minute, "longest pause between captures, gaps in the recording are shortened to it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || *speed < 0 {
+		fmt.Fprintln(os.Stderr, "usage: adsb2otel replay [-speed 1] [-max-gap 1m] <file|directory>...")
+		return 2
+	}
+
+	logging.Init()
+	if envErr != nil {
+		logging.Debug("Environment file not found (this is normal in production)", "error", envErr)
+	}
+	applyLowResource()
+
+	// Live positions from other feeds don't belong in a recording
+	for _, key := range []string{"MLAT_DATA_URL", "SATELLITE_DATA_URL", "RECEIVERS", "REMOTEID_URL"} {
+		if os.Getenv(key) != "" {
+			logging.Info("Not merging live data into the replay", "key", key)
+			os.Unsetenv(key)
+		}
+	}
+
+	shutdownTracing, err := tracing.InitTracing()
+	if err != nil {
+		logging.Error("Failed to initialize OpenTelemetry tracing", "error", err)
+	}
+	defer shutdownTracing()
+	shutdownLogs, err := logs.InitLogs()
+	if err != nil {
+		logging.Error("Failed to initialize OpenTelemetry logging", "error", err)
+	}
+	defer shutdownLogs()
+	shutdownMetrics, err := metrics.InitMetrics()
+	if err != nil {
+		logging.Error("Failed to initialize OpenTelemetry metrics", "error", err)
+	}
+	defer shutdownMetrics()
+	shutdownSinks, err := sinks.InitSinks()
+	if err != nil {
+		logging.Error("Failed to initialize sinks", "error", err)
+	}
+	defer shutdownSinks()
+	shutdownRoutes, err := enrich.InitRoutes()
+	if err != nil {
+		logging.Error("Failed to initialize route lookups", "error", err)
+	}
+	defer shutdownRoutes()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var snapshots, failed int
+	var first, last float64
+	replay := func(doc *models.Dump1090fa) error {
+		if snapshots > 0 && *speed > 0 {
+			wait := time.Duration((doc.Now - last) * float64(time.Second) / *speed)
+			select {
+			case <-time.After(min(wait, *maxGap)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if snapshots == 0 {
+			first = doc.Now
+		}
+		last = doc.Now
+		snapshots++
+		if err := flightdata.Push(ctx, doc); err != nil {
+			failed++
+			logging.Error("Failed to replay capture", "timestamp", time.Unix(int64(doc.Now), 0).UTC(), "error", err)
+		}
+		return nil
+	}
+
+	for _, root := range fs.Args() {
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if err := readCapture(path, replay); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				logging.Warn("Skipping unreadable capture", "path", path, "error", err)
+			}
+			return nil
+		})
+		if errors.Is(err, context.Canceled) {
+			logging.Info("Replay interrupted")
+			break
+		} else if err != nil {
+			logging.Error("Failed to read captures", "path", root, "error", err)
+			return 1
+		}
+	}
+
+	if snapshots == 0 {
+		logging.Error("No captures found to replay", "paths", fs.Args())
+		return 1
+	}
+	logging.Info("Replay completed", "snapshots", snapshots, "failed", failed,
+		"from", time.Unix(int64(first), 0).UTC(), "to", time.Unix(int64(last), 0).UTC())
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readCapture calls fn for each snapshot in a capture file, which may be
+// gzipped and hold aircraft.json documents as read by readSnapshots, or be a
+// tar1090 history chunk, stopping at the first error fn returns
+func readCapture(path string, fn func(*models.Dump1090fa) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return readCaptureData(data, fn)
+}
+
+// readCaptureData is readCapture for a capture already read into memory
+func readCaptureData(data []byte, fn func(*models.Dump1090fa) error) error {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+	}
+
+	var chunk struct {
+		Files []json.RawMessage `json:"files"`
+	}
+	if json.Unmarshal(data, &chunk) != nil || chunk.Files == nil {
+		var fnErr error
+		_, err := readSnapshots(bytes.NewReader(data), func(doc *models.Dump1090fa) {
+			if fnErr == nil {
+				fnErr = fn(doc)
+			}
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		return err
+	}
+
+	for i, file := range chunk.Files {
+		doc, err := decodeChunkFile(file)
+		if err != nil {
+			return fmt.Errorf("file %d: %w", i+1, err)
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeChunkFile decodes a snapshot of a tar1090 history chunk, whose
+// aircraft are objects or compact arrays of compactFields
+func decodeChunkFile(raw json.RawMessage) (*models.Dump1090fa, error) {
+	var file struct {
+		Now      float64           `json:"now"`
+		Messages int               `json:"messages"`
+		Aircraft []json.RawMessage `json:"aircraft"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, err
+	}
+
+	doc := &models.Dump1090fa{Now: file.Now, Messages: file.Messages, Aircraft: make([]models.Aircraft, 0, len(file.Aircraft))}
+	for _, entry := range file.Aircraft {
+		if entry = bytes.TrimSpace(entry); len(entry) > 0 && entry[0] == '[' {
+			var values []any
+			if err := json.Unmarshal(entry, &values); err != nil {
+				return nil, err
+			}
+			fields := make(map[string]any, len(compactFields))
+			for i, value := range values {
+				if i < len(compactFields) && value != nil {
+					fields[compactFields[i]] = value
+				}
+			}
+			var err error
+			if entry, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+		var aircraft models.Aircraft
+		if err := json.Unmarshal(entry, &aircraft); err != nil {
+			return nil, err
+		}
+		doc.Aircraft = append(doc.Aircraft, aircraft)
+	}
+	return doc, nil
+}
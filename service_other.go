@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// runService reports false, as the service only runs under a service
+// manager on Windows; elsewhere systemd and Docker run it as a process
+func runService(envErr error) (int, bool) {
+	return 0, false
+}
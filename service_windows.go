@@ -0,0 +1,116 @@
+//go:build windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+)
+
+// serviceName is the event log source, and the name the service is
+// expected to be installed under
+const serviceName = "adsb2otel"
+
+// Event IDs of the messages written to the event log
+const (
+	eventInfo = iota + 1
+	eventWarning
+	eventError
+)
+
+// runService runs the service under the Windows service control manager,
+// reporting false if the process was not started by it
+func runService(envErr error) (int, bool) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return 0, false
+	}
+
+	// Services start in the system directory, so look for .env next to the executable
+	if envErr != nil {
+		if exe, err := os.Executable(); err == nil {
+			envErr = godotenv.Load(filepath.Join(filepath.Dir(exe), ".env"))
+		}
+	}
+
+	// Register the event source, which only succeeds the first time
+	_ = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if elog, err := eventlog.Open(serviceName); err == nil {
+		defer elog.Close()
+		logging.SetOutput(eventLogWriter{elog})
+	}
+
+	s := &windowsService{envErr: envErr}
+	if err := svc.Run(serviceName, s); err != nil {
+		return 1, true
+	}
+	return s.code, true
+}
+
+// windowsService runs the fetch loop until the service control manager
+// stops it or the system shuts down
+type windowsService struct {
+	envErr error
+	code   int
+}
+
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan int, 1)
+	go func() {
+		done <- run(ctx, s.envErr, false)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case s.code = <-done:
+			// The service stopped by itself, e.g. because another instance holds the lock
+			return s.code != 0, uint32(s.code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				s.code = <-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogWriter writes log lines to the Windows event log, as warnings or
+// errors by their level
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSpace(p))
+	var err error
+	switch {
+	case bytes.Contains(p, []byte("level=ERROR")):
+		err = w.log.Error(eventError, msg)
+	case bytes.Contains(p, []byte("level=WARN")):
+		err = w.log.Warning(eventWarning, msg)
+	default:
+		err = w.log.Info(eventInfo, msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
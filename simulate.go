@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/burnettdev/adsb2otel/pkg/flightdata"
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+// ruleStats counts how often a rule fired and how many observations it matched
+type ruleStats struct {
+	fired        int
+	observations int
+}
+
+// simulation replays recorded snapshots through the configured rules
+// A rule fires for an aircraft when it starts matching it, and again only
+// after it has stopped matching in between
+type simulation struct {
+	rules  *routing.Rules
+	out    io.Writer
+	active map[string]bool
+	stats  map[string]*ruleStats
+}
+
+// newSimulation returns a simulation of the given rules writing to out
+func newSimulation(rules *routing.Rules, out io.Writer) *simulation {
+	return &simulation{
+		rules:  rules,
+		out:    out,
+		active: make(map[string]bool),
+		stats:  make(map[string]*ruleStats),
+	}
+}
+
+// runSimulateRulesCommand evaluates the configured alert, filter, mute and
+// routing rules against recorded aircraft.json snapshots and reports which
+// rules would have fired when, without exporting anything
+// The receiver health alerts are not simulated, as recordings don't hold the
+// receiver's stats.json
+func runSimulateRulesCommand(args []string) int {
+	fs := flag.NewFlagSet("simulate-rules", flag.ContinueOnError)
+	input := fs.String("input", "", "recorded aircraft.json snapshots, optionally gzipped: a document, an array of documents, one document per line or a tar1090 history chunk (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "usage: adsb2otel simulate-rules --input recorded.json")
+		fmt.Fprintln(os.Stderr, "Simulated: the emergency and rapid descent alerts, SINK_ROUTES conditions, sink filters and muted sectors")
+		fmt.Fprintln(os.Stderr, "Not simulated: the receiver health alerts, which need the receiver's stats.json")
+		return 2
+	}
+
+	problems := checkRules()
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "config: %s\n", problem)
+	}
+	if len(problems) > 0 {
+		return 2
+	}
+
+	var data []byte
+	var err error
+	if *input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read input: %v\n", err)
+		return 1
+	}
+
+	sim := newSimulation(routing.Get(), os.Stdout)
+	snapshots := 0
+	err = readCaptureData(data, func(doc *models.Dump1090fa) error {
+		sim.evaluate(doc)
+		snapshots++
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read snapshots: %v\n", err)
+		return 1
+	}
+	sim.summary(snapshots)
+	return 0
+}
+
+// checkRules returns problems with the rule configuration
+func checkRules() []string {
+	var problems []string
+	if _, err := routing.ParseRules(os.Getenv("SINK_ROUTES")); err != nil {
+		problems = append(problems, fmt.Sprintf("SINK_ROUTES: %v", err))
+	}
+	_, filterErrs := routing.FiltersFromEnv()
+	for _, err := range filterErrs {
+		problems = append(problems, err.Error())
+	}
+	if _, err := routing.ParseSectors(os.Getenv("MUTED_SECTORS")); err != nil {
+		problems = append(problems, fmt.Sprintf("MUTED_SECTORS: %v", err))
+	}
+	return problems
+}
+
+// readSnapshots decodes aircraft.json documents from r, calling fn for each
+// Each document is decoded like a poll, so aggregator "ac" arrays and the
+// FLIGHT_DATA_SCHEMA mode apply. Returns the number of documents read
+func readSnapshots(r io.Reader, fn func(*models.Dump1090fa)) (int, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, fmt.Errorf("empty input")
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		br.ReadByte()
+	}
+
+	dec := json.NewDecoder(br)
+	if b, _ := br.Peek(1); b[0] == '[' {
+		var docs []json.RawMessage
+		if err := dec.Decode(&docs); err != nil {
+			return 0, err
+		}
+		for i, raw := range docs {
+			doc, err := flightdata.DecodeDocument(bytes.NewReader(raw))
+			if err != nil {
+				return i, fmt.Errorf("document %d: %w", i+1, err)
+			}
+			fn(doc)
+		}
+		return len(docs), nil
+	}
+
+	n := 0
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("document %d: %w", n+1, err)
+		}
+		doc, err := flightdata.DecodeDocument(bytes.NewReader(raw))
+		if err != nil {
+			return n, fmt.Errorf("document %d: %w", n+1, err)
+		}
+		fn(doc)
+		n++
+	}
+}
+
+// evaluate runs the rules against every aircraft of a snapshot
+func (s *simulation) evaluate(doc *models.Dump1090fa) {
+	ts := time.Unix(int64(doc.Now), 0).UTC()
+	matched := make(map[string]bool)
+
+	for i := range doc.Aircraft {
+		a := &doc.Aircraft[i]
+		for _, rule := range s.match(a) {
+			key := rule.name + "\x00" + a.Hex
+			matched[key] = true
+			stats := s.stat(rule.name)
+			stats.observations++
+			if !s.active[key] {
+				stats.fired++
+				fmt.Fprintf(s.out, "%s  %-28s %-7s %-8s %s\n", ts.Format(time.RFC3339), rule.name, a.Hex, strings.TrimSpace(a.Flight), rule.detail)
+			}
+		}
+	}
+
+	// Rules that no longer match an aircraft can fire again later
+	s.active = matched
+}
+
+type ruleMatch struct {
+	name   string
+	detail string
+}
+
+// match returns the rules an aircraft matches
+func (s *simulation) match(a *models.Aircraft) []ruleMatch {
+	var matches []ruleMatch
+
+	if sector, ok := s.rules.MutedBy(a); ok {
+		// Muted aircraft are sent nowhere, so no other rule applies
+		return []ruleMatch{{name: "mute:" + sector.String(), detail: "muted"}}
+	}
+
+	class := routing.Classify(a)
+	values := routing.ValuesOf(a)
+	if class == routing.ClassEmergency && s.rules.Allows(class, "alertmanager", values) && s.rules.Match("alertmanager", values) {
+		reason := a.Emergency
+		if reason == "" || reason == "none" {
+			reason = "squawk " + a.Squawk
+		}
+		matches = append(matches, ruleMatch{name: "alert:emergency", detail: reason})
+	}
+
+	if rate, altitude, ok := flightdata.RapidDescent(a); ok {
+		matches = append(matches, ruleMatch{name: "alert:rapid_descent", detail: fmt.Sprintf("%d ft/min at %d ft", rate, altitude)})
+	}
+	if rule, ok := s.rules.ConditionalRoute(class, values); ok {
+		matches = append(matches, ruleMatch{name: "route:" + string(class), detail: rule})
+	}
+
+	filters := s.rules.Filters()
+	sinks := make([]string, 0, len(filters))
+	for sink := range filters {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+	for _, sink := range sinks {
+		if s.rules.Allows(class, sink, values) && filters[sink].Match(values) {
+			matches = append(matches, ruleMatch{name: "filter:" + sink, detail: filters[sink].String()})
+		}
+	}
+	return matches
+}
+
+func (s *simulation) stat(rule string) *ruleStats {
+	stats, ok := s.stats[rule]
+	if !ok {
+		stats = &ruleStats{}
+		s.stats[rule] = stats
+	}
+	return stats
+}
+
+// summary prints how often each rule fired
+func (s *simulation) summary(snapshots int) {
+	fmt.Fprintf(s.out, "\n%d snapshots evaluated\n", snapshots)
+	if len(s.stats) == 0 {
+		fmt.Fprintln(s.out, "No rules fired")
+		return
+	}
+
+	rules := make([]string, 0, len(s.stats))
+	for rule := range s.stats {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tFIRED\tOBSERVATIONS")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", rule, s.stats[rule].fired, s.stats[rule].observations)
+	}
+	w.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/burnettdev/adsb2otel/pkg/models"
+	"github.com/burnettdev/adsb2otel/pkg/routing"
+)
+
+func TestSimulateRules(t *testing.T) {
+	t.Setenv("ANOMALY_DESCENT_RATE", "3000")
+
+	rules, err := routing.ParseRules("emergency:*;position(distance_nm < 50):nats;*:otlp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gzipped like a recording, one document per line, with an aggregator "ac" array
+	var capture bytes.Buffer
+	zw := gzip.NewWriter(&capture)
+	for _, doc := range []string{
+		`{"now":1700000000,"ac":[{"hex":"4ca7b4","squawk":"7700"},{"hex":"3c6444","alt_baro":3000,"baro_rate":-4000,"lat":53.4,"lon":-6.2,"r_dst":20}]}`,
+		`{"now":1700000005,"ac":[{"hex":"4ca7b4","squawk":"7700"},{"hex":"3c6444","alt_baro":2700,"baro_rate":-3800,"lat":53.4,"lon":-6.2,"r_dst":20}]}`,
+		`{"now":1700000010,"ac":[{"hex":"4ca7b4","squawk":"1200"},{"hex":"3c6444","alt_baro":2600,"baro_rate":-500,"lat":53.4,"lon":-6.2,"r_dst":20}]}`,
+		`{"now":1700000015,"ac":[{"hex":"4ca7b4","squawk":"7700"}]}`,
+	} {
+		zw.Write([]byte(doc + "\n"))
+	}
+	zw.Close()
+
+	var out bytes.Buffer
+	sim := newSimulation(rules, &out)
+	snapshots := 0
+	err = readCaptureData(capture.Bytes(), func(doc *models.Dump1090fa) error {
+		sim.evaluate(doc)
+		snapshots++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshots != 4 {
+		t.Fatalf("%d snapshots read, want 4", snapshots)
+	}
+
+	want := map[string]ruleStats{
+		// Fires again once the emergency ended in between
+		"alert:emergency":     {fired: 2, observations: 3},
+		"alert:rapid_descent": {fired: 1, observations: 2},
+		"route:position":      {fired: 1, observations: 3},
+	}
+	for rule, stats := range want {
+		if got := sim.stats[rule]; got == nil || *got != stats {
+			t.Errorf("%s: %+v, want %+v", rule, got, stats)
+		}
+	}
+	if len(sim.stats) != len(want) {
+		t.Errorf("rules fired: %v", sim.stats)
+	}
+	if !strings.Contains(out.String(), "-4000 ft/min at 3000 ft") || !strings.Contains(out.String(), "position(distance_nm < 50):nats") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+
+	"github.com/burnettdev/adsb2otel/pkg/config"
+	"github.com/burnettdev/adsb2otel/pkg/flightdata"
+	"github.com/burnettdev/adsb2otel/pkg/logging"
+	"github.com/burnettdev/adsb2otel/pkg/otel/logs"
+	"github.com/burnettdev/adsb2otel/pkg/version"
+)
+
+// resolvedEndpoints are the variables holding URLs or endpoints of services
+// whose host names are resolved at startup
+var resolvedEndpoints = []string{
+	"SATELLITE_DATA_URL", "MLAT_DATA_URL", "STATS_URL",
+	"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT",
+	"INFLUXDB_URL", "NATS_URL", "ALERTMANAGER_URL", "LOKI_URL", "ROUTES_API_URL", "METAR_URL",
+}
+
+// errSkipped is returned by startup checks that don't apply to the configuration
+var errSkipped = errors.New("skipped")
+
+// startupReport is what was found about the configuration at startup
+type startupReport struct {
+	// checks maps each check run to its outcome, "ok", "skipped" or the problem found
+	checks map[string]string
+	// problems lists the configuration problems and failed checks
+	problems []string
+}
+
+// checkStartup validates the configuration and, unless STARTUP_CHECKS is
+// false, runs the checks within STARTUP_CHECK_TIMEOUT
+func checkStartup(ctx context.Context) startupReport {
+	if value := os.Getenv("STARTUP_CHECKS"); value != "" && !isTrue(value) {
+		return startupReport{checks: make(map[string]string), problems: checkConfig()}
+	}
+
+	timeout := 5 * time.Second
+	if value := os.Getenv("STARTUP_CHECK_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			timeout = d
+		} else {
+			logging.Warn("Invalid STARTUP_CHECK_TIMEOUT, using default", "value", value, "default", timeout)
+		}
+	}
+	return runChecks(ctx, timeout)
+}
+
+// runChecks validates the configuration and checks that the receiver
+// answers, the endpoints configured resolve and the OTLP endpoint accepts an
+// export, all within timeout
+func runChecks(ctx context.Context, timeout time.Duration) startupReport {
+	report := startupReport{checks: make(map[string]string), problems: checkConfig()}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	check := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fn()
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, errSkipped):
+				report.checks[name] = "skipped"
+			case err != nil:
+				report.checks[name] = err.Error()
+				report.problems = append(report.problems, fmt.Sprintf("%s: %v", name, err))
+			default:
+				report.checks[name] = "ok"
+			}
+		}()
+	}
+
+	if target := os.Getenv("FLIGHT_DATA_URL"); target != "" {
+		check("receiver", func() error {
+			_, err := flightdata.Probe(ctx, target)
+			return err
+		})
+	}
+	check("otlp_logs", func() error {
+		checked, err := logs.CheckExport(ctx)
+		switch {
+		case !checked:
+			return errSkipped
+		case err != nil:
+			return fmt.Errorf("export refused: %w", err)
+		}
+		return nil
+	})
+	for _, key := range resolvedEndpoints {
+		host := endpointHost(os.Getenv(key))
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		check("resolve."+key, func() error {
+			_, err := net.DefaultResolver.LookupHost(ctx, host)
+			return err
+		})
+	}
+	wg.Wait()
+
+	sort.Strings(report.problems)
+	return report
+}
+
+// endpointHost returns the host name of a URL, or of an endpoint given as
+// host:port as OTLP endpoints can be
+func endpointHost(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if !strings.Contains(value, "://") {
+		value = "//" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// emitStartup checks the configuration and logs a single startup report
+// with the build, the optional features enabled, the outcome of the checks
+// and the problems found, and emits it as a service.started event along with
+// the effective configuration, so what a feeder runs and what is wrong with
+// it can be told from its telemetry
+func emitStartup(ctx context.Context) {
+	features := config.Features()
+	report := checkStartup(ctx)
+
+	checks := make([]string, 0, len(report.checks))
+	for name, outcome := range report.checks {
+		checks = append(checks, name+"="+outcome)
+	}
+	sort.Strings(checks)
+	args := []interface{}{
+		"version", version.Get(), "revision", version.Revision(), "build_date", version.Date(),
+		"features", strings.Join(features, ","), "checks", strings.Join(checks, ", "),
+	}
+	severity := otellog.SeverityInfo
+	if len(report.problems) > 0 {
+		severity = otellog.SeverityWarn
+		logging.Warn("Startup report", append(args, "problems", strings.Join(report.problems, "; "))...)
+	} else {
+		logging.Info("Startup report", args...)
+	}
+
+	logger := logs.GetLogger("service")
+	if logger == nil {
+		return
+	}
+	values := make([]otellog.Value, len(features))
+	for i, feature := range features {
+		values[i] = otellog.StringValue(feature)
+	}
+	problems := make([]otellog.Value, len(report.problems))
+	for i, problem := range report.problems {
+		problems[i] = otellog.StringValue(problem)
+	}
+	outcomes := make([]otellog.KeyValue, 0, len(report.checks))
+	for name, outcome := range report.checks {
+		outcomes = append(outcomes, otellog.String(name, outcome))
+	}
+	effective := config.EnvironRedacted()
+	settings := make([]otellog.KeyValue, 0, len(effective))
+	for key, value := range effective {
+		settings = append(settings, otellog.String(key, value))
+	}
+
+	record := otellog.Record{}
+	record.SetEventName("service.started")
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(severity)
+	record.SetBody(otellog.StringValue(fmt.Sprintf("adsb2otel %s started with %d configuration problems", version.Get(), len(report.problems))))
+	record.AddAttributes(
+		otellog.String(string(semconv.ServiceVersionKey), version.Get()),
+		otellog.String(string(semconv.VCSRefHeadRevisionKey), version.Revision()),
+		otellog.String("adsb2otel.build.date", version.Date()),
+		otellog.Slice("adsb2otel.features", values...),
+		otellog.Slice("startup.problems", problems...),
+		otellog.Map("startup.checks", outcomes...),
+		otellog.Map("startup.config", settings...),
+	)
+	logger.Emit(ctx, record)
+}
+
+// isTrue checks if a string represents a true value
+func isTrue(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "true" || s == "1" || s == "yes" || s == "on"
+}